@@ -0,0 +1,521 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+const (
+	CONNECT    string = "connect"
+	DISCONNECT string = "disconnect"
+	ACTIVE     string = "active"
+)
+
+// Server commands a gateway answers
+var commandTopics = []string{
+	mqttSvc.TOPIC_SV_SYNC,
+	mqttSvc.TOPIC_SV_UHF_U,
+	mqttSvc.TOPIC_SV_UHF_D,
+	mqttSvc.TOPIC_SV_UHF_BATCH,
+	mqttSvc.TOPIC_SV_UHF_CONFIG,
+	mqttSvc.TOPIC_SV_GATEWAY_U,
+	mqttSvc.TOPIC_SV_GATEWAY_D,
+	mqttSvc.TOPIC_SV_GATEWAY_UPGRADE,
+}
+
+type uhf struct {
+	address      string
+	family       string
+	version      string
+	connectState string
+	state        string
+	config       *models.UHFReaderConfig
+}
+
+// gateway emulates one gateway on its own MQTT connection: it boots up,
+// reports its UHFs, reads tags and answers server commands
+type gateway struct {
+	id     string
+	site   string
+	opts   *Options
+	topics *mqttSvc.Topics
+	tags   *tagGenerator
+	client mqtt.Client
+
+	mu       sync.Mutex
+	rnd      *rand.Rand
+	version  string
+	state    string
+	uhfs     map[string]*uhf
+	readRate float64
+	stopRead chan struct{} // nil when not reading
+}
+
+func newGateway(id string, opts *Options, topics *mqttSvc.Topics, tags *tagGenerator, seed int64) *gateway {
+	g := &gateway{
+		id:       id,
+		site:     opts.Site,
+		opts:     opts,
+		topics:   topics,
+		tags:     tags,
+		rnd:      rand.New(rand.NewSource(seed)),
+		version:  opts.Version,
+		state:    ACTIVE,
+		uhfs:     map[string]*uhf{},
+		readRate: opts.ReadRate,
+	}
+	for i := 1; i <= opts.UHFs; i++ {
+		address := strconv.Itoa(i)
+		g.uhfs[address] = &uhf{
+			address:      address,
+			family:       "R2000",
+			version:      "1.0",
+			connectState: CONNECT,
+			state:        ACTIVE,
+		}
+	}
+	return g
+}
+
+func (g *gateway) clientOptions() *mqtt.ClientOptions {
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(g.opts.Broker)
+	opts.SetClientID(g.id)
+	// Embedded broker knows gateways by their ID
+	username := g.opts.Username
+	if username == "" {
+		username = g.id
+	}
+	opts.SetUsername(username)
+	opts.SetPassword(g.opts.Password)
+	// Handlers publish and wait, they must not block the incoming messages
+	opts.SetOrderMatters(false)
+	opts.SetAutoReconnect(true)
+	if will, err := g.topics.Gateway(mqttSvc.TOPIC_GW_LASTWILL, g.site, g.id); err == nil {
+		opts.SetBinaryWill(will, g.willPayload(), 1, false)
+	}
+	opts.SetOnConnectHandler(g.onConnect)
+	opts.SetConnectionLostHandler(func(c mqtt.Client, err error) {
+		g.stopReading()
+		log.Printf("%s: connection lost, err %s", g.id, err.Error())
+	})
+	return opts
+}
+
+func (g *gateway) willPayload() []byte {
+	return encode(g.id, "", mqttSvc.EmptyPayload{})
+}
+
+// connect opens connection of gateway, or boots it up again when connected
+func (g *gateway) connect() error {
+	if g.client == nil {
+		g.client = mqtt.NewClient(g.clientOptions())
+	}
+	if g.client.IsConnected() {
+		return g.bootup("")
+	}
+	t := g.client.Connect()
+	t.Wait()
+	return t.Error()
+}
+
+// onConnect runs on every connect, like a gateway does after power on
+func (g *gateway) onConnect(c mqtt.Client) {
+	for _, topic := range commandTopics {
+		name, err := g.topics.Server(topic, g.site, g.id)
+		if err != nil {
+			log.Printf("%s: %s", g.id, err.Error())
+			continue
+		}
+		t := c.Subscribe(name, 1, g.command(topic))
+		if t.Wait() && t.Error() != nil {
+			log.Printf("%s: subscribe %s failed, err %s", g.id, name, t.Error().Error())
+		}
+	}
+	for _, report := range []func() error{
+		func() error { return g.bootup("") },
+		g.scan,
+		func() error { return g.reportGateway(CONNECT, "") },
+	} {
+		if err := report(); err != nil {
+			log.Printf("%s: %s", g.id, err.Error())
+		}
+	}
+	g.startReading()
+	log.Printf("%s: connected", g.id)
+}
+
+func (g *gateway) publish(topic string, correlationId string, msg interface{}) error {
+	name, err := g.topics.Gateway(topic, g.site, g.id)
+	if err != nil {
+		return err
+	}
+	t := g.client.Publish(name, 1, false, encode(g.id, correlationId, msg))
+	if t.Wait() && t.Error() != nil {
+		return fmt.Errorf("publish %s failed, err %s", name, t.Error().Error())
+	}
+	return nil
+}
+
+func (g *gateway) bootup(correlationId string) error {
+	g.mu.Lock()
+	msg := bootupMessage{Version: g.version, State: g.state}
+	g.mu.Unlock()
+	return g.publish(mqttSvc.TOPIC_GW_BOOTUP, correlationId, msg)
+}
+
+func (g *gateway) scan() error {
+	msg := scanMessage{UHFs: []scanUHF{}}
+	for _, u := range g.snapshot() {
+		msg.UHFs = append(msg.UHFs, scanUHF{Address: u.address, Family: u.family, Version: u.version, State: u.state})
+	}
+	return g.publish(mqttSvc.TOPIC_GW_UHF_SCAN, "", msg)
+}
+
+// snapshot returns copies of UHFs ordered by address
+func (g *gateway) snapshot() []uhf {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	uhfs := make([]uhf, 0, len(g.uhfs))
+	for _, u := range g.uhfs {
+		uhfs = append(uhfs, *u)
+	}
+	sort.Slice(uhfs, func(i, j int) bool {
+		ni, _ := strconv.Atoi(uhfs[i].address)
+		nj, _ := strconv.Atoi(uhfs[j].address)
+		return ni < nj
+	})
+	return uhfs
+}
+
+func (g *gateway) reportUHF(address string, correlationId string) error {
+	g.mu.Lock()
+	u, ok := g.uhfs[address]
+	if !ok {
+		g.mu.Unlock()
+		return fmt.Errorf("gateway %s has no UHF %s", g.id, address)
+	}
+	msg := uhfStateMessage{Address: address, ConnectionState: u.connectState, Timestamp: now(), State: u.state}
+	g.mu.Unlock()
+	return g.publish(mqttSvc.TOPIC_GW_UHF_CONNECT_STATE, correlationId, msg)
+}
+
+func (g *gateway) reportGateway(connectState string, correlationId string) error {
+	g.mu.Lock()
+	msg := gatewayStateMessage{ConnectionState: connectState, State: g.state}
+	g.mu.Unlock()
+	return g.publish(mqttSvc.TOPIC_GW_GW_CONNECT_STATE, correlationId, msg)
+}
+
+func (g *gateway) setUHFConnectState(address string, connectState string) error {
+	g.mu.Lock()
+	u, ok := g.uhfs[address]
+	if ok {
+		u.connectState = connectState
+	}
+	g.mu.Unlock()
+	return g.reportUHF(address, "")
+}
+
+// flap turns a random UHF off or back on
+func (g *gateway) flap() error {
+	uhfs := g.snapshot()
+	if len(uhfs) == 0 {
+		return nil
+	}
+	g.mu.Lock()
+	u := uhfs[g.rnd.Intn(len(uhfs))]
+	g.mu.Unlock()
+	state := DISCONNECT
+	if u.connectState == DISCONNECT {
+		state = CONNECT
+	}
+	return g.setUHFConnectState(u.address, state)
+}
+
+func (g *gateway) log(text string) error {
+	return g.publish(mqttSvc.TOPIC_GW_LOG, "", logMessage{Log: text, Timestamp: now()})
+}
+
+// readTags reports mems read by UHF at address, as given
+func (g *gateway) readTags(address string, mems []string) error {
+	msg := tagMessage{Address: address, Tags: []mqttSvc.UHFTagInfo{}}
+	for i, mem := range mems {
+		msg.Tags = append(msg.Tags, mqttSvc.UHFTagInfo{EPC: fmt.Sprintf("E2801190%016X", i), Mem: mem, TimeStamp: now()})
+	}
+	return g.publish(mqttSvc.TOPIC_GW_TAG, "", msg)
+}
+
+// read reports tags of the population read by every UHF that is connected
+// and active
+func (g *gateway) read() {
+	for _, u := range g.snapshot() {
+		if u.connectState != CONNECT || u.state != ACTIVE {
+			continue
+		}
+		msg := tagMessage{Address: u.address, Tags: []mqttSvc.UHFTagInfo{}}
+		for i := 0; i < g.opts.TagsPerRead; i++ {
+			msg.Tags = append(msg.Tags, g.tags.read())
+		}
+		if err := g.publish(mqttSvc.TOPIC_GW_TAG, "", msg); err != nil {
+			log.Printf("%s: %s", g.id, err.Error())
+		}
+	}
+}
+
+// setReadRate changes tag messages per second of each UHF, 0 stops reads
+func (g *gateway) setReadRate(rate float64) {
+	g.mu.Lock()
+	g.readRate = rate
+	reading := g.stopRead != nil
+	g.mu.Unlock()
+	if reading {
+		g.stopReading()
+		g.startReading()
+	}
+}
+
+func (g *gateway) startReading() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopRead != nil || g.readRate <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	g.stopRead = stop
+	interval := time.Duration(float64(time.Second) / g.readRate)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				g.read()
+			}
+		}
+	}()
+}
+
+func (g *gateway) stopReading() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopRead != nil {
+		close(g.stopRead)
+		g.stopRead = nil
+	}
+}
+
+// lastwill drops gateway the way a crash does. Paho always disconnects
+// cleanly, which makes broker discard the will, so the will is published
+// before disconnecting.
+func (g *gateway) lastwill() error {
+	g.stopReading()
+	name, err := g.topics.Gateway(mqttSvc.TOPIC_GW_LASTWILL, g.site, g.id)
+	if err != nil {
+		return err
+	}
+	t := g.client.Publish(name, 1, false, g.willPayload())
+	t.Wait()
+	g.client.Disconnect(250)
+	return t.Error()
+}
+
+// shutdown tells server gateway is decommissioned, server deletes it
+func (g *gateway) shutdown() error {
+	g.stopReading()
+	err := g.publish(mqttSvc.TOPIC_GW_SHUTDOWN, "", mqttSvc.EmptyPayload{})
+	g.client.Disconnect(250)
+	return err
+}
+
+// stop reports gateway disconnected and closes its connection
+func (g *gateway) stop() {
+	g.stopReading()
+	if g.client == nil || !g.client.IsConnected() {
+		return
+	}
+	if err := g.reportGateway(DISCONNECT, ""); err != nil {
+		log.Printf("%s: %s", g.id, err.Error())
+	}
+	g.client.Disconnect(250)
+}
+
+// command returns handler of server commands on topic. Without sites every
+// gateway receives commands of every other, those are ignored.
+func (g *gateway) command(topic string) mqtt.MessageHandler {
+	return func(c mqtt.Client, msg mqtt.Message) {
+		cmd := command{}
+		if err := json.Unmarshal(msg.Payload(), &cmd); err != nil {
+			log.Printf("%s: invalid command on %s, err %s", g.id, msg.Topic(), err.Error())
+			return
+		}
+		if cmd.GatewayID != g.id {
+			return
+		}
+		if err := g.handle(topic, cmd); err != nil {
+			log.Printf("%s: command on %s failed, err %s", g.id, msg.Topic(), err.Error())
+		}
+	}
+}
+
+func (g *gateway) handle(topic string, cmd command) error {
+	switch topic {
+	case mqttSvc.TOPIC_SV_SYNC:
+		p := mqttSvc.SyncPayload{}
+		if err := json.Unmarshal(cmd.Message, &p); err != nil {
+			return err
+		}
+		return g.sync(p, cmd.CorrelationID)
+	case mqttSvc.TOPIC_SV_UHF_U:
+		p := mqttSvc.UHFStatePayload{}
+		if err := json.Unmarshal(cmd.Message, &p); err != nil {
+			return err
+		}
+		g.mu.Lock()
+		if u, ok := g.uhfs[p.Address]; ok {
+			u.state = p.State
+		}
+		g.mu.Unlock()
+		return g.reportUHF(p.Address, cmd.CorrelationID)
+	case mqttSvc.TOPIC_SV_UHF_D:
+		p := mqttSvc.UHFAddressPayload{}
+		if err := json.Unmarshal(cmd.Message, &p); err != nil {
+			return err
+		}
+		g.mu.Lock()
+		delete(g.uhfs, p.Address)
+		g.mu.Unlock()
+		return g.log(fmt.Sprintf("UHF %s removed", p.Address))
+	case mqttSvc.TOPIC_SV_UHF_BATCH:
+		p := mqttSvc.UHFBatchPayload{}
+		if err := json.Unmarshal(cmd.Message, &p); err != nil {
+			return err
+		}
+		return g.batch(p, cmd.CorrelationID)
+	case mqttSvc.TOPIC_SV_UHF_CONFIG:
+		p := mqttSvc.UHFConfigPayload{}
+		if err := json.Unmarshal(cmd.Message, &p); err != nil {
+			return err
+		}
+		g.mu.Lock()
+		u, ok := g.uhfs[p.Address]
+		if ok {
+			u.config = &p.Config
+		}
+		g.mu.Unlock()
+		if !ok {
+			return fmt.Errorf("gateway %s has no UHF %s", g.id, p.Address)
+		}
+		return g.publish(mqttSvc.TOPIC_GW_UHF_CONFIG, cmd.CorrelationID, uhfConfigMessage{Address: p.Address, Config: p.Config})
+	case mqttSvc.TOPIC_SV_GATEWAY_U:
+		p := mqttSvc.GatewayStatePayload{}
+		if err := json.Unmarshal(cmd.Message, &p); err != nil {
+			return err
+		}
+		g.mu.Lock()
+		g.state = p.State
+		g.mu.Unlock()
+		return g.reportGateway(CONNECT, cmd.CorrelationID)
+	case mqttSvc.TOPIC_SV_GATEWAY_D:
+		log.Printf("%s: deleted by server, going offline", g.id)
+		go g.stop()
+		return nil
+	case mqttSvc.TOPIC_SV_GATEWAY_UPGRADE:
+		p := mqttSvc.UpgradePayload{}
+		if err := json.Unmarshal(cmd.Message, &p); err != nil {
+			return err
+		}
+		go g.upgrade(p, cmd.CorrelationID)
+		return nil
+	}
+	return fmt.Errorf("unknown command topic %s", topic)
+}
+
+// sync applies states server wants and reports them back
+func (g *gateway) sync(p mqttSvc.SyncPayload, correlationId string) error {
+	addresses := []string{}
+	g.mu.Lock()
+	for _, s := range p.UHFs {
+		if u, ok := g.uhfs[s.UHFAddress]; ok && s.State != "" {
+			u.state = s.State
+			addresses = append(addresses, s.UHFAddress)
+		}
+	}
+	if p.State != "" {
+		g.state = p.State
+	}
+	g.mu.Unlock()
+	for _, address := range addresses {
+		if err := g.reportUHF(address, correlationId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batch applies states and removals of a bulk operation, updated UHFs are
+// reported back
+func (g *gateway) batch(p mqttSvc.UHFBatchPayload, correlationId string) error {
+	addresses := []string{}
+	g.mu.Lock()
+	for _, s := range p.Updates {
+		if u, ok := g.uhfs[s.Address]; ok {
+			u.state = s.State
+			addresses = append(addresses, s.Address)
+		}
+	}
+	for _, d := range p.Deletes {
+		delete(g.uhfs, d.Address)
+	}
+	g.mu.Unlock()
+	for _, address := range addresses {
+		if err := g.reportUHF(address, correlationId); err != nil {
+			return err
+		}
+	}
+	if len(p.Deletes) > 0 {
+		return g.log(fmt.Sprintf("%d UHFs removed", len(p.Deletes)))
+	}
+	return nil
+}
+
+// upgrade reports download and install of firmware, then boots up on the new
+// version unless it fails
+func (g *gateway) upgrade(p mqttSvc.UpgradePayload, correlationId string) {
+	report := func(status string, errMsg string) {
+		msg := upgradeMessage{CampaignID: p.CampaignID, Status: status, Error: errMsg}
+		if err := g.publish(mqttSvc.TOPIC_GW_UPGRADE, correlationId, msg); err != nil {
+			log.Printf("%s: %s", g.id, err.Error())
+		}
+	}
+	report("downloading", "")
+	time.Sleep(g.opts.UpgradeStep)
+	g.mu.Lock()
+	failed := g.rnd.Float64() < g.opts.UpgradeFailRate
+	g.mu.Unlock()
+	if failed {
+		report("failed", "checksum mismatch")
+		return
+	}
+	report("installing", "")
+	time.Sleep(g.opts.UpgradeStep)
+	g.mu.Lock()
+	g.version = p.Version
+	g.mu.Unlock()
+	report("success", "")
+	if err := g.bootup(correlationId); err != nil {
+		log.Printf("%s: %s", g.id, err.Error())
+	}
+}
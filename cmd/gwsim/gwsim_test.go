@@ -0,0 +1,258 @@
+//go:build unit
+// +build unit
+
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/fakes"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/tidwall/gjson"
+)
+
+func testOptions() Options {
+	return Options{
+		Prefix:      mqttSvc.DEFAULT_TOPIC_PREFIX,
+		Site:        "hcm",
+		Gateways:    2,
+		IDPrefix:    "gw",
+		UHFs:        2,
+		Version:     "1.0.0",
+		TagsPerRead: 1,
+		Users:       10,
+		Packages:    10,
+	}
+}
+
+// newTestGateway returns first gateway of a simulation, connected to a fake client
+func newTestGateway(t *testing.T, opts Options) (*gateway, *fakes.Client) {
+	t.Helper()
+	sim, err := NewSimulator(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fakes.NewClient()
+	g := sim.gateways[0]
+	g.client = client
+	g.onConnect(client)
+	return g, client
+}
+
+// published returns TOPIC_GW_* topics of what gateway published, checking
+// every payload against schema of its topic
+func published(t *testing.T, g *gateway, client *fakes.Client) []string {
+	t.Helper()
+	validator, err := mqttSvc.NewSchemaValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics := []string{}
+	for _, p := range client.Published() {
+		gwTopic, ok := g.topics.Parse(p.Topic)
+		if !ok || gwTopic.GatewayID != g.id {
+			t.Fatalf("got topic %s, wanted topic of gateway %s", p.Topic, g.id)
+		}
+		if _, err := validator.Validate(gwTopic.Topic, []byte(p.Payload)); err != nil {
+			t.Errorf("%s: %s", gwTopic.Topic, err.Error())
+		}
+		topics = append(topics, gwTopic.Topic)
+	}
+	return topics
+}
+
+func serverCommand(g *gateway, topic string, payload string) {
+	g.command(topic)(nil, &testMessage{topic: topic, payload: []byte(payload)})
+}
+
+type testMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *testMessage) Duplicate() bool   { return false }
+func (m *testMessage) Qos() byte         { return 1 }
+func (m *testMessage) Retained() bool    { return false }
+func (m *testMessage) Topic() string     { return m.topic }
+func (m *testMessage) MessageID() uint16 { return 0 }
+func (m *testMessage) Payload() []byte   { return m.payload }
+func (m *testMessage) Ack()              {}
+
+func TestGatewayConnect(t *testing.T) {
+	g, client := newTestGateway(t, testOptions())
+	for _, topic := range commandTopics {
+		name, _ := g.topics.Server(topic, "hcm", "gw001")
+		if !client.Subscribed(name) {
+			t.Errorf("got %s not subscribed", name)
+		}
+	}
+	want := []string{mqttSvc.TOPIC_GW_BOOTUP, mqttSvc.TOPIC_GW_UHF_SCAN, mqttSvc.TOPIC_GW_GW_CONNECT_STATE}
+	if got := published(t, g, client); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, wanted %v", got, want)
+	}
+	if uhfs := gjson.Get(client.Published()[1].Payload, "message.uhfs.#.address").String(); uhfs != `["1","2"]` {
+		t.Errorf("got %s, wanted UHFs 1 and 2 scanned", uhfs)
+	}
+}
+
+func TestGatewayCommands(t *testing.T) {
+	g, client := newTestGateway(t, testOptions())
+	before := len(client.Published())
+
+	serverCommand(g, mqttSvc.TOPIC_SV_SYNC, mqttSvc.ServerBootupSystemPayload("gw002", nil))
+	if len(client.Published()) != before {
+		t.Errorf("got command of gw002 answered by gw001")
+	}
+
+	serverCommand(g, mqttSvc.TOPIC_SV_SYNC,
+		`{"gateway_id": "gw001", "correlation_id": "c1", "message": {"uhfs": [{"uhf_address": "2", "state": "inactive"}], "state": "active"}}`)
+	serverCommand(g, mqttSvc.TOPIC_SV_UHF_U, `{"gateway_id": "gw001", "message": {"address": "1", "state": "inactive"}}`)
+	serverCommand(g, mqttSvc.TOPIC_SV_UHF_CONFIG, `{"gateway_id": "gw001", "message": {"address": "1", "config": {"rf_power": 30}}}`)
+	serverCommand(g, mqttSvc.TOPIC_SV_GATEWAY_U, `{"gateway_id": "gw001", "message": {"state": "inactive"}}`)
+	serverCommand(g, mqttSvc.TOPIC_SV_UHF_D, `{"gateway_id": "gw001", "message": {"address": "2"}}`)
+
+	want := []string{
+		mqttSvc.TOPIC_GW_UHF_CONNECT_STATE,
+		mqttSvc.TOPIC_GW_UHF_CONNECT_STATE,
+		mqttSvc.TOPIC_GW_UHF_CONFIG,
+		mqttSvc.TOPIC_GW_GW_CONNECT_STATE,
+		mqttSvc.TOPIC_GW_LOG,
+	}
+	if got := published(t, g, client)[before:]; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, wanted %v", got, want)
+	}
+	answers := client.Published()[before:]
+	if gjson.Get(answers[0].Payload, "correlation_id").String() != "c1" {
+		t.Errorf("got %s, wanted correlation ID of sync echoed", answers[0].Payload)
+	}
+	if state := gjson.Get(answers[1].Payload, "message.state").String(); state != "inactive" {
+		t.Errorf("got %s, wanted UHF inactive", state)
+	}
+	if uhfs := g.snapshot(); len(uhfs) != 1 || uhfs[0].state != "inactive" || uhfs[0].config == nil {
+		t.Errorf("got %+v, wanted UHF 1 left, inactive and configured", uhfs)
+	}
+}
+
+func TestGatewayBatch(t *testing.T) {
+	g, client := newTestGateway(t, testOptions())
+	before := len(client.Published())
+
+	serverCommand(g, mqttSvc.TOPIC_SV_UHF_BATCH, `{"gateway_id": "gw001", "message": {`+
+		`"updates": [{"address": "1", "state": "inactive"}, {"address": "9", "state": "inactive"}], "deletes": [{"address": "2"}]}}`)
+	want := []string{mqttSvc.TOPIC_GW_UHF_CONNECT_STATE, mqttSvc.TOPIC_GW_LOG}
+	if got := published(t, g, client)[before:]; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, wanted %v", got, want)
+	}
+	if uhfs := g.snapshot(); len(uhfs) != 1 || uhfs[0].address != "1" || uhfs[0].state != "inactive" {
+		t.Errorf("got %+v, wanted UHF 1 left and inactive", uhfs)
+	}
+}
+
+func TestGatewayUpgrade(t *testing.T) {
+	opts := testOptions()
+	g, client := newTestGateway(t, opts)
+	before := len(client.Published())
+
+	g.upgrade(mqttSvc.UpgradePayload{CampaignID: 7, Version: "1.1.0"}, "c1")
+	answers := client.Published()[before:]
+	statuses := []string{}
+	for _, a := range answers[:3] {
+		statuses = append(statuses, gjson.Get(a.Payload, "message.status").String())
+	}
+	if strings.Join(statuses, ",") != "downloading,installing,success" {
+		t.Errorf("got %v, wanted download, install and success", statuses)
+	}
+	if version := gjson.Get(answers[3].Payload, "message.version").String(); version != "1.1.0" {
+		t.Errorf("got %s, wanted bootup on 1.1.0", version)
+	}
+	published(t, g, client)
+
+	opts.UpgradeFailRate = 1
+	g, client = newTestGateway(t, opts)
+	before = len(client.Published())
+	g.upgrade(mqttSvc.UpgradePayload{CampaignID: 7, Version: "1.1.0"}, "")
+	answers = client.Published()[before:]
+	if len(answers) != 2 || gjson.Get(answers[1].Payload, "message.status").String() != "failed" {
+		t.Errorf("got %+v, wanted download then failure", answers)
+	}
+}
+
+func TestTagGeneratorDecodes(t *testing.T) {
+	decoder, _ := mqttSvc.NewTagDecoder(mqttSvc.TAG_ENCODING_AUTO, mqttSvc.DEFAULT_TAG_TYPES)
+	for _, encoding := range []string{mqttSvc.TAG_ENCODING_ASCII, mqttSvc.TAG_ENCODING_HEX} {
+		tags, err := newTagGenerator(5, 5, encoding, 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds := map[string]int{}
+		for i := 0; i < 100; i++ {
+			tag, err := decoder.Decode(tags.read().Mem)
+			if err != nil {
+				t.Fatalf("%s: %s", encoding, err.Error())
+			}
+			kinds[tag.Kind]++
+		}
+		if kinds[mqttSvc.TAG_KIND_USER] == 0 || kinds[mqttSvc.TAG_KIND_PACKAGE] == 0 {
+			t.Errorf("%s: got %v, wanted users and packages read", encoding, kinds)
+		}
+	}
+
+	tags, _ := newTagGenerator(5, 5, mqttSvc.TAG_ENCODING_ASCII, 1, 1)
+	for i := 0; i < 20; i++ {
+		if _, err := decoder.Decode(tags.read().Mem); err == nil {
+			t.Errorf("got malformed memory decoded")
+		}
+	}
+	if _, err := newTagGenerator(0, 0, "", 0, 1); err == nil {
+		t.Errorf("got empty population accepted")
+	}
+}
+
+func TestParseScenario(t *testing.T) {
+	steps, err := parseScenario(strings.NewReader(`
+# comment
+10s  stop
+5s   tags      gw001 1 U01ABCDE12345XYZ # read a user
+0s   read_rate *     0.5
+5s   uhf_state gw001 1 disconnect
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, s := range steps {
+		got = append(got, s.action)
+	}
+	if strings.Join(got, ",") != "read_rate,tags,uhf_state,stop" {
+		t.Errorf("got %v, wanted steps ordered by offset", got)
+	}
+	if steps[1].line != 4 || strings.Join(steps[1].args, " ") != "1 U01ABCDE12345XYZ" {
+		t.Errorf("got %+v, wanted tags step of line 4 without comment", steps[1])
+	}
+
+	for _, bad := range []string{
+		"soon bootup gw001",
+		"1s reboot gw001",
+		"1s bootup",
+		"1s tags gw001 1",
+		"1s uhf_state gw001 1 off",
+		"1s read_rate * fast",
+		"1s stop gw001",
+	} {
+		if _, err := parseScenario(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: got nil, wanted error", bad)
+		}
+	}
+
+	sim, _ := NewSimulator(testOptions())
+	steps, _ = parseScenario(strings.NewReader("1s bootup gw003"))
+	if err := sim.checkTargets(steps); err == nil {
+		t.Errorf("got unknown gateway accepted")
+	}
+	if err := sim.Run(context.Background(), steps); err == nil || errors.Is(err, errScenarioStopped) {
+		t.Errorf("got %v, wanted unknown gateway rejected before connecting", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Command gwsim emulates gateways against an MQTT broker, for development of
+// the server without hardware and for load testing. Every gateway has its own
+// connection, boots up, reports its UHFs, reads tags and answers sync, UHF,
+// gateway and upgrade commands of the server.
+//
+//	go run ./cmd/gwsim -gateways 50 -uhfs 4 -read-rate 2
+//	go run ./cmd/gwsim -site hcm -scenario ./cmd/gwsim/scenarios/outage.txt
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func main() {
+	opts := Options{}
+	flag.StringVar(&opts.Broker, "broker", "tcp://localhost:1883", "broker URL")
+	flag.StringVar(&opts.Username, "username", "", "broker username, gateway ID if empty")
+	flag.StringVar(&opts.Password, "password", "", "broker password")
+	flag.StringVar(&opts.Prefix, "prefix", mqttSvc.DEFAULT_TOPIC_PREFIX, "topic prefix, as MQTT_TOPIC_PREFIX of server")
+	flag.StringVar(&opts.Site, "site", "", "site gateways publish in, empty for topics without sites")
+	flag.IntVar(&opts.Gateways, "gateways", 1, "number of gateways")
+	flag.StringVar(&opts.IDPrefix, "id-prefix", "gwsim-", "gateway IDs are this prefix and a number")
+	flag.IntVar(&opts.UHFs, "uhfs", 2, "UHFs per gateway")
+	flag.StringVar(&opts.Version, "version", "1.0.0", "firmware version gateways boot with")
+	flag.Float64Var(&opts.ReadRate, "read-rate", 1, "tag messages per second of each UHF, 0 reads only in scenario")
+	flag.IntVar(&opts.TagsPerRead, "tags-per-read", 1, "tags in a tag message")
+	flag.IntVar(&opts.Users, "users", 100, "user tags read")
+	flag.IntVar(&opts.Packages, "packages", 100, "package tags read")
+	flag.StringVar(&opts.Encoding, "encoding", mqttSvc.TAG_ENCODING_ASCII, "tag memory encoding, ascii or hex")
+	flag.Float64Var(&opts.BadTagRate, "bad-tag-rate", 0, "share of malformed tag memories, 0 to 1")
+	flag.DurationVar(&opts.FlapInterval, "flap-interval", 0, "a random UHF goes off or back on every interval, 0 never")
+	flag.DurationVar(&opts.UpgradeStep, "upgrade-step", 2*time.Second, "time to download and to install firmware")
+	flag.Float64Var(&opts.UpgradeFailRate, "upgrade-fail-rate", 0, "share of failed upgrades, 0 to 1")
+	flag.Int64Var(&opts.Seed, "seed", time.Now().UnixNano(), "seed of random reads, same seed replays same reads")
+	scenario := flag.String("scenario", "", "scenario file, see scenario.go for its format")
+	duration := flag.Duration("duration", 0, "stop after duration, 0 runs until interrupted")
+	flag.Parse()
+
+	if err := run(opts, *scenario, *duration); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(opts Options, scenario string, duration time.Duration) error {
+	steps := []step{}
+	if scenario != "" {
+		f, err := os.Open(scenario)
+		if err != nil {
+			return err
+		}
+		steps, err = parseScenario(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("scenario %s: %w", scenario, err)
+		}
+	}
+	sim, err := NewSimulator(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	log.SetFlags(log.Ltime | log.Lmicroseconds)
+	return sim.Run(ctx, steps)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+// Timestamps of gateway messages are local time in this layout
+const timeLayout string = "2006-01-02 15:04:05"
+
+// Messages a gateway publishes, see mqttSvc/schemas for their schemas
+
+type bootupMessage struct {
+	Version string `json:"version"`
+	State   string `json:"state"`
+}
+
+type scanUHF struct {
+	Address string `json:"address"`
+	Family  string `json:"family"`
+	Version string `json:"version"`
+	State   string `json:"state"`
+}
+
+type scanMessage struct {
+	UHFs []scanUHF `json:"uhfs"`
+}
+
+type uhfStateMessage struct {
+	Address         string `json:"address"`
+	ConnectionState string `json:"connection_state"`
+	Timestamp       string `json:"timestamp"`
+	State           string `json:"state"`
+}
+
+type gatewayStateMessage struct {
+	ConnectionState string `json:"connection_state"`
+	State           string `json:"state"`
+}
+
+type tagMessage struct {
+	Address string               `json:"address"`
+	Tags    []mqttSvc.UHFTagInfo `json:"tags"`
+}
+
+type logMessage struct {
+	Log       string `json:"log"`
+	Timestamp string `json:"timestamp"`
+}
+
+type upgradeMessage struct {
+	CampaignID uint   `json:"campaign_id"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+type uhfConfigMessage struct {
+	Address string                 `json:"address"`
+	Config  models.UHFReaderConfig `json:"config"`
+}
+
+// command is a server message, Message is decoded by the handler of its topic
+type command struct {
+	GatewayID     string          `json:"gateway_id"`
+	MessageID     string          `json:"message_id"`
+	CorrelationID string          `json:"correlation_id"`
+	Message       json.RawMessage `json:"message"`
+}
+
+// encode wraps msg in an envelope of gateway gwId, echoing correlationId of
+// the command it answers
+func encode(gwId string, correlationId string, msg interface{}) []byte {
+	env := mqttSvc.NewEnvelope(gwId, msg)
+	env.CorrelationID = correlationId
+	payload, _ := json.Marshal(env)
+	return payload
+}
+
+func now() string {
+	return time.Now().Format(timeLayout)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scenario is a script of steps, one per line, run at an offset from start:
+//
+//	# offset  action         gateway    args
+//	0s        read_rate      *          0
+//	5s        tags           gwsim-001  1 U01ABCDE12345XYZ P020000000042R01
+//	10s       uhf_state      gwsim-001  2 disconnect
+//	15s       gateway_state  gwsim-002  disconnect
+//	20s       lastwill       gwsim-002
+//	30s       bootup         gwsim-002
+//	40s       log            *          battery low
+//	50s       shutdown       gwsim-001
+//	60s       stop
+//
+// Gateway is a gateway ID or * for every gateway. bootup connects a gateway
+// that is offline. stop ends the simulation, without it the simulation runs
+// until interrupted or -duration is over.
+type step struct {
+	line    int
+	at      time.Duration
+	action  string
+	gateway string
+	args    []string
+}
+
+const ACTION_STOP string = "stop"
+
+type action struct {
+	minArgs int
+	maxArgs int // -1 for no limit
+	check   func(args []string) error
+	run     func(g *gateway, args []string) error
+}
+
+var actions = map[string]action{
+	"bootup": {run: func(g *gateway, args []string) error { return g.connect() }},
+	"scan":   {run: func(g *gateway, args []string) error { return g.scan() }},
+	"tags": {
+		minArgs: 2, maxArgs: -1,
+		run: func(g *gateway, args []string) error { return g.readTags(args[0], args[1:]) },
+	},
+	"uhf_state": {
+		minArgs: 2, maxArgs: 2,
+		check: func(args []string) error { return checkConnectState(args[1]) },
+		run:   func(g *gateway, args []string) error { return g.setUHFConnectState(args[0], args[1]) },
+	},
+	"gateway_state": {
+		minArgs: 1, maxArgs: 1,
+		check: func(args []string) error { return checkConnectState(args[0]) },
+		run:   func(g *gateway, args []string) error { return g.reportGateway(args[0], "") },
+	},
+	"log": {
+		minArgs: 1, maxArgs: -1,
+		run: func(g *gateway, args []string) error { return g.log(strings.Join(args, " ")) },
+	},
+	"read_rate": {
+		minArgs: 1, maxArgs: 1,
+		check: func(args []string) error {
+			rate, err := strconv.ParseFloat(args[0], 64)
+			if err != nil || rate < 0 {
+				return fmt.Errorf("read rate %q must be a number not below 0", args[0])
+			}
+			return nil
+		},
+		run: func(g *gateway, args []string) error {
+			rate, _ := strconv.ParseFloat(args[0], 64)
+			g.setReadRate(rate)
+			return nil
+		},
+	},
+	"lastwill": {run: func(g *gateway, args []string) error { return g.lastwill() }},
+	"shutdown": {run: func(g *gateway, args []string) error { return g.shutdown() }},
+}
+
+var errScenarioStopped = errors.New("scenario stopped")
+
+func checkConnectState(state string) error {
+	if state != CONNECT && state != DISCONNECT {
+		return fmt.Errorf("state %q must be %s or %s", state, CONNECT, DISCONNECT)
+	}
+	return nil
+}
+
+// parseScenario reads steps ordered by offset, steps at the same offset keep
+// their order
+func parseScenario(r io.Reader) ([]step, error) {
+	steps := []step{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		s, err := parseStep(line, fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		steps = append(steps, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].at < steps[j].at })
+	return steps, nil
+}
+
+func parseStep(line int, fields []string) (step, error) {
+	if len(fields) < 2 {
+		return step{}, fmt.Errorf("want offset and action")
+	}
+	at, err := time.ParseDuration(fields[0])
+	if err != nil || at < 0 {
+		return step{}, fmt.Errorf("invalid offset %q", fields[0])
+	}
+	s := step{line: line, at: at, action: fields[1]}
+	if s.action == ACTION_STOP {
+		if len(fields) > 2 {
+			return step{}, fmt.Errorf("%s takes no gateway", ACTION_STOP)
+		}
+		return s, nil
+	}
+	a, ok := actions[s.action]
+	if !ok {
+		return step{}, fmt.Errorf("unknown action %q", s.action)
+	}
+	if len(fields) < 3 {
+		return step{}, fmt.Errorf("%s needs a gateway", s.action)
+	}
+	s.gateway, s.args = fields[2], fields[3:]
+	if len(s.args) < a.minArgs || (a.maxArgs >= 0 && len(s.args) > a.maxArgs) {
+		return step{}, fmt.Errorf("%s got %d args, want %s", s.action, len(s.args), argCount(a))
+	}
+	if a.check != nil {
+		if err := a.check(s.args); err != nil {
+			return step{}, err
+		}
+	}
+	return s, nil
+}
+
+func argCount(a action) string {
+	switch {
+	case a.maxArgs < 0:
+		return fmt.Sprintf("at least %d", a.minArgs)
+	case a.minArgs == a.maxArgs:
+		return strconv.Itoa(a.minArgs)
+	}
+	return fmt.Sprintf("%d to %d", a.minArgs, a.maxArgs)
+}
+
+// play runs steps on gateways of simulator, failed steps are logged and
+// skipped. Returns errScenarioStopped on stop step, ctx error when cancelled.
+func (s *Simulator) play(ctx context.Context, steps []step) error {
+	start := time.Now()
+	for _, st := range steps {
+		timer := time.NewTimer(time.Until(start.Add(st.at)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if st.action == ACTION_STOP {
+			return errScenarioStopped
+		}
+		for _, g := range s.targets(st.gateway) {
+			if err := actions[st.action].run(g, st.args); err != nil {
+				log.Printf("line %d: %s %s failed, err %s", st.line, st.action, g.id, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// checkTargets makes sure every step names a simulated gateway
+func (s *Simulator) checkTargets(steps []step) error {
+	for _, st := range steps {
+		if st.action != ACTION_STOP && len(s.targets(st.gateway)) == 0 {
+			return fmt.Errorf("line %d: no gateway %s, simulated are %s to %s",
+				st.line, st.gateway, s.gateways[0].id, s.gateways[len(s.gateways)-1].id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+// Options of a simulation, set by flags
+type Options struct {
+	Broker   string
+	Username string
+	Password string
+
+	Prefix   string // topic prefix, see mqttSvc.Topics
+	Site     string // per site topics when set
+	Gateways int
+	IDPrefix string // gateway IDs are IDPrefix001, IDPrefix002...
+	UHFs     int    // per gateway
+	Version  string // firmware version gateways boot with
+
+	ReadRate    float64 // tag messages per second of each UHF
+	TagsPerRead int
+	Users       int
+	Packages    int
+	Encoding    string
+	BadTagRate  float64
+
+	FlapInterval    time.Duration // a random UHF goes off or back on every interval, 0 never
+	UpgradeStep     time.Duration
+	UpgradeFailRate float64
+	Seed            int64
+}
+
+type Simulator struct {
+	opts     Options
+	gateways []*gateway
+	rnd      *rand.Rand
+}
+
+func NewSimulator(opts Options) (*Simulator, error) {
+	switch {
+	case opts.Gateways < 1:
+		return nil, fmt.Errorf("simulate at least 1 gateway")
+	case opts.UHFs < 0:
+		return nil, fmt.Errorf("UHFs per gateway must not be below 0")
+	case opts.ReadRate < 0:
+		return nil, fmt.Errorf("read rate must not be below 0")
+	case opts.TagsPerRead < 1:
+		return nil, fmt.Errorf("read at least 1 tag per message")
+	case opts.UpgradeFailRate < 0 || opts.UpgradeFailRate > 1:
+		return nil, fmt.Errorf("upgrade fail rate must be between 0 and 1")
+	case opts.Site == mqttSvc.TOPIC_ALL_SITES:
+		return nil, fmt.Errorf("gateways publish in one site, not %s", opts.Site)
+	}
+	sites := []string{}
+	if opts.Site != "" {
+		sites = append(sites, opts.Site)
+	}
+	topics, err := mqttSvc.NewTopics(opts.Prefix, sites)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := newTagGenerator(opts.Users, opts.Packages, opts.Encoding, opts.BadTagRate, opts.Seed)
+	if err != nil {
+		return nil, err
+	}
+	s := &Simulator{opts: opts, rnd: rand.New(rand.NewSource(opts.Seed))}
+	for i := 1; i <= opts.Gateways; i++ {
+		id := fmt.Sprintf("%s%03d", opts.IDPrefix, i)
+		s.gateways = append(s.gateways, newGateway(id, &s.opts, topics, tags, opts.Seed+int64(i)))
+	}
+	return s, nil
+}
+
+// targets returns gateway gwId, or every gateway for *
+func (s *Simulator) targets(gwId string) []*gateway {
+	if gwId == "*" {
+		return s.gateways
+	}
+	for _, g := range s.gateways {
+		if g.id == gwId {
+			return []*gateway{g}
+		}
+	}
+	return nil
+}
+
+// Run connects every gateway and simulates until ctx is done or scenario
+// stops, then reports gateways disconnected
+func (s *Simulator) Run(ctx context.Context, steps []step) error {
+	if err := s.checkTargets(steps); err != nil {
+		return err
+	}
+	defer func() {
+		for _, g := range s.gateways {
+			g.stop()
+		}
+	}()
+	for _, g := range s.gateways {
+		if err := g.connect(); err != nil {
+			return fmt.Errorf("connect %s failed, err %s", g.id, err.Error())
+		}
+	}
+	log.Printf("%d gateways with %d UHFs each connected to %s", len(s.gateways), s.opts.UHFs, s.opts.Broker)
+
+	if s.opts.FlapInterval > 0 {
+		go s.flap(ctx)
+	}
+	if len(steps) > 0 {
+		err := s.play(ctx, steps)
+		if errors.Is(err, errScenarioStopped) {
+			log.Printf("scenario stopped")
+			return nil
+		}
+		if err == nil {
+			log.Printf("scenario done, running until interrupted")
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// flap turns a UHF of a random gateway off or back on every FlapInterval
+func (s *Simulator) flap(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.FlapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g := s.gateways[s.rnd.Intn(len(s.gateways))]
+			if !g.client.IsConnected() {
+				continue
+			}
+			if err := g.flap(); err != nil {
+				log.Printf("%s: %s", g.id, err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+const tagRandomChars string = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// User IDs are SIMU followed by 6 digits
+const maxUsers int = 1000000
+
+// tagGenerator makes tag memories of a population of user and package tags,
+// laid out as mqttSvc.TagDecoder expects: type(1) group(2) id(10) random(3).
+// A share of reads is malformed the ways real reads are.
+type tagGenerator struct {
+	users    int
+	packages int
+	encoding string  // mqttSvc.TAG_ENCODING_ASCII or mqttSvc.TAG_ENCODING_HEX
+	badRate  float64 // share of malformed memories, 0 to 1
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newTagGenerator(users int, packages int, encoding string, badRate float64, seed int64) (*tagGenerator, error) {
+	if users < 0 || packages < 0 || users+packages == 0 {
+		return nil, fmt.Errorf("tag population needs users or packages")
+	}
+	if users > maxUsers {
+		return nil, fmt.Errorf("at most %d users fit in tag memory", maxUsers)
+	}
+	switch encoding {
+	case "", mqttSvc.TAG_ENCODING_ASCII:
+		encoding = mqttSvc.TAG_ENCODING_ASCII
+	case mqttSvc.TAG_ENCODING_HEX:
+	default:
+		return nil, fmt.Errorf("unsupported tag encoding %q, want %s or %s",
+			encoding, mqttSvc.TAG_ENCODING_ASCII, mqttSvc.TAG_ENCODING_HEX)
+	}
+	if badRate < 0 || badRate > 1 {
+		return nil, fmt.Errorf("bad tag rate %v must be between 0 and 1", badRate)
+	}
+	return &tagGenerator{
+		users:    users,
+		packages: packages,
+		encoding: encoding,
+		badRate:  badRate,
+		rnd:      rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// userMem is memory of user n, e.g. U01SIMU000042K7Q
+func userMem(n int, random string) string {
+	return fmt.Sprintf("U%02d%s%06d%s", 1+n%9, "SIMU", n, random)
+}
+
+// packageMem is memory of package n, e.g. P020000000042R01
+func packageMem(n int, random string) string {
+	return fmt.Sprintf("P%02d%010d%s", 1+n%9, n, random)
+}
+
+// read returns a tag read now by an antenna
+func (g *tagGenerator) read() mqttSvc.UHFTagInfo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	random := make([]byte, mqttSvc.TAG_RANDOM_LEN)
+	for i := range random {
+		random[i] = tagRandomChars[g.rnd.Intn(len(tagRandomChars))]
+	}
+	n := g.rnd.Intn(g.users + g.packages)
+	mem, epc := "", ""
+	if n < g.users {
+		mem, epc = userMem(n, string(random)), fmt.Sprintf("E2801160%016X", n)
+	} else {
+		n -= g.users
+		mem, epc = packageMem(n, string(random)), fmt.Sprintf("E2801170%016X", n)
+	}
+	if g.rnd.Float64() < g.badRate {
+		mem = g.malform(mem)
+	}
+	return mqttSvc.UHFTagInfo{EPC: epc, Mem: g.encode(mem), TimeStamp: now()}
+}
+
+// malform breaks memory in one of the ways the decoder rejects
+func (g *tagGenerator) malform(mem string) string {
+	switch g.rnd.Intn(3) {
+	case 0:
+		return mem[:mqttSvc.TAG_MEM_LEN/2]
+	case 1:
+		return "X" + mem[1:]
+	default:
+		return mem[:3] + "-" + mem[4:]
+	}
+}
+
+func (g *tagGenerator) encode(mem string) string {
+	if g.encoding == mqttSvc.TAG_ENCODING_HEX {
+		return strings.ToUpper(hex.EncodeToString([]byte(mem)))
+	}
+	return mem
+}
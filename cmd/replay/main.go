@@ -0,0 +1,95 @@
+// Command replay feeds a capture recorded with MQTT_CAPTURE_FILE back through
+// the gateway subscribers of the server, against the database of an env file.
+// Nothing is sent to gateways, what subscribers publish is printed with -v.
+//
+//	go run ./cmd/replay -env .env.test -capture capture.jsonl -speed 10
+//	go run ./cmd/replay -env .env.test -capture capture.jsonl -speed 0 -gateway gw01
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ecoprohcm/DMS_BackendServer/fakes"
+	"github.com/ecoprohcm/DMS_BackendServer/initializers"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func main() {
+	envFile := flag.String("env", ".env", "env file of the database to replay against, topics and tag settings are read from it too")
+	capture := flag.String("capture", "", "capture file, as written with MQTT_CAPTURE_FILE")
+	opts := mqttSvc.ReplayOptions{}
+	flag.Float64Var(&opts.Speed, "speed", 1, "1 keeps original pace, 10 is ten times faster, 0 does not wait")
+	flag.StringVar(&opts.GatewayID, "gateway", "", "replay only messages of this gateway")
+	verbose := flag.Bool("v", false, "print what subscribers publish")
+	flag.Parse()
+
+	if err := run(*envFile, *capture, opts, *verbose); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(envFile string, capture string, opts mqttSvc.ReplayOptions, verbose bool) error {
+	if capture == "" {
+		return fmt.Errorf("-capture is required")
+	}
+	if opts.Speed < 0 {
+		return fmt.Errorf("-speed must not be negative")
+	}
+	f, err := os.Open(capture)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	config, err := initializers.ProvideConfig(envFile)
+	if err != nil {
+		return err
+	}
+	db, closeDb, err := initializers.ProvideGormDb(config)
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+	svcOptions := initializers.ProvideSvcOptions(config, db)
+	tags, err := initializers.ProvideTagDecoder(config)
+	if err != nil {
+		return err
+	}
+	schemas, err := initializers.ProvideSchemaValidator(config)
+	if err != nil {
+		return err
+	}
+	topics, err := initializers.ProvideTopics(config)
+	if err != nil {
+		return err
+	}
+
+	// Rejected messages are counted, not kept as dead letters of the database
+	writer := mqttSvc.NewBatchWriter(svcOptions, config.IngestBatchSize, config.IngestFlushInterval, config.IngestMaxPending)
+	ing := mqttSvc.NewIngestion(mqttSvc.IngestionOptions{}, topics,
+		initializers.ProvideReadDebouncer(config), tags, schemas, writer, nil)
+	client := fakes.NewClient()
+	mqttSvc.RegisterSubscribers(client, svcOptions, ing)
+	ing.Start()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stats, err := ing.ReplayCapture(ctx, f, client, opts)
+	ing.Stop()
+
+	if verbose {
+		for _, p := range client.Published() {
+			fmt.Printf("%s %s\n", p.Topic, p.Payload)
+		}
+	}
+	out, _ := json.MarshalIndent(stats, "", "  ")
+	fmt.Println(string(out))
+	return err
+}
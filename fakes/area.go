@@ -0,0 +1,181 @@
+package fakes
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+type AreaSvc struct {
+	s *Store
+}
+
+func (as *AreaSvc) FindAllArea(ctx context.Context) ([]models.Area, error) {
+	as.s.mu.Lock()
+	defer as.s.mu.Unlock()
+	if err := as.s.failure("AreaSvc.FindAllArea"); err != nil {
+		return nil, err
+	}
+	aList := []models.Area{}
+	for _, a := range as.s.areas {
+		if visible(ctx, a.OrganizationID) {
+			aList = append(aList, a)
+		}
+	}
+	return aList, nil
+}
+
+func (as *AreaSvc) FindAreaByID(ctx context.Context, id string) (*models.Area, error) {
+	as.s.mu.Lock()
+	defer as.s.mu.Unlock()
+	if err := as.s.failure("AreaSvc.FindAreaByID"); err != nil {
+		return nil, err
+	}
+	for _, a := range as.s.areas {
+		if a.ID == parseID(id) && visible(ctx, a.OrganizationID) {
+			return &a, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (as *AreaSvc) CreateArea(a *models.Area, ctx context.Context) (*models.Area, error) {
+	as.s.mu.Lock()
+	defer as.s.mu.Unlock()
+	if err := as.s.failure("AreaSvc.CreateArea"); err != nil {
+		return nil, err
+	}
+	stamp(ctx, &a.TenantModel)
+	if as.s.areaNamed(a.OrganizationID, a.Name) {
+		return nil, duplicate("area %s already exists", a.Name)
+	}
+	as.s.created(&a.GormModel)
+	a.Revision = models.Revision{Revision: 1}
+	as.s.areas = append(as.s.areas, *a)
+	return a, nil
+}
+
+func (as *AreaSvc) UpdateArea(ctx context.Context, a *models.Area) (bool, error) {
+	as.s.mu.Lock()
+	defer as.s.mu.Unlock()
+	if err := as.s.failure("AreaSvc.UpdateArea"); err != nil {
+		return false, err
+	}
+	for i := range as.s.areas {
+		if as.s.areas[i].ID == a.ID && visible(ctx, as.s.areas[i].OrganizationID) {
+			if err := checkRevision(as.s.areas[i].Revision, a.Revision.Revision); err != nil {
+				return false, err
+			}
+			merge(ctx, &as.s.areas[i], a)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (as *AreaSvc) DeleteArea(ctx context.Context, d *models.DeleteArea, revision uint) ([]models.UHF, error) {
+	as.s.mu.Lock()
+	defer as.s.mu.Unlock()
+	if err := as.s.failure("AreaSvc.DeleteArea"); err != nil {
+		return nil, err
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	i := -1
+	for j, a := range as.s.areas {
+		if a.ID == d.ID && visible(ctx, a.OrganizationID) {
+			i = j
+		}
+	}
+	if i < 0 {
+		_, err := affected(0)
+		return nil, err
+	}
+	if err := checkRevision(as.s.areas[i].Revision, revision); err != nil {
+		return nil, err
+	}
+	areaId := strconv.FormatUint(uint64(d.ID), 10)
+	var unassigned []models.UHF
+	switch d.Devices {
+	case models.AREA_DEVICES_REASSIGN:
+		found := false
+		for _, a := range as.s.areas {
+			found = found || (strconv.FormatUint(uint64(a.ID), 10) == d.AreaID && visible(ctx, a.OrganizationID))
+		}
+		if !found {
+			return nil, models.NewValidationError("area_id", "area %s does not exist", d.AreaID)
+		}
+		as.s.moveAreaDevices(ctx, areaId, func(gw *models.Gateway) { gw.AreaID = d.AreaID }, func(uhf *models.UHF) { uhf.AreaId = d.AreaID })
+	case models.AREA_DEVICES_UNASSIGN:
+		unassigned = as.s.moveAreaDevices(ctx, areaId, func(gw *models.Gateway) { gw.AreaID = "" }, func(uhf *models.UHF) {
+			uhf.AreaId = ""
+			uhf.DesiredState = "inactive"
+			uhf.SyncAttempts = 0
+		})
+	default:
+		var gwCnt, uhfCnt int64
+		for _, gw := range as.s.gateways {
+			if gw.AreaID == areaId {
+				gwCnt++
+			}
+		}
+		for _, uhf := range as.s.uhfs {
+			if uhf.AreaId == areaId {
+				uhfCnt++
+			}
+		}
+		if gwCnt > 0 || uhfCnt > 0 {
+			return nil, models.NewConflictError("area %d still has %d gateways and %d UHFs, reassign or unassign them", d.ID, gwCnt, uhfCnt)
+		}
+	}
+	a := as.s.areas[i]
+	a.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	as.s.trashedAreas = append(as.s.trashedAreas, a)
+	as.s.areas = append(as.s.areas[:i], as.s.areas[i+1:]...)
+	return unassigned, nil
+}
+
+// areaNamed reports whether an area of organization orgId, live or in
+// trash, has name
+func (s *Store) areaNamed(orgId uint, name string) bool {
+	for _, areas := range [][]models.Area{s.areas, s.trashedAreas} {
+		for _, a := range areas {
+			if a.OrganizationID == orgId && a.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// moveAreaDevices changes gateways and UHFs of area areaId, trashed ones
+// too, and returns live UHFs changed
+func (s *Store) moveAreaDevices(ctx context.Context, areaId string, gateway func(*models.Gateway), uhf func(*models.UHF)) []models.UHF {
+	for _, gateways := range [][]models.Gateway{s.gateways, s.trashedGateways} {
+		for i := range gateways {
+			if gateways[i].AreaID == areaId {
+				gateway(&gateways[i])
+				revise(ctx, &gateways[i].Revision)
+			}
+		}
+	}
+	moved := []models.UHF{}
+	for _, uhfs := range [][]models.UHF{s.uhfs, s.trashedUHFs} {
+		for i := range uhfs {
+			if uhfs[i].AreaId == areaId {
+				uhf(&uhfs[i])
+				revise(ctx, &uhfs[i].Revision)
+				if uhfs[i].DeletedAt.Valid {
+					continue
+				}
+				moved = append(moved, uhfs[i])
+			}
+		}
+	}
+	return moved
+}
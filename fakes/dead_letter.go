@@ -0,0 +1,95 @@
+package fakes
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+type DeadLetterSvc struct {
+	s *Store
+}
+
+func (ds *DeadLetterSvc) FindDeadLetters(ctx context.Context, filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	ds.s.mu.Lock()
+	defer ds.s.mu.Unlock()
+	if err := ds.s.failure("DeadLetterSvc.FindDeadLetters"); err != nil {
+		return nil, err
+	}
+	dlList := []models.DeadLetter{}
+	for _, dl := range ds.s.deadLetters {
+		if (filter.Topic == "" || dl.Topic == filter.Topic) &&
+			(filter.GatewayID == "" || dl.GatewayID == filter.GatewayID) &&
+			(filter.Site == "" || dl.Site == filter.Site) &&
+			(filter.Reason == "" || dl.Reason == filter.Reason) {
+			dlList = append(dlList, dl)
+		}
+	}
+	sort.SliceStable(dlList, func(i, j int) bool {
+		return dlList[i].ReceivedAt.After(dlList[j].ReceivedAt)
+	})
+	return dlList, nil
+}
+
+func (ds *DeadLetterSvc) FindDeadLetterByID(ctx context.Context, id string) (*models.DeadLetter, error) {
+	ds.s.mu.Lock()
+	defer ds.s.mu.Unlock()
+	if err := ds.s.failure("DeadLetterSvc.FindDeadLetterByID"); err != nil {
+		return nil, err
+	}
+	for _, dl := range ds.s.deadLetters {
+		if dl.ID == parseID(id) {
+			return &dl, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (ds *DeadLetterSvc) CreateDeadLetter(ctx context.Context, dl *models.DeadLetter) (*models.DeadLetter, error) {
+	ds.s.mu.Lock()
+	defer ds.s.mu.Unlock()
+	if err := ds.s.failure("DeadLetterSvc.CreateDeadLetter"); err != nil {
+		return nil, err
+	}
+	ds.s.created(&dl.GormModel)
+	ds.s.deadLetters = append(ds.s.deadLetters, *dl)
+	return dl, nil
+}
+
+func (ds *DeadLetterSvc) MarkDeadLetterReplayed(ctx context.Context, id uint, reason string, detail string) (bool, error) {
+	ds.s.mu.Lock()
+	defer ds.s.mu.Unlock()
+	if err := ds.s.failure("DeadLetterSvc.MarkDeadLetterReplayed"); err != nil {
+		return false, err
+	}
+	for i := range ds.s.deadLetters {
+		dl := &ds.s.deadLetters[i]
+		if dl.ID == id {
+			now := time.Now()
+			dl.Reason = reason
+			dl.Detail = detail
+			dl.ReplayCount++
+			dl.LastReplayAt = &now
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (ds *DeadLetterSvc) DeleteDeadLetter(ctx context.Context, id uint) (bool, error) {
+	ds.s.mu.Lock()
+	defer ds.s.mu.Unlock()
+	if err := ds.s.failure("DeadLetterSvc.DeleteDeadLetter"); err != nil {
+		return false, err
+	}
+	for i, dl := range ds.s.deadLetters {
+		if dl.ID == id {
+			ds.s.deadLetters = append(ds.s.deadLetters[:i], ds.s.deadLetters[i+1:]...)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
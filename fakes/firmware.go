@@ -0,0 +1,113 @@
+package fakes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+type FirmwareSvc struct {
+	s *Store
+}
+
+// SetFirmwareDir makes FirmwareSvc store artifacts in dir so they can be
+// downloaded, artifacts are only hashed and dropped by default
+func (s *Store) SetFirmwareDir(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.firmwareDir = dir
+}
+
+func (fs *FirmwareSvc) FindAllFirmware(ctx context.Context) ([]models.Firmware, error) {
+	fs.s.mu.Lock()
+	defer fs.s.mu.Unlock()
+	if err := fs.s.failure("FirmwareSvc.FindAllFirmware"); err != nil {
+		return nil, err
+	}
+	return append([]models.Firmware{}, fs.s.firmwares...), nil
+}
+
+func (fs *FirmwareSvc) FindFirmwareByID(ctx context.Context, id string) (*models.Firmware, error) {
+	fs.s.mu.Lock()
+	defer fs.s.mu.Unlock()
+	if err := fs.s.failure("FirmwareSvc.FindFirmwareByID"); err != nil {
+		return nil, err
+	}
+	return fs.s.firmware(parseID(id))
+}
+
+func (s *Store) firmware(id uint) (*models.Firmware, error) {
+	for _, fw := range s.firmwares {
+		if fw.ID == id {
+			return &fw, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (fs *FirmwareSvc) CreateFirmware(ctx context.Context, fw *models.Firmware, artifact io.Reader) (*models.Firmware, error) {
+	fs.s.mu.Lock()
+	defer fs.s.mu.Unlock()
+	if err := fs.s.failure("FirmwareSvc.CreateFirmware"); err != nil {
+		return nil, err
+	}
+	for _, existing := range fs.s.firmwares {
+		if existing.Version == fw.Version {
+			return nil, duplicate("firmware %s already exists", fw.Version)
+		}
+	}
+
+	w := io.Discard
+	if fs.s.firmwareDir != "" {
+		dir := filepath.Join(fs.s.firmwareDir, filepath.Base(fw.Version))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		fw.ArtifactPath = filepath.Join(dir, filepath.Base(fw.FileName))
+		f, err := os.Create(fw.ArtifactPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		w = f
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), artifact)
+	if err != nil {
+		return nil, err
+	}
+	fw.Size = size
+	fw.Checksum = hex.EncodeToString(hash.Sum(nil))
+	fs.s.created(&fw.GormModel)
+	fs.s.firmwares = append(fs.s.firmwares, *fw)
+	return fw, nil
+}
+
+func (fs *FirmwareSvc) DeleteFirmware(ctx context.Context, id uint) (bool, error) {
+	fs.s.mu.Lock()
+	defer fs.s.mu.Unlock()
+	if err := fs.s.failure("FirmwareSvc.DeleteFirmware"); err != nil {
+		return false, err
+	}
+	for _, r := range fs.s.rollouts {
+		if r.FirmwareID == id && r.Status != models.ROLLOUT_HALTED && r.Status != models.ROLLOUT_COMPLETED {
+			return false, models.NewConflictError("firmware is used by an unfinished rollout")
+		}
+	}
+	for i, fw := range fs.s.firmwares {
+		if fw.ID == id {
+			fs.s.firmwares = append(fs.s.firmwares[:i], fs.s.firmwares[i+1:]...)
+			if fw.ArtifactPath != "" {
+				os.Remove(fw.ArtifactPath)
+			}
+			return affected(1)
+		}
+	}
+	return false, utils.ErrRecordNotFound
+}
@@ -0,0 +1,288 @@
+package fakes
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+type GatewaySvc struct {
+	s *Store
+}
+
+// gateway returns index of gateway gwId tenant of ctx sees, -1 when there is none
+func (s *Store) gateway(ctx context.Context, gwId string) int {
+	for i, gw := range s.gateways {
+		if gw.GatewayID == gwId && visible(ctx, gw.OrganizationID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) trashedGateway(ctx context.Context, gwId string) int {
+	for i, gw := range s.trashedGateways {
+		if gw.GatewayID == gwId && visible(ctx, gw.OrganizationID) {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadGateway copies gateway with its UHFs preloaded
+func (s *Store) loadGateway(ctx context.Context, gw models.Gateway) models.Gateway {
+	gw.UHFs = []models.UHF{}
+	for _, uhf := range s.uhfs {
+		if uhf.GatewayID == gw.GatewayID && visible(ctx, uhf.OrganizationID) {
+			uhf.AfterFind(nil)
+			gw.UHFs = append(gw.UHFs, uhf)
+		}
+	}
+	gw.AfterFind(nil)
+	return gw
+}
+
+func (gs *GatewaySvc) FindAllGateway(ctx context.Context) ([]models.Gateway, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.FindAllGateway"); err != nil {
+		return nil, err
+	}
+	gwList := []models.Gateway{}
+	for _, gw := range gs.s.gateways {
+		if visible(ctx, gw.OrganizationID) {
+			gwList = append(gwList, gs.s.loadGateway(ctx, gw))
+		}
+	}
+	return gwList, nil
+}
+
+func (gs *GatewaySvc) FindGatewaysBySelector(ctx context.Context, s models.BulkSelector) ([]models.Gateway, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.FindGatewaysBySelector"); err != nil {
+		return nil, err
+	}
+	gwList := []models.Gateway{}
+	for _, gw := range gs.s.gateways {
+		if visible(ctx, gw.OrganizationID) && s.MatchGateway(&gw) {
+			gwList = append(gwList, gs.s.loadGateway(ctx, gw))
+		}
+	}
+	return gwList, nil
+}
+
+func (gs *GatewaySvc) FindGatewayByID(ctx context.Context, id string) (*models.Gateway, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.FindGatewayByID"); err != nil {
+		return nil, err
+	}
+	for _, gw := range gs.s.gateways {
+		if gw.ID == parseID(id) && visible(ctx, gw.OrganizationID) {
+			gw = gs.s.loadGateway(ctx, gw)
+			return &gw, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (gs *GatewaySvc) FindGatewayByGatewayID(ctx context.Context, id string) (*models.Gateway, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.FindGatewayByGatewayID"); err != nil {
+		return nil, err
+	}
+	i := gs.s.gateway(ctx, id)
+	if i < 0 {
+		return nil, models.NewNotFoundError("gateway %s not found", id)
+	}
+	gw := gs.s.loadGateway(ctx, gs.s.gateways[i])
+	return &gw, nil
+}
+
+func (gs *GatewaySvc) FindGatewayTenant(ctx context.Context, id string) (string, uint, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.FindGatewayTenant"); err != nil {
+		return "", 0, err
+	}
+	if i := gs.s.gateway(ctx, id); i >= 0 {
+		return gs.s.gateways[i].Site, gs.s.gateways[i].OrganizationID, nil
+	}
+	if i := gs.s.trashedGateway(ctx, id); i >= 0 {
+		return gs.s.trashedGateways[i].Site, gs.s.trashedGateways[i].OrganizationID, nil
+	}
+	return "", 0, utils.ErrRecordNotFound
+}
+
+func (gs *GatewaySvc) CountGatewaysByConnectState(ctx context.Context) (map[string]int64, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.CountGatewaysByConnectState"); err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, gw := range gs.s.gateways {
+		if visible(ctx, gw.OrganizationID) {
+			counts[gw.ConnectState]++
+		}
+	}
+	return counts, nil
+}
+
+func (gs *GatewaySvc) UpdateGateway(ctx context.Context, g *models.Gateway) (bool, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.UpdateGateway"); err != nil {
+		return false, err
+	}
+	i := gs.s.gateway(ctx, g.GatewayID)
+	if i < 0 {
+		return false, models.NewNotFoundError("gateway %s not found", g.GatewayID)
+	}
+	if err := checkRevision(gs.s.gateways[i].Revision, g.Revision.Revision); err != nil {
+		return false, err
+	}
+	merge(ctx, &gs.s.gateways[i], g)
+	return true, nil
+}
+
+func (gs *GatewaySvc) DeleteGateway(ctx context.Context, gwID string, revision uint, trashedBy string) (bool, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.DeleteGateway"); err != nil {
+		return false, err
+	}
+	i := gs.s.gateway(ctx, gwID)
+	if i < 0 {
+		return affected(0)
+	}
+	if err := checkRevision(gs.s.gateways[i].Revision, revision); err != nil {
+		return false, err
+	}
+	// UHFs of gateway go to trash with it, at the same time
+	deletedAt := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	gw := gs.s.gateways[i]
+	gw.DeletedAt = deletedAt
+	gw.TrashedBy = trashedBy
+	gs.s.trashedGateways = append(gs.s.trashedGateways, gw)
+	gs.s.gateways = append(gs.s.gateways[:i], gs.s.gateways[i+1:]...)
+	kept := []models.UHF{}
+	for _, uhf := range gs.s.uhfs {
+		if uhf.GatewayID == gwID {
+			uhf.DeletedAt = deletedAt
+			gs.s.trashedUHFs = append(gs.s.trashedUHFs, uhf)
+			continue
+		}
+		kept = append(kept, uhf)
+	}
+	gs.s.uhfs = kept
+	return affected(1)
+}
+
+func (gs *GatewaySvc) DeleteGatewayUHF(ctx context.Context, gw *models.Gateway, d *models.UHF) (*models.Gateway, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.DeleteGatewayUHF"); err != nil {
+		return nil, err
+	}
+	for j := range gs.s.uhfs {
+		if gs.s.uhfs[j].ID == d.ID && gs.s.uhfs[j].GatewayID == gw.GatewayID {
+			gs.s.uhfs[j].GatewayID = ""
+			revise(ctx, &gs.s.uhfs[j].Revision)
+		}
+	}
+	return gw, nil
+}
+
+func (gs *GatewaySvc) UpdateGatewayConnectState(ctx context.Context, gwId string, state string) (bool, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.UpdateGatewayConnectState"); err != nil {
+		return false, err
+	}
+	if i := gs.s.gateway(ctx, gwId); i >= 0 {
+		gs.s.gateways[i].ConnectState = state
+		revise(ctx, &gs.s.gateways[i].Revision)
+	}
+	return true, nil
+}
+
+func (gs *GatewaySvc) CreateGateway(ctx context.Context, g *models.Gateway) (*models.Gateway, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.CreateGateway"); err != nil {
+		return nil, err
+	}
+	if gs.s.gateway(models.WithSystemTenant(ctx), g.GatewayID) >= 0 || gs.s.trashedGateway(models.WithSystemTenant(ctx), g.GatewayID) >= 0 {
+		return nil, duplicate("gateway %s already exists", g.GatewayID)
+	}
+	stamp(ctx, &g.TenantModel)
+	gs.s.created(&g.GormModel)
+	g.Revision = models.Revision{Revision: 1}
+	row := *g
+	row.UHFs = nil
+	gs.s.gateways = append(gs.s.gateways, row)
+	return g, nil
+}
+
+func (gs *GatewaySvc) FindDriftedGateways(ctx context.Context) ([]models.Gateway, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure("GatewaySvc.FindDriftedGateways"); err != nil {
+		return nil, err
+	}
+	gwList := []models.Gateway{}
+	for _, gw := range gs.s.gateways {
+		if gw.Drifted() && visible(ctx, gw.OrganizationID) {
+			gw.AfterFind(nil)
+			gwList = append(gwList, gw)
+		}
+	}
+	return gwList, nil
+}
+
+func (gs *GatewaySvc) UpdateGatewayDesiredState(ctx context.Context, gwId string, state string) (bool, error) {
+	return gs.updateTwin(ctx, "GatewaySvc.UpdateGatewayDesiredState", gwId, func(twin *models.DeviceTwin) {
+		twin.DesiredState = state
+		twin.SyncAttempts = 0
+	})
+}
+
+func (gs *GatewaySvc) UpdateGatewayReportedState(ctx context.Context, gwId string, state string) (bool, error) {
+	return gs.updateTwin(ctx, "GatewaySvc.UpdateGatewayReportedState", gwId, func(twin *models.DeviceTwin) {
+		now := time.Now()
+		twin.ReportedState = state
+		twin.ReportedAt = &now
+		if twin.DesiredState == state {
+			twin.SyncAttempts = 0
+		}
+	})
+}
+
+func (gs *GatewaySvc) MarkGatewaySyncAttempt(ctx context.Context, gwId string) (bool, error) {
+	return gs.updateTwin(ctx, "GatewaySvc.MarkGatewaySyncAttempt", gwId, func(twin *models.DeviceTwin) {
+		now := time.Now()
+		twin.SyncAttempts++
+		twin.LastSyncAt = &now
+	})
+}
+
+func (gs *GatewaySvc) updateTwin(ctx context.Context, method string, gwId string, update func(twin *models.DeviceTwin)) (bool, error) {
+	gs.s.mu.Lock()
+	defer gs.s.mu.Unlock()
+	if err := gs.s.failure(method); err != nil {
+		return false, err
+	}
+	i := gs.s.gateway(ctx, gwId)
+	if i < 0 {
+		return affected(0)
+	}
+	update(&gs.s.gateways[i].DeviceTwin)
+	revise(ctx, &gs.s.gateways[i].Revision)
+	return affected(1)
+}
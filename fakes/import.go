@@ -0,0 +1,87 @@
+package fakes
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+type ImportSvc struct {
+	s *Store
+}
+
+// ApplyImport rolls every change back when a step fails, like the transaction of GORM
+func (is *ImportSvc) ApplyImport(ctx context.Context, plan *models.ImportPlan) (*models.ImportResult, error) {
+	is.s.mu.Lock()
+	defer is.s.mu.Unlock()
+	if err := is.s.failure("ImportSvc.ApplyImport"); err != nil {
+		return nil, err
+	}
+	areas := append([]models.Area{}, is.s.areas...)
+	gateways := append([]models.Gateway{}, is.s.gateways...)
+	uhfs := append([]models.UHF{}, is.s.uhfs...)
+	nextID := is.s.nextID
+
+	result, err := is.s.applyImport(ctx, plan)
+	if err != nil {
+		is.s.areas, is.s.gateways, is.s.uhfs, is.s.nextID = areas, gateways, uhfs, nextID
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *Store) applyImport(ctx context.Context, plan *models.ImportPlan) (*models.ImportResult, error) {
+	for _, a := range plan.NewAreas {
+		stamp(ctx, &a.TenantModel)
+		if s.areaNamed(a.OrganizationID, a.Name) {
+			return nil, duplicate("area %s already exists", a.Name)
+		}
+		s.created(&a.GormModel)
+		a.Revision = models.Revision{Revision: 1}
+		s.areas = append(s.areas, a)
+	}
+	areaIds := map[string]string{}
+	for i := range s.areas {
+		if !visible(ctx, s.areas[i].OrganizationID) {
+			continue
+		}
+		for _, a := range plan.UpdatedAreas {
+			if s.areas[i].ID == a.ID {
+				s.areas[i].Manager = a.Manager
+				revise(ctx, &s.areas[i].Revision)
+			}
+		}
+		areaIds[s.areas[i].Name] = strconv.FormatUint(uint64(s.areas[i].ID), 10)
+	}
+
+	result := &models.ImportResult{Gateways: []models.Gateway{}, UHFs: []models.UHF{}}
+	for _, gw := range plan.Gateways {
+		i := s.gateway(ctx, gw.GatewayID)
+		if i < 0 {
+			return nil, models.NewNotFoundError("gateway %s not found", gw.GatewayID)
+		}
+		if gw.Name != "" {
+			s.gateways[i].Name = gw.Name
+		}
+		if gw.Area != "" {
+			s.gateways[i].AreaID = areaIds[gw.Area]
+		}
+		revise(ctx, &s.gateways[i].Revision)
+		updated := s.gateways[i]
+		updated.AfterFind(nil)
+		result.Gateways = append(result.Gateways, updated)
+	}
+	for _, uhf := range plan.UHFs {
+		i := s.uhf(ctx, uhf.UHFAddress, uhf.GatewayID)
+		if i < 0 {
+			return nil, models.NewNotFoundError("uhf %s of gateway %s not found", uhf.UHFAddress, uhf.GatewayID)
+		}
+		s.uhfs[i].AreaId = areaIds[uhf.Area]
+		revise(ctx, &s.uhfs[i].Revision)
+		updated := s.uhfs[i]
+		updated.AfterFind(nil)
+		result.UHFs = append(result.UHFs, updated)
+	}
+	return result, nil
+}
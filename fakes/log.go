@@ -0,0 +1,132 @@
+package fakes
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+type LogSvc struct {
+	s *Store
+}
+
+func (s *Store) findGatewayLogs(ctx context.Context, match func(gl models.GatewayLog) bool) []models.GatewayLog {
+	glList := []models.GatewayLog{}
+	for _, gl := range s.gatewayLogs {
+		if visible(ctx, gl.OrganizationID) && match(gl) {
+			glList = append(glList, gl)
+		}
+	}
+	return glList
+}
+
+func (s *Store) findGatewayLogByID(ctx context.Context, id string) (*models.GatewayLog, error) {
+	for _, gl := range s.gatewayLogs {
+		if gl.ID == parseID(id) && visible(ctx, gl.OrganizationID) {
+			return &gl, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (ls *LogSvc) FindAllGatewayLog(ctx context.Context) ([]models.GatewayLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("LogSvc.FindAllGatewayLog"); err != nil {
+		return nil, err
+	}
+	return ls.s.findGatewayLogs(ctx, func(gl models.GatewayLog) bool { return true }), nil
+}
+
+func (ls *LogSvc) FindGatewayLogByID(ctx context.Context, id string) (*models.GatewayLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("LogSvc.FindGatewayLogByID"); err != nil {
+		return nil, err
+	}
+	return ls.s.findGatewayLogByID(ctx, id)
+}
+
+func (ls *LogSvc) FindGatewayByGatewayID(ctx context.Context, gatewayId string) (*[]models.GatewayLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("LogSvc.FindGatewayByGatewayID"); err != nil {
+		return nil, err
+	}
+	glList := ls.s.findGatewayLogs(ctx, func(gl models.GatewayLog) bool {
+		return gl.GatewayID == gatewayId
+	})
+	return &glList, nil
+}
+
+func (ls *LogSvc) CreateGatewayLog(ctx context.Context, gl *models.GatewayLog) (*models.GatewayLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("LogSvc.CreateGatewayLog"); err != nil {
+		return nil, err
+	}
+	ls.s.createGatewayLog(ctx, gl)
+	return gl, nil
+}
+
+func (ls *LogSvc) CreateGatewayLogs(ctx context.Context, glList []models.GatewayLog, batchSize int) error {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("LogSvc.CreateGatewayLogs"); err != nil {
+		return err
+	}
+	for i := range glList {
+		ls.s.createGatewayLog(ctx, &glList[i])
+	}
+	return nil
+}
+
+func (s *Store) createGatewayLog(ctx context.Context, gl *models.GatewayLog) {
+	stamp(ctx, &gl.TenantModel)
+	gl.ID = s.newID()
+	gl.CreatedAt = time.Now()
+	s.gatewayLogs = append(s.gatewayLogs, *gl)
+}
+
+func (ls *LogSvc) FindGatewayLogsByGatewayIDAndTime(ctx context.Context, gatewayId string, from string, to string) (*[]models.GatewayLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("LogSvc.FindGatewayLogsByGatewayIDAndTime"); err != nil {
+		return nil, err
+	}
+	glList := ls.s.findGatewayLogs(ctx, func(gl models.GatewayLog) bool {
+		return gl.GatewayID == gatewayId && inRange(gl.LogTime, from, to)
+	})
+	return &glList, nil
+}
+
+func (ls *LogSvc) FindGatewayLogsByTime(ctx context.Context, from string, to string) (*[]models.GatewayLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("LogSvc.FindGatewayLogsByTime"); err != nil {
+		return nil, err
+	}
+	glList := ls.s.findGatewayLogs(ctx, func(gl models.GatewayLog) bool {
+		return inRange(gl.LogTime, from, to)
+	})
+	return &glList, nil
+}
+
+func (ls *LogSvc) DeleteGatewayLogInTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("LogSvc.DeleteGatewayLogInTimeRange"); err != nil {
+		return false, err
+	}
+	kept := []models.GatewayLog{}
+	for _, gl := range ls.s.gatewayLogs {
+		if !visible(ctx, gl.OrganizationID) || !inRange(gl.LogTime, from, to) {
+			kept = append(kept, gl)
+		}
+	}
+	deleted := len(ls.s.gatewayLogs) - len(kept)
+	ls.s.gatewayLogs = kept
+	return affected(deleted)
+}
@@ -0,0 +1,158 @@
+package fakes
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Publication is a message published through Client
+type Publication struct {
+	Topic    string
+	Qos      byte
+	Retained bool
+	Payload  string
+}
+
+// Client is a connected MQTT client that keeps what is published instead of
+// sending it, subscriptions are recorded and never receive messages
+type Client struct {
+	mu            sync.Mutex
+	connected     bool
+	publishErr    error
+	published     []Publication
+	subscriptions map[string]mqtt.MessageHandler
+}
+
+func NewClient() *Client {
+	return &Client{
+		connected:     true,
+		subscriptions: map[string]mqtt.MessageHandler{},
+	}
+}
+
+// SetConnected opens or closes connection IsConnected and IsConnectionOpen report
+func (c *Client) SetConnected(connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = connected
+}
+
+// FailPublish makes publish tokens complete with err, nil clears it
+func (c *Client) FailPublish(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.publishErr = err
+}
+
+// Published returns messages published so far
+func (c *Client) Published() []Publication {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Publication{}, c.published...)
+}
+
+// Subscribed reports whether topic filter was subscribed
+func (c *Client) Subscribed(filter string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.subscriptions[filter]
+	return ok
+}
+
+func (c *Client) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *Client) IsConnectionOpen() bool {
+	return c.IsConnected()
+}
+
+func (c *Client) Connect() mqtt.Token {
+	c.SetConnected(true)
+	return &Token{}
+}
+
+func (c *Client) Disconnect(quiesce uint) {
+	c.SetConnected(false)
+}
+
+func (c *Client) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.publishErr != nil {
+		return &Token{Err: c.publishErr}
+	}
+	var p string
+	switch v := payload.(type) {
+	case string:
+		p = v
+	case []byte:
+		p = string(v)
+	default:
+		return &Token{Err: fmt.Errorf("unknown payload type %T", payload)}
+	}
+	c.published = append(c.published, Publication{Topic: topic, Qos: qos, Retained: retained, Payload: p})
+	return &Token{}
+}
+
+func (c *Client) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscriptions[topic] = callback
+	return &Token{}
+}
+
+func (c *Client) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for filter := range filters {
+		c.subscriptions[filter] = callback
+	}
+	return &Token{}
+}
+
+func (c *Client) Unsubscribe(topics ...string) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range topics {
+		delete(c.subscriptions, topic)
+	}
+	return &Token{}
+}
+
+func (c *Client) AddRoute(topic string, callback mqtt.MessageHandler) {
+}
+
+func (c *Client) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.NewClient(mqtt.NewClientOptions()).OptionsReader()
+}
+
+// Token is a completed token, Err is the error it completed with
+type Token struct {
+	Err error
+}
+
+func (t *Token) Wait() bool {
+	return true
+}
+
+func (t *Token) WaitTimeout(time.Duration) bool {
+	return true
+}
+
+func (t *Token) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (t *Token) Error() error {
+	return t.Err
+}
+
+var _ mqtt.Client = (*Client)(nil)
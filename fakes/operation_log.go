@@ -0,0 +1,125 @@
+package fakes
+
+import (
+	"context"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+type OperationLogSvc struct {
+	s *Store
+}
+
+func (s *Store) findOperationLogs(ctx context.Context, match func(ol models.OperationLog) bool) []models.OperationLog {
+	olList := []models.OperationLog{}
+	for _, ol := range s.operationLogs {
+		if visible(ctx, ol.OrganizationID) && match(ol) {
+			olList = append(olList, ol)
+		}
+	}
+	return olList
+}
+
+func (ls *OperationLogSvc) GetAllOperationLogs(ctx context.Context) ([]models.OperationLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("OperationLogSvc.GetAllOperationLogs"); err != nil {
+		return nil, err
+	}
+	return ls.s.findOperationLogs(ctx, func(ol models.OperationLog) bool { return true }), nil
+}
+
+func (ls *OperationLogSvc) GetOperationLogByID(ctx context.Context, id string) (*models.OperationLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("OperationLogSvc.GetOperationLogByID"); err != nil {
+		return nil, err
+	}
+	for _, ol := range ls.s.operationLogs {
+		if ol.ID == parseID(id) && visible(ctx, ol.OrganizationID) {
+			return &ol, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (ls *OperationLogSvc) GetOperationLogByGatewayID(ctx context.Context, doorId string) ([]models.OperationLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("OperationLogSvc.GetOperationLogByGatewayID"); err != nil {
+		return nil, err
+	}
+	return ls.s.findOperationLogs(ctx, func(ol models.OperationLog) bool {
+		return ol.GatewayID == doorId
+	}), nil
+}
+
+func (ls *OperationLogSvc) FindOperationLogsByGatewayIDAndTime(ctx context.Context, gateway_id string, from string, to string) (*[]models.OperationLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("OperationLogSvc.FindOperationLogsByGatewayIDAndTime"); err != nil {
+		return nil, err
+	}
+	olList := ls.s.findOperationLogs(ctx, func(ol models.OperationLog) bool {
+		return ol.GatewayID == gateway_id && inRange(ol.Time, from, to)
+	})
+	return &olList, nil
+}
+
+func (ls *OperationLogSvc) FindOperationLogsByTime(ctx context.Context, from string, to string) (*[]models.OperationLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("OperationLogSvc.FindOperationLogsByTime"); err != nil {
+		return nil, err
+	}
+	olList := ls.s.findOperationLogs(ctx, func(ol models.OperationLog) bool {
+		return inRange(ol.Time, from, to)
+	})
+	return &olList, nil
+}
+
+func (ls *OperationLogSvc) CreateOperationLog(ctx context.Context, dlsl *models.OperationLog) (*models.OperationLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("OperationLogSvc.CreateOperationLog"); err != nil {
+		return nil, err
+	}
+	ls.s.createOperationLog(ctx, dlsl)
+	return dlsl, nil
+}
+
+func (ls *OperationLogSvc) CreateOperationLogs(ctx context.Context, dlslList []models.OperationLog, batchSize int) error {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("OperationLogSvc.CreateOperationLogs"); err != nil {
+		return err
+	}
+	for i := range dlslList {
+		ls.s.createOperationLog(ctx, &dlslList[i])
+	}
+	return nil
+}
+
+func (s *Store) createOperationLog(ctx context.Context, ol *models.OperationLog) {
+	stamp(ctx, &ol.TenantModel)
+	ol.ID = s.newID()
+	s.operationLogs = append(s.operationLogs, *ol)
+}
+
+func (ls *OperationLogSvc) DeleteOperationLogInTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("OperationLogSvc.DeleteOperationLogInTimeRange"); err != nil {
+		return false, err
+	}
+	kept := []models.OperationLog{}
+	for _, ol := range ls.s.operationLogs {
+		if !visible(ctx, ol.OrganizationID) || !inRange(ol.Time, from, to) {
+			kept = append(kept, ol)
+		}
+	}
+	deleted := len(ls.s.operationLogs) - len(kept)
+	ls.s.operationLogs = kept
+	return affected(deleted)
+}
@@ -0,0 +1,184 @@
+package fakes
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+type PackageAccessSvc struct {
+	s *Store
+}
+
+func (s *Store) findPackageAccesses(ctx context.Context, match func(pa models.PackageAccess) bool) []models.PackageAccess {
+	paList := []models.PackageAccess{}
+	for _, pa := range s.packageAccesses {
+		if visible(ctx, pa.OrganizationID) && match(pa) {
+			paList = append(paList, pa)
+		}
+	}
+	return paList
+}
+
+func (ps *PackageAccessSvc) CreatePackageAccess(ctx context.Context, package_acesses *models.PackageAccess) (*models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.CreatePackageAccess"); err != nil {
+		return nil, err
+	}
+	ps.s.createPackageAccess(ctx, package_acesses)
+	return package_acesses, nil
+}
+
+func (ps *PackageAccessSvc) CreatePackageAccesses(ctx context.Context, package_acesses []models.PackageAccess, batchSize int) error {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.CreatePackageAccesses"); err != nil {
+		return err
+	}
+	for i := range package_acesses {
+		ps.s.createPackageAccess(ctx, &package_acesses[i])
+	}
+	return nil
+}
+
+func (s *Store) createPackageAccess(ctx context.Context, pa *models.PackageAccess) {
+	stamp(ctx, &pa.TenantModel)
+	pa.ID = s.newID()
+	s.packageAccesses = append(s.packageAccesses, *pa)
+}
+
+func (ps *PackageAccessSvc) FindAllPackageAccess(ctx context.Context) ([]models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.FindAllPackageAccess"); err != nil {
+		return nil, err
+	}
+	return ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool { return true }), nil
+}
+
+func (ps *PackageAccessSvc) FindAllPackageAccessByPackageID(ctx context.Context, id string) ([]models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.FindAllPackageAccessByPackageID"); err != nil {
+		return nil, err
+	}
+	return ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool {
+		return pa.PackageID == id
+	}), nil
+}
+
+func (ps *PackageAccessSvc) FindPackageAccessByPackageIDAndTimeRange(ctx context.Context, package_id string, from string, to string) (*[]models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.FindPackageAccessByPackageIDAndTimeRange"); err != nil {
+		return nil, err
+	}
+	paList := ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool {
+		return pa.PackageID == package_id && inRange(pa.Time, from, to)
+	})
+	return &paList, nil
+}
+
+func (ps *PackageAccessSvc) FindPackageAccessByPackageIDAndAreaID(ctx context.Context, package_id string, area_id string) (*[]models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.FindPackageAccessByPackageIDAndAreaID"); err != nil {
+		return nil, err
+	}
+	paList := ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool {
+		return pa.PackageID == package_id && pa.AreaID == area_id
+	})
+	return &paList, nil
+}
+
+func (ps *PackageAccessSvc) FindAllPackageAccessByPackageIDAndAreaIDinTimeRange(ctx context.Context, package_id string, area_id string, from string, to string) (*[]models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.FindAllPackageAccessByPackageIDAndAreaIDinTimeRange"); err != nil {
+		return nil, err
+	}
+	paList := ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool {
+		return pa.PackageID == package_id && pa.AreaID == area_id && inRange(pa.Time, from, to)
+	})
+	return &paList, nil
+}
+
+func (ps *PackageAccessSvc) FindAllUserAccessByAreaID(ctx context.Context, package_id string, area_id string, from string, to string) (*[]models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.FindAllUserAccessByAreaID"); err != nil {
+		return nil, err
+	}
+	paList := ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool {
+		return pa.PackageID == package_id && pa.AreaID == area_id && inRange(pa.Time, from, to)
+	})
+	return &paList, nil
+}
+
+func (ps *PackageAccessSvc) FindAllPackageAccessByAreaID(ctx context.Context, area_id string) (*[]models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.FindAllPackageAccessByAreaID"); err != nil {
+		return nil, err
+	}
+	paList := ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool {
+		return pa.AreaID == area_id
+	})
+	return &paList, nil
+}
+
+func (ps *PackageAccessSvc) FindAllPackageAccessByAreaIDAndTimeRange(ctx context.Context, area_id string, from string, to string) (*[]models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.FindAllPackageAccessByAreaIDAndTimeRange"); err != nil {
+		return nil, err
+	}
+	paList := ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool {
+		return pa.AreaID == area_id && inRange(pa.Time, from, to)
+	})
+	return &paList, nil
+}
+
+func (ps *PackageAccessSvc) FindAllPackageAccessTimeRange(ctx context.Context, from string, to string) (*[]models.PackageAccess, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.FindAllPackageAccessTimeRange"); err != nil {
+		return nil, err
+	}
+	paList := ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool {
+		return inRange(pa.Time, from, to)
+	})
+	return &paList, nil
+}
+
+func (ps *PackageAccessSvc) CountPackageAccessesByArea(ctx context.Context, since time.Time) (map[string]int64, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.CountPackageAccessesByArea"); err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, pa := range ps.s.findPackageAccesses(ctx, func(pa models.PackageAccess) bool { return !pa.Time.Before(since) }) {
+		counts[pa.AreaID]++
+	}
+	return counts, nil
+}
+
+func (ps *PackageAccessSvc) DeletePackageAccessTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+	if err := ps.s.failure("PackageAccessSvc.DeletePackageAccessTimeRange"); err != nil {
+		return false, err
+	}
+	kept := []models.PackageAccess{}
+	for _, pa := range ps.s.packageAccesses {
+		if !visible(ctx, pa.OrganizationID) || !inRange(pa.Time, from, to) {
+			kept = append(kept, pa)
+		}
+	}
+	deleted := len(ps.s.packageAccesses) - len(kept)
+	ps.s.packageAccesses = kept
+	return affected(deleted)
+}
@@ -0,0 +1,280 @@
+package fakes
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+// RolloutSvc keeps targets apart from campaigns like their own table
+type RolloutSvc struct {
+	s *Store
+}
+
+// loadRollout copies campaign with its firmware and, if withTargets, its targets preloaded
+func (s *Store) loadRollout(r models.RolloutCampaign, withTargets bool) models.RolloutCampaign {
+	r.Firmware, _ = s.firmware(r.FirmwareID)
+	r.Targets = nil
+	if withTargets {
+		for _, t := range s.targets {
+			if t.CampaignID == r.ID {
+				r.Targets = append(r.Targets, t)
+			}
+		}
+	}
+	return r
+}
+
+func (rs *RolloutSvc) FindAllRollout(ctx context.Context) ([]models.RolloutCampaign, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.FindAllRollout"); err != nil {
+		return nil, err
+	}
+	rList := []models.RolloutCampaign{}
+	for _, r := range rs.s.rollouts {
+		rList = append(rList, rs.s.loadRollout(r, false))
+	}
+	return rList, nil
+}
+
+func (rs *RolloutSvc) FindRolloutByID(ctx context.Context, id string) (*models.RolloutCampaign, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.FindRolloutByID"); err != nil {
+		return nil, err
+	}
+	for _, r := range rs.s.rollouts {
+		if r.ID == parseID(id) {
+			r = rs.s.loadRollout(r, true)
+			return &r, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (rs *RolloutSvc) FindRolloutsByStatus(ctx context.Context, status string) ([]models.RolloutCampaign, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.FindRolloutsByStatus"); err != nil {
+		return nil, err
+	}
+	rList := []models.RolloutCampaign{}
+	for _, r := range rs.s.rollouts {
+		if r.Status == status {
+			rList = append(rList, rs.s.loadRollout(r, false))
+		}
+	}
+	return rList, nil
+}
+
+func (rs *RolloutSvc) CreateRollout(ctx context.Context, r *models.RolloutCampaign) (*models.RolloutCampaign, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.CreateRollout"); err != nil {
+		return nil, err
+	}
+	fw, err := rs.s.firmware(r.FirmwareID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Status = models.ROLLOUT_PENDING
+	r.Targets = []models.RolloutTarget{}
+	for _, gw := range rs.s.gateways {
+		if !visible(ctx, gw.OrganizationID) || gw.SoftwareVersion == fw.Version ||
+			(r.TargetAreaID != "" && gw.AreaID != r.TargetAreaID) ||
+			(r.TargetVersion != "" && gw.SoftwareVersion != r.TargetVersion) {
+			continue
+		}
+		r.Targets = append(r.Targets, models.RolloutTarget{
+			GatewayID:   gw.GatewayID,
+			FromVersion: gw.SoftwareVersion,
+			Status:      models.TARGET_PENDING,
+		})
+	}
+	if len(r.Targets) == 0 {
+		return nil, models.NewValidationError("target_area_id", "no gateway matches rollout target")
+	}
+
+	rs.s.created(&r.GormModel)
+	for i := range r.Targets {
+		rs.s.created(&r.Targets[i].GormModel)
+		r.Targets[i].CampaignID = r.ID
+		rs.s.targets = append(rs.s.targets, r.Targets[i])
+	}
+	row := *r
+	row.Targets = nil
+	rs.s.rollouts = append(rs.s.rollouts, row)
+	r.Firmware = fw
+	return r, nil
+}
+
+func (rs *RolloutSvc) UpdateRolloutStatus(ctx context.Context, id uint, status string, reason string) (bool, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.UpdateRolloutStatus"); err != nil {
+		return false, err
+	}
+	for i := range rs.s.rollouts {
+		r := &rs.s.rollouts[i]
+		if r.ID != id {
+			continue
+		}
+		now := time.Now()
+		r.Status = status
+		r.HaltReason = reason
+		switch status {
+		case models.ROLLOUT_RUNNING:
+			if r.StartedAt == nil {
+				r.StartedAt = &now
+			}
+		case models.ROLLOUT_HALTED, models.ROLLOUT_COMPLETED:
+			r.FinishedAt = &now
+		}
+		return affected(1)
+	}
+	return affected(0)
+}
+
+func (rs *RolloutSvc) DeleteRollout(ctx context.Context, id uint) (bool, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.DeleteRollout"); err != nil {
+		return false, err
+	}
+	for i, r := range rs.s.rollouts {
+		if r.ID != id {
+			continue
+		}
+		if r.Status == models.ROLLOUT_RUNNING {
+			return false, models.NewConflictError("rollout is running, pause it first")
+		}
+		kept := []models.RolloutTarget{}
+		for _, t := range rs.s.targets {
+			if t.CampaignID != id {
+				kept = append(kept, t)
+			}
+		}
+		rs.s.targets = kept
+		rs.s.rollouts = append(rs.s.rollouts[:i], rs.s.rollouts[i+1:]...)
+		return affected(1)
+	}
+	return false, utils.ErrRecordNotFound
+}
+
+func (rs *RolloutSvc) GetRolloutProgress(ctx context.Context, campaignId uint) (models.RolloutProgress, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.GetRolloutProgress"); err != nil {
+		return nil, err
+	}
+	progress := models.RolloutProgress{}
+	for _, t := range rs.s.targets {
+		if t.CampaignID == campaignId {
+			progress[t.Status]++
+		}
+	}
+	return progress, nil
+}
+
+func (rs *RolloutSvc) FindPendingTargets(ctx context.Context, campaignId uint, limit int) ([]models.RolloutTarget, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.FindPendingTargets"); err != nil {
+		return nil, err
+	}
+	tList := []models.RolloutTarget{}
+	for _, t := range rs.s.targets {
+		if len(tList) >= limit {
+			break
+		}
+		if t.CampaignID == campaignId && t.Status == models.TARGET_PENDING {
+			tList = append(tList, t)
+		}
+	}
+	return tList, nil
+}
+
+func (rs *RolloutSvc) MarkTargetSent(ctx context.Context, targetId uint) (bool, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.MarkTargetSent"); err != nil {
+		return false, err
+	}
+	for i := range rs.s.targets {
+		if rs.s.targets[i].ID == targetId {
+			now := time.Now()
+			rs.s.targets[i].Status = models.TARGET_SENT
+			rs.s.targets[i].SentAt = &now
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (rs *RolloutSvc) ExpireTargets(ctx context.Context, campaignId uint, deadline time.Time) (int64, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.ExpireTargets"); err != nil {
+		return 0, err
+	}
+	var expired int64
+	for i := range rs.s.targets {
+		t := &rs.s.targets[i]
+		if t.CampaignID == campaignId && inFlight(t.Status) && t.SentAt != nil && t.SentAt.Before(deadline) {
+			t.Status = models.TARGET_FAILED
+			t.Message = "timeout"
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (rs *RolloutSvc) UpdateTargetStatus(ctx context.Context, campaignId uint, gwId string, status string, message string) (bool, error) {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.UpdateTargetStatus"); err != nil {
+		return false, err
+	}
+	updated := 0
+	for i := range rs.s.targets {
+		t := &rs.s.targets[i]
+		if t.CampaignID == campaignId && t.GatewayID == gwId &&
+			t.Status != models.TARGET_SUCCEEDED && t.Status != models.TARGET_FAILED {
+			t.Status = status
+			t.Message = message
+			updated++
+		}
+	}
+	return affected(updated)
+}
+
+func (rs *RolloutSvc) ConfirmGatewayVersion(ctx context.Context, gwId string, version string) error {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+	if err := rs.s.failure("RolloutSvc.ConfirmGatewayVersion"); err != nil {
+		return err
+	}
+	for _, r := range rs.s.rollouts {
+		fw, err := rs.s.firmware(r.FirmwareID)
+		if err != nil || r.Status != models.ROLLOUT_RUNNING || fw.Version != version {
+			continue
+		}
+		for i := range rs.s.targets {
+			t := &rs.s.targets[i]
+			if t.CampaignID == r.ID && t.GatewayID == gwId && inFlight(t.Status) {
+				t.Status = models.TARGET_SUCCEEDED
+				t.Message = "confirmed on bootup"
+			}
+		}
+	}
+	return nil
+}
+
+// Target was sent to gateway and has not finished yet
+func inFlight(status string) bool {
+	return status == models.TARGET_SENT || status == models.TARGET_DOWNLOADING || status == models.TARGET_INSTALLING
+}
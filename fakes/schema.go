@@ -0,0 +1,24 @@
+package fakes
+
+import (
+	"context"
+)
+
+type SchemaSvc struct {
+	s *Store
+}
+
+func (ss *SchemaSvc) Ping(ctx context.Context) error {
+	ss.s.mu.Lock()
+	defer ss.s.mu.Unlock()
+	return ss.s.failure("SchemaSvc.Ping")
+}
+
+func (ss *SchemaSvc) SchemaVersion(ctx context.Context) (int, error) {
+	ss.s.mu.Lock()
+	defer ss.s.mu.Unlock()
+	if err := ss.s.failure("SchemaSvc.SchemaVersion"); err != nil {
+		return 0, err
+	}
+	return ss.s.schemaVersion, nil
+}
@@ -0,0 +1,234 @@
+// Package fakes provides in-memory implementations of the model services
+// and of the MQTT client, so handlers and MQTT subscribers can be tested
+// without SQL Server or a broker.
+//
+// Fakes follow what the GORM services do closely enough for tests: finds by
+// ID fail with utils.ErrRecordNotFound, updates and deletes matching nothing
+// fail with utils.ErrNoRecordAffected, updates with a struct skip its zero
+// fields and unique columns are enforced, rows in trash included. Deleted
+// areas, gateways and UHFs move to trash. Contexts with a tenant only see
+// rows of their organization, contexts without tenant see everything.
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+// Store keeps rows of every fake service, services of one store see each
+// other's rows like tables of one database
+type Store struct {
+	mu          sync.Mutex
+	nextID      uint
+	failures    map[string]error
+	firmwareDir string
+
+	gateways        []models.Gateway
+	areas           []models.Area
+	gatewayLogs     []models.GatewayLog
+	uhfStatusLogs   []models.UHFStatusLog
+	uhfs            []models.UHF
+	userAccesses    []models.UserAccess
+	packageAccesses []models.PackageAccess
+	systemLogs      []models.SystemLog
+	operationLogs   []models.OperationLog
+	firmwares       []models.Firmware
+	rollouts        []models.RolloutCampaign
+	targets         []models.RolloutTarget
+	templates       []models.UHFConfigTemplate
+	deadLetters     []models.DeadLetter
+	tagReadErrors   []models.TagReadError
+	organizations   []models.Organization
+	sites           []models.Site
+	apiKeys         []models.ApiKey
+	schemaVersion   int
+
+	// deleted rows, DeletedAt is set
+	trashedAreas    []models.Area
+	trashedGateways []models.Gateway
+	trashedUHFs     []models.UHF
+}
+
+// NewStore returns a store like a migrated database, default organization
+// exists and has ID 1
+func NewStore() *Store {
+	s := &Store{
+		failures:      map[string]error{},
+		schemaVersion: models.SCHEMA_VERSION,
+	}
+	s.createOrganization(&models.Organization{Name: models.DEFAULT_ORGANIZATION_NAME})
+	return s
+}
+
+// NewServiceOptions returns fake services backed by store s
+func NewServiceOptions(s *Store) *models.ServiceOptions {
+	return &models.ServiceOptions{
+		GatewaySvc:       &GatewaySvc{s},
+		AreaSvc:          &AreaSvc{s},
+		LogSvc:           &LogSvc{s},
+		UHFStatusLogSvc:  &UHFStatusLogSvc{s},
+		UHFSvc:           &UHFSvc{s},
+		UserAccessSvc:    &UserAccessSvc{s},
+		PackageAccessSvc: &PackageAccessSvc{s},
+		SystemLogSvc:     &SystemLogSvc{s},
+		OperationLogSvc:  &OperationLogSvc{s},
+		FirmwareSvc:      &FirmwareSvc{s},
+		RolloutSvc:       &RolloutSvc{s},
+		UHFConfigSvc:     &UHFConfigSvc{s},
+		DeadLetterSvc:    &DeadLetterSvc{s},
+		TagReadErrorSvc:  &TagReadErrorSvc{s},
+		TenantSvc:        &TenantSvc{s},
+		SchemaSvc:        &SchemaSvc{s},
+		ImportSvc:        &ImportSvc{s},
+		TrashSvc:         &TrashSvc{s},
+	}
+}
+
+// Fail makes method return err until it is cleared with a nil err. Method
+// is named by field of ServiceOptions and method, e.g. "GatewaySvc.FindAllGateway".
+func (s *Store) Fail(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.failures, method)
+		return
+	}
+	s.failures[method] = err
+}
+
+// SetSchemaVersion sets version SchemaSvc reports, SCHEMA_VERSION by default
+func (s *Store) SetSchemaVersion(version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemaVersion = version
+}
+
+// failure is called with s.mu held
+func (s *Store) failure(method string) error {
+	return s.failures[method]
+}
+
+func (s *Store) newID() uint {
+	s.nextID++
+	return s.nextID
+}
+
+// created sets ID and timestamps of a new row like GORM does
+func (s *Store) created(m *models.GormModel) {
+	now := time.Now()
+	m.ID = s.newID()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+}
+
+// visible reports whether tenant of ctx sees rows of organization orgId
+func visible(ctx context.Context, orgId uint) bool {
+	t, ok := models.TenantFromContext(ctx)
+	return !ok || t.System || t.OrganizationID == orgId
+}
+
+// stamp sets organization of a new row to tenant of ctx, system tenants
+// create rows on behalf of the organization already set
+func stamp(ctx context.Context, m *models.TenantModel) {
+	if t, ok := models.TenantFromContext(ctx); ok && !t.System {
+		m.OrganizationID = t.OrganizationID
+	}
+}
+
+// merge copies non-zero fields of src into dst like Updates with a struct,
+// associations are left alone, tenants can't move rows to another organization
+// and revision moves on
+func merge(ctx context.Context, dst interface{}, src interface{}) {
+	t, _ := models.TenantFromContext(ctx)
+	mergeValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), !t.System)
+	if revision := reflect.ValueOf(dst).Elem().FieldByName("Revision"); revision.IsValid() && revision.Kind() == reflect.Struct {
+		revise(ctx, revision.Addr().Interface().(*models.Revision))
+	}
+}
+
+func mergeValue(dst reflect.Value, src reflect.Value, keepTenant bool) {
+	for i := 0; i < src.NumField(); i++ {
+		f := src.Type().Field(i)
+		switch {
+		case f.Anonymous && f.Type.Kind() == reflect.Struct:
+			mergeValue(dst.Field(i), src.Field(i), keepTenant)
+			continue
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct:
+			continue
+		case f.Tag.Get("gorm") == "-", f.Name == "ID", f.Name == "CreatedAt", f.Name == "Revision":
+			continue
+		case keepTenant && f.Name == "OrganizationID":
+			continue
+		}
+		if !src.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	if updatedAt := dst.FieldByName("UpdatedAt"); updatedAt.IsValid() && updatedAt.CanSet() {
+		updatedAt.Set(reflect.ValueOf(time.Now()))
+	}
+}
+
+// revise moves revision of a row on like the revision callback does, writes
+// of a context without revision leave it
+func revise(ctx context.Context, r *models.Revision) {
+	if !models.KeepsRevision(ctx) {
+		r.Revision++
+	}
+}
+
+// checkRevision fails a write limited to revision expected of a row at r, 0
+// matches any
+func checkRevision(r models.Revision, expected uint) error {
+	if expected != 0 && r.Revision != expected {
+		return models.NewPreconditionFailedError("revision %d is outdated, fetch it again", expected)
+	}
+	return nil
+}
+
+// ID of path and query parameters, ID that is not a number matches nothing
+func parseID(id string) uint {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(n)
+}
+
+// inRange compares like "time >= from AND time <= to" on bounds formatted
+// with DEFAULT_TIME_FORMAT, bounds that don't parse match nothing
+func inRange(t time.Time, from string, to string) bool {
+	fromTime, err := time.Parse(models.DEFAULT_TIME_FORMAT, from)
+	if err != nil {
+		return false
+	}
+	toTime, err := time.Parse(models.DEFAULT_TIME_FORMAT, to)
+	if err != nil {
+		return false
+	}
+	return !t.Before(fromTime) && !t.After(toTime)
+}
+
+// Duplicate key of a unique column, classified like SQL Server error 2627
+func duplicate(format string, args ...interface{}) error {
+	return &models.DomainError{
+		Kind: models.ErrConflict,
+		Code: models.ERR_CODE_DUPLICATE,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Result of update or delete, like utils.ReturnBoolStateFromResult
+func affected(n int) (bool, error) {
+	if n == 0 {
+		return false, utils.ErrNoRecordAffected
+	}
+	return true, nil
+}
@@ -0,0 +1,49 @@
+package fakes
+
+import (
+	"context"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+// SystemLogSvc finds gateway logs like models.SystemLogSvc does
+type SystemLogSvc struct {
+	s *Store
+}
+
+func (ls *SystemLogSvc) FindAllGatewayLog(ctx context.Context) ([]models.GatewayLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("SystemLogSvc.FindAllGatewayLog"); err != nil {
+		return nil, err
+	}
+	return ls.s.findGatewayLogs(ctx, func(gl models.GatewayLog) bool { return true }), nil
+}
+
+func (ls *SystemLogSvc) FindGatewayLogByID(ctx context.Context, id string) (*models.GatewayLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("SystemLogSvc.FindGatewayLogByID"); err != nil {
+		return nil, err
+	}
+	return ls.s.findGatewayLogByID(ctx, id)
+}
+
+func (ls *SystemLogSvc) CreateSystemLog(ctx context.Context, gl *models.SystemLog) (*models.SystemLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("SystemLogSvc.CreateSystemLog"); err != nil {
+		return nil, err
+	}
+	stamp(ctx, &gl.TenantModel)
+	ls.s.created(&gl.GormModel)
+	ls.s.systemLogs = append(ls.s.systemLogs, *gl)
+	return gl, nil
+}
+
+// SystemLogs returns system logs created so far, models.SystemLogSvc has no find for them
+func (s *Store) SystemLogs() []models.SystemLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.SystemLog{}, s.systemLogs...)
+}
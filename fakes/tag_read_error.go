@@ -0,0 +1,63 @@
+package fakes
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+type TagReadErrorSvc struct {
+	s *Store
+}
+
+func (ts *TagReadErrorSvc) FindTagReadErrors(ctx context.Context, filter models.TagReadErrorFilter) ([]models.TagReadError, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TagReadErrorSvc.FindTagReadErrors"); err != nil {
+		return nil, err
+	}
+	teList := []models.TagReadError{}
+	for _, te := range ts.s.tagReadErrors {
+		if visible(ctx, te.OrganizationID) &&
+			(filter.GatewayID == "" || te.GatewayID == filter.GatewayID) &&
+			(filter.Reason == "" || te.Reason == filter.Reason) {
+			teList = append(teList, te)
+		}
+	}
+	sort.SliceStable(teList, func(i, j int) bool {
+		return teList[i].Time.After(teList[j].Time)
+	})
+	return teList, nil
+}
+
+func (ts *TagReadErrorSvc) CreateTagReadErrors(ctx context.Context, teList []models.TagReadError, batchSize int) error {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TagReadErrorSvc.CreateTagReadErrors"); err != nil {
+		return err
+	}
+	for i := range teList {
+		stamp(ctx, &teList[i].TenantModel)
+		teList[i].ID = ts.s.newID()
+		ts.s.tagReadErrors = append(ts.s.tagReadErrors, teList[i])
+	}
+	return nil
+}
+
+func (ts *TagReadErrorSvc) DeleteTagReadErrorInTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TagReadErrorSvc.DeleteTagReadErrorInTimeRange"); err != nil {
+		return false, err
+	}
+	kept := []models.TagReadError{}
+	for _, te := range ts.s.tagReadErrors {
+		if !visible(ctx, te.OrganizationID) || !inRange(te.Time, from, to) {
+			kept = append(kept, te)
+		}
+	}
+	deleted := len(ts.s.tagReadErrors) - len(kept)
+	ts.s.tagReadErrors = kept
+	return affected(deleted)
+}
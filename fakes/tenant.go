@@ -0,0 +1,266 @@
+package fakes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+type TenantSvc struct {
+	s *Store
+}
+
+func (ts *TenantSvc) FindAllOrganization(ctx context.Context) ([]models.Organization, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.FindAllOrganization"); err != nil {
+		return nil, err
+	}
+	orgList := []models.Organization{}
+	for _, org := range ts.s.organizations {
+		if visible(ctx, org.ID) {
+			orgList = append(orgList, org)
+		}
+	}
+	return orgList, nil
+}
+
+func (ts *TenantSvc) FindDefaultOrganization(ctx context.Context) (*models.Organization, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.FindDefaultOrganization"); err != nil {
+		return nil, err
+	}
+	for _, org := range ts.s.organizations {
+		if org.Name == models.DEFAULT_ORGANIZATION_NAME {
+			return &org, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (ts *TenantSvc) CreateOrganization(ctx context.Context, org *models.Organization) (*models.Organization, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.CreateOrganization"); err != nil {
+		return nil, err
+	}
+	if err := ts.s.createOrganization(org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (s *Store) createOrganization(org *models.Organization) error {
+	for _, existing := range s.organizations {
+		if existing.Name == org.Name {
+			return duplicate("organization %s already exists", org.Name)
+		}
+	}
+	s.created(&org.GormModel)
+	s.organizations = append(s.organizations, *org)
+	return nil
+}
+
+func (ts *TenantSvc) UpdateOrganization(ctx context.Context, org *models.Organization) (bool, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.UpdateOrganization"); err != nil {
+		return false, err
+	}
+	for i := range ts.s.organizations {
+		if ts.s.organizations[i].ID == org.ID {
+			merge(ctx, &ts.s.organizations[i], org)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (ts *TenantSvc) DeleteOrganization(ctx context.Context, orgId uint) (bool, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.DeleteOrganization"); err != nil {
+		return false, err
+	}
+	var cnt int64
+	for _, site := range ts.s.sites {
+		if site.OrganizationID == orgId {
+			cnt++
+		}
+	}
+	if cnt > 0 {
+		return false, models.NewConflictError("organization still has %d sites", cnt)
+	}
+	for _, gateways := range [][]models.Gateway{ts.s.gateways, ts.s.trashedGateways} {
+		for _, gw := range gateways {
+			if gw.OrganizationID == orgId {
+				cnt++
+			}
+		}
+	}
+	if cnt > 0 {
+		return false, models.NewConflictError("organization still has %d gateways", cnt)
+	}
+	kept := []models.ApiKey{}
+	for _, key := range ts.s.apiKeys {
+		if key.OrganizationID != orgId {
+			kept = append(kept, key)
+		}
+	}
+	ts.s.apiKeys = kept
+	for i, org := range ts.s.organizations {
+		if org.ID == orgId {
+			ts.s.organizations = append(ts.s.organizations[:i], ts.s.organizations[i+1:]...)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (ts *TenantSvc) FindAllSite(ctx context.Context) ([]models.Site, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.FindAllSite"); err != nil {
+		return nil, err
+	}
+	siteList := []models.Site{}
+	for _, site := range ts.s.sites {
+		if visible(ctx, site.OrganizationID) {
+			siteList = append(siteList, site)
+		}
+	}
+	return siteList, nil
+}
+
+func (ts *TenantSvc) FindSiteByCode(ctx context.Context, code string) (*models.Site, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.FindSiteByCode"); err != nil {
+		return nil, err
+	}
+	for _, site := range ts.s.sites {
+		if site.Code == code && visible(ctx, site.OrganizationID) {
+			return &site, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (ts *TenantSvc) CreateSite(ctx context.Context, site *models.Site) (*models.Site, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.CreateSite"); err != nil {
+		return nil, err
+	}
+	for _, existing := range ts.s.sites {
+		if existing.Code == site.Code {
+			return nil, duplicate("site %s already exists", site.Code)
+		}
+	}
+	stamp(ctx, &site.TenantModel)
+	ts.s.created(&site.GormModel)
+	ts.s.sites = append(ts.s.sites, *site)
+	return site, nil
+}
+
+func (ts *TenantSvc) UpdateSite(ctx context.Context, site *models.Site) (bool, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.UpdateSite"); err != nil {
+		return false, err
+	}
+	for i := range ts.s.sites {
+		if ts.s.sites[i].ID == site.ID && visible(ctx, ts.s.sites[i].OrganizationID) {
+			merge(ctx, &ts.s.sites[i], site)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (ts *TenantSvc) DeleteSite(ctx context.Context, siteId uint) (bool, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.DeleteSite"); err != nil {
+		return false, err
+	}
+	for i, site := range ts.s.sites {
+		if site.ID == siteId && visible(ctx, site.OrganizationID) {
+			ts.s.sites = append(ts.s.sites[:i], ts.s.sites[i+1:]...)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (ts *TenantSvc) FindAllApiKey(ctx context.Context) ([]models.ApiKey, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.FindAllApiKey"); err != nil {
+		return nil, err
+	}
+	keyList := []models.ApiKey{}
+	for _, key := range ts.s.apiKeys {
+		if visible(ctx, key.OrganizationID) {
+			keyList = append(keyList, key)
+		}
+	}
+	return keyList, nil
+}
+
+func (ts *TenantSvc) CreateApiKey(ctx context.Context, key *models.ApiKey) (*models.CreatedApiKey, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.CreateApiKey"); err != nil {
+		return nil, err
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	raw := hex.EncodeToString(b)
+	key.Prefix = raw[:8]
+	key.KeyHash = models.HashApiKey(raw)
+	stamp(ctx, &key.TenantModel)
+	ts.s.created(&key.GormModel)
+	ts.s.apiKeys = append(ts.s.apiKeys, *key)
+	return &models.CreatedApiKey{ApiKey: *key, Key: raw}, nil
+}
+
+func (ts *TenantSvc) DeleteApiKey(ctx context.Context, keyId uint) (bool, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.DeleteApiKey"); err != nil {
+		return false, err
+	}
+	for i, key := range ts.s.apiKeys {
+		if key.ID == keyId && visible(ctx, key.OrganizationID) {
+			ts.s.apiKeys = append(ts.s.apiKeys[:i], ts.s.apiKeys[i+1:]...)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (ts *TenantSvc) AuthenticateApiKey(ctx context.Context, raw string) (*models.ApiKey, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TenantSvc.AuthenticateApiKey"); err != nil {
+		return nil, err
+	}
+	hash := models.HashApiKey(raw)
+	for i := range ts.s.apiKeys {
+		if ts.s.apiKeys[i].KeyHash == hash {
+			now := time.Now()
+			ts.s.apiKeys[i].LastUsedAt = &now
+			key := ts.s.apiKeys[i]
+			return &key, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
@@ -0,0 +1,177 @@
+package fakes
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"gorm.io/gorm"
+)
+
+type TrashSvc struct {
+	s *Store
+}
+
+func (ts *TrashSvc) FindTrash(ctx context.Context) (*models.Trash, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TrashSvc.FindTrash"); err != nil {
+		return nil, err
+	}
+	t := &models.Trash{Areas: []models.Area{}, Gateways: []models.Gateway{}, UHFs: []models.UHF{}}
+	for _, a := range ts.s.trashedAreas {
+		if visible(ctx, a.OrganizationID) {
+			t.Areas = append(t.Areas, a)
+		}
+	}
+	for _, gw := range ts.s.trashedGateways {
+		if visible(ctx, gw.OrganizationID) {
+			t.Gateways = append(t.Gateways, gw)
+		}
+	}
+	for _, uhf := range ts.s.trashedUHFs {
+		if visible(ctx, uhf.OrganizationID) {
+			t.UHFs = append(t.UHFs, uhf)
+		}
+	}
+	return t, nil
+}
+
+// restored clears deletion of a row and moves its revision on like the
+// update of deleted_at does
+func restored(ctx context.Context, d *gorm.DeletedAt, r *models.Revision, updatedAt *time.Time) {
+	*d = gorm.DeletedAt{}
+	revise(ctx, r)
+	*updatedAt = time.Now()
+}
+
+func notInTrash(what string, id string) error {
+	return models.NewNotFoundError("%s %s is not in trash", what, id)
+}
+
+func (ts *TrashSvc) RestoreArea(ctx context.Context, id string) (*models.Area, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TrashSvc.RestoreArea"); err != nil {
+		return nil, err
+	}
+	for i, a := range ts.s.trashedAreas {
+		if a.ID == parseID(id) && visible(ctx, a.OrganizationID) {
+			restored(ctx, &a.DeletedAt, &a.Revision, &a.UpdatedAt)
+			ts.s.areas = append(ts.s.areas, a)
+			ts.s.trashedAreas = append(ts.s.trashedAreas[:i], ts.s.trashedAreas[i+1:]...)
+			return &a, nil
+		}
+	}
+	return nil, notInTrash(models.TRASH_AREA, id)
+}
+
+func (ts *TrashSvc) FindTrashedGateway(ctx context.Context, gwId string) (*models.Gateway, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TrashSvc.FindTrashedGateway"); err != nil {
+		return nil, err
+	}
+	i := ts.s.trashedGateway(ctx, gwId)
+	if i < 0 {
+		return nil, notInTrash(models.TRASH_GATEWAY, gwId)
+	}
+	gw := ts.s.trashedGateways[i]
+	return &gw, nil
+}
+
+func (ts *TrashSvc) RestoreGateway(ctx context.Context, gwId string) (*models.Gateway, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TrashSvc.RestoreGateway"); err != nil {
+		return nil, err
+	}
+	i := ts.s.trashedGateway(ctx, gwId)
+	if i < 0 {
+		return nil, notInTrash(models.TRASH_GATEWAY, gwId)
+	}
+	gw := ts.s.trashedGateways[i]
+	// UHFs deleted along with gateway come back, those deleted before stay
+	kept := []models.UHF{}
+	for _, uhf := range ts.s.trashedUHFs {
+		if uhf.GatewayID == gwId && uhf.DeletedAt.Time.Equal(gw.DeletedAt.Time) {
+			restored(ctx, &uhf.DeletedAt, &uhf.Revision, &uhf.UpdatedAt)
+			ts.s.uhfs = append(ts.s.uhfs, uhf)
+			continue
+		}
+		kept = append(kept, uhf)
+	}
+	ts.s.trashedUHFs = kept
+	restored(ctx, &gw.DeletedAt, &gw.Revision, &gw.UpdatedAt)
+	gw.TrashedBy = ""
+	ts.s.gateways = append(ts.s.gateways, gw)
+	ts.s.trashedGateways = append(ts.s.trashedGateways[:i], ts.s.trashedGateways[i+1:]...)
+	gw = ts.s.loadGateway(ctx, gw)
+	return &gw, nil
+}
+
+func (ts *TrashSvc) RestoreUHF(ctx context.Context, id string) (*models.UHF, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TrashSvc.RestoreUHF"); err != nil {
+		return nil, err
+	}
+	for i, uhf := range ts.s.trashedUHFs {
+		if uhf.ID != parseID(id) || !visible(ctx, uhf.OrganizationID) {
+			continue
+		}
+		if ts.s.gateway(ctx, uhf.GatewayID) < 0 {
+			return nil, models.NewConflictError("gateway %s of UHF %s is not live, restore it first", uhf.GatewayID, id)
+		}
+		if ts.s.uhf(ctx, uhf.UHFAddress, uhf.GatewayID) >= 0 {
+			return nil, models.NewConflictError("gateway %s already has a UHF at address %s", uhf.GatewayID, uhf.UHFAddress)
+		}
+		restored(ctx, &uhf.DeletedAt, &uhf.Revision, &uhf.UpdatedAt)
+		ts.s.uhfs = append(ts.s.uhfs, uhf)
+		ts.s.trashedUHFs = append(ts.s.trashedUHFs[:i], ts.s.trashedUHFs[i+1:]...)
+		return &uhf, nil
+	}
+	return nil, notInTrash(models.TRASH_UHF, id)
+}
+
+func (ts *TrashSvc) PurgeTrash(ctx context.Context, item *models.TrashItem) (bool, error) {
+	ts.s.mu.Lock()
+	defer ts.s.mu.Unlock()
+	if err := ts.s.failure("TrashSvc.PurgeTrash"); err != nil {
+		return false, err
+	}
+	if err := item.Validate(); err != nil {
+		return false, err
+	}
+	switch item.Type {
+	case models.TRASH_AREA:
+		for i, a := range ts.s.trashedAreas {
+			if a.ID == parseID(item.ID) && visible(ctx, a.OrganizationID) {
+				ts.s.trashedAreas = append(ts.s.trashedAreas[:i], ts.s.trashedAreas[i+1:]...)
+				return true, nil
+			}
+		}
+	case models.TRASH_GATEWAY:
+		if i := ts.s.trashedGateway(ctx, item.ID); i >= 0 {
+			gw := ts.s.trashedGateways[i]
+			kept := []models.UHF{}
+			for _, uhf := range ts.s.trashedUHFs {
+				if uhf.GatewayID == gw.GatewayID && uhf.DeletedAt.Time.Equal(gw.DeletedAt.Time) {
+					continue
+				}
+				kept = append(kept, uhf)
+			}
+			ts.s.trashedUHFs = kept
+			ts.s.trashedGateways = append(ts.s.trashedGateways[:i], ts.s.trashedGateways[i+1:]...)
+			return true, nil
+		}
+	default:
+		for i, uhf := range ts.s.trashedUHFs {
+			if uhf.ID == parseID(item.ID) && visible(ctx, uhf.OrganizationID) {
+				ts.s.trashedUHFs = append(ts.s.trashedUHFs[:i], ts.s.trashedUHFs[i+1:]...)
+				return true, nil
+			}
+		}
+	}
+	return false, notInTrash(item.Type, item.ID)
+}
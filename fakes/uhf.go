@@ -0,0 +1,258 @@
+package fakes
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+type UHFSvc struct {
+	s *Store
+}
+
+// uhf returns index of UHF at address of gateway gwID tenant of ctx sees, -1 when there is none
+func (s *Store) uhf(ctx context.Context, address string, gwID string) int {
+	for i, uhf := range s.uhfs {
+		if uhf.UHFAddress == address && uhf.GatewayID == gwID && visible(ctx, uhf.OrganizationID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) findUHFs(ctx context.Context, match func(uhf models.UHF) bool) []models.UHF {
+	uhfList := []models.UHF{}
+	for _, uhf := range s.uhfs {
+		if visible(ctx, uhf.OrganizationID) && match(uhf) {
+			uhf.AfterFind(nil)
+			uhfList = append(uhfList, uhf)
+		}
+	}
+	return uhfList
+}
+
+func (us *UHFSvc) FindAllUHF(ctx context.Context) ([]models.UHF, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.FindAllUHF"); err != nil {
+		return nil, err
+	}
+	return us.s.findUHFs(ctx, func(uhf models.UHF) bool { return true }), nil
+}
+
+func (us *UHFSvc) FindUHFsBySelector(ctx context.Context, s models.BulkSelector) ([]models.UHF, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.FindUHFsBySelector"); err != nil {
+		return nil, err
+	}
+	return us.s.findUHFs(ctx, func(uhf models.UHF) bool { return s.MatchUHF(&uhf) }), nil
+}
+
+func (us *UHFSvc) FindUHFByID(ctx context.Context, id string) (*models.UHF, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.FindUHFByID"); err != nil {
+		return nil, err
+	}
+	uhfList := us.s.findUHFs(ctx, func(uhf models.UHF) bool { return uhf.ID == parseID(id) })
+	if len(uhfList) == 0 {
+		return nil, utils.ErrRecordNotFound
+	}
+	return &uhfList[0], nil
+}
+
+func (us *UHFSvc) FindUHFByAddress(ctx context.Context, address string, gwID string) (*models.UHF, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.FindUHFByAddress"); err != nil {
+		return nil, err
+	}
+	i := us.s.uhf(ctx, address, gwID)
+	if i < 0 {
+		return nil, models.NewNotFoundError("uhf %s of gateway %s not found", address, gwID)
+	}
+	uhf := us.s.uhfs[i]
+	uhf.AfterFind(nil)
+	return &uhf, nil
+}
+
+func (us *UHFSvc) UpdateUHF(ctx context.Context, dl *models.UHF) (bool, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.UpdateUHF"); err != nil {
+		return false, err
+	}
+	i := us.s.uhf(ctx, dl.UHFAddress, dl.GatewayID)
+	if i < 0 {
+		return affected(0)
+	}
+	if err := checkRevision(us.s.uhfs[i].Revision, dl.Revision.Revision); err != nil {
+		return false, err
+	}
+	merge(ctx, &us.s.uhfs[i], dl)
+	return affected(1)
+}
+
+func (us *UHFSvc) UpdateUHFWithDesiredState(ctx context.Context, dl *models.UHF, state string) (bool, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.UpdateUHFWithDesiredState"); err != nil {
+		return false, err
+	}
+	i := us.s.uhf(ctx, dl.UHFAddress, dl.GatewayID)
+	if i < 0 {
+		return affected(0)
+	}
+	if err := checkRevision(us.s.uhfs[i].Revision, dl.Revision.Revision); err != nil {
+		return false, err
+	}
+	dl.DesiredState = state
+	merge(ctx, &us.s.uhfs[i], dl)
+	us.s.uhfs[i].SyncAttempts = 0
+	return affected(1)
+}
+
+func (us *UHFSvc) UpdateUHFByAddress(ctx context.Context, dl *models.UHF) (bool, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.UpdateUHFByAddress"); err != nil {
+		return false, err
+	}
+	i := us.s.uhf(ctx, dl.UHFAddress, dl.GatewayID)
+	if i < 0 {
+		return affected(0)
+	}
+	merge(ctx, &us.s.uhfs[i], dl)
+	return affected(1)
+}
+
+func (us *UHFSvc) DeleteUHF(ctx context.Context, id string, revision uint) (bool, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.DeleteUHF"); err != nil {
+		return false, err
+	}
+	for i, uhf := range us.s.uhfs {
+		if uhf.ID == parseID(id) && visible(ctx, uhf.OrganizationID) {
+			if err := checkRevision(uhf.Revision, revision); err != nil {
+				return false, err
+			}
+			uhf.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+			us.s.trashedUHFs = append(us.s.trashedUHFs, uhf)
+			us.s.uhfs = append(us.s.uhfs[:i], us.s.uhfs[i+1:]...)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+// Rooms are areas of UHFs in the fake, UHF table has no room column
+func (us *UHFSvc) FindAllUHFByRoomID(ctx context.Context, roomId string) ([]*models.UHF, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.FindAllUHFByRoomID"); err != nil {
+		return nil, err
+	}
+	uhfList := us.s.findUHFs(ctx, func(uhf models.UHF) bool { return uhf.AreaId == roomId })
+	if len(uhfList) == 0 {
+		return nil, models.NewNotFoundError("no uhf in room %s", roomId)
+	}
+	dl := make([]*models.UHF, len(uhfList))
+	for i := range uhfList {
+		dl[i] = &uhfList[i]
+	}
+	return dl, nil
+}
+
+func (us *UHFSvc) FindAllUHFByGatewayID(ctx context.Context, gwId string) ([]models.UHF, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.FindAllUHFByGatewayID"); err != nil {
+		return nil, err
+	}
+	return us.s.findUHFs(ctx, func(uhf models.UHF) bool { return uhf.GatewayID == gwId }), nil
+}
+
+func (us *UHFSvc) CreateUHF(ctx context.Context, dl *models.UHF) (*models.UHF, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.CreateUHF"); err != nil {
+		return nil, err
+	}
+	for _, uhfs := range [][]models.UHF{us.s.uhfs, us.s.trashedUHFs} {
+		for _, uhf := range uhfs {
+			if uhf.UHFSerialNumber == dl.UHFSerialNumber {
+				return nil, duplicate("uhf serial number %s already exists", dl.UHFSerialNumber)
+			}
+		}
+	}
+	stamp(ctx, &dl.TenantModel)
+	dl.Revision = models.Revision{Revision: 1}
+	us.s.created(&dl.GormModel)
+	us.s.uhfs = append(us.s.uhfs, *dl)
+	return dl, nil
+}
+
+func (us *UHFSvc) FindDriftedUHFs(ctx context.Context) ([]models.UHF, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.FindDriftedUHFs"); err != nil {
+		return nil, err
+	}
+	return us.s.findUHFs(ctx, func(uhf models.UHF) bool { return uhf.Drifted() }), nil
+}
+
+func (us *UHFSvc) CountActiveUHFs(ctx context.Context) (int64, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UHFSvc.CountActiveUHFs"); err != nil {
+		return 0, err
+	}
+	return int64(len(us.s.findUHFs(ctx, func(uhf models.UHF) bool { return uhf.ActiveState == "active" }))), nil
+}
+
+func (us *UHFSvc) UpdateUHFDesiredState(ctx context.Context, address string, gwID string, state string) (bool, error) {
+	return us.updateUHF(ctx, "UHFSvc.UpdateUHFDesiredState", address, gwID, func(uhf *models.UHF) {
+		uhf.DesiredState = state
+		uhf.SyncAttempts = 0
+	})
+}
+
+func (us *UHFSvc) UpdateUHFReportedState(ctx context.Context, address string, gwID string, state string) (bool, error) {
+	return us.updateUHF(ctx, "UHFSvc.UpdateUHFReportedState", address, gwID, func(uhf *models.UHF) {
+		now := time.Now()
+		uhf.ReportedState = state
+		uhf.ActiveState = state
+		uhf.ReportedAt = &now
+		if uhf.DesiredState == state {
+			uhf.SyncAttempts = 0
+		}
+	})
+}
+
+func (us *UHFSvc) MarkUHFSyncAttempt(ctx context.Context, address string, gwID string) (bool, error) {
+	return us.updateUHF(ctx, "UHFSvc.MarkUHFSyncAttempt", address, gwID, func(uhf *models.UHF) {
+		now := time.Now()
+		uhf.SyncAttempts++
+		uhf.LastSyncAt = &now
+	})
+}
+
+func (us *UHFSvc) updateUHF(ctx context.Context, method string, address string, gwID string, update func(uhf *models.UHF)) (bool, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure(method); err != nil {
+		return false, err
+	}
+	i := us.s.uhf(ctx, address, gwID)
+	if i < 0 {
+		return affected(0)
+	}
+	update(&us.s.uhfs[i])
+	revise(ctx, &us.s.uhfs[i].Revision)
+	return affected(1)
+}
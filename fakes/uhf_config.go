@@ -0,0 +1,149 @@
+package fakes
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+type UHFConfigSvc struct {
+	s *Store
+}
+
+func (cs *UHFConfigSvc) FindAllTemplate(ctx context.Context) ([]models.UHFConfigTemplate, error) {
+	cs.s.mu.Lock()
+	defer cs.s.mu.Unlock()
+	if err := cs.s.failure("UHFConfigSvc.FindAllTemplate"); err != nil {
+		return nil, err
+	}
+	tList := []models.UHFConfigTemplate{}
+	for _, t := range cs.s.templates {
+		if visible(ctx, t.OrganizationID) {
+			tList = append(tList, t)
+		}
+	}
+	return tList, nil
+}
+
+func (cs *UHFConfigSvc) FindTemplateByID(ctx context.Context, id string) (*models.UHFConfigTemplate, error) {
+	cs.s.mu.Lock()
+	defer cs.s.mu.Unlock()
+	if err := cs.s.failure("UHFConfigSvc.FindTemplateByID"); err != nil {
+		return nil, err
+	}
+	for _, t := range cs.s.templates {
+		if t.ID == parseID(id) && visible(ctx, t.OrganizationID) {
+			return &t, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (cs *UHFConfigSvc) CreateTemplate(ctx context.Context, t *models.UHFConfigTemplate) (*models.UHFConfigTemplate, error) {
+	if err := t.Config.Validate(); err != nil {
+		return nil, err
+	}
+	cs.s.mu.Lock()
+	defer cs.s.mu.Unlock()
+	if err := cs.s.failure("UHFConfigSvc.CreateTemplate"); err != nil {
+		return nil, err
+	}
+	stamp(ctx, &t.TenantModel)
+	for _, existing := range cs.s.templates {
+		if existing.OrganizationID == t.OrganizationID && existing.Name == t.Name {
+			return nil, duplicate("template %s already exists", t.Name)
+		}
+	}
+	cs.s.created(&t.GormModel)
+	cs.s.templates = append(cs.s.templates, *t)
+	return t, nil
+}
+
+func (cs *UHFConfigSvc) UpdateTemplate(ctx context.Context, t *models.UHFConfigTemplate) (bool, error) {
+	if err := t.Config.Validate(); err != nil {
+		return false, err
+	}
+	cs.s.mu.Lock()
+	defer cs.s.mu.Unlock()
+	if err := cs.s.failure("UHFConfigSvc.UpdateTemplate"); err != nil {
+		return false, err
+	}
+	for i := range cs.s.templates {
+		if cs.s.templates[i].ID == t.ID && visible(ctx, cs.s.templates[i].OrganizationID) {
+			merge(ctx, &cs.s.templates[i], t)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (cs *UHFConfigSvc) DeleteTemplate(ctx context.Context, id uint) (bool, error) {
+	cs.s.mu.Lock()
+	defer cs.s.mu.Unlock()
+	if err := cs.s.failure("UHFConfigSvc.DeleteTemplate"); err != nil {
+		return false, err
+	}
+	for i, t := range cs.s.templates {
+		if t.ID == id && visible(ctx, t.OrganizationID) {
+			cs.s.templates = append(cs.s.templates[:i], cs.s.templates[i+1:]...)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (cs *UHFConfigSvc) UpdateUHFDesiredConfig(ctx context.Context, uhfId uint, revision uint, config models.UHFReaderConfig, templateId *uint) (bool, error) {
+	if err := config.Validate(); err != nil {
+		return false, err
+	}
+	cs.s.mu.Lock()
+	defer cs.s.mu.Unlock()
+	if err := cs.s.failure("UHFConfigSvc.UpdateUHFDesiredConfig"); err != nil {
+		return false, err
+	}
+	for i := range cs.s.uhfs {
+		uhf := &cs.s.uhfs[i]
+		if uhf.ID == uhfId && visible(ctx, uhf.OrganizationID) {
+			if err := checkRevision(uhf.Revision, revision); err != nil {
+				return false, err
+			}
+			config.Normalize()
+			uhf.DesiredConfig = &config
+			uhf.ConfigTemplateID = templateId
+			revise(ctx, &uhf.Revision)
+			return affected(1)
+		}
+	}
+	return affected(0)
+}
+
+func (cs *UHFConfigSvc) UpdateUHFReportedConfig(ctx context.Context, address string, gwID string, config models.UHFReaderConfig) (bool, error) {
+	cs.s.mu.Lock()
+	defer cs.s.mu.Unlock()
+	if err := cs.s.failure("UHFConfigSvc.UpdateUHFReportedConfig"); err != nil {
+		return false, err
+	}
+	i := cs.s.uhf(ctx, address, gwID)
+	if i < 0 {
+		return affected(0)
+	}
+	now := time.Now()
+	config.Normalize()
+	cs.s.uhfs[i].ReportedConfig = &config
+	cs.s.uhfs[i].ConfigReportedAt = &now
+	revise(ctx, &cs.s.uhfs[i].Revision)
+	return affected(1)
+}
+
+func (cs *UHFConfigSvc) FindConfigMismatchedUHFs(ctx context.Context) ([]models.UHF, error) {
+	cs.s.mu.Lock()
+	defer cs.s.mu.Unlock()
+	if err := cs.s.failure("UHFConfigSvc.FindConfigMismatchedUHFs"); err != nil {
+		return nil, err
+	}
+	return cs.s.findUHFs(ctx, func(uhf models.UHF) bool {
+		return uhf.DesiredConfig != nil && !uhf.DesiredConfig.Equal(uhf.ReportedConfig)
+	}), nil
+}
@@ -0,0 +1,125 @@
+package fakes
+
+import (
+	"context"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+type UHFStatusLogSvc struct {
+	s *Store
+}
+
+func (s *Store) findUHFStatusLogs(ctx context.Context, match func(l models.UHFStatusLog) bool) []models.UHFStatusLog {
+	lList := []models.UHFStatusLog{}
+	for _, l := range s.uhfStatusLogs {
+		if visible(ctx, l.OrganizationID) && match(l) {
+			lList = append(lList, l)
+		}
+	}
+	return lList
+}
+
+func (ls *UHFStatusLogSvc) GetAllUHFStatusLogs(ctx context.Context) ([]models.UHFStatusLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("UHFStatusLogSvc.GetAllUHFStatusLogs"); err != nil {
+		return nil, err
+	}
+	return ls.s.findUHFStatusLogs(ctx, func(l models.UHFStatusLog) bool { return true }), nil
+}
+
+func (ls *UHFStatusLogSvc) GetUHFStatusLogByID(ctx context.Context, id string) (*models.UHFStatusLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("UHFStatusLogSvc.GetUHFStatusLogByID"); err != nil {
+		return nil, err
+	}
+	for _, l := range ls.s.uhfStatusLogs {
+		if l.ID == parseID(id) && visible(ctx, l.OrganizationID) {
+			return &l, nil
+		}
+	}
+	return nil, utils.ErrRecordNotFound
+}
+
+func (ls *UHFStatusLogSvc) GetUHFStatusLogByUHFAddress(ctx context.Context, uhf_address string, gateway_id string) ([]models.UHFStatusLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("UHFStatusLogSvc.GetUHFStatusLogByUHFAddress"); err != nil {
+		return nil, err
+	}
+	return ls.s.findUHFStatusLogs(ctx, func(l models.UHFStatusLog) bool {
+		return l.UHFAddress == uhf_address && l.GatewayID == gateway_id
+	}), nil
+}
+
+func (ls *UHFStatusLogSvc) CreateUHFStatusLog(ctx context.Context, dlsl *models.UHFStatusLog) (*models.UHFStatusLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("UHFStatusLogSvc.CreateUHFStatusLog"); err != nil {
+		return nil, err
+	}
+	ls.s.createUHFStatusLog(ctx, dlsl)
+	return dlsl, nil
+}
+
+func (ls *UHFStatusLogSvc) CreateUHFStatusLogs(ctx context.Context, dlslList []models.UHFStatusLog, batchSize int) error {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("UHFStatusLogSvc.CreateUHFStatusLogs"); err != nil {
+		return err
+	}
+	for i := range dlslList {
+		ls.s.createUHFStatusLog(ctx, &dlslList[i])
+	}
+	return nil
+}
+
+func (s *Store) createUHFStatusLog(ctx context.Context, l *models.UHFStatusLog) {
+	stamp(ctx, &l.TenantModel)
+	l.ID = s.newID()
+	s.uhfStatusLogs = append(s.uhfStatusLogs, *l)
+}
+
+func (ls *UHFStatusLogSvc) GetUHFStatusLogBYGatewayIDAndUHFAddressInTimeRange(ctx context.Context, from string, to string, gateway_id string, uhf_address string) (*[]models.UHFStatusLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("UHFStatusLogSvc.GetUHFStatusLogBYGatewayIDAndUHFAddressInTimeRange"); err != nil {
+		return nil, err
+	}
+	lList := ls.s.findUHFStatusLogs(ctx, func(l models.UHFStatusLog) bool {
+		return l.GatewayID == gateway_id && l.UHFAddress == uhf_address && inRange(l.Time, from, to)
+	})
+	return &lList, nil
+}
+
+func (ls *UHFStatusLogSvc) GetUHFStatusLogInTimeRange(ctx context.Context, from string, to string) (*[]models.UHFStatusLog, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("UHFStatusLogSvc.GetUHFStatusLogInTimeRange"); err != nil {
+		return nil, err
+	}
+	lList := ls.s.findUHFStatusLogs(ctx, func(l models.UHFStatusLog) bool {
+		return inRange(l.Time, from, to)
+	})
+	return &lList, nil
+}
+
+func (ls *UHFStatusLogSvc) DeleteUHFLogInTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	ls.s.mu.Lock()
+	defer ls.s.mu.Unlock()
+	if err := ls.s.failure("UHFStatusLogSvc.DeleteUHFLogInTimeRange"); err != nil {
+		return false, err
+	}
+	kept := []models.UHFStatusLog{}
+	for _, l := range ls.s.uhfStatusLogs {
+		if !visible(ctx, l.OrganizationID) || !inRange(l.Time, from, to) {
+			kept = append(kept, l)
+		}
+	}
+	deleted := len(ls.s.uhfStatusLogs) - len(kept)
+	ls.s.uhfStatusLogs = kept
+	return affected(deleted)
+}
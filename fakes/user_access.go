@@ -0,0 +1,167 @@
+package fakes
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+type UserAccessSvc struct {
+	s *Store
+}
+
+func (s *Store) findUserAccesses(ctx context.Context, match func(ua models.UserAccess) bool) []models.UserAccess {
+	uaList := []models.UserAccess{}
+	for _, ua := range s.userAccesses {
+		if visible(ctx, ua.OrganizationID) && match(ua) {
+			uaList = append(uaList, ua)
+		}
+	}
+	return uaList
+}
+
+func (us *UserAccessSvc) CreateUserAccess(ctx context.Context, user_acesses *models.UserAccess) (*models.UserAccess, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.CreateUserAccess"); err != nil {
+		return nil, err
+	}
+	us.s.createUserAccess(ctx, user_acesses)
+	return user_acesses, nil
+}
+
+func (us *UserAccessSvc) CreateUserAccesses(ctx context.Context, user_acesses []models.UserAccess, batchSize int) error {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.CreateUserAccesses"); err != nil {
+		return err
+	}
+	for i := range user_acesses {
+		us.s.createUserAccess(ctx, &user_acesses[i])
+	}
+	return nil
+}
+
+func (s *Store) createUserAccess(ctx context.Context, ua *models.UserAccess) {
+	stamp(ctx, &ua.TenantModel)
+	ua.ID = s.newID()
+	s.userAccesses = append(s.userAccesses, *ua)
+}
+
+func (us *UserAccessSvc) FindAllUserAccess(ctx context.Context) ([]models.UserAccess, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.FindAllUserAccess"); err != nil {
+		return nil, err
+	}
+	return us.s.findUserAccesses(ctx, func(ua models.UserAccess) bool { return true }), nil
+}
+
+func (us *UserAccessSvc) FindAllUserAccessByUserID(ctx context.Context, id string) ([]models.UserAccess, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.FindAllUserAccessByUserID"); err != nil {
+		return nil, err
+	}
+	return us.s.findUserAccesses(ctx, func(ua models.UserAccess) bool {
+		return ua.UserID == id
+	}), nil
+}
+
+func (us *UserAccessSvc) FindAllUserAccessByUserIDAndAreaID(ctx context.Context, id string, area_id string) ([]models.UserAccess, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.FindAllUserAccessByUserIDAndAreaID"); err != nil {
+		return nil, err
+	}
+	return us.s.findUserAccesses(ctx, func(ua models.UserAccess) bool {
+		return ua.UserID == id && ua.AreaID == area_id
+	}), nil
+}
+
+func (us *UserAccessSvc) FindUserAccessesByUserIDAndTimeRange(ctx context.Context, user_id string, from string, to string) (*[]models.UserAccess, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.FindUserAccessesByUserIDAndTimeRange"); err != nil {
+		return nil, err
+	}
+	uaList := us.s.findUserAccesses(ctx, func(ua models.UserAccess) bool {
+		return ua.UserID == user_id && inRange(ua.Time, from, to)
+	})
+	return &uaList, nil
+}
+
+func (us *UserAccessSvc) FindAllUserAccessByUserIDAndAreaIDinTimeRange(ctx context.Context, id string, area_id string, from string, to string) ([]models.UserAccess, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.FindAllUserAccessByUserIDAndAreaIDinTimeRange"); err != nil {
+		return nil, err
+	}
+	return us.s.findUserAccesses(ctx, func(ua models.UserAccess) bool {
+		return ua.UserID == id && ua.AreaID == area_id && inRange(ua.Time, from, to)
+	}), nil
+}
+
+func (us *UserAccessSvc) FindAllUserAccessByAreaID(ctx context.Context, area_id string) ([]models.UserAccess, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.FindAllUserAccessByAreaID"); err != nil {
+		return nil, err
+	}
+	return us.s.findUserAccesses(ctx, func(ua models.UserAccess) bool {
+		return ua.AreaID == area_id
+	}), nil
+}
+
+func (us *UserAccessSvc) FindAllUserAccessByAreaIDAndTimeRange(ctx context.Context, area_id string, from string, to string) ([]models.UserAccess, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.FindAllUserAccessByAreaIDAndTimeRange"); err != nil {
+		return nil, err
+	}
+	return us.s.findUserAccesses(ctx, func(ua models.UserAccess) bool {
+		return ua.AreaID == area_id && inRange(ua.Time, from, to)
+	}), nil
+}
+
+func (us *UserAccessSvc) FindAllUserAccessTimeRange(ctx context.Context, from string, to string) ([]models.UserAccess, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.FindAllUserAccessTimeRange"); err != nil {
+		return nil, err
+	}
+	return us.s.findUserAccesses(ctx, func(ua models.UserAccess) bool {
+		return inRange(ua.Time, from, to)
+	}), nil
+}
+
+func (us *UserAccessSvc) CountUserAccessesByArea(ctx context.Context, since time.Time) (map[string]int64, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.CountUserAccessesByArea"); err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, ua := range us.s.findUserAccesses(ctx, func(ua models.UserAccess) bool { return !ua.Time.Before(since) }) {
+		counts[ua.AreaID]++
+	}
+	return counts, nil
+}
+
+func (us *UserAccessSvc) DeleteUserAccessTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	us.s.mu.Lock()
+	defer us.s.mu.Unlock()
+	if err := us.s.failure("UserAccessSvc.DeleteUserAccessTimeRange"); err != nil {
+		return false, err
+	}
+	kept := []models.UserAccess{}
+	for _, ua := range us.s.userAccesses {
+		if !visible(ctx, ua.OrganizationID) || !inRange(ua.Time, from, to) {
+			kept = append(kept, ua)
+		}
+	}
+	deleted := len(us.s.userAccesses) - len(kept)
+	us.s.userAccesses = kept
+	return affected(deleted)
+}
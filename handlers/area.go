@@ -28,13 +28,9 @@ func NewAreaHandler(deps *HandlerDependencies) *AreaHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/areas [get]
 func (h *AreaHandler) FindAllArea(c *gin.Context) {
-	aList, err := h.deps.SvcOpts.AreaSvc.FindAllArea(c)
+	aList, err := h.deps.SvcOpts.AreaSvc.FindAllArea(c.Request.Context())
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all areas failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all areas failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, aList)
@@ -47,20 +43,18 @@ func (h *AreaHandler) FindAllArea(c *gin.Context) {
 // @Produce json
 // @Param        id	path	string	true	"Area ID"
 // @Success 200 {object} models.Area
+// @Header 200 {string} ETag "Revision of area, send it back in If-Match"
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/area/{id} [get]
 func (h *AreaHandler) FindAreaByID(c *gin.Context) {
 	id := c.Param("id")
 
-	a, err := h.deps.SvcOpts.AreaSvc.FindAreaByID(c, id)
+	a, err := h.deps.SvcOpts.AreaSvc.FindAreaByID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get area failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get area failed", err)
 		return
 	}
+	c.Header("ETag", a.ETag())
 	utils.ResponseJson(c, http.StatusOK, a)
 }
 
@@ -78,20 +72,12 @@ func (h *AreaHandler) CreateArea(c *gin.Context) {
 	a := &models.Area{}
 	err := c.ShouldBind(a)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Invalid req body",
-			ErrorMsg:   err.Error(),
-		})
+		responseBindError(c, "Invalid req body", err)
 		return
 	}
 	a, err = h.deps.SvcOpts.AreaSvc.CreateArea(a, c.Request.Context())
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Create area failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Create area failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, a)
@@ -100,31 +86,31 @@ func (h *AreaHandler) CreateArea(c *gin.Context) {
 // Update area
 // @Summary Update Area By ID
 // @Schemes
-// @Description Update area, must have "id" field
+// @Description Update area, must have "id" field and If-Match header with ETag of the area
 // @Accept  json
 // @Produce json
+// @Param	If-Match	header	string	true	"ETag of area as read, * for any"
 // @Param	data	body	models.SwagUpdateArea	true	"Fields need to update a area"
 // @Success 200 {boolean} true
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 412 {object} utils.ErrorResponse
+// @Failure 428 {object} utils.ErrorResponse
 // @Router /v1/area [patch]
 func (h *AreaHandler) UpdateArea(c *gin.Context) {
 	a := &models.Area{}
 	err := c.ShouldBind(a)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Invalid req body",
-			ErrorMsg:   err.Error(),
-		})
+		responseBindError(c, "Invalid req body", err)
 		return
 	}
+	revision, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	a.Revision = models.Revision{Revision: revision}
 	isSuccess, err := h.deps.SvcOpts.AreaSvc.UpdateArea(c.Request.Context(), a)
 	if err != nil || !isSuccess {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Update area failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Update area failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
@@ -133,34 +119,53 @@ func (h *AreaHandler) UpdateArea(c *gin.Context) {
 // Delete area
 // @Summary Delete Area By ID
 // @Schemes
-// @Description Delete area using "id" field
+// @Description Move area to trash using "id" field and If-Match header with ETag of the area. "devices" says what happens to its gateways and UHFs: restrict (default) refuses while it has any, reassign moves them to area "area_id", unassign leaves them without area and deactivates UHFs, which is sent to MQTT broker
 // @Accept  json
 // @Produce json
-// @Param	data	body	object{id=int}	true	"Area ID"
+// @Param	If-Match	header	string	true	"ETag of area as read, * for any"
+// @Param	data	body	models.DeleteArea	true	"Area ID and rule for its devices"
 // @Success 200 {boolean} true
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 412 {object} utils.ErrorResponse
+// @Failure 428 {object} utils.ErrorResponse
 // @Router /v1/area [delete]
 func (h *AreaHandler) DeleteArea(c *gin.Context) {
-	dId := &models.DeleteID{}
-	err := c.ShouldBind(dId)
+	d := &models.DeleteArea{}
+	err := c.ShouldBind(d)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Invalid req body",
-			ErrorMsg:   err.Error(),
-		})
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	revision, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	isSuccess, err := h.deps.SvcOpts.AreaSvc.DeleteArea(c.Request.Context(), dId.ID)
-	if err != nil || !isSuccess {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Delete area failed",
-			ErrorMsg:   err.Error(),
-		})
+	ctx := c.Request.Context()
+	unassigned, err := h.deps.SvcOpts.AreaSvc.DeleteArea(ctx, d, revision)
+	if err != nil {
+		responseError(c, "Delete area failed", err)
 		return
 	}
-	utils.ResponseJson(c, http.StatusOK, isSuccess)
 
+	// UHFs left without area are deactivated on their gateways
+	gwIds := []string{}
+	updated := map[string][]models.UHF{}
+	for _, uhf := range unassigned {
+		if uhf.GatewayID == "" {
+			continue
+		}
+		if _, ok := updated[uhf.GatewayID]; !ok {
+			gwIds = append(gwIds, uhf.GatewayID)
+		}
+		updated[uhf.GatewayID] = append(updated[uhf.GatewayID], uhf)
+	}
+	for _, gwId := range gwIds {
+		for _, err := range h.deps.Publisher.PublishUHFChanges(ctx, gwId, updated[gwId], nil) {
+			responseError(c, "Delete area mqtt failed", err)
+			return
+		}
+	}
+	utils.ResponseJson(c, http.StatusOK, true)
 }
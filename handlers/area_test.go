@@ -0,0 +1,151 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestArea(t *testing.T) {
+	ts := newTestServer(t)
+
+	area := &models.Area{}
+	w := ts.do(http.MethodPost, "/v1/area", `{"name": "Hall", "manager": "Lan"}`)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, area)
+	expectStatus(t, ts.do(http.MethodPost, "/v1/area", `{"name": "Hall", "manager": "Minh"}`),
+		http.StatusConflict, models.ERR_CODE_DUPLICATE)
+
+	areas := []models.Area{}
+	w = ts.do(http.MethodGet, "/v1/areas", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &areas)
+	if len(areas) != 1 || areas[0].Name != "Hall" {
+		t.Errorf("got %+v, wanted Hall", areas)
+	}
+
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/area", fmt.Sprintf(`{"id": %d, "manager": "Minh"}`, area.ID)), http.StatusOK, "")
+	found := &models.Area{}
+	w = ts.do(http.MethodGet, fmt.Sprintf("/v1/area/%d", area.ID), "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, found)
+	if found.Name != "Hall" || found.Manager != "Minh" {
+		t.Errorf("got %s %s, wanted Hall Minh", found.Name, found.Manager)
+	}
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/area", `{"id": 999, "manager": "Minh"}`),
+		http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	body := fmt.Sprintf(`{"id": %d}`, area.ID)
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/area", body), http.StatusOK, "")
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/area", body), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.do(http.MethodGet, fmt.Sprintf("/v1/area/%d", area.ID), ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/area", `[]`), http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+
+	ts.store.Fail("AreaSvc.FindAllArea", fmt.Errorf("connection reset"))
+	expectStatus(t, ts.do(http.MethodGet, "/v1/areas", ""), http.StatusInternalServerError, models.ERR_CODE_INTERNAL)
+}
+
+func TestAreaNameOfTenant(t *testing.T) {
+	ts := newTestServer(t)
+	org, err := ts.svc.TenantSvc.CreateOrganization(context.Background(), &models.Organization{Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"name": "Hall", "manager": "Lan"}`
+	expectStatus(t, ts.doAs("1", http.MethodPost, "/v1/area", body), http.StatusOK, "")
+
+	// names are only unique within an organization
+	expectStatus(t, ts.doAs(fmt.Sprint(org.ID), http.MethodPost, "/v1/area", body), http.StatusOK, "")
+	expectStatus(t, ts.doAs(fmt.Sprint(org.ID), http.MethodPost, "/v1/area", body), http.StatusConflict, models.ERR_CODE_DUPLICATE)
+}
+
+func TestAreaRevision(t *testing.T) {
+	ts := newTestServer(t)
+
+	area := &models.Area{}
+	w := ts.do(http.MethodPost, "/v1/area", `{"name": "Hall", "manager": "Lan"}`)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, area)
+	path := fmt.Sprintf("/v1/area/%d", area.ID)
+	w = ts.do(http.MethodGet, path, "")
+	expectStatus(t, w, http.StatusOK, "")
+	etag := w.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("got ETag %s, wanted \"1\"", etag)
+	}
+
+	body := fmt.Sprintf(`{"id": %d, "manager": "Minh"}`, area.ID)
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/area", body), http.StatusPreconditionRequired, ERR_CODE_PRECONDITION_REQUIRED)
+	expectStatus(t, ts.doIfMatch("3", http.MethodPatch, "/v1/area", body), http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+	expectStatus(t, ts.doIfMatch(etag, http.MethodPatch, "/v1/area", body), http.StatusOK, "")
+	// a second operator still holding the first ETag is refused
+	expectStatus(t, ts.doIfMatch(etag, http.MethodPatch, "/v1/area", fmt.Sprintf(`{"id": %d, "manager": "An"}`, area.ID)),
+		http.StatusPreconditionFailed, models.ERR_CODE_PRECONDITION)
+	found := &models.Area{}
+	w = ts.do(http.MethodGet, path, "")
+	decode(t, w, found)
+	if found.Manager != "Minh" || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("got %s at %s, wanted Minh at \"2\"", found.Manager, w.Header().Get("ETag"))
+	}
+
+	body = fmt.Sprintf(`{"id": %d}`, area.ID)
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/area", body), http.StatusPreconditionRequired, ERR_CODE_PRECONDITION_REQUIRED)
+	expectStatus(t, ts.doIfMatch(etag, http.MethodDelete, "/v1/area", body), http.StatusPreconditionFailed, models.ERR_CODE_PRECONDITION)
+	expectStatus(t, ts.doIfMatch(`"2"`, http.MethodDelete, "/v1/area", body), http.StatusOK, "")
+	expectStatus(t, ts.doIfMatch(`"2"`, http.MethodDelete, "/v1/area", body), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+}
+
+func TestDeleteAreaDevices(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	ts.seedGateway(t, ctx, "gw01", "1", "2")
+	hall, _ := ts.svc.AreaSvc.CreateArea(&models.Area{Name: "Hall", Manager: "an"}, ctx)
+	lobby, _ := ts.svc.AreaSvc.CreateArea(&models.Area{Name: "Lobby", Manager: "binh"}, ctx)
+	hallId := fmt.Sprint(hall.ID)
+	ts.svc.GatewaySvc.UpdateGateway(ctx, &models.Gateway{GatewayID: "gw01", AreaID: hallId})
+	for _, address := range []string{"1", "2"} {
+		ts.svc.UHFSvc.UpdateUHF(ctx, &models.UHF{GatewayID: "gw01", UHFAddress: address, AreaId: hallId, ActiveState: "active"})
+	}
+	// UHF 2 is in trash and follows its area as well
+	uhf2, _ := ts.svc.UHFSvc.FindUHFByAddress(ctx, "2", "gw01")
+	ts.svc.UHFSvc.DeleteUHF(ctx, fmt.Sprint(uhf2.ID), 0)
+
+	// devices are kept from being orphaned unless a rule says what happens to them
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/area", fmt.Sprintf(`{"id": %d}`, hall.ID)),
+		http.StatusConflict, models.ERR_CODE_CONFLICT)
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/area", fmt.Sprintf(`{"id": %d, "devices": "move"}`, hall.ID)),
+		http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/area", fmt.Sprintf(`{"id": %d, "devices": "reassign", "area_id": "99"}`, hall.ID)),
+		http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/area", fmt.Sprintf(`{"id": %d, "devices": "reassign", "area_id": "%d"}`, hall.ID, lobby.ID)),
+		http.StatusOK, "")
+	lobbyId := fmt.Sprint(lobby.ID)
+	if gw, _ := ts.svc.GatewaySvc.FindGatewayByGatewayID(ctx, "gw01"); gw.AreaID != lobbyId || gw.UHFs[0].AreaId != lobbyId {
+		t.Errorf("got %+v, wanted gateway and UHF reassigned to lobby", gw)
+	}
+	trash, _ := ts.svc.TrashSvc.FindTrash(ctx)
+	if len(trash.Areas) != 1 || trash.Areas[0].ID != hall.ID || trash.UHFs[0].AreaId != lobbyId {
+		t.Errorf("got %+v, wanted hall in trash and trashed UHF reassigned", trash)
+	}
+
+	// unassigned UHFs are deactivated on their gateway
+	published := len(ts.client.Published())
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/area", fmt.Sprintf(`{"id": %d, "devices": "unassign"}`, lobby.ID)),
+		http.StatusOK, "")
+	uhf, _ := ts.svc.UHFSvc.FindUHFByAddress(ctx, "1", "gw01")
+	if uhf.AreaId != "" || uhf.DesiredState != "inactive" {
+		t.Errorf("got area %q desired %s, wanted UHF unassigned and inactive", uhf.AreaId, uhf.DesiredState)
+	}
+	sent := ts.client.Published()[published:]
+	if len(sent) != 1 || !strings.Contains(sent[0].Payload, `"inactive"`) {
+		t.Errorf("got %+v, wanted one batch deactivating UHF 1", sent)
+	}
+}
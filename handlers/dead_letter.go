@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type DeadLetterHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewDeadLetterHandler(deps *HandlerDependencies) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		deps,
+	}
+}
+
+// Find dead letters
+// @Summary Find All Dead Letter
+// @Schemes
+// @Description find rejected gateway messages, newest first, optionally filtered by topic, gateway_id, site and reason
+// @Produce json
+// @Param        topic	query	string	false	"MQTT topic"
+// @Param        gateway_id	query	string	false	"Gateway ID"
+// @Param        site	query	string	false	"Site of topic"
+// @Param        reason	query	string	false	"Reject reason: invalid_payload, schema_violation, unsupported_version, invalid_topic, site_mismatch, unknown_site, unknown_gateway, unknown_uhf, uhf_no_area, handler_panic, handler_error"
+// @Success 200 {array} []models.DeadLetter
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/dead_letters [get]
+func (h *DeadLetterHandler) FindDeadLetters(c *gin.Context) {
+	filter := models.DeadLetterFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		responseBindError(c, "Invalid req query", err)
+		return
+	}
+	dlList, err := h.deps.SvcOpts.DeadLetterSvc.FindDeadLetters(c.Request.Context(), filter)
+	if err != nil {
+		responseError(c, "Get dead letters failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dlList)
+}
+
+// Find dead letter by id
+// @Summary Find Dead Letter By ID
+// @Schemes
+// @Description find rejected gateway message by id
+// @Produce json
+// @Param        id	path	string	true	"Dead letter ID"
+// @Success 200 {object} models.DeadLetter
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/dead_letter/{id} [get]
+func (h *DeadLetterHandler) FindDeadLetterByID(c *gin.Context) {
+	id := c.Param("id")
+	dl, err := h.deps.SvcOpts.DeadLetterSvc.FindDeadLetterByID(c.Request.Context(), id)
+	if err != nil {
+		responseError(c, "Get dead letter failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dl)
+}
+
+// Replay dead letters
+// @Summary Replay Dead Letters
+// @Schemes
+// @Description Run dead letters through subscriber of their topic again. Handled ones are removed, rejected ones keep the new reason
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.ReplayDeadLetters	true	"Dead letter IDs"
+// @Success 200 {array} []models.DeadLetterResult
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/dead_letter/replay [post]
+func (h *DeadLetterHandler) ReplayDeadLetters(c *gin.Context) {
+	req := &models.ReplayDeadLetters{}
+	err := c.ShouldBind(req)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+
+	results := make([]models.DeadLetterResult, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		err := h.deps.Ingestion.Replay(c.Request.Context(), id)
+		result := models.DeadLetterResult{ID: id, Success: err == nil}
+		if err != nil {
+			result.Message = err.Error()
+		}
+		results = append(results, result)
+	}
+	utils.ResponseJson(c, http.StatusOK, results)
+}
+
+// Discard dead letter
+// @Summary Delete Dead Letter By ID
+// @Schemes
+// @Description Discard rejected gateway message using "id" field
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Dead letter ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/dead_letter [delete]
+func (h *DeadLetterHandler) DeleteDeadLetter(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+
+	isSuccess, err := h.deps.SvcOpts.DeadLetterSvc.DeleteDeadLetter(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		responseError(c, "Delete dead letter failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
@@ -0,0 +1,73 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func TestDeadLetter(t *testing.T) {
+	ts := newTestServer(t)
+	handled := 0
+	ts.ing.Register(mqttSvc.TOPIC_GW_LOG, func(c mqtt.Client, msg *mqttSvc.GatewayMessage) error {
+		if strings.Contains(string(msg.Payload()), "broken") {
+			return fmt.Errorf("%w: broken", mqttSvc.ErrInvalidPayload)
+		}
+		handled++
+		return nil
+	})
+	seeds := []models.DeadLetter{
+		{Topic: mqttSvc.TOPIC_GW_LOG, GatewayID: "gw01", Payload: `{"gateway_id": "gw01"}`, Reason: "invalid_payload"},
+		{Topic: mqttSvc.TOPIC_GW_LOG, GatewayID: "gw01", Payload: `{"gateway_id": "gw01", "broken": true}`, Reason: "invalid_payload"},
+		{Topic: mqttSvc.TOPIC_GW_SHUTDOWN, GatewayID: "gw02", Payload: `{"gateway_id": "gw02"}`, Reason: "unknown_gateway"},
+	}
+	for i := range seeds {
+		seeds[i].ReceivedAt = time.Now()
+		if _, err := ts.svc.DeadLetterSvc.CreateDeadLetter(context.Background(), &seeds[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dlList := []models.DeadLetter{}
+	w := ts.do(http.MethodGet, "/v1/dead_letters?gateway_id=gw01", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &dlList)
+	if len(dlList) != 2 {
+		t.Errorf("got %d dead letters, wanted 2 of gw01", len(dlList))
+	}
+	expectStatus(t, ts.do(http.MethodGet, fmt.Sprintf("/v1/dead_letter/%d", seeds[0].ID), ""), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodGet, "/v1/dead_letter/999", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	results := []models.DeadLetterResult{}
+	w = ts.do(http.MethodPost, "/v1/dead_letter/replay", fmt.Sprintf(`{"ids": [%d, %d, %d, 999]}`, seeds[0].ID, seeds[1].ID, seeds[2].ID))
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &results)
+	if len(results) != 4 || !results[0].Success || results[1].Success || results[2].Success || results[3].Success {
+		t.Errorf("got %+v, wanted only first dead letter replayed", results)
+	}
+	if handled != 1 {
+		t.Errorf("got %d messages handled, wanted 1", handled)
+	}
+	expectStatus(t, ts.do(http.MethodGet, fmt.Sprintf("/v1/dead_letter/%d", seeds[0].ID), ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	replayed := &models.DeadLetter{}
+	w = ts.do(http.MethodGet, fmt.Sprintf("/v1/dead_letter/%d", seeds[1].ID), "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, replayed)
+	if replayed.ReplayCount != 1 || replayed.LastReplayAt == nil {
+		t.Errorf("got %d %v, wanted replay counted", replayed.ReplayCount, replayed.LastReplayAt)
+	}
+
+	body := fmt.Sprintf(`{"id": %d}`, seeds[1].ID)
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/dead_letter", body), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/dead_letter", body), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes of problems raised by handlers themselves, domain error codes are in models
+const (
+	ERR_CODE_INVALID_REQUEST string = "invalid_request"
+	ERR_CODE_INVALID_TYPE    string = "invalid_type"
+	ERR_CODE_UNAUTHORIZED    string = "unauthorized"
+	ERR_CODE_FORBIDDEN       string = "forbidden"
+	// PATCH or DELETE of a revisioned entity without If-Match
+	ERR_CODE_PRECONDITION_REQUIRED string = "precondition_required"
+)
+
+var kindStatus = map[error]int{
+	models.ErrNotFound:           http.StatusNotFound,
+	models.ErrConflict:           http.StatusConflict,
+	models.ErrValidation:         http.StatusUnprocessableEntity,
+	models.ErrUnavailable:        http.StatusServiceUnavailable,
+	models.ErrPreconditionFailed: http.StatusPreconditionFailed,
+}
+
+// Validation errors of request binding name fields as in JSON
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name := strings.Split(f.Tag.Get(tag), ",")[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return f.Name
+	})
+}
+
+// Write failed service call as problem details, status follows kind of
+// domain error and anything unclassified is an internal error
+func responseError(c *gin.Context, msg string, err error) {
+	p := &utils.ErrorResponse{
+		Status: http.StatusInternalServerError,
+		Code:   models.ERR_CODE_INTERNAL,
+		Detail: msg,
+	}
+	if de := models.AsDomainError(err); de != nil {
+		if status, ok := kindStatus[de.Kind]; ok {
+			p.Status = status
+			p.Code = de.Code
+			p.Errors = de.Fields
+		}
+	}
+	if err != nil {
+		p.Detail = msg + ": " + err.Error()
+	}
+	utils.ResponseProblem(c, p)
+}
+
+// Write malformed request as 400 problem details with its invalid fields
+func responseBindError(c *gin.Context, msg string, err error) {
+	p := &utils.ErrorResponse{
+		Status: http.StatusBadRequest,
+		Code:   ERR_CODE_INVALID_REQUEST,
+		Detail: msg + ": " + err.Error(),
+	}
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var de *models.DomainError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fe := range validationErrs {
+			p.Errors = append(p.Errors, utils.FieldError{
+				Field:   fieldPath(fe.Namespace()),
+				Code:    fe.Tag(),
+				Message: fe.Error(),
+			})
+		}
+	case errors.As(err, &typeErr):
+		p.Errors = []utils.FieldError{{
+			Field:   typeErr.Field,
+			Code:    ERR_CODE_INVALID_TYPE,
+			Message: "must be " + typeErr.Type.String(),
+		}}
+	case errors.As(err, &de):
+		p.Errors = de.Fields
+	}
+	utils.ResponseProblem(c, p)
+}
+
+// ifMatch returns revision a PATCH or DELETE is limited to by its If-Match
+// header, 0 for "*". Missing header is answered with 428 and anything but a
+// single ETag of ours with 400.
+func ifMatch(c *gin.Context) (uint, bool) {
+	tag := strings.TrimSpace(c.GetHeader("If-Match"))
+	if tag == "" {
+		utils.ResponseProblem(c, &utils.ErrorResponse{
+			Status: http.StatusPreconditionRequired,
+			Code:   ERR_CODE_PRECONDITION_REQUIRED,
+			Detail: "If-Match header is required, send ETag of the record as read",
+		})
+		return 0, false
+	}
+	if tag == "*" {
+		return 0, true
+	}
+	revision, ok := models.ParseETag(tag)
+	if !ok {
+		responseBindError(c, "Invalid If-Match header", models.NewValidationError("If-Match", "If-Match must be an ETag like \"3\" or *"))
+		return 0, false
+	}
+	return revision, true
+}
+
+// Unknown routes answer with problem details as well
+func NoRoute(c *gin.Context) {
+	utils.ResponseProblem(c, &utils.ErrorResponse{
+		Status: http.StatusNotFound,
+		Code:   models.ERR_CODE_NOT_FOUND,
+		Detail: "no route " + c.Request.Method + " " + c.Request.URL.Path,
+	})
+}
+
+// Namespace of validator starts with name of the bound struct
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
@@ -0,0 +1,88 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func doProblemRequest(t *testing.T, h gin.HandlerFunc, body string) (*httptest.ResponseRecorder, utils.ErrorResponse) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/v1/test", h)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, utils.PROBLEM_CONTENT_TYPE) {
+		t.Errorf("got %v, wanted %v", ct, utils.PROBLEM_CONTENT_TYPE)
+	}
+	p := utils.ErrorResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("got %v, wanted problem body", err)
+	}
+	return w, p
+}
+
+func TestResponseErrorStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{utils.ErrRecordNotFound, http.StatusNotFound, models.ERR_CODE_NOT_FOUND},
+		{models.NewConflictError("rollout is running"), http.StatusConflict, models.ERR_CODE_CONFLICT},
+		{models.NewValidationError("q", "q must be between 0 and 15"), http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION},
+		{models.NewUnavailableError(models.ERR_CODE_BROKER_UNAVAILABLE, nil), http.StatusServiceUnavailable, models.ERR_CODE_BROKER_UNAVAILABLE},
+		{nil, http.StatusInternalServerError, models.ERR_CODE_INTERNAL},
+	}
+	for i, c := range cases {
+		w, p := doProblemRequest(t, func(ctx *gin.Context) {
+			responseError(ctx, "Do test failed", c.err)
+		}, "")
+		if w.Code != c.status || p.Status != c.status || p.Code != c.code {
+			t.Errorf("case %d: got %d %d %s, wanted %d %s", i, w.Code, p.Status, p.Code, c.status, c.code)
+		}
+		if p.Instance != "/v1/test" || p.Title != http.StatusText(c.status) {
+			t.Errorf("case %d: got %s %s, wanted %s %s", i, p.Instance, p.Title, "/v1/test", http.StatusText(c.status))
+		}
+	}
+}
+
+func TestResponseBindErrorFields(t *testing.T) {
+	type testReq struct {
+		GatewayID string `json:"gateway_id" binding:"required"`
+		Count     int    `json:"count"`
+	}
+	bind := func(ctx *gin.Context) {
+		req := &testReq{}
+		if err := ctx.ShouldBind(req); err != nil {
+			responseBindError(ctx, "Invalid req body", err)
+			return
+		}
+		ctx.Status(http.StatusOK)
+	}
+
+	w, p := doProblemRequest(t, bind, `{"count": 1}`)
+	if w.Code != http.StatusBadRequest || p.Code != ERR_CODE_INVALID_REQUEST {
+		t.Errorf("got %d %s, wanted %d %s", w.Code, p.Code, http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+	}
+	if len(p.Errors) != 1 || p.Errors[0].Field != "gateway_id" || p.Errors[0].Code != "required" {
+		t.Errorf("got %v, wanted gateway_id required", p.Errors)
+	}
+
+	_, p = doProblemRequest(t, bind, `{"gateway_id": "gw", "count": "many"}`)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "count" || p.Errors[0].Code != ERR_CODE_INVALID_TYPE {
+		t.Errorf("got %v, wanted count invalid_type", p.Errors)
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type FirmwareHandler struct {
+	deps   *HandlerDependencies
+	signer *models.ArtifactSigner
+}
+
+func NewFirmwareHandler(deps *HandlerDependencies, signer *models.ArtifactSigner) *FirmwareHandler {
+	return &FirmwareHandler{
+		deps,
+		signer,
+	}
+}
+
+// Find all firmware info
+// @Summary Find All Firmware
+// @Schemes
+// @Description find all firmware in registry
+// @Produce json
+// @Success 200 {array} []models.Firmware
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/firmwares [get]
+func (h *FirmwareHandler) FindAllFirmware(c *gin.Context) {
+	fwList, err := h.deps.SvcOpts.FirmwareSvc.FindAllFirmware(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get all firmware failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, fwList)
+}
+
+// Find firmware info by id
+// @Summary Find Firmware By ID
+// @Schemes
+// @Description find firmware info by id
+// @Produce json
+// @Param        id	path	string	true	"Firmware ID"
+// @Success 200 {object} models.Firmware
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/firmware/{id} [get]
+func (h *FirmwareHandler) FindFirmwareByID(c *gin.Context) {
+	id := c.Param("id")
+	fw, err := h.deps.SvcOpts.FirmwareSvc.FindFirmwareByID(c.Request.Context(), id)
+	if err != nil {
+		responseError(c, "Get firmware failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, fw)
+}
+
+// Download firmware artifact
+// @Summary Download Firmware Artifact
+// @Schemes
+// @Description download firmware artifact, used by gateways during upgrade with the signed URL of their upgrade command
+// @Produce octet-stream
+// @Param        id	path	string	true	"Firmware ID"
+// @Param        expires	query	int	true	"Unix time URL expires at"
+// @Param        signature	query	string	true	"Signature of URL"
+// @Success 200 {file} binary
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 403 {object} utils.ErrorResponse
+// @Router /v1/firmware/{id}/artifact [get]
+func (h *FirmwareHandler) DownloadFirmware(c *gin.Context) {
+	id := c.Param("id")
+	fwId, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		err = models.ErrArtifactUrlSignature
+	} else {
+		err = h.signer.Verify(uint(fwId), c.Request.URL.Query(), time.Now())
+	}
+	if err != nil {
+		utils.ResponseProblem(c, &utils.ErrorResponse{
+			Status: http.StatusForbidden,
+			Code:   ERR_CODE_FORBIDDEN,
+			Detail: err.Error(),
+		})
+		return
+	}
+	fw, err := h.deps.SvcOpts.FirmwareSvc.FindFirmwareByID(c.Request.Context(), id)
+	if err != nil {
+		responseError(c, "Get firmware failed", err)
+		return
+	}
+	c.Header("X-Checksum-Sha256", fw.Checksum)
+	c.FileAttachment(fw.ArtifactPath, fw.FileName)
+}
+
+// Upload firmware
+// @Summary Upload Firmware
+// @Schemes
+// @Description Upload firmware artifact, checksum is computed by server
+// @Accept  multipart/form-data
+// @Produce json
+// @Param	file	formData	file	true	"Firmware artifact"
+// @Param	version	formData	string	true	"Firmware version"
+// @Param	description	formData	string	false	"Firmware description"
+// @Success 200 {object} models.Firmware
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/firmware [post]
+func (h *FirmwareHandler) CreateFirmware(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	version := c.PostForm("version")
+	if err == nil && version == "" {
+		err = models.NewValidationError("version", "version is required")
+	}
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	artifact, err := fileHeader.Open()
+	if err != nil {
+		responseBindError(c, "Invalid firmware file", err)
+		return
+	}
+	defer artifact.Close()
+
+	fw := &models.Firmware{
+		Version:     version,
+		Description: c.PostForm("description"),
+		FileName:    fileHeader.Filename,
+	}
+	fw, err = h.deps.SvcOpts.FirmwareSvc.CreateFirmware(c.Request.Context(), fw, artifact)
+	if err != nil {
+		responseError(c, "Create firmware failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, fw)
+}
+
+// Delete firmware
+// @Summary Delete Firmware By ID
+// @Schemes
+// @Description Delete firmware and its artifact using "id" field, refused while a pending, running or paused rollout uses it
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Firmware ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Router /v1/firmware [delete]
+func (h *FirmwareHandler) DeleteFirmware(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+
+	isSuccess, err := h.deps.SvcOpts.FirmwareSvc.DeleteFirmware(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		responseError(c, "Delete firmware failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
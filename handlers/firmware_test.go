@@ -0,0 +1,97 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+// uploadFirmware posts artifact as multipart form with admin key
+func (ts *testServer) uploadFirmware(t *testing.T, version string, artifact []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	if version != "" {
+		form.WriteField("version", version)
+	}
+	form.WriteField("description", "test build")
+	part, err := form.CreateFormFile("file", "gateway.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(artifact)
+	form.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/firmware", body)
+	req.Header.Set("X-API-Key", testAdminKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	ts.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestFirmware(t *testing.T) {
+	ts := newTestServer(t)
+	ts.store.SetFirmwareDir(t.TempDir())
+	artifact := []byte("firmware 1.1.0")
+	sum := sha256.Sum256(artifact)
+
+	fw := &models.Firmware{}
+	w := ts.uploadFirmware(t, "1.1.0", artifact)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, fw)
+	if fw.Checksum != hex.EncodeToString(sum[:]) || fw.Size != int64(len(artifact)) {
+		t.Errorf("got %s %d, wanted checksum and size of artifact", fw.Checksum, fw.Size)
+	}
+	expectStatus(t, ts.uploadFirmware(t, "1.1.0", artifact), http.StatusConflict, models.ERR_CODE_DUPLICATE)
+	expectStatus(t, ts.uploadFirmware(t, "", artifact), http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+
+	fwList := []models.Firmware{}
+	w = ts.doAs("1", http.MethodGet, "/v1/firmwares", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &fwList)
+	if len(fwList) != 1 {
+		t.Errorf("got %d firmware, wanted 1", len(fwList))
+	}
+	expectStatus(t, ts.doAs("1", http.MethodGet, fmt.Sprintf("/v1/firmware/%d", fw.ID), ""), http.StatusOK, "")
+	expectStatus(t, ts.doAs("1", http.MethodGet, "/v1/firmware/999", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	// gateways download without API key, with the signed URL of their upgrade command
+	artifactPath := func(id uint, query url.Values) string {
+		return fmt.Sprintf("/v1/firmware/%d/artifact?%s", id, query.Encode())
+	}
+	w = ts.doWithHeader(http.MethodGet, artifactPath(fw.ID, ts.signer.Sign(fw.ID, time.Now())), "", http.Header{})
+	expectStatus(t, w, http.StatusOK, "")
+	if w.Body.String() != string(artifact) || w.Header().Get("X-Checksum-Sha256") != fw.Checksum {
+		t.Errorf("got %q %s, wanted artifact and its checksum", w.Body.String(), w.Header().Get("X-Checksum-Sha256"))
+	}
+	expired := ts.signer.Sign(fw.ID, time.Now().Add(-2*time.Hour))
+	other := ts.signer.Sign(fw.ID+1, time.Now())
+	for _, path := range []string{
+		artifactPath(fw.ID, nil),
+		artifactPath(fw.ID, expired),
+		artifactPath(fw.ID, other),
+		artifactPath(fw.ID+1, other) + "0",
+		"/v1/firmware/x/artifact",
+	} {
+		w = ts.doWithHeader(http.MethodGet, path, "", http.Header{})
+		expectStatus(t, w, http.StatusForbidden, ERR_CODE_FORBIDDEN)
+	}
+
+	body := fmt.Sprintf(`{"id": %d}`, fw.ID)
+	expectStatus(t, ts.doAs("1", http.MethodDelete, "/v1/firmware", body), http.StatusForbidden, ERR_CODE_FORBIDDEN)
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/firmware", body), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/firmware", body), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+}
@@ -1,10 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
-	"strconv"
 	"time"
 
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
@@ -30,13 +31,9 @@ func NewGatewayHandler(deps *HandlerDependencies) *GatewayHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/gateways [get]
 func (h *GatewayHandler) FindAllGateway(c *gin.Context) {
-	gwList, err := h.deps.SvcOpts.GatewaySvc.FindAllGateway(c)
+	gwList, err := h.deps.SvcOpts.GatewaySvc.FindAllGateway(c.Request.Context())
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all gateways failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all gateways failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, gwList)
@@ -49,20 +46,18 @@ func (h *GatewayHandler) FindAllGateway(c *gin.Context) {
 // @Produce json
 // @Param        id	path	string	true	"ID"
 // @Success 200 {object} models.Gateway
+// @Header 200 {string} ETag "Revision of gateway, send it back in If-Match"
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/gateway/{id} [get]
 func (h *GatewayHandler) FindGatewayByID(c *gin.Context) {
 	id := c.Param("id")
 
-	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByID(c, id)
+	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get gateway failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get gateway failed", err)
 		return
 	}
+	c.Header("ETag", gw.ETag())
 	utils.ResponseJson(c, http.StatusOK, gw)
 }
 
@@ -73,69 +68,100 @@ func (h *GatewayHandler) FindGatewayByID(c *gin.Context) {
 // @Produce json
 // @Param        id	path	string	true	"gateway_id"
 // @Success 200 {object} models.Gateway
+// @Header 200 {string} ETag "Revision of gateway, send it back in If-Match"
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/gateway/gateway_id/{gateway_id} [get]
 func (h *GatewayHandler) FindGatewayByGatewayID(c *gin.Context) {
 	id := c.Param("gateway_id")
 
-	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByGatewayID(c, id)
+	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByGatewayID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get gateway failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get gateway failed", err)
 		return
 	}
+	c.Header("ETag", gw.ETag())
 	utils.ResponseJson(c, http.StatusOK, gw)
 }
 
+// Find gateways out of sync
+// @Summary Find Drifted Gateways
+// @Schemes
+// @Description find gateways whose reported state differs from desired state
+// @Produce json
+// @Success 200 {array} []models.Gateway
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/gateways/drift [get]
+func (h *GatewayHandler) FindDriftedGateways(c *gin.Context) {
+	gwList, err := h.deps.SvcOpts.GatewaySvc.FindDriftedGateways(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get drifted gateways failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, gwList)
+}
+
 // Update gateway
 // @Summary Update Gateway By Gateway ID
 // @Schemes
-// @Description Update gateway, must have "gateway_id" field. Send updated info to MQTT broker
+// @Description Update gateway, must have "gateway_id" field and If-Match header with ETag of the gateway. "desired_state" sets the desired state. Send updated info to MQTT broker
 // @Accept  json
 // @Produce json
+// @Param	If-Match	header	string	true	"ETag of gateway as read, * for any"
 // @Param	data	body	models.SwagUpateGateway	true	"Fields need to update a gateway"
 // @Success 200 {boolean} true
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 412 {object} utils.ErrorResponse
+// @Failure 428 {object} utils.ErrorResponse
 // @Router /v1/gateway [patch]
 func (h *GatewayHandler) UpdateGateway(c *gin.Context) {
 	gw := &models.Gateway{}
 	err := c.ShouldBind(gw)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Invalid req body",
-			ErrorMsg:   err.Error(),
-		})
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	revision, ok := ifMatch(c)
+	if !ok {
 		return
 	}
+	gw.Revision = models.Revision{Revision: revision}
+
+	// "connect_state" in req body is kept as desired state, only gateway reports may change the applied one
+	desiredState := gw.DesiredState
+	if desiredState == "" {
+		desiredState = gw.ConnectState
+	}
+	gw.DeviceTwin = models.DeviceTwin{}
+	gw.ConnectState = ""
 
 	isSuccess, err := h.deps.SvcOpts.GatewaySvc.UpdateGateway(c.Request.Context(), gw)
 	if err != nil || !isSuccess {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Update gateway failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Update gateway failed", err)
+		return
+	}
+	if desiredState != "" {
+		_, err = h.deps.SvcOpts.GatewaySvc.UpdateGatewayDesiredState(c.Request.Context(), gw.GatewayID, desiredState)
+		if err != nil {
+			responseError(c, "Update gateway desired state failed", err)
+			return
+		}
+		new_gw_log := &models.GatewayLog{}
+		new_gw_log.StateType = "Desired State"
+		new_gw_log.GatewayID = gw.GatewayID
+		new_gw_log.StateValue = desiredState
+		new_gw_log.LogTime = time.Now()
+		h.deps.SvcOpts.LogSvc.CreateGatewayLog(c.Request.Context(), new_gw_log)
+	}
+	updated_gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByGatewayID(c.Request.Context(), gw.GatewayID)
+	if err != nil {
+		responseError(c, "Find Gateway failed", err)
 		return
 	}
-	updated_gw, _ := h.deps.SvcOpts.GatewaySvc.FindGatewayByGatewayID(c.Request.Context(), gw.GatewayID)
-	new_gw_log := &models.GatewayLog{}
-	new_gw_log.StateType = "ConnectState"
-	new_gw_log.GatewayID = gw.GatewayID
-	new_gw_log.StateValue = updated_gw.ConnectState
-	new_gw_log.LogTime = time.Now()
-	h.deps.SvcOpts.LogSvc.CreateGatewayLog(c.Request.Context(), new_gw_log)
 
-	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_GATEWAY_U, 1, false, mqttSvc.ServerUpdateGatewayPayload(gw))
+	t := h.deps.Publisher.PublishToSite(c.Request.Context(), mqttSvc.TOPIC_SV_GATEWAY_U, updated_gw.Site, updated_gw.GatewayID,
+		mqttSvc.ServerUpdateGatewayPayload(updated_gw))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Update gateway mqtt failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Update gateway mqtt failed", err)
 		return
 	}
 
@@ -145,78 +171,137 @@ func (h *GatewayHandler) UpdateGateway(c *gin.Context) {
 // Delete gateway
 // @Summary Delete Gateway By Gateway ID
 // @Schemes
-// @Description Delete gateway using "id" field. Send deleted info to MQTT broker
+// @Description Move gateway and its UHFs to trash using "id" field and If-Match header with ETag of the gateway. Send deleted info to MQTT broker
 // @Accept  json
 // @Produce json
+// @Param	If-Match	header	string	true	"ETag of gateway as read, * for any"
 // @Param	data	body	object{gateway_id=string}	true	"Gateway ID"
 // @Success 200 {boolean} true
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 412 {object} utils.ErrorResponse
+// @Failure 428 {object} utils.ErrorResponse
 // @Router /v1/gateway [delete]
 func (h *GatewayHandler) DeleteGateway(c *gin.Context) {
 	dgw := &models.DeleteGateway{}
 	err := c.ShouldBind(dgw)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Invalid req body",
-			ErrorMsg:   err.Error(),
-		})
+		responseBindError(c, "Invalid req body", err)
 		return
 	}
-
-	_, err1 := h.deps.SvcOpts.GatewaySvc.FindGatewayByGatewayID(c.Request.Context(), dgw.GatewayID)
-	if err1 != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Find Gateway failed",
-			ErrorMsg:   err1.Error(),
-		})
+	revision, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	dls, err := h.deps.SvcOpts.UHFSvc.FindAllUHFByGatewayID(c.Request.Context(), dgw.GatewayID)
-	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Find UHF failed",
-			ErrorMsg:   err.Error(),
-		})
+	deleted_gw, err1 := h.deps.SvcOpts.GatewaySvc.FindGatewayByGatewayID(c.Request.Context(), dgw.GatewayID)
+	if err1 != nil {
+		responseError(c, "Find Gateway failed", err1)
 		return
 	}
 
-	//delete gateway first
-	isSuccess, err := h.deps.SvcOpts.GatewaySvc.DeleteGateway(c.Request.Context(), dgw.GatewayID)
+	// UHFs of gateway go to trash along with it
+	isSuccess, err := h.deps.SvcOpts.GatewaySvc.DeleteGateway(c.Request.Context(), dgw.GatewayID, revision, models.TRASHED_BY_OPERATOR)
 	if err != nil || !isSuccess {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Delete gateway failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Delete gateway failed", err)
 		return
 	}
 
-	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_GATEWAY_D, 1, false, mqttSvc.ServerDeleteGatewayPayload(dgw.GatewayID))
+	// gateway is gone, its site was read before deleting
+	t := h.deps.Publisher.PublishToSite(c.Request.Context(), mqttSvc.TOPIC_SV_GATEWAY_D, deleted_gw.Site, dgw.GatewayID,
+		mqttSvc.ServerDeleteGatewayPayload(dgw.GatewayID))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Delete gateway mqtt failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Delete gateway mqtt failed", err)
 		return
 	}
 
-	// delete UHFs belong to this gateway
-	for i := 0; i < len(dls); i++ {
-		isSuccess, err := h.deps.SvcOpts.UHFSvc.DeleteUHF(c.Request.Context(), strconv.FormatUint(uint64(dls[i].ID), 10))
-		if err != nil || !isSuccess {
-			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-				StatusCode: http.StatusBadRequest,
-				Msg:        "Delete doorlock failed",
-				ErrorMsg:   err.Error(),
-			})
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Bulk operation on gateways
+// @Summary Bulk Update Gateways
+// @Schemes
+// @Description Apply "action" (activate, deactivate, reassign_area or delete) to every gateway matching "selector" (area_id, gateway_id, version as software version). "area_id" is the new area of reassign_area, delete moves UHFs of gateways to trash too. Every gateway gets its own result and a single message through MQTT broker
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.BulkRequest	true	"Selector and action"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /v1/gateways/bulk [post]
+func (h *GatewayHandler) BulkUpdateGateways(c *gin.Context) {
+	req := &models.BulkRequest{}
+	err := c.ShouldBind(req)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	if err := req.Validate(true); err != nil {
+		responseError(c, "Invalid bulk request", err)
+		return
+	}
+	ctx := c.Request.Context()
+	if req.Action == models.BULK_REASSIGN_AREA {
+		if _, err := h.deps.SvcOpts.AreaSvc.FindAreaByID(ctx, req.AreaID); err != nil {
+			responseError(c, "This area ID does not exist",
+				models.NewValidationError("area_id", "area %s does not exist", req.AreaID))
 			return
 		}
 	}
+	gwList, err := h.deps.SvcOpts.GatewaySvc.FindGatewaysBySelector(ctx, req.Selector)
+	if err != nil {
+		responseError(c, "Get selected gateways failed", err)
+		return
+	}
 
-	utils.ResponseJson(c, http.StatusOK, isSuccess)
+	result := models.NewBulkResult(req.Action)
+	for i := range gwList {
+		gw := &gwList[i]
+		changed, err := h.bulkUpdateGateway(ctx, req, gw)
+		result.Add(models.BulkItemResult{ID: gw.ID, GatewayID: gw.GatewayID}, err)
+		if err != nil {
+			continue
+		}
+		var t mqtt.Token
+		if req.Action == models.BULK_DELETE {
+			t = h.deps.Publisher.PublishToSite(ctx, mqttSvc.TOPIC_SV_GATEWAY_D, gw.Site, gw.GatewayID,
+				mqttSvc.ServerDeleteGatewayPayload(gw.GatewayID))
+		} else {
+			t = h.deps.Publisher.PublishToSite(ctx, mqttSvc.TOPIC_SV_GATEWAY_U, changed.Site, changed.GatewayID,
+				mqttSvc.ServerUpdateGatewayPayload(changed))
+		}
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			result.PublishFailed(gw.GatewayID, err)
+		}
+	}
+	utils.ResponseJson(c, http.StatusOK, result)
+}
+
+// bulkUpdateGateway applies action of req to gw and returns it as stored after
+func (h *GatewayHandler) bulkUpdateGateway(ctx context.Context, req *models.BulkRequest, gw *models.Gateway) (*models.Gateway, error) {
+	switch req.Action {
+	case models.BULK_DELETE:
+		// UHFs of gateway go to trash along with it
+		if _, err := h.deps.SvcOpts.GatewaySvc.DeleteGateway(ctx, gw.GatewayID, gw.Revision.Revision, models.TRASHED_BY_OPERATOR); err != nil {
+			return nil, err
+		}
+		return gw, nil
+	case models.BULK_REASSIGN_AREA:
+		_, err := h.deps.SvcOpts.GatewaySvc.UpdateGateway(ctx, &models.Gateway{GatewayID: gw.GatewayID, AreaID: req.AreaID, Revision: gw.Revision})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		state := req.DesiredState()
+		_, err := h.deps.SvcOpts.GatewaySvc.UpdateGatewayDesiredState(ctx, gw.GatewayID, state)
+		if err != nil {
+			return nil, err
+		}
+		h.deps.SvcOpts.LogSvc.CreateGatewayLog(ctx, &models.GatewayLog{
+			GatewayID:  gw.GatewayID,
+			StateType:  "Desired State",
+			StateValue: state,
+			LogTime:    time.Now(),
+		})
+	}
+	return h.deps.SvcOpts.GatewaySvc.FindGatewayByGatewayID(ctx, gw.GatewayID)
 }
@@ -0,0 +1,230 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func TestFindGateway(t *testing.T) {
+	ts := newTestServer(t)
+	gw := ts.seedGateway(t, context.Background(), "gw01", "1", "2")
+
+	gwList := []models.Gateway{}
+	w := ts.do(http.MethodGet, "/v1/gateways", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &gwList)
+	if len(gwList) != 1 || len(gwList[0].UHFs) != 2 {
+		t.Errorf("got %+v, wanted gateway with 2 UHFs", gwList)
+	}
+
+	found := &models.Gateway{}
+	w = ts.do(http.MethodGet, fmt.Sprintf("/v1/gateway/%d", gw.ID), "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, found)
+	if found.GatewayID != "gw01" {
+		t.Errorf("got %s, wanted gw01", found.GatewayID)
+	}
+	expectStatus(t, ts.do(http.MethodGet, "/v1/gateway/gateway_id/gw01", ""), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodGet, "/v1/gateway/gateway_id/gw02", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.do(http.MethodGet, "/v1/gateway/999", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	ts.store.Fail("GatewaySvc.FindAllGateway", fmt.Errorf("connection reset"))
+	expectStatus(t, ts.do(http.MethodGet, "/v1/gateways", ""), http.StatusInternalServerError, models.ERR_CODE_INTERNAL)
+}
+
+func TestFindGatewayOfTenant(t *testing.T) {
+	ts := newTestServer(t)
+	org, err := ts.svc.TenantSvc.CreateOrganization(context.Background(), &models.Organization{Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.seedGateway(t, models.WithTenant(context.Background(), 1), "gw01")
+	ts.seedGateway(t, models.WithTenant(context.Background(), org.ID), "gw02")
+
+	gwList := []models.Gateway{}
+	w := ts.doAs(fmt.Sprint(org.ID), http.MethodGet, "/v1/gateways", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &gwList)
+	if len(gwList) != 1 || gwList[0].GatewayID != "gw02" {
+		t.Errorf("got %+v, wanted only gw02", gwList)
+	}
+	expectStatus(t, ts.doAs(fmt.Sprint(org.ID), http.MethodGet, "/v1/gateway/gateway_id/gw01", ""),
+		http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+}
+
+func TestFindDriftedGateways(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01")
+	ts.seedGateway(t, context.Background(), "gw02")
+	ts.seedGateway(t, context.Background(), "gw03")
+	for _, gwId := range []string{"gw02", "gw03"} {
+		if _, err := ts.svc.GatewaySvc.UpdateGatewayDesiredState(context.Background(), gwId, "disconnected"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// gw03 never reported its state
+	if _, err := ts.svc.GatewaySvc.UpdateGatewayReportedState(context.Background(), "gw02", "connected"); err != nil {
+		t.Fatal(err)
+	}
+
+	gwList := []models.Gateway{}
+	w := ts.do(http.MethodGet, "/v1/gateways/drift", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &gwList)
+	if len(gwList) != 1 || gwList[0].GatewayID != "gw02" {
+		t.Errorf("got %+v, wanted only gw02", gwList)
+	}
+}
+
+func TestUpdateGateway(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01")
+
+	w := ts.doIfMatch("*", http.MethodPatch, "/v1/gateway", `{"gateway_id": "gw01", "name": "Lobby", "connect_state": "disconnected"}`)
+	expectStatus(t, w, http.StatusOK, "")
+
+	gw, err := ts.svc.GatewaySvc.FindGatewayByGatewayID(context.Background(), "gw01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gw.Name != "Lobby" || gw.DesiredState != "disconnected" || gw.ConnectState != "connected" {
+		t.Errorf("got %s %s %s, wanted Lobby disconnected connected", gw.Name, gw.DesiredState, gw.ConnectState)
+	}
+	logs, _ := ts.svc.LogSvc.FindGatewayByGatewayID(context.Background(), "gw01")
+	if len(*logs) != 1 || (*logs)[0].StateType != "Desired State" {
+		t.Errorf("got %+v, wanted desired state log", *logs)
+	}
+	published := ts.client.Published()
+	if len(published) != 1 || published[0].Topic != mqttSvc.TOPIC_SV_GATEWAY_U ||
+		!strings.Contains(published[0].Payload, `"gw01"`) {
+		t.Errorf("got %+v, wanted gateway update", published)
+	}
+
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/gateway", `{"gateway_id": "gw02", "name": "Lobby"}`),
+		http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/gateway", `{"gateway_id": 1}`),
+		http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+
+	ts.client.FailPublish(fmt.Errorf("not connected"))
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/gateway", `{"gateway_id": "gw01", "name": "Hall"}`),
+		http.StatusServiceUnavailable, models.ERR_CODE_BROKER_UNAVAILABLE)
+}
+
+func TestDeleteGateway(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01", "1", "2")
+
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/gateway", `{"gateway_id": "gw01"}`), http.StatusOK, "")
+	if _, err := ts.svc.GatewaySvc.FindGatewayByGatewayID(context.Background(), "gw01"); models.AsDomainError(err) == nil {
+		t.Errorf("got %v, wanted gateway gone", err)
+	}
+	if uhfs, _ := ts.svc.UHFSvc.FindAllUHF(context.Background()); len(uhfs) != 0 {
+		t.Errorf("got %d UHFs, wanted UHFs of gateway deleted", len(uhfs))
+	}
+	published := ts.client.Published()
+	if len(published) != 1 || published[0].Topic != mqttSvc.TOPIC_SV_GATEWAY_D {
+		t.Errorf("got %+v, wanted gateway delete", published)
+	}
+
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/gateway", `{"gateway_id": "gw01"}`),
+		http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/gateway", `{}`),
+		http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+}
+
+func TestGatewayRevision(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01", "1")
+
+	w := ts.do(http.MethodGet, "/v1/gateway/gateway_id/gw01", "")
+	expectStatus(t, w, http.StatusOK, "")
+	etag := w.Header().Get("ETag")
+	gw := &models.Gateway{}
+	decode(t, w, gw)
+	if etag != gw.ETag() {
+		t.Fatalf("got ETag %s, wanted %s", etag, gw.ETag())
+	}
+	if w := ts.do(http.MethodGet, fmt.Sprintf("/v1/gateway/%d", gw.ID), ""); w.Header().Get("ETag") != etag {
+		t.Errorf("got ETag %s by ID, wanted %s", w.Header().Get("ETag"), etag)
+	}
+
+	// a gateway report moves revision on, the operator's copy is outdated
+	if _, err := ts.svc.GatewaySvc.UpdateGatewayConnectState(context.Background(), "gw01", "disconnected"); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, ts.doIfMatch(etag, http.MethodPatch, "/v1/gateway", `{"gateway_id": "gw01", "name": "Lobby"}`),
+		http.StatusPreconditionFailed, models.ERR_CODE_PRECONDITION)
+	expectStatus(t, ts.doIfMatch(etag, http.MethodDelete, "/v1/gateway", `{"gateway_id": "gw01"}`),
+		http.StatusPreconditionFailed, models.ERR_CODE_PRECONDITION)
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/gateway", `{"gateway_id": "gw01"}`),
+		http.StatusPreconditionRequired, ERR_CODE_PRECONDITION_REQUIRED)
+	if published := ts.client.Published(); len(published) != 0 {
+		t.Errorf("got %+v, wanted nothing sent to gateway", published)
+	}
+
+	etag = ts.do(http.MethodGet, "/v1/gateway/gateway_id/gw01", "").Header().Get("ETag")
+	expectStatus(t, ts.doIfMatch(etag, http.MethodPatch, "/v1/gateway", `{"gateway_id": "gw01", "name": "Lobby"}`), http.StatusOK, "")
+}
+
+func TestBulkUpdateGateways(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	ts.seedGateway(t, ctx, "gw01", "1", "2")
+	ts.seedGateway(t, ctx, "gw02")
+	ts.seedGateway(t, ctx, "gw03")
+	area, err := ts.svc.AreaSvc.CreateArea(&models.Area{Name: "Hall", Manager: "an"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gwId := range []string{"gw01", "gw02"} {
+		if _, err := ts.svc.GatewaySvc.UpdateGateway(ctx, &models.Gateway{GatewayID: gwId, SoftwareVersion: "1.0.0"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := &models.BulkResult{}
+	w := ts.do(http.MethodPost, "/v1/gateways/bulk", `{"selector": {"version": "1.0.0"}, "action": "deactivate"}`)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, result)
+	if result.Matched != 2 || result.Succeeded != 2 {
+		t.Fatalf("got %+v, wanted gw01 and gw02 deactivated", result)
+	}
+	if gw, _ := ts.svc.GatewaySvc.FindGatewayByGatewayID(ctx, "gw02"); gw.DesiredState != "inactive" {
+		t.Errorf("got %s, wanted gw02 desired inactive", gw.DesiredState)
+	}
+	published := ts.client.Published()
+	if len(published) != 2 || published[0].Topic != mqttSvc.TOPIC_SV_GATEWAY_U {
+		t.Errorf("got %+v, wanted a gateway update each", published)
+	}
+
+	body := fmt.Sprintf(`{"selector": {"gateway_id": "gw03"}, "action": "reassign_area", "area_id": "%d"}`, area.ID)
+	expectStatus(t, ts.do(http.MethodPost, "/v1/gateways/bulk", body), http.StatusOK, "")
+	if gw, _ := ts.svc.GatewaySvc.FindGatewayByGatewayID(ctx, "gw03"); gw.AreaID != fmt.Sprint(area.ID) {
+		t.Errorf("got area %s, wanted Hall", gw.AreaID)
+	}
+
+	w = ts.do(http.MethodPost, "/v1/gateways/bulk", `{"selector": {"gateway_id": "gw01"}, "action": "delete"}`)
+	expectStatus(t, w, http.StatusOK, "")
+	if _, err := ts.svc.GatewaySvc.FindGatewayByGatewayID(ctx, "gw01"); models.AsDomainError(err) == nil {
+		t.Errorf("got %v, wanted gateway gone", err)
+	}
+	if uhfs, _ := ts.svc.UHFSvc.FindAllUHF(ctx); len(uhfs) != 0 {
+		t.Errorf("got %d UHFs, wanted UHFs of gateway deleted", len(uhfs))
+	}
+	published = ts.client.Published()
+	if last := published[len(published)-1]; last.Topic != mqttSvc.TOPIC_SV_GATEWAY_D {
+		t.Errorf("got %+v, wanted gateway delete", last)
+	}
+
+	expectStatus(t, ts.do(http.MethodPost, "/v1/gateways/bulk", `{"selector": {"family": "r2000"}, "action": "delete"}`),
+		http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+}
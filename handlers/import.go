@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Largest import file accepted, a building has a few hundred readers
+const MAX_IMPORT_SIZE int64 = 10 << 20
+
+type ImportHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewImportHandler(deps *HandlerDependencies) *ImportHandler {
+	return &ImportHandler{
+		deps,
+	}
+}
+
+// Import areas, gateways and UHF assignments
+// @Summary Bulk Import Areas, Gateways And UHFs
+// @Schemes
+// @Description Import a CSV or XLSX file with columns type, name, manager, gateway_id, uhf_address and area. "area" rows create areas or change their manager, "gateway" rows rename gateways and assign them to an area, "uhf" rows assign UHFs to an area. Every invalid row is reported with status 422 and nothing is changed. Changes are applied in one transaction, then sent to MQTT broker. "dry_run" only validates and returns the plan
+// @Accept  multipart/form-data
+// @Produce json
+// @Param	file	formData	file	true	"CSV or XLSX file"
+// @Param	dry_run	query	bool	false	"Validate only"
+// @Success 200 {object} models.ImportReport
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /v1/import [post]
+func (h *ImportHandler) Import(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		responseBindError(c, "Invalid dry_run", models.NewValidationError("dry_run", "dry_run must be true or false"))
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err == nil && fileHeader.Size > MAX_IMPORT_SIZE {
+		err = models.NewValidationError("file", "file is larger than %d bytes", MAX_IMPORT_SIZE)
+	}
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		responseBindError(c, "Invalid import file", err)
+		return
+	}
+	defer file.Close()
+	table, err := utils.ReadTable(file, fileHeader.Filename)
+	if err != nil {
+		responseBindError(c, "Invalid import file", models.NewValidationError("file", err.Error()))
+		return
+	}
+
+	rows, rowErrs := models.ParseImportRows(table)
+	if len(rowErrs) > 0 {
+		responseError(c, "Invalid import file", importRowsError(rowErrs))
+		return
+	}
+	ctx := c.Request.Context()
+	areas, err := h.deps.SvcOpts.AreaSvc.FindAllArea(ctx)
+	if err != nil {
+		responseError(c, "Get all areas failed", err)
+		return
+	}
+	gateways, err := h.deps.SvcOpts.GatewaySvc.FindAllGateway(ctx)
+	if err != nil {
+		responseError(c, "Get all gateways failed", err)
+		return
+	}
+	uhfs, err := h.deps.SvcOpts.UHFSvc.FindAllUHF(ctx)
+	if err != nil {
+		responseError(c, "Get all UHFs failed", err)
+		return
+	}
+	plan, rowErrs := models.PlanImport(rows, areas, gateways, uhfs)
+	if len(rowErrs) > 0 {
+		responseError(c, "Invalid import file", importRowsError(rowErrs))
+		return
+	}
+
+	report := &models.ImportReport{DryRun: dryRun, Rows: len(rows), Plan: plan, PublishErrors: []string{}}
+	if dryRun {
+		utils.ResponseJson(c, http.StatusOK, report)
+		return
+	}
+	result, err := h.deps.SvcOpts.ImportSvc.ApplyImport(ctx, plan)
+	if err != nil {
+		responseError(c, "Import failed", err)
+		return
+	}
+
+	// Changes are stored, gateways missing an update catch up on next sync
+	for i := range result.Gateways {
+		gw := &result.Gateways[i]
+		t := h.deps.Publisher.PublishToSite(ctx, mqttSvc.TOPIC_SV_GATEWAY_U, gw.Site, gw.GatewayID,
+			mqttSvc.ServerUpdateGatewayPayload(gw))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			report.PublishErrors = append(report.PublishErrors, fmt.Sprintf("gateway %s: %s", gw.GatewayID, err.Error()))
+		}
+	}
+	for i := range result.UHFs {
+		uhf := &result.UHFs[i]
+		t := h.deps.Publisher.Publish(ctx, mqttSvc.TOPIC_SV_UHF_U, uhf.GatewayID, mqttSvc.ServerUpdateUHFPayload(uhf))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			report.PublishErrors = append(report.PublishErrors,
+				fmt.Sprintf("UHF %s of gateway %s: %s", uhf.UHFAddress, uhf.GatewayID, err.Error()))
+		}
+	}
+	utils.ResponseJson(c, http.StatusOK, report)
+}
+
+// Invalid rows answer 422 with an error per cell
+func importRowsError(rowErrs []utils.FieldError) *models.DomainError {
+	return &models.DomainError{
+		Kind:   models.ErrValidation,
+		Code:   models.ERR_CODE_VALIDATION,
+		Msg:    fmt.Sprintf("%d invalid cells", len(rowErrs)),
+		Fields: rowErrs,
+	}
+}
@@ -0,0 +1,268 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+const importCSV = "type,name,manager,gateway_id,uhf_address,area\n" +
+	"area,Lobby,binh,,,\n" +
+	"gateway,Entrance,,gw01,,Lobby\n" +
+	"uhf,,,gw01,1,Lobby\n" +
+	"uhf,,,gw01,2,Hall\n"
+
+// importFile posts content as multipart file named name to /v1/import
+func (ts *testServer) importFile(t *testing.T, query string, name string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/import"+query, body)
+	req.Header.Set("X-API-Key", testAdminKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	ts.router.ServeHTTP(w, req)
+	return w
+}
+
+func (ts *testServer) seedImport(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	ts.seedGateway(t, ctx, "gw01", "1", "2")
+	if _, err := ts.svc.AreaSvc.CreateArea(&models.Area{Name: "Hall", Manager: "an"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// areaIds maps names of stored areas to their ID as gateways and UHFs keep it
+func (ts *testServer) areaIds(t *testing.T) map[string]string {
+	t.Helper()
+	areas, err := ts.svc.AreaSvc.FindAllArea(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]string{}
+	for _, a := range areas {
+		ids[a.Name] = strconv.FormatUint(uint64(a.ID), 10)
+	}
+	return ids
+}
+
+func TestImport(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedImport(t)
+	ctx := context.Background()
+
+	report := &models.ImportReport{}
+	w := ts.importFile(t, "?dry_run=true", "building.csv", []byte(importCSV))
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, report)
+	if !report.DryRun || report.Rows != 4 || len(report.Plan.NewAreas) != 1 || len(report.Plan.UHFs) != 2 {
+		t.Errorf("got %+v, wanted plan of 4 rows", report)
+	}
+	if ids := ts.areaIds(t); len(ids) != 1 || len(ts.client.Published()) != 0 {
+		t.Fatalf("got areas %v, wanted dry run to change nothing", ids)
+	}
+
+	w = ts.importFile(t, "", "building.csv", []byte(importCSV))
+	expectStatus(t, w, http.StatusOK, "")
+	report = &models.ImportReport{}
+	decode(t, w, report)
+	if report.DryRun || len(report.PublishErrors) != 0 {
+		t.Errorf("got %+v, wanted import without publish errors", report)
+	}
+	ids := ts.areaIds(t)
+	gw, err := ts.svc.GatewaySvc.FindGatewayByGatewayID(ctx, "gw01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gw.Name != "Entrance" || gw.AreaID != ids["Lobby"] {
+		t.Errorf("got %s in area %s, wanted Entrance in Lobby %s", gw.Name, gw.AreaID, ids["Lobby"])
+	}
+	for address, area := range map[string]string{"1": "Lobby", "2": "Hall"} {
+		uhf, err := ts.svc.UHFSvc.FindUHFByAddress(ctx, address, "gw01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uhf.AreaId != ids[area] {
+			t.Errorf("got UHF %s in area %s, wanted %s %s", address, uhf.AreaId, area, ids[area])
+		}
+	}
+	topics := map[string]int{}
+	for _, p := range ts.client.Published() {
+		topics[p.Topic]++
+	}
+	if topics[mqttSvc.TOPIC_SV_GATEWAY_U] != 1 || topics[mqttSvc.TOPIC_SV_UHF_U] != 2 {
+		t.Errorf("got %v, wanted a gateway and 2 UHF updates", topics)
+	}
+
+	// area exists now, a second import only changes what differs
+	w = ts.importFile(t, "?dry_run=true", "building.csv", []byte(importCSV))
+	expectStatus(t, w, http.StatusOK, "")
+	report = &models.ImportReport{}
+	decode(t, w, report)
+	if len(report.Plan.NewAreas) != 0 || len(report.Plan.UpdatedAreas) != 0 {
+		t.Errorf("got %+v, wanted no area change", report.Plan)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedImport(t)
+
+	csv := "type,name,manager,gateway_id,uhf_address,area\n" +
+		"uhf,,,gw01,1,Roof\n" +
+		"uhf,,,gw01,9,Hall\n" +
+		"door\n"
+	w := ts.importFile(t, "", "building.csv", []byte(csv))
+	expectStatus(t, w, http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+	p := struct {
+		Errors []struct {
+			Field string `json:"field"`
+			Code  string `json:"code"`
+		} `json:"errors"`
+	}{}
+	decode(t, w, &p)
+	wanted := []string{"rows[2].area", "rows[3].uhf_address", "rows[4].type"}
+	if len(p.Errors) != len(wanted) {
+		t.Fatalf("got %+v, wanted errors of %v", p.Errors, wanted)
+	}
+	for i, field := range wanted {
+		if p.Errors[i].Field != field {
+			t.Errorf("got %s, wanted %s", p.Errors[i].Field, field)
+		}
+	}
+
+	expectStatus(t, ts.importFile(t, "", "building.txt", []byte(csv)), http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+	expectStatus(t, ts.importFile(t, "", "building.xlsx", []byte(csv)), http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+	expectStatus(t, ts.importFile(t, "?dry_run=maybe", "building.csv", []byte(importCSV)), http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+	expectStatus(t, ts.do(http.MethodPost, "/v1/import", ""), http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+}
+
+func TestImportRollback(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedImport(t)
+
+	ts.store.Fail("ImportSvc.ApplyImport", fmt.Errorf("connection reset"))
+	expectStatus(t, ts.importFile(t, "", "building.csv", []byte(importCSV)), http.StatusInternalServerError, "")
+	ts.store.Fail("ImportSvc.ApplyImport", nil)
+	if ids := ts.areaIds(t); len(ids) != 1 || len(ts.client.Published()) != 0 {
+		t.Errorf("got areas %v, wanted nothing imported", ids)
+	}
+
+	// changes are kept when gateways miss their update
+	ts.client.FailPublish(fmt.Errorf("not connected"))
+	w := ts.importFile(t, "", "building.csv", []byte(importCSV))
+	expectStatus(t, w, http.StatusOK, "")
+	report := &models.ImportReport{}
+	decode(t, w, report)
+	if len(report.PublishErrors) != 3 {
+		t.Errorf("got %v, wanted 3 publish errors", report.PublishErrors)
+	}
+	if ids := ts.areaIds(t); len(ids) != 2 {
+		t.Errorf("got areas %v, wanted Lobby imported", ids)
+	}
+}
+
+// xlsxFile builds a workbook whose first sheet holds rows, strings are shared
+// or inline by turn
+func xlsxFile(t *testing.T, rows [][]string) []byte {
+	t.Helper()
+	shared := &bytes.Buffer{}
+	sheet := &bytes.Buffer{}
+	count := 0
+	for r, row := range rows {
+		// leave a blank row out like Excel does
+		fmt.Fprintf(sheet, `<row r="%d">`, r*2+1)
+		for c, value := range row {
+			if value == "" {
+				continue
+			}
+			ref := fmt.Sprintf("%c%d", 'A'+c, r*2+1)
+			if count%2 == 0 {
+				fmt.Fprintf(sheet, `<c r="%s" t="s"><v>%d</v></c>`, ref, count/2)
+				fmt.Fprintf(shared, `<si><t>%s</t></si>`, value)
+			} else {
+				fmt.Fprintf(sheet, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, value)
+			}
+			count++
+		}
+		sheet.WriteString(`</row>`)
+	}
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Import" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":     `<sst>` + shared.String() + `</sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` + sheet.String() + `</sheetData></worksheet>`,
+	}
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range parts {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportXLSX(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedImport(t)
+
+	content := xlsxFile(t, [][]string{
+		{"type", "gateway_id", "uhf_address", "area"},
+		{"uhf", "gw01", "1", "Hall"},
+		{"uhf", "gw01", "9", "Hall"},
+	})
+	w := ts.importFile(t, "", "building.xlsx", content)
+	expectStatus(t, w, http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+	p := struct {
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}{}
+	decode(t, w, &p)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "rows[5].uhf_address" {
+		t.Fatalf("got %+v, wanted unknown UHF on row 5 as Excel numbers it", p.Errors)
+	}
+
+	content = xlsxFile(t, [][]string{
+		{"type", "gateway_id", "uhf_address", "area"},
+		{"uhf", "gw01", "1", "Hall"},
+	})
+	expectStatus(t, ts.importFile(t, "", "building.xlsx", content), http.StatusOK, "")
+	uhf, err := ts.svc.UHFSvc.FindUHFByAddress(context.Background(), "1", "gw01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uhf.AreaId != ts.areaIds(t)["Hall"] {
+		t.Errorf("got area %s, wanted Hall", uhf.AreaId)
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type IngestionHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewIngestionHandler(deps *HandlerDependencies) *IngestionHandler {
+	return &IngestionHandler{
+		deps,
+	}
+}
+
+// Get tag read debounce counters
+// @Summary Get Tag Debounce Stats
+// @Schemes
+// @Description get debounce window and counters of recorded and suppressed tag reads
+// @Produce json
+// @Success 200 {object} mqttSvc.DebounceStats
+// @Router /v1/ingestion/debounce [get]
+func (h *IngestionHandler) GetDebounceStats(c *gin.Context) {
+	utils.ResponseJson(c, http.StatusOK, h.deps.Ingestion.Debouncer.Stats())
+}
+
+// Get ingestion queue and batch writer counters
+// @Summary Get Ingestion Stats
+// @Schemes
+// @Description get queue size and counters of queued, handled, overflowed and dropped MQTT messages and batched writes
+// @Produce json
+// @Success 200 {object} mqttSvc.IngestionStats
+// @Router /v1/ingestion/stats [get]
+func (h *IngestionHandler) GetIngestionStats(c *gin.Context) {
+	utils.ResponseJson(c, http.StatusOK, h.deps.Ingestion.Stats())
+}
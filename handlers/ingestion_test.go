@@ -0,0 +1,51 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func TestIngestionStats(t *testing.T) {
+	ts := newTestServer(t)
+	ts.ing.Start()
+	handler := func(c mqtt.Client, msg *mqttSvc.GatewayMessage) error {
+		return nil
+	}
+	cb := ts.ing.Register(mqttSvc.TOPIC_GW_LOG, handler)
+	cb(ts.client, &testMessage{topic: mqttSvc.TOPIC_GW_LOG, payload: `{"gateway_id": "gw01"}`})
+	ts.ing.Stop()
+
+	stats := mqttSvc.IngestionStats{}
+	w := ts.do(http.MethodGet, "/v1/ingestion/stats", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &stats)
+	if stats.Enqueued != 1 || stats.Processed != 1 {
+		t.Errorf("got %d %d, wanted 1 message enqueued and processed", stats.Enqueued, stats.Processed)
+	}
+
+	debounce := map[string]interface{}{}
+	w = ts.do(http.MethodGet, "/v1/ingestion/debounce", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &debounce)
+	expectStatus(t, ts.doAs("1", http.MethodGet, "/v1/ingestion/stats", ""), http.StatusForbidden, ERR_CODE_FORBIDDEN)
+}
+
+// testMessage is a message received on topic
+type testMessage struct {
+	topic   string
+	payload string
+}
+
+func (m *testMessage) Duplicate() bool   { return false }
+func (m *testMessage) Qos() byte         { return 1 }
+func (m *testMessage) Retained() bool    { return false }
+func (m *testMessage) Topic() string     { return m.topic }
+func (m *testMessage) MessageID() uint16 { return 0 }
+func (m *testMessage) Payload() []byte   { return []byte(m.payload) }
+func (m *testMessage) Ack()              {}
@@ -29,13 +29,9 @@ func NewGatewayLogHandler(deps *HandlerDependencies) *GatewayLogHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/gateway_logs [get]
 func (h *GatewayLogHandler) FindAllGatewayLog(c *gin.Context) {
-	glList, err := h.deps.SvcOpts.LogSvc.FindAllGatewayLog(c)
+	glList, err := h.deps.SvcOpts.LogSvc.FindAllGatewayLog(c.Request.Context())
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all gateway logs failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all gateway logs failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
@@ -52,13 +48,9 @@ func (h *GatewayLogHandler) FindAllGatewayLog(c *gin.Context) {
 // @Router /v1/gateway_logs/{id} [get]
 func (h *GatewayLogHandler) FindGatewayLogByID(c *gin.Context) {
 	id := c.Param("id")
-	gl, err := h.deps.SvcOpts.LogSvc.FindGatewayLogByID(c, id)
+	gl, err := h.deps.SvcOpts.LogSvc.FindGatewayLogByID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get gateway log failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get gateway log failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, gl)
@@ -75,13 +67,9 @@ func (h *GatewayLogHandler) FindGatewayLogByID(c *gin.Context) {
 // @Router /v1/gateway_logs/gateway_id/{id} [get]
 func (h *GatewayLogHandler) FindGatewayByGatewayID(c *gin.Context) {
 	id := c.Param("id")
-	gl, err := h.deps.SvcOpts.LogSvc.FindGatewayByGatewayID(c, id)
+	gl, err := h.deps.SvcOpts.LogSvc.FindGatewayByGatewayID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get gateway log failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get gateway log failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, gl)
@@ -106,14 +94,11 @@ func (h *GatewayLogHandler) FindGatewayLogsByGatewayIDAndTime(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.LogSvc.FindGatewayLogsByGatewayIDAndTime(gatewayId, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.LogSvc.FindGatewayLogsByGatewayIDAndTime(c.Request.Context(), gatewayId, fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to get gateway logs",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to get gateway logs", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -136,14 +121,11 @@ func (h *GatewayLogHandler) FindGatewayLogByPeriod(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.LogSvc.FindGatewayLogsByTime(fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.LogSvc.FindGatewayLogsByTime(c.Request.Context(), fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to get gateway logs",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to get gateway logs", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -165,13 +147,9 @@ func (h *GatewayLogHandler) DeleteGatewayLogInTimeRange(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	isSuccess, err := h.deps.SvcOpts.LogSvc.DeleteGatewayLogInTimeRange(fromFormatted, toFormatted)
+	isSuccess, err := h.deps.SvcOpts.LogSvc.DeleteGatewayLogInTimeRange(c.Request.Context(), fromFormatted, toFormatted)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to delete gateway logs",
-			ErrorMsg:   "There is no record in this time range",
-		})
+		responseError(c, "Failed to delete gateway logs", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
@@ -0,0 +1,72 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+// period is the path suffix of time range routes, in unix seconds
+func period(from time.Time, to time.Time) string {
+	return fmt.Sprintf("/period/%d/%d", from.Unix(), to.Unix())
+}
+
+func TestGatewayLog(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now()
+	for i, gwId := range []string{"gw01", "gw01", "gw02"} {
+		_, err := ts.svc.LogSvc.CreateGatewayLog(context.Background(), &models.GatewayLog{
+			GatewayID:  gwId,
+			StateType:  "ConnectState",
+			StateValue: "connected",
+			LogTime:    now.Add(-time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	glList := []models.GatewayLog{}
+	w := ts.do(http.MethodGet, "/v1/gateway_logs", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &glList)
+	if len(glList) != 3 {
+		t.Fatalf("got %d logs, wanted 3", len(glList))
+	}
+	expectStatus(t, ts.do(http.MethodGet, fmt.Sprintf("/v1/gateway_logs/%d", glList[0].ID), ""), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodGet, "/v1/gateway_logs/999", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	w = ts.do(http.MethodGet, "/v1/gateway_logs/gateway_id/gw01", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &glList)
+	if len(glList) != 2 {
+		t.Errorf("got %d logs, wanted 2 of gw01", len(glList))
+	}
+
+	lastHalfHour := period(now.Add(-30*time.Minute), now.Add(time.Minute))
+	w = ts.do(http.MethodGet, "/v1/gateway_logs/gateway_id/gw01"+lastHalfHour, "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &glList)
+	if len(glList) != 1 {
+		t.Errorf("got %d logs, wanted 1 of gw01 in last half hour", len(glList))
+	}
+	w = ts.do(http.MethodGet, "/v1/gateway_logs"+period(now.Add(-90*time.Minute), now.Add(time.Minute)), "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &glList)
+	if len(glList) != 2 {
+		t.Errorf("got %d logs, wanted 2 in last hour and a half", len(glList))
+	}
+
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/gateway_logs"+lastHalfHour, ""), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/gateway_logs"+lastHalfHour, ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	ts.store.Fail("LogSvc.FindAllGatewayLog", fmt.Errorf("connection reset"))
+	expectStatus(t, ts.do(http.MethodGet, "/v1/gateway_logs", ""), http.StatusInternalServerError, models.ERR_CODE_INTERNAL)
+}
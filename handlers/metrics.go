@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// Requests matching no route share one label so unknown paths can't grow series
+const UNMATCHED_ROUTE string = "unmatched"
+
+// MetricsMiddleware observes latency of requests by route template, not raw path
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = UNMATCHED_ROUTE
+		}
+		metrics.HttpRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
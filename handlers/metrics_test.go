@@ -0,0 +1,15 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	ts := newTestServer(t)
+	w := ts.doWithHeader(http.MethodGet, "/metrics", "", http.Header{})
+	expectStatus(t, w, http.StatusOK, "")
+}
@@ -29,13 +29,9 @@ func NewOperationLogHandler(deps *HandlerDependencies) *OperationLogHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/operation_logs [get]
 func (h *OperationLogHandler) FindAllOperationLog(c *gin.Context) {
-	glList, err := h.deps.SvcOpts.OperationLogSvc.GetAllOperationLogs(c)
+	glList, err := h.deps.SvcOpts.OperationLogSvc.GetAllOperationLogs(c.Request.Context())
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all gateway logs failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all gateway logs failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
@@ -52,13 +48,9 @@ func (h *OperationLogHandler) FindAllOperationLog(c *gin.Context) {
 // @Router /v1/operation_logs/gateway_id/{gateway_id} [get]
 func (h *OperationLogHandler) FindOperationLogByGatewayID(c *gin.Context) {
 	id := c.Param("gateway_id")
-	gl, err := h.deps.SvcOpts.OperationLogSvc.GetOperationLogByGatewayID(c, id)
+	gl, err := h.deps.SvcOpts.OperationLogSvc.GetOperationLogByGatewayID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get gateway log failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get gateway log failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, gl)
@@ -75,13 +67,9 @@ func (h *OperationLogHandler) FindOperationLogByGatewayID(c *gin.Context) {
 // @Router /v1/operation_logs/{id} [get]
 func (h *OperationLogHandler) FindAllOperationLogByID(c *gin.Context) {
 	id := c.Param("id")
-	ol, err := h.deps.SvcOpts.OperationLogSvc.GetOperationLogByID(c, id)
+	ol, err := h.deps.SvcOpts.OperationLogSvc.GetOperationLogByID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get gateway log failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get gateway log failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, ol)
@@ -106,14 +94,11 @@ func (h *OperationLogHandler) FindOperationLogsByGatewayIDAndTime(c *gin.Context
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.OperationLogSvc.FindOperationLogsByGatewayIDAndTime(gatewayId, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.OperationLogSvc.FindOperationLogsByGatewayIDAndTime(c.Request.Context(), gatewayId, fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to get gateway logs",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to get gateway logs", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -136,14 +121,11 @@ func (h *OperationLogHandler) FindOperationLogsByTime(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.OperationLogSvc.FindOperationLogsByTime(fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.OperationLogSvc.FindOperationLogsByTime(c.Request.Context(), fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to get gateway logs",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to get gateway logs", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -165,13 +147,9 @@ func (h *OperationLogHandler) DeleteOperationLogInTimeRange(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	isSuccess, err := h.deps.SvcOpts.OperationLogSvc.DeleteOperationLogInTimeRange(fromFormatted, toFormatted)
+	isSuccess, err := h.deps.SvcOpts.OperationLogSvc.DeleteOperationLogInTimeRange(c.Request.Context(), fromFormatted, toFormatted)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to delete operation logs",
-			ErrorMsg:   "There is no record in this time range",
-		})
+		responseError(c, "Failed to delete operation logs", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
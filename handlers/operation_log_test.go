@@ -0,0 +1,58 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestOperationLog(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now()
+	for i, gwId := range []string{"gw01", "gw01", "gw02"} {
+		_, err := ts.svc.OperationLogSvc.CreateOperationLog(context.Background(), &models.OperationLog{
+			GatewayID: gwId,
+			Content:   "reboot",
+			Time:      now.Add(-time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lastHalfHour := period(now.Add(-30*time.Minute), now.Add(time.Minute))
+	cases := []struct {
+		path string
+		n    int
+	}{
+		{"/v1/operation_logs", 3},
+		{"/v1/operation_logs/gateway_id/gw01", 2},
+		{"/v1/operation_logs/gateway_id/gw01" + lastHalfHour, 1},
+		{"/v1/operation_logs" + lastHalfHour, 1},
+	}
+	for _, c := range cases {
+		olList := []models.OperationLog{}
+		w := ts.do(http.MethodGet, c.path, "")
+		expectStatus(t, w, http.StatusOK, "")
+		decode(t, w, &olList)
+		if len(olList) != c.n {
+			t.Errorf("%s: got %d logs, wanted %d", c.path, len(olList), c.n)
+		}
+	}
+
+	olList, _ := ts.svc.OperationLogSvc.GetAllOperationLogs(context.Background())
+	expectStatus(t, ts.do(http.MethodGet, fmt.Sprintf("/v1/operation_logs/%d", olList[0].ID), ""), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodGet, "/v1/operation_logs/999", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/operation_logs"+lastHalfHour, ""), http.StatusOK, "")
+	if olList, _ = ts.svc.OperationLogSvc.GetAllOperationLogs(context.Background()); len(olList) != 2 {
+		t.Errorf("got %d logs, wanted 2 older than half an hour", len(olList))
+	}
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"github.com/gin-gonic/gin"
@@ -29,13 +28,9 @@ func NewPackageAccessHandler(deps *HandlerDependencies) *PackageAccessHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/package_accesses [get]
 func (h *PackageAccessHandler) FindAllAccesses(c *gin.Context) {
-	gwList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccess(c)
+	gwList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccess(c.Request.Context())
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all accesses failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all accesses failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, gwList)
@@ -51,13 +46,9 @@ func (h *PackageAccessHandler) FindAllAccesses(c *gin.Context) {
 // @Router /v1/package_accesses/package_id/{id} [get]
 func (h *PackageAccessHandler) FindPackageAccessByPackageID(c *gin.Context) {
 	id := c.Param("id")
-	accesslist, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessByPackageID(context.Background(), id)
+	accesslist, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessByPackageID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all accesses failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all accesses failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, accesslist)
@@ -79,14 +70,11 @@ func (h *PackageAccessHandler) FindPackageAccessByPackageIDAndTimeRange(c *gin.C
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindPackageAccessByPackageIDAndTimeRange(context.Background(), package_id, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindPackageAccessByPackageIDAndTimeRange(c.Request.Context(), package_id, fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -108,14 +96,11 @@ func (h *PackageAccessHandler) FindAllPackageAccessByPackageIDAndAreaIDinTimeRan
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessByPackageIDAndAreaIDinTimeRange(context.Background(), package_id, area_id, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessByPackageIDAndAreaIDinTimeRange(c.Request.Context(), package_id, area_id, fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -131,14 +116,11 @@ func (h *PackageAccessHandler) FindAllPackageAccessByPackageIDAndAreaIDinTimeRan
 func (h *PackageAccessHandler) FindPackageAccessByPackageIDAndAreaID(c *gin.Context) {
 	package_id := c.Param("id")
 	area_id := c.Param("area_id")
-	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindPackageAccessByPackageIDAndAreaID(context.Background(), package_id, area_id)
+	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindPackageAccessByPackageIDAndAreaID(c.Request.Context(), package_id, area_id)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -153,14 +135,11 @@ func (h *PackageAccessHandler) FindPackageAccessByPackageIDAndAreaID(c *gin.Cont
 // @Router /v1/package_accesses/area_id/{area_id} [get]
 func (h *PackageAccessHandler) FindAllPackageAccessByAreaID(c *gin.Context) {
 	area_id := c.Param("area_id")
-	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessByAreaID(context.Background(), area_id)
+	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessByAreaID(c.Request.Context(), area_id)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -182,13 +161,10 @@ func (h *PackageAccessHandler) FindAllPackageAccessByAreaIDAndTimeRange(c *gin.C
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessByAreaIDAndTimeRange(context.Background(), area_id, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessByAreaIDAndTimeRange(c.Request.Context(), area_id, fromFormatted, toFormatted)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -208,13 +184,10 @@ func (h *PackageAccessHandler) FindAllPackageAccessTimeRange(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessTimeRange(context.Background(), fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.PackageAccessSvc.FindAllPackageAccessTimeRange(c.Request.Context(), fromFormatted, toFormatted)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -236,13 +209,9 @@ func (h *PackageAccessHandler) DeletePackageAccessTimeRange(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	isSuccess, err := h.deps.SvcOpts.PackageAccessSvc.DeletePackageAccessTimeRange(fromFormatted, toFormatted)
+	isSuccess, err := h.deps.SvcOpts.PackageAccessSvc.DeletePackageAccessTimeRange(c.Request.Context(), fromFormatted, toFormatted)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to delete package accesses logs",
-			ErrorMsg:   "There is no record in this time range",
-		})
+		responseError(c, "Failed to delete package accesses logs", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
@@ -0,0 +1,55 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestPackageAccess(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now()
+	seeds := []models.PackageAccess{
+		{PackageID: "p1", AreaID: "1", Time: now},
+		{PackageID: "p1", AreaID: "2", Time: now.Add(-time.Hour)},
+		{PackageID: "p2", AreaID: "1", Time: now.Add(-2 * time.Hour)},
+	}
+	if err := ts.svc.PackageAccessSvc.CreatePackageAccesses(context.Background(), seeds, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	lastHalfHour := period(now.Add(-30*time.Minute), now.Add(time.Minute))
+	cases := []struct {
+		path string
+		n    int
+	}{
+		{"/v1/package_accesses", 3},
+		{"/v1/package_accesses/package_id/p1", 2},
+		{"/v1/package_accesses/package_id/p1/area_id/2", 1},
+		{"/v1/package_accesses/package_id/p1" + lastHalfHour, 1},
+		{"/v1/package_accesses/package_id/p1/area_id/1" + lastHalfHour, 1},
+		{"/v1/package_accesses/area_id/1", 2},
+		{"/v1/package_accesses/area_id/1" + lastHalfHour, 1},
+		{"/v1/package_accesses" + period(now.Add(-90*time.Minute), now.Add(time.Minute)), 2},
+	}
+	for _, c := range cases {
+		paList := []models.PackageAccess{}
+		w := ts.do(http.MethodGet, c.path, "")
+		expectStatus(t, w, http.StatusOK, "")
+		decode(t, w, &paList)
+		if len(paList) != c.n {
+			t.Errorf("%s: got %d accesses, wanted %d", c.path, len(paList), c.n)
+		}
+	}
+
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/package_accesses"+lastHalfHour, ""), http.StatusOK, "")
+	if paList, _ := ts.svc.PackageAccessSvc.FindAllPackageAccess(context.Background()); len(paList) != 2 {
+		t.Errorf("got %d accesses, wanted 2 older than half an hour", len(paList))
+	}
+}
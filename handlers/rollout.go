@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type RolloutHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewRolloutHandler(deps *HandlerDependencies) *RolloutHandler {
+	return &RolloutHandler{
+		deps,
+	}
+}
+
+// Find all rollout campaigns
+// @Summary Find All Rollout
+// @Schemes
+// @Description find all firmware rollout campaigns
+// @Produce json
+// @Success 200 {array} []models.RolloutCampaign
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/rollouts [get]
+func (h *RolloutHandler) FindAllRollout(c *gin.Context) {
+	rList, err := h.deps.SvcOpts.RolloutSvc.FindAllRollout(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get all rollouts failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, rList)
+}
+
+// Find rollout campaign by id
+// @Summary Find Rollout By ID
+// @Schemes
+// @Description find rollout campaign with its targets and progress
+// @Produce json
+// @Param        id	path	string	true	"Rollout ID"
+// @Success 200 {object} models.RolloutCampaign
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/rollout/{id} [get]
+func (h *RolloutHandler) FindRolloutByID(c *gin.Context) {
+	id := c.Param("id")
+	r, err := h.deps.SvcOpts.RolloutSvc.FindRolloutByID(c.Request.Context(), id)
+	if err != nil {
+		responseError(c, "Get rollout failed", err)
+		return
+	}
+	r.Progress, _ = h.deps.SvcOpts.RolloutSvc.GetRolloutProgress(c.Request.Context(), r.ID)
+	utils.ResponseJson(c, http.StatusOK, r)
+}
+
+// Create rollout campaign
+// @Summary Create Rollout
+// @Schemes
+// @Description Create rollout campaign, target gateways are selected by area and software version
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.CreateRollout	true	"Fields need to create a rollout"
+// @Success 200 {object} models.RolloutCampaign
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/rollout [post]
+func (h *RolloutHandler) CreateRollout(c *gin.Context) {
+	cr := &models.CreateRollout{}
+	err := c.ShouldBind(cr)
+	if err == nil && (cr.FailureThreshold < 0 || cr.FailureThreshold > 1) {
+		err = fmt.Errorf("failure_threshold must be between 0 and 1")
+	}
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+
+	r := &models.RolloutCampaign{
+		Name:             cr.Name,
+		FirmwareID:       cr.FirmwareID,
+		TargetAreaID:     cr.TargetAreaID,
+		TargetVersion:    cr.TargetVersion,
+		BatchSize:        cr.BatchSize,
+		FailureThreshold: cr.FailureThreshold,
+		MinFinished:      cr.MinFinished,
+	}
+	r, err = h.deps.SvcOpts.RolloutSvc.CreateRollout(c.Request.Context(), r)
+	if err != nil {
+		responseError(c, "Create rollout failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, r)
+}
+
+// Control rollout campaign
+// @Summary Start, Pause Or Resume Rollout
+// @Schemes
+// @Description Change rollout state with "action": start, pause, resume. Halted and completed rollouts can't be changed
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.RolloutCmd	true	"Rollout command"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/rollout [patch]
+func (h *RolloutHandler) UpdateRollout(c *gin.Context) {
+	cmd := &models.RolloutCmd{}
+	err := c.ShouldBind(cmd)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+
+	r, err := h.deps.SvcOpts.RolloutSvc.FindRolloutByID(c.Request.Context(), fmt.Sprint(cmd.ID))
+	if err != nil {
+		responseError(c, "Find rollout failed", err)
+		return
+	}
+
+	status := ""
+	switch {
+	case cmd.Action == "start" && r.Status == models.ROLLOUT_PENDING,
+		cmd.Action == "resume" && r.Status == models.ROLLOUT_PAUSED:
+		status = models.ROLLOUT_RUNNING
+	case cmd.Action == "pause" && r.Status == models.ROLLOUT_RUNNING:
+		status = models.ROLLOUT_PAUSED
+	default:
+		responseError(c, "Invalid rollout action",
+			models.NewConflictError("can't %s rollout in %s state", cmd.Action, r.Status))
+		return
+	}
+
+	isSuccess, err := h.deps.SvcOpts.RolloutSvc.UpdateRolloutStatus(c.Request.Context(), cmd.ID, status, "")
+	if err != nil || !isSuccess {
+		responseError(c, "Update rollout failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Delete rollout campaign
+// @Summary Delete Rollout By ID
+// @Schemes
+// @Description Delete rollout campaign which is not running
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Rollout ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/rollout [delete]
+func (h *RolloutHandler) DeleteRollout(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+
+	isSuccess, err := h.deps.SvcOpts.RolloutSvc.DeleteRollout(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		responseError(c, "Delete rollout failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
@@ -0,0 +1,80 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestRollout(t *testing.T) {
+	ts := newTestServer(t)
+	for _, gwId := range []string{"gw01", "gw02"} {
+		ts.seedGateway(t, context.Background(), gwId)
+		if _, err := ts.svc.GatewaySvc.UpdateGateway(context.Background(), &models.Gateway{GatewayID: gwId, SoftwareVersion: "1.0.0"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fw, err := ts.svc.FirmwareSvc.CreateFirmware(context.Background(), &models.Firmware{Version: "1.1.0"}, strings.NewReader("firmware"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, ts.do(http.MethodPost, "/v1/rollout", fmt.Sprintf(`{"name": "q3", "firmware_id": %d, "failure_threshold": 2}`, fw.ID)),
+		http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+	expectStatus(t, ts.do(http.MethodPost, "/v1/rollout", fmt.Sprintf(`{"name": "q3", "firmware_id": %d, "target_version": "0.9.0"}`, fw.ID)),
+		http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+	expectStatus(t, ts.do(http.MethodPost, "/v1/rollout", `{"name": "q3", "firmware_id": 999}`),
+		http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	r := &models.RolloutCampaign{}
+	w := ts.do(http.MethodPost, "/v1/rollout", fmt.Sprintf(`{"name": "q3", "firmware_id": %d, "batch_size": 1}`, fw.ID))
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, r)
+	if r.Status != models.ROLLOUT_PENDING || len(r.Targets) != 2 {
+		t.Errorf("got %s %d, wanted pending rollout of 2 gateways", r.Status, len(r.Targets))
+	}
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/firmware", fmt.Sprintf(`{"id": %d}`, fw.ID)), http.StatusConflict, models.ERR_CODE_CONFLICT)
+
+	rList := []models.RolloutCampaign{}
+	w = ts.do(http.MethodGet, "/v1/rollouts", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &rList)
+	if len(rList) != 1 {
+		t.Errorf("got %d rollouts, wanted 1", len(rList))
+	}
+
+	found := &models.RolloutCampaign{}
+	w = ts.do(http.MethodGet, fmt.Sprintf("/v1/rollout/%d", r.ID), "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, found)
+	if found.Progress[models.TARGET_PENDING] != 2 {
+		t.Errorf("got %v, wanted 2 pending targets", found.Progress)
+	}
+	expectStatus(t, ts.do(http.MethodGet, "/v1/rollout/999", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	cmd := func(action string) string {
+		return fmt.Sprintf(`{"id": %d, "action": "%s"}`, r.ID, action)
+	}
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/rollout", cmd("pause")), http.StatusConflict, models.ERR_CODE_CONFLICT)
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/rollout", cmd("start")), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/rollout", fmt.Sprintf(`{"id": %d}`, r.ID)), http.StatusConflict, models.ERR_CODE_CONFLICT)
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/rollout", cmd("pause")), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/rollout", cmd("resume")), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/rollout", cmd("pause")), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/rollout", `{"id": 999, "action": "start"}`), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	// firmware stays while paused rollout may still send it
+	fwId := fmt.Sprintf(`{"id": %d}`, fw.ID)
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/firmware", fwId), http.StatusConflict, models.ERR_CODE_CONFLICT)
+
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/rollout", fmt.Sprintf(`{"id": %d}`, r.ID)), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/rollout", fmt.Sprintf(`{"id": %d}`, r.ID)), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/firmware", fwId), http.StatusOK, "")
+}
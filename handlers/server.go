@@ -12,16 +12,41 @@ func SetupRouter(
 
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
+	r.Use(TracingMiddleware())
 	r.Use(logger.GinLogger())
+	r.Use(MetricsMiddleware())
 	r.Use(CORSMiddleware())
+	r.NoRoute(NoRoute)
+	// Probes and metrics without API key
+	r.GET("/healthz", hOpts.SystemHandler.Healthz)
+	r.GET("/readyz", hOpts.SystemHandler.Readyz)
+	r.GET("/metrics", gin.WrapH(hOpts.Metrics))
 	v1R := r.Group("/v1")
+	// Gateways download firmware without API key, with a signed URL instead
+	v1R.GET("/firmware/:id/artifact", hOpts.FirmwareHandler.DownloadFirmware)
+
+	v1R.Use(hOpts.TenantHandler.Authenticate())
 	{
+		// Organization, site and API key routes
+		v1R.GET("/organizations", hOpts.TenantHandler.FindAllOrganization)
+		v1R.GET("/sites", hOpts.TenantHandler.FindAllSite)
+		v1R.POST("/site", hOpts.TenantHandler.CreateSite)
+		v1R.PATCH("/site", hOpts.TenantHandler.UpdateSite)
+		v1R.DELETE("/site", hOpts.TenantHandler.DeleteSite)
+		v1R.GET("/api_keys", hOpts.TenantHandler.FindAllApiKey)
+		v1R.POST("/api_key", hOpts.TenantHandler.CreateApiKey)
+		v1R.DELETE("/api_key", hOpts.TenantHandler.DeleteApiKey)
+
+		v1R.GET("/system/status", hOpts.SystemHandler.SystemStatus)
+
 		// Gateway routes
 		v1R.GET("/gateways", hOpts.GatewayHandler.FindAllGateway)
+		v1R.GET("/gateways/drift", hOpts.GatewayHandler.FindDriftedGateways)
 		v1R.GET("/gateway/:id", hOpts.GatewayHandler.FindGatewayByID)
 		v1R.GET("/gateway/gateway_id/:gateway_id", hOpts.GatewayHandler.FindGatewayByGatewayID)
 		v1R.PATCH("/gateway", hOpts.GatewayHandler.UpdateGateway)
 		v1R.DELETE("/gateway", hOpts.GatewayHandler.DeleteGateway)
+		v1R.POST("/gateways/bulk", hOpts.GatewayHandler.BulkUpdateGateways)
 
 		// Area routes
 		v1R.GET("/areas", hOpts.AreaHandler.FindAllArea)
@@ -32,9 +57,21 @@ func SetupRouter(
 
 		// UHF routes
 		v1R.GET("/uhfs", hOpts.UHFHandler.FindAllUHFs)
+		v1R.GET("/uhfs/drift", hOpts.UHFHandler.FindDriftedUHFs)
+		v1R.GET("/uhfs/config_drift", hOpts.UHFConfigHandler.FindConfigMismatchedUHFs)
 		v1R.GET("/uhf/:id", hOpts.UHFHandler.FindUHFByID)
 		v1R.PATCH("/uhf", hOpts.UHFHandler.UpdateUHF)
 		v1R.DELETE("/uhf", hOpts.UHFHandler.DeleteUHF)
+		v1R.POST("/uhfs/bulk", hOpts.UHFHandler.BulkUpdateUHFs)
+		v1R.PATCH("/uhf/config", hOpts.UHFConfigHandler.UpdateUHFConfig)
+
+		// UHF config template routes
+		v1R.GET("/uhf_config_templates", hOpts.UHFConfigHandler.FindAllTemplate)
+		v1R.GET("/uhf_config_template/:id", hOpts.UHFConfigHandler.FindTemplateByID)
+		v1R.POST("/uhf_config_template", hOpts.UHFConfigHandler.CreateTemplate)
+		v1R.POST("/uhf_config_template/apply", hOpts.UHFConfigHandler.ApplyTemplate)
+		v1R.PATCH("/uhf_config_template", hOpts.UHFConfigHandler.UpdateTemplate)
+		v1R.DELETE("/uhf_config_template", hOpts.UHFConfigHandler.DeleteTemplate)
 
 		// Gateway log routes
 		v1R.GET("/gateway_logs", hOpts.LogHandler.FindAllGatewayLog)
@@ -77,6 +114,55 @@ func SetupRouter(
 		v1R.GET("/package_accesses/area_id/:area_id/period/:from/:to", hOpts.PackageAccessHandler.FindAllPackageAccessByAreaIDAndTimeRange)
 		v1R.GET("/package_accesses/period/:from/:to", hOpts.PackageAccessHandler.FindAllPackageAccessTimeRange)
 		v1R.DELETE("/package_accesses/period/:fromTime/:toTime", hOpts.PackageAccessHandler.DeletePackageAccessTimeRange)
+
+		// Tag read error routes
+		v1R.GET("/tag_read_errors", hOpts.TagReadErrorHandler.FindTagReadErrors)
+		v1R.DELETE("/tag_read_errors/period/:fromTime/:toTime", hOpts.TagReadErrorHandler.DeleteTagReadErrorInTimeRange)
+
+		// Firmware routes
+		v1R.GET("/firmwares", hOpts.FirmwareHandler.FindAllFirmware)
+		v1R.GET("/firmware/:id", hOpts.FirmwareHandler.FindFirmwareByID)
+
+		// Import routes
+		v1R.POST("/import", hOpts.ImportHandler.Import)
+
+		// Trash routes
+		v1R.GET("/trash", hOpts.TrashHandler.FindTrash)
+		v1R.POST("/trash/restore", hOpts.TrashHandler.Restore)
+		v1R.DELETE("/trash", hOpts.TrashHandler.Purge)
+	}
+
+	// Routes spanning every organization
+	sysR := v1R.Group("", hOpts.TenantHandler.RequireSystem())
+	{
+		sysR.POST("/organization", hOpts.TenantHandler.CreateOrganization)
+		sysR.PATCH("/organization", hOpts.TenantHandler.UpdateOrganization)
+		sysR.DELETE("/organization", hOpts.TenantHandler.DeleteOrganization)
+
+		// Ingestion routes
+		sysR.GET("/ingestion/debounce", hOpts.IngestionHandler.GetDebounceStats)
+		sysR.GET("/ingestion/stats", hOpts.IngestionHandler.GetIngestionStats)
+
+		// Dead letter routes
+		sysR.GET("/dead_letters", hOpts.DeadLetterHandler.FindDeadLetters)
+		sysR.GET("/dead_letter/:id", hOpts.DeadLetterHandler.FindDeadLetterByID)
+		sysR.POST("/dead_letter/replay", hOpts.DeadLetterHandler.ReplayDeadLetters)
+		sysR.DELETE("/dead_letter", hOpts.DeadLetterHandler.DeleteDeadLetter)
+
+		// Firmware routes
+		sysR.POST("/firmware", hOpts.FirmwareHandler.CreateFirmware)
+		sysR.DELETE("/firmware", hOpts.FirmwareHandler.DeleteFirmware)
+
+		// Firmware rollout routes
+		sysR.GET("/rollouts", hOpts.RolloutHandler.FindAllRollout)
+		sysR.GET("/rollout/:id", hOpts.RolloutHandler.FindRolloutByID)
+		sysR.POST("/rollout", hOpts.RolloutHandler.CreateRollout)
+		sysR.PATCH("/rollout", hOpts.RolloutHandler.UpdateRollout)
+		sysR.DELETE("/rollout", hOpts.RolloutHandler.DeleteRollout)
+
+		// Log level routes
+		sysR.GET("/system/log_levels", hOpts.SystemHandler.FindLogLevels)
+		sysR.PATCH("/system/log_levels", hOpts.SystemHandler.UpdateLogLevels)
 	}
 	return r
 }
@@ -86,8 +172,9 @@ func CORSMiddleware() gin.HandlerFunc {
 
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Accept, Origin, Cache-Control, X-Requested-With, User-Agent, Accept-Language, Accept-Encoding")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, X-API-Key, X-Organization-ID, X-Correlation-ID, If-Match, traceparent, tracestate, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Accept, Origin, Cache-Control, X-Requested-With, User-Agent, Accept-Language, Accept-Encoding")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Correlation-ID, ETag")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -0,0 +1,215 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/fakes"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const testAdminKey string = "test-admin-key"
+
+// testServer is the router of every handler wired to fake services and a
+// fake MQTT client
+type testServer struct {
+	router    *gin.Engine
+	store     *fakes.Store
+	svc       *models.ServiceOptions
+	client    *fakes.Client
+	publisher *mqttSvc.Publisher
+	ing       *mqttSvc.Ingestion
+	monitor   *mqttSvc.ConnectionMonitor
+	signer    *models.ArtifactSigner
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store := fakes.NewStore()
+	svc := fakes.NewServiceOptions(store)
+	client := fakes.NewClient()
+
+	topics, err := mqttSvc.NewTopics(mqttSvc.DEFAULT_TOPIC_PREFIX, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := mqttSvc.NewTagDecoder(mqttSvc.TAG_ENCODING_AUTO, mqttSvc.DEFAULT_TAG_TYPES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ing := mqttSvc.NewIngestion(mqttSvc.IngestionOptions{Workers: 1, QueueSize: 10}, topics,
+		mqttSvc.NewReadDebouncer(0), tags, nil, mqttSvc.NewBatchWriter(svc, 10, 0, 100), svc.DeadLetterSvc)
+	signer, err := models.NewArtifactSigner("test-secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps := &HandlerDependencies{
+		SvcOpts:   svc,
+		Publisher: mqttSvc.NewPublisher(client, topics, svc.GatewaySvc),
+		Ingestion: ing,
+		Monitor:   mqttSvc.NewConnectionMonitor(),
+	}
+	hOpts := &HandlerOptions{
+		AreaHandler:          NewAreaHandler(deps),
+		GatewayHandler:       NewGatewayHandler(deps),
+		LogHandler:           NewGatewayLogHandler(deps),
+		UHFStatusLogHandler:  NewUHFStatusLogHandler(deps),
+		UHFHandler:           NewUHFHandler(deps),
+		UserAccessHandler:    NewUserAccessHandler(deps),
+		PackageAccessHandler: NewPackageAccessHandler(deps),
+		OperationLogHandler:  NewOperationLogHandler(deps),
+		FirmwareHandler:      NewFirmwareHandler(deps, signer),
+		RolloutHandler:       NewRolloutHandler(deps),
+		UHFConfigHandler:     NewUHFConfigHandler(deps),
+		IngestionHandler:     NewIngestionHandler(deps),
+		DeadLetterHandler:    NewDeadLetterHandler(deps),
+		TagReadErrorHandler:  NewTagReadErrorHandler(deps),
+		TenantHandler:        NewTenantHandler(deps, []string{testAdminKey}),
+		SystemHandler:        NewSystemHandler(deps),
+		ImportHandler:        NewImportHandler(deps),
+		TrashHandler:         NewTrashHandler(deps),
+		Metrics:              metrics.Handler(prometheus.NewRegistry()),
+	}
+	return &testServer{
+		router:    SetupRouter(hOpts),
+		store:     store,
+		svc:       svc,
+		client:    client,
+		publisher: deps.Publisher,
+		ing:       ing,
+		monitor:   deps.Monitor,
+		signer:    signer,
+	}
+}
+
+// do sends request with admin key, acting as system tenant
+func (ts *testServer) do(method string, path string, body string) *httptest.ResponseRecorder {
+	return ts.doWithHeader(method, path, body, http.Header{"X-Api-Key": {testAdminKey}})
+}
+
+// doIfMatch sends request with admin key and If-Match header tag
+func (ts *testServer) doIfMatch(tag string, method string, path string, body string) *httptest.ResponseRecorder {
+	return ts.doWithHeader(method, path, body, http.Header{
+		"X-Api-Key": {testAdminKey},
+		"If-Match":  {tag},
+	})
+}
+
+// doAs sends request with admin key acting as organization orgId
+func (ts *testServer) doAs(orgId string, method string, path string, body string) *httptest.ResponseRecorder {
+	return ts.doWithHeader(method, path, body, http.Header{
+		"X-Api-Key":         {testAdminKey},
+		"X-Organization-Id": {orgId},
+	})
+}
+
+func (ts *testServer) doWithHeader(method string, path string, body string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	ts.router.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("got %v, wanted JSON body, body %s", err, w.Body.String())
+	}
+}
+
+// expectStatus checks status of response, problem code too when code is set
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got %d, wanted %d, body %s", w.Code, status, w.Body.String())
+	}
+	if code == "" {
+		return
+	}
+	p := struct {
+		Code string `json:"code"`
+	}{}
+	decode(t, w, &p)
+	if p.Code != code {
+		t.Errorf("got %s, wanted %s", p.Code, code)
+	}
+}
+
+// seedGateway creates gateway gwId with UHFs of addresses, in organization of ctx
+func (ts *testServer) seedGateway(t *testing.T, ctx context.Context, gwId string, addresses ...string) *models.Gateway {
+	t.Helper()
+	gw, err := ts.svc.GatewaySvc.CreateGateway(ctx, &models.Gateway{GatewayID: gwId, Name: gwId, ConnectState: "connected"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range addresses {
+		_, err := ts.svc.UHFSvc.CreateUHF(ctx, &models.UHF{
+			GatewayID:       gwId,
+			UHFAddress:      address,
+			UHFSerialNumber: gwId + "-" + address,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gw
+}
+
+func TestAuthenticate(t *testing.T) {
+	ts := newTestServer(t)
+	created, err := ts.svc.TenantSvc.CreateApiKey(models.WithTenant(context.Background(), 1), &models.ApiKey{Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		header http.Header
+		status int
+		code   string
+	}{
+		{http.Header{}, http.StatusUnauthorized, ERR_CODE_UNAUTHORIZED},
+		{http.Header{"X-Api-Key": {"unknown"}}, http.StatusUnauthorized, ERR_CODE_UNAUTHORIZED},
+		{http.Header{"X-Api-Key": {testAdminKey}, "X-Organization-Id": {"abc"}}, http.StatusBadRequest, ERR_CODE_INVALID_REQUEST},
+		{http.Header{"X-Api-Key": {testAdminKey}}, http.StatusOK, ""},
+		{http.Header{"X-Api-Key": {created.Key}}, http.StatusOK, ""},
+		{http.Header{"Authorization": {"Bearer " + created.Key}}, http.StatusOK, ""},
+	}
+	for i, c := range cases {
+		w := ts.doWithHeader(http.MethodGet, "/v1/gateways", "", c.header)
+		if w.Code != c.status {
+			t.Errorf("case %d: got %d, wanted %d", i, w.Code, c.status)
+		}
+		if c.code != "" {
+			expectStatus(t, w, c.status, c.code)
+		}
+	}
+}
+
+func TestRequireSystem(t *testing.T) {
+	ts := newTestServer(t)
+	expectStatus(t, ts.doAs("1", http.MethodGet, "/v1/dead_letters", ""), http.StatusForbidden, ERR_CODE_FORBIDDEN)
+	expectStatus(t, ts.do(http.MethodGet, "/v1/dead_letters", ""), http.StatusOK, "")
+}
+
+func TestNoRoute(t *testing.T) {
+	ts := newTestServer(t)
+	w := ts.do(http.MethodGet, "/v1/unknown", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got %d, wanted %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Set at build time with -ldflags "-X github.com/ecoprohcm/DMS_BackendServer/handlers.BuildVersion=..."
+var BuildVersion = "dev"
+
+const (
+	CHECK_OK   string = "ok"
+	CHECK_FAIL string = "fail"
+)
+
+type SystemHandler struct {
+	deps      *HandlerDependencies
+	startedAt time.Time
+}
+
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+type SystemStatus struct {
+	Version              string                   `json:"version"`
+	StartedAt            time.Time                `json:"started_at"`
+	Uptime               string                   `json:"uptime"`
+	SchemaVersion        int                      `json:"schema_version"`
+	Broker               mqttSvc.ConnectionStatus `json:"broker"`
+	BrokerConnected      bool                     `json:"broker_connected"`
+	GatewaysConnectState map[string]int64         `json:"gateways_connect_state"`
+}
+
+func NewSystemHandler(deps *HandlerDependencies) *SystemHandler {
+	return &SystemHandler{
+		deps:      deps,
+		startedAt: time.Now(),
+	}
+}
+
+// Liveness probe
+// @Summary Liveness
+// @Schemes
+// @Description process is up and serving requests
+// @Produce json
+// @Success 200 {object} HealthCheck
+// @Router /healthz [get]
+func (h *SystemHandler) Healthz(c *gin.Context) {
+	utils.ResponseJson(c, http.StatusOK, &HealthCheck{Status: CHECK_OK})
+}
+
+// Readiness probe
+// @Summary Readiness
+// @Schemes
+// @Description database reachable, broker connected, gateway topics subscribed and schema migrated
+// @Produce json
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /readyz [get]
+func (h *SystemHandler) Readyz(c *gin.Context) {
+	ctx := c.Request.Context()
+	resp := &ReadinessResponse{
+		Ready:  true,
+		Checks: map[string]HealthCheck{},
+	}
+	check := func(name string, err error) {
+		if err != nil {
+			resp.Ready = false
+			resp.Checks[name] = HealthCheck{Status: CHECK_FAIL, Error: err.Error()}
+			return
+		}
+		resp.Checks[name] = HealthCheck{Status: CHECK_OK}
+	}
+
+	check("database", h.deps.SvcOpts.SchemaSvc.Ping(ctx))
+
+	var mqttErr error
+	if !h.deps.Publisher.IsConnectionOpen() {
+		mqttErr = fmt.Errorf("broker connection is not open")
+	}
+	check("mqtt", mqttErr)
+
+	var subErr error
+	if status := h.deps.Monitor.Status(); !status.AllSubscribed {
+		subErr = fmt.Errorf("gateway topics not subscribed, failed: %v, missing: %v", status.FailedTopics, status.MissingTopics)
+	}
+	check("subscriptions", subErr)
+
+	version, err := h.deps.SvcOpts.SchemaSvc.SchemaVersion(ctx)
+	if err == nil && version < models.SCHEMA_VERSION {
+		err = fmt.Errorf("schema version %d, wanted %d", version, models.SCHEMA_VERSION)
+	}
+	check("migration", err)
+
+	if !resp.Ready {
+		utils.ResponseJson(c, http.StatusServiceUnavailable, resp)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, resp)
+}
+
+// System status
+// @Summary System Status
+// @Schemes
+// @Description build version, uptime, broker connection history and gateways by connect state of organization
+// @Produce json
+// @Success 200 {object} SystemStatus
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/system/status [get]
+func (h *SystemHandler) SystemStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+	counts, err := h.deps.SvcOpts.GatewaySvc.CountGatewaysByConnectState(ctx)
+	if err != nil {
+		responseError(c, "Get gateway counts failed", err)
+		return
+	}
+	version, err := h.deps.SvcOpts.SchemaSvc.SchemaVersion(ctx)
+	if err != nil {
+		responseError(c, "Get schema version failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, &SystemStatus{
+		Version:              BuildVersion,
+		StartedAt:            h.startedAt,
+		Uptime:               time.Since(h.startedAt).Round(time.Second).String(),
+		SchemaVersion:        version,
+		Broker:               h.deps.Monitor.Status(),
+		BrokerConnected:      h.deps.Publisher.IsConnectionOpen(),
+		GatewaysConnectState: counts,
+	})
+}
+
+// Find log levels
+// @Summary Find Log Levels
+// @Schemes
+// @Description default log level and levels of server components that differ from it
+// @Produce json
+// @Success 200 {object} logger.LogLevels
+// @Router /v1/system/log_levels [get]
+func (h *SystemHandler) FindLogLevels(c *gin.Context) {
+	utils.ResponseJson(c, http.StatusOK, logger.Levels())
+}
+
+// Update log levels
+// @Summary Update Log Levels
+// @Schemes
+// @Description change log levels without restart, "level" sets default level, a component set to "" follows default level again. Components: MQTT, SQLSERVER, UAMS_SERVER, GIN_ROUTER
+// @Accept  json
+// @Produce json
+// @Param	data	body	logger.LogLevels	true	"Log levels"
+// @Success 200 {object} logger.LogLevels
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/system/log_levels [patch]
+func (h *SystemHandler) UpdateLogLevels(c *gin.Context) {
+	lv := logger.LogLevels{}
+	if err := c.ShouldBind(&lv); err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	if err := logger.SetLevels(lv); err != nil {
+		responseError(c, "Update log levels failed", err)
+		return
+	}
+	levels := logger.Levels()
+	logger.LogfWithContext(c.Request.Context(), logger.UAMSSERVER, logger.InfoLevel, nil,
+		"Log levels changed to %s %v", levels.Level, levels.Components)
+	utils.ResponseJson(c, http.StatusOK, levels)
+}
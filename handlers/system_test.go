@@ -0,0 +1,98 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestHealthz(t *testing.T) {
+	ts := newTestServer(t)
+	w := ts.doWithHeader(http.MethodGet, "/healthz", "", http.Header{})
+	expectStatus(t, w, http.StatusOK, "")
+}
+
+func TestReadyz(t *testing.T) {
+	ts := newTestServer(t)
+	ready := func() *ReadinessResponse {
+		resp := &ReadinessResponse{}
+		w := ts.doWithHeader(http.MethodGet, "/readyz", "", http.Header{})
+		decode(t, w, resp)
+		if (w.Code == http.StatusOK) != resp.Ready {
+			t.Errorf("got %d, wanted 200 only when ready", w.Code)
+		}
+		return resp
+	}
+
+	if resp := ready(); resp.Ready || resp.Checks["subscriptions"].Status != CHECK_FAIL {
+		t.Errorf("got %+v, wanted not ready before subscribing", resp)
+	}
+	ts.monitor.Expect("uams/gateway/#", "uams/gateway/bootup")
+	ts.monitor.Connected()
+	ts.monitor.Subscription("uams/gateway/#", nil)
+	if resp := ready(); resp.Ready || resp.Checks["subscriptions"].Status != CHECK_FAIL {
+		t.Errorf("got %+v, wanted not ready while a subscription is missing", resp)
+	}
+	ts.monitor.Subscription("uams/gateway/bootup", nil)
+	if resp := ready(); !resp.Ready {
+		t.Errorf("got %+v, wanted ready", resp)
+	}
+
+	ts.store.SetSchemaVersion(models.SCHEMA_VERSION - 1)
+	if resp := ready(); resp.Ready || resp.Checks["migration"].Status != CHECK_FAIL {
+		t.Errorf("got %+v, wanted migration check failed", resp)
+	}
+	ts.store.SetSchemaVersion(models.SCHEMA_VERSION)
+
+	ts.client.SetConnected(false)
+	ts.store.Fail("SchemaSvc.Ping", fmt.Errorf("connection refused"))
+	resp := ready()
+	if resp.Ready || resp.Checks["mqtt"].Status != CHECK_FAIL || resp.Checks["database"].Status != CHECK_FAIL {
+		t.Errorf("got %+v, wanted mqtt and database checks failed", resp)
+	}
+}
+
+func TestSystemStatus(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01")
+
+	status := &SystemStatus{}
+	w := ts.do(http.MethodGet, "/v1/system/status", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, status)
+	if status.SchemaVersion != models.SCHEMA_VERSION || !status.BrokerConnected || status.GatewaysConnectState["connected"] != 1 {
+		t.Errorf("got %+v, wanted one connected gateway", status)
+	}
+
+	ts.store.Fail("SchemaSvc.SchemaVersion", fmt.Errorf("connection refused"))
+	expectStatus(t, ts.do(http.MethodGet, "/v1/system/status", ""), http.StatusInternalServerError, models.ERR_CODE_INTERNAL)
+}
+
+func TestLogLevels(t *testing.T) {
+	ts := newTestServer(t)
+	defer logger.SetLevels(logger.Levels())
+
+	levels := logger.LogLevels{}
+	w := ts.do(http.MethodPatch, "/v1/system/log_levels", `{"level": "debug"}`)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &levels)
+	if levels.Level != "debug" {
+		t.Errorf("got %s, wanted debug", levels.Level)
+	}
+	w = ts.do(http.MethodGet, "/v1/system/log_levels", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &levels)
+	if levels.Level != "debug" {
+		t.Errorf("got %s, wanted debug", levels.Level)
+	}
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/system/log_levels", `{"level": "loud"}`),
+		http.StatusInternalServerError, models.ERR_CODE_INTERNAL)
+	expectStatus(t, ts.doAs("1", http.MethodGet, "/v1/system/log_levels", ""), http.StatusForbidden, ERR_CODE_FORBIDDEN)
+}
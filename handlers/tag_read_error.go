@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type TagReadErrorHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewTagReadErrorHandler(deps *HandlerDependencies) *TagReadErrorHandler {
+	return &TagReadErrorHandler{
+		deps,
+	}
+}
+
+// Find tag reads with unparseable memory
+// @Summary Find All Tag Read Error
+// @Schemes
+// @Description find tag reads whose memory could not be decoded, newest first, optionally filtered by gateway_id and reason
+// @Produce json
+// @Param        gateway_id	query	string	false	"Gateway ID"
+// @Param        reason	query	string	false	"Decode failure: too_short, bad_encoding, bad_charset, unknown_type"
+// @Success 200 {array} []models.TagReadError
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/tag_read_errors [get]
+func (h *TagReadErrorHandler) FindTagReadErrors(c *gin.Context) {
+	filter := models.TagReadErrorFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		responseBindError(c, "Invalid req query", err)
+		return
+	}
+	teList, err := h.deps.SvcOpts.TagReadErrorSvc.FindTagReadErrors(c.Request.Context(), filter)
+	if err != nil {
+		responseError(c, "Get tag read errors failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, teList)
+}
+
+// Delete tag read errors in time range
+// @Summary Delete Tag Read Error In Time Range
+// @Schemes
+// @Description delete tag read errors in time range
+// @Produce json
+// @Param 		 from path  string  true    "From Unix time"
+// @Param 		 to path    string  true    "To Unix time"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/tag_read_errors/period/{from}/{to} [delete]
+func (h *TagReadErrorHandler) DeleteTagReadErrorInTimeRange(c *gin.Context) {
+	from := c.Param("fromTime")
+	to := c.Param("toTime")
+	fromInt, _ := strconv.ParseInt(from, 10, 64)
+	toInt, _ := strconv.ParseInt(to, 10, 64)
+	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
+	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
+	isSuccess, err := h.deps.SvcOpts.TagReadErrorSvc.DeleteTagReadErrorInTimeRange(c.Request.Context(), fromFormatted, toFormatted)
+	if err != nil {
+		responseError(c, "Failed to delete tag read errors", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
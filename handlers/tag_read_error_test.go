@@ -0,0 +1,48 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestTagReadError(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now()
+	seeds := []models.TagReadError{
+		{GatewayID: "gw01", Reason: "invalid_mem", Time: now},
+		{GatewayID: "gw01", Reason: "unknown_type", Time: now.Add(-time.Hour)},
+		{GatewayID: "gw02", Reason: "invalid_mem", Time: now.Add(-2 * time.Hour)},
+	}
+	if err := ts.svc.TagReadErrorSvc.CreateTagReadErrors(context.Background(), seeds, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		query string
+		n     int
+	}{
+		{"", 3},
+		{"?gateway_id=gw01", 2},
+		{"?gateway_id=gw01&reason=invalid_mem", 1},
+	}
+	for _, c := range cases {
+		teList := []models.TagReadError{}
+		w := ts.do(http.MethodGet, "/v1/tag_read_errors"+c.query, "")
+		expectStatus(t, w, http.StatusOK, "")
+		decode(t, w, &teList)
+		if len(teList) != c.n {
+			t.Errorf("%s: got %d errors, wanted %d", c.query, len(teList), c.n)
+		}
+	}
+
+	lastHalfHour := period(now.Add(-30*time.Minute), now.Add(time.Minute))
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/tag_read_errors"+lastHalfHour, ""), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/tag_read_errors"+lastHalfHour, ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+}
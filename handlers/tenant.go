@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type TenantHandler struct {
+	deps      *HandlerDependencies
+	adminKeys []string
+}
+
+// Admin keys authenticate as system tenant, which sees every organization
+func NewTenantHandler(deps *HandlerDependencies, adminKeys []string) *TenantHandler {
+	return &TenantHandler{
+		deps:      deps,
+		adminKeys: adminKeys,
+	}
+}
+
+// Authenticate resolves tenant of request from its API key, sent as X-API-Key
+// or bearer token. Admins act as system tenant, or as the organization of
+// X-Organization-ID header when set.
+func (h *TenantHandler) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if auth := c.GetHeader("Authorization"); key == "" && strings.HasPrefix(auth, "Bearer ") {
+			key = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if key == "" {
+			utils.ResponseProblem(c, &utils.ErrorResponse{
+				Status: http.StatusUnauthorized,
+				Code:   ERR_CODE_UNAUTHORIZED,
+				Detail: "missing API key",
+			})
+			c.Abort()
+			return
+		}
+
+		ctx := c.Request.Context()
+		if h.isAdminKey(key) {
+			ctx = models.WithSystemTenant(ctx)
+			if orgHeader := c.GetHeader("X-Organization-ID"); orgHeader != "" {
+				orgId, err := strconv.ParseUint(orgHeader, 10, 32)
+				if err != nil || orgId == 0 {
+					utils.ResponseProblem(c, &utils.ErrorResponse{
+						Status: http.StatusBadRequest,
+						Code:   ERR_CODE_INVALID_REQUEST,
+						Detail: "Invalid X-Organization-ID header: " + orgHeader,
+					})
+					c.Abort()
+					return
+				}
+				ctx = models.WithTenant(ctx, uint(orgId))
+			}
+		} else {
+			apiKey, err := h.deps.SvcOpts.TenantSvc.AuthenticateApiKey(ctx, key)
+			if err != nil {
+				utils.ResponseProblem(c, &utils.ErrorResponse{
+					Status: http.StatusUnauthorized,
+					Code:   ERR_CODE_UNAUTHORIZED,
+					Detail: "invalid API key",
+				})
+				c.Abort()
+				return
+			}
+			ctx = models.WithTenant(ctx, apiKey.OrganizationID)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func (h *TenantHandler) isAdminKey(key string) bool {
+	for _, adminKey := range h.adminKeys {
+		if adminKey != "" && subtle.ConstantTimeCompare([]byte(adminKey), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireSystem keeps routes spanning every organization to admins
+func (h *TenantHandler) RequireSystem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if t, _ := models.TenantFromContext(c.Request.Context()); !t.System {
+			utils.ResponseProblem(c, &utils.ErrorResponse{
+				Status: http.StatusForbidden,
+				Code:   ERR_CODE_FORBIDDEN,
+				Detail: "route requires an admin API key",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Find all organizations
+// @Summary Find All Organization
+// @Schemes
+// @Description find all organizations, tenants only see their own
+// @Produce json
+// @Success 200 {array} []models.Organization
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/organizations [get]
+func (h *TenantHandler) FindAllOrganization(c *gin.Context) {
+	orgList, err := h.deps.SvcOpts.TenantSvc.FindAllOrganization(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get all organizations failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, orgList)
+}
+
+// Create organization
+// @Summary Create Organization
+// @Schemes
+// @Description Create organization, requires admin API key
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{name=string,description=string}	true	"Fields need to create an organization"
+// @Success 200 {object} models.Organization
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/organization [post]
+func (h *TenantHandler) CreateOrganization(c *gin.Context) {
+	org := &models.Organization{}
+	err := c.ShouldBind(org)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	org, err = h.deps.SvcOpts.TenantSvc.CreateOrganization(c.Request.Context(), org)
+	if err != nil {
+		responseError(c, "Create organization failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, org)
+}
+
+// Update organization
+// @Summary Update Organization By ID
+// @Schemes
+// @Description Update organization, must have "id" field, requires admin API key
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int,name=string,description=string}	true	"Fields need to update an organization"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/organization [patch]
+func (h *TenantHandler) UpdateOrganization(c *gin.Context) {
+	org := &models.Organization{}
+	err := c.ShouldBind(org)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.TenantSvc.UpdateOrganization(c.Request.Context(), org)
+	if err != nil || !isSuccess {
+		responseError(c, "Update organization failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Delete organization
+// @Summary Delete Organization By ID
+// @Schemes
+// @Description Delete organization without sites and gateways using "id" field, requires admin API key
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Organization ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/organization [delete]
+func (h *TenantHandler) DeleteOrganization(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.TenantSvc.DeleteOrganization(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		responseError(c, "Delete organization failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Find all sites
+// @Summary Find All Site
+// @Schemes
+// @Description find all sites of organization
+// @Produce json
+// @Success 200 {array} []models.Site
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/sites [get]
+func (h *TenantHandler) FindAllSite(c *gin.Context) {
+	siteList, err := h.deps.SvcOpts.TenantSvc.FindAllSite(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get all sites failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, siteList)
+}
+
+// Create site
+// @Summary Create Site
+// @Schemes
+// @Description Create site, code is the site of MQTT topics its gateways publish on. Admins must set organization_id.
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{code=string,name=string,organization_id=int}	true	"Fields need to create a site"
+// @Success 200 {object} models.Site
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/site [post]
+func (h *TenantHandler) CreateSite(c *gin.Context) {
+	site := &models.Site{}
+	err := c.ShouldBind(site)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	site, err = h.deps.SvcOpts.TenantSvc.CreateSite(c.Request.Context(), site)
+	if err != nil {
+		responseError(c, "Create site failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, site)
+}
+
+// Update site
+// @Summary Update Site By ID
+// @Schemes
+// @Description Update site, must have "id" field
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int,code=string,name=string}	true	"Fields need to update a site"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/site [patch]
+func (h *TenantHandler) UpdateSite(c *gin.Context) {
+	site := &models.Site{}
+	err := c.ShouldBind(site)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.TenantSvc.UpdateSite(c.Request.Context(), site)
+	if err != nil || !isSuccess {
+		responseError(c, "Update site failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Delete site
+// @Summary Delete Site By ID
+// @Schemes
+// @Description Delete site using "id" field
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Site ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/site [delete]
+func (h *TenantHandler) DeleteSite(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.TenantSvc.DeleteSite(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		responseError(c, "Delete site failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Find all API keys
+// @Summary Find All API Key
+// @Schemes
+// @Description find all API keys of organization, keys themselves are never returned
+// @Produce json
+// @Success 200 {array} []models.ApiKey
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/api_keys [get]
+func (h *TenantHandler) FindAllApiKey(c *gin.Context) {
+	keyList, err := h.deps.SvcOpts.TenantSvc.FindAllApiKey(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get all API keys failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, keyList)
+}
+
+// Create API key
+// @Summary Create API Key
+// @Schemes
+// @Description Create API key of organization, the key is only returned once. Admins must set organization_id.
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{name=string,organization_id=int}	true	"Fields need to create an API key"
+// @Success 200 {object} models.CreatedApiKey
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/api_key [post]
+func (h *TenantHandler) CreateApiKey(c *gin.Context) {
+	key := &models.ApiKey{}
+	err := c.ShouldBind(key)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	created, err := h.deps.SvcOpts.TenantSvc.CreateApiKey(c.Request.Context(), key)
+	if err != nil {
+		responseError(c, "Create API key failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, created)
+}
+
+// Delete API key
+// @Summary Delete API Key By ID
+// @Schemes
+// @Description Revoke API key using "id" field
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"API key ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/api_key [delete]
+func (h *TenantHandler) DeleteApiKey(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.TenantSvc.DeleteApiKey(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		responseError(c, "Delete API key failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
@@ -0,0 +1,101 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestOrganization(t *testing.T) {
+	ts := newTestServer(t)
+
+	org := &models.Organization{}
+	w := ts.do(http.MethodPost, "/v1/organization", `{"name": "acme"}`)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, org)
+	expectStatus(t, ts.do(http.MethodPost, "/v1/organization", `{"name": "acme"}`), http.StatusConflict, models.ERR_CODE_DUPLICATE)
+	expectStatus(t, ts.doAs("1", http.MethodPost, "/v1/organization", `{"name": "other"}`), http.StatusForbidden, ERR_CODE_FORBIDDEN)
+
+	orgList := []models.Organization{}
+	w = ts.do(http.MethodGet, "/v1/organizations", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &orgList)
+	if len(orgList) != 2 {
+		t.Errorf("got %d organizations, wanted default and acme", len(orgList))
+	}
+
+	orgId := fmt.Sprint(org.ID)
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/organization", `{"id": `+orgId+`, "description": "Acme Corp"}`), http.StatusOK, "")
+	expectStatus(t, ts.doAs(orgId, http.MethodPost, "/v1/site", `{"code": "hcm", "name": "Ho Chi Minh"}`), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/organization", `{"id": `+orgId+`}`), http.StatusConflict, models.ERR_CODE_CONFLICT)
+
+	sites, _ := ts.svc.TenantSvc.FindAllSite(models.WithTenant(context.Background(), org.ID))
+	expectStatus(t, ts.doAs(orgId, http.MethodDelete, "/v1/site", fmt.Sprintf(`{"id": %d}`, sites[0].ID)), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/organization", `{"id": `+orgId+`}`), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/organization", `{"id": `+orgId+`}`), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+}
+
+func TestSite(t *testing.T) {
+	ts := newTestServer(t)
+	org, err := ts.svc.TenantSvc.CreateOrganization(context.Background(), &models.Organization{Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgId := fmt.Sprint(org.ID)
+
+	site := &models.Site{}
+	w := ts.doAs(orgId, http.MethodPost, "/v1/site", `{"code": "hcm", "name": "Ho Chi Minh"}`)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, site)
+	if site.OrganizationID != org.ID {
+		t.Errorf("got %d, wanted site of organization %d", site.OrganizationID, org.ID)
+	}
+	expectStatus(t, ts.doAs("1", http.MethodPost, "/v1/site", `{"code": "hcm"}`), http.StatusConflict, models.ERR_CODE_DUPLICATE)
+
+	sites := []models.Site{}
+	w = ts.doAs("1", http.MethodGet, "/v1/sites", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &sites)
+	if len(sites) != 0 {
+		t.Errorf("got %+v, wanted no site of other organization", sites)
+	}
+
+	body := fmt.Sprintf(`{"id": %d, "name": "Saigon"}`, site.ID)
+	expectStatus(t, ts.doAs("1", http.MethodPatch, "/v1/site", body), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.doAs(orgId, http.MethodPatch, "/v1/site", body), http.StatusOK, "")
+	body = fmt.Sprintf(`{"id": %d}`, site.ID)
+	expectStatus(t, ts.doAs("1", http.MethodDelete, "/v1/site", body), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.doAs(orgId, http.MethodDelete, "/v1/site", body), http.StatusOK, "")
+}
+
+func TestApiKey(t *testing.T) {
+	ts := newTestServer(t)
+
+	created := &models.CreatedApiKey{}
+	w := ts.doAs("1", http.MethodPost, "/v1/api_key", `{"name": "ci"}`)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, created)
+	if created.Key == "" || created.Prefix != created.Key[:len(created.Prefix)] {
+		t.Errorf("got %+v, wanted raw key shown once", created)
+	}
+
+	keyList := []models.ApiKey{}
+	w = ts.doWithHeader(http.MethodGet, "/v1/api_keys", "", http.Header{"X-Api-Key": {created.Key}})
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &keyList)
+	if len(keyList) != 1 || keyList[0].LastUsedAt == nil {
+		t.Errorf("got %+v, wanted key marked used", keyList)
+	}
+
+	body := fmt.Sprintf(`{"id": %d}`, created.ID)
+	expectStatus(t, ts.doAs("1", http.MethodDelete, "/v1/api_key", body), http.StatusOK, "")
+	expectStatus(t, ts.doWithHeader(http.MethodGet, "/v1/api_keys", "", http.Header{"X-Api-Key": {created.Key}}),
+		http.StatusUnauthorized, ERR_CODE_UNAUTHORIZED)
+	expectStatus(t, ts.doAs("1", http.MethodDelete, "/v1/api_key", body), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+}
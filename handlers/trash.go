@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewTrashHandler(deps *HandlerDependencies) *TrashHandler {
+	return &TrashHandler{
+		deps,
+	}
+}
+
+// Find deleted areas, gateways and UHFs
+// @Summary Find Trash
+// @Schemes
+// @Description Find deleted areas, gateways and UHFs that can still be restored, most recently deleted first
+// @Produce json
+// @Success 200 {object} models.Trash
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/trash [get]
+func (h *TrashHandler) FindTrash(c *gin.Context) {
+	t, err := h.deps.SvcOpts.TrashSvc.FindTrash(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get trash failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, t)
+}
+
+// Restore record from trash
+// @Summary Restore From Trash
+// @Schemes
+// @Description Restore record "id" of "type" (area, gateway or uhf) from trash, "id" is gateway_id for gateways. A gateway comes back with UHFs deleted along with it, a UHF only to a live gateway. Restored gateways and UHFs are sent to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.TrashItem	true	"Type and ID of record"
+// @Success 200 {object} object "Restored area, gateway or UHF"
+// @Header 200 {string} ETag "Revision of restored record, send it back in If-Match"
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Router /v1/trash/restore [post]
+func (h *TrashHandler) Restore(c *gin.Context) {
+	item := &models.TrashItem{}
+	err := c.ShouldBind(item)
+	if err == nil {
+		err = item.Validate()
+	}
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	switch item.Type {
+	case models.TRASH_AREA:
+		a, err := h.deps.SvcOpts.TrashSvc.RestoreArea(ctx, item.ID)
+		if err != nil {
+			responseError(c, "Restore area failed", err)
+			return
+		}
+		c.Header("ETag", a.ETag())
+		utils.ResponseJson(c, http.StatusOK, a)
+	case models.TRASH_GATEWAY:
+		gw, err := h.deps.SvcOpts.TrashSvc.RestoreGateway(ctx, item.ID)
+		if err != nil {
+			responseError(c, "Restore gateway failed", err)
+			return
+		}
+		t := h.deps.Publisher.PublishToSite(ctx, mqttSvc.TOPIC_SV_SYNC, gw.Site, gw.GatewayID,
+			mqttSvc.ServerBootupSystemPayload(gw.GatewayID, gw.UHFs))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			responseError(c, "Restore gateway mqtt failed", err)
+			return
+		}
+		c.Header("ETag", gw.ETag())
+		utils.ResponseJson(c, http.StatusOK, gw)
+	default:
+		uhf, err := h.deps.SvcOpts.TrashSvc.RestoreUHF(ctx, item.ID)
+		if err != nil {
+			responseError(c, "Restore UHF failed", err)
+			return
+		}
+		t := h.deps.Publisher.Publish(ctx, mqttSvc.TOPIC_SV_UHF_U, uhf.GatewayID, mqttSvc.ServerUpdateUHFPayload(uhf))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			responseError(c, "Restore UHF mqtt failed", err)
+			return
+		}
+		c.Header("ETag", uhf.ETag())
+		utils.ResponseJson(c, http.StatusOK, uhf)
+	}
+}
+
+// Delete record in trash for good
+// @Summary Purge From Trash
+// @Schemes
+// @Description Delete record "id" of "type" (area, gateway or uhf) in trash for good, "id" is gateway_id for gateways. A gateway takes UHFs deleted along with it
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.TrashItem	true	"Type and ID of record"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /v1/trash [delete]
+func (h *TrashHandler) Purge(c *gin.Context) {
+	item := &models.TrashItem{}
+	err := c.ShouldBind(item)
+	if err == nil {
+		err = item.Validate()
+	}
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.TrashSvc.PurgeTrash(c.Request.Context(), item)
+	if err != nil || !isSuccess {
+		responseError(c, "Purge trash failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
@@ -0,0 +1,91 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func TestTrash(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	ts.seedGateway(t, ctx, "gw01", "1", "2")
+	uhf1, _ := ts.svc.UHFSvc.FindUHFByAddress(ctx, "1", "gw01")
+	uhf2, _ := ts.svc.UHFSvc.FindUHFByAddress(ctx, "2", "gw01")
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/uhf", fmt.Sprintf(`{"id": "%d"}`, uhf1.ID)), http.StatusOK, "")
+
+	// UHFs left go to trash with their gateway
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/gateway", `{"gateway_id": "gw01"}`), http.StatusOK, "")
+	trash := &models.Trash{}
+	w := ts.do(http.MethodGet, "/v1/trash", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, trash)
+	if len(trash.Gateways) != 1 || len(trash.UHFs) != 2 || !trash.Gateways[0].DeletedAt.Valid ||
+		trash.Gateways[0].TrashedBy != models.TRASHED_BY_OPERATOR {
+		t.Fatalf("got %+v, wanted gateway and both UHFs in trash", trash)
+	}
+	expectStatus(t, ts.do(http.MethodGet, "/v1/gateway/gateway_id/gw01", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	restore := func(item string) *httptest.ResponseRecorder {
+		return ts.do(http.MethodPost, "/v1/trash/restore", item)
+	}
+	expectStatus(t, restore(fmt.Sprintf(`{"type": "uhf", "id": "%d"}`, uhf2.ID)), http.StatusConflict, models.ERR_CODE_CONFLICT)
+	expectStatus(t, restore(`{"type": "room", "id": "1"}`), http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+	expectStatus(t, restore(`{"type": "gateway", "id": "gw02"}`), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	// UHF 1 was deleted before its gateway and stays in trash
+	w = restore(`{"type": "gateway", "id": "gw01"}`)
+	expectStatus(t, w, http.StatusOK, "")
+	gw := &models.Gateway{}
+	decode(t, w, gw)
+	if len(gw.UHFs) != 1 || gw.UHFs[0].UHFAddress != "2" || w.Header().Get("ETag") != gw.ETag() {
+		t.Errorf("got %+v at %s, wanted gateway back with UHF 2", gw, w.Header().Get("ETag"))
+	}
+	published := ts.client.Published()
+	if sync := published[len(published)-1]; !strings.HasSuffix(sync.Topic, mqttSvc.TOPIC_SV_SYNC) {
+		t.Errorf("got %+v, wanted gateway synced", sync)
+	}
+	expectStatus(t, restore(fmt.Sprintf(`{"type": "uhf", "id": "%d"}`, uhf1.ID)), http.StatusOK, "")
+	expectStatus(t, restore(fmt.Sprintf(`{"type": "uhf", "id": "%d"}`, uhf1.ID)), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	// purged records are gone for good
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/gateway", `{"gateway_id": "gw01"}`), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/trash", `{"type": "gateway", "id": "gw01"}`), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/trash", `{"type": "gateway", "id": "gw01"}`), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	w = ts.do(http.MethodGet, "/v1/trash", "")
+	trash = &models.Trash{}
+	decode(t, w, trash)
+	if len(trash.Gateways)+len(trash.UHFs) != 0 {
+		t.Errorf("got %+v, wanted trash empty", trash)
+	}
+	// gateway_id of a purged gateway is free again
+	ts.seedGateway(t, ctx, "gw01")
+}
+
+func TestRestoreArea(t *testing.T) {
+	ts := newTestServer(t)
+	area := &models.Area{}
+	w := ts.do(http.MethodPost, "/v1/area", `{"name": "Hall", "manager": "Lan"}`)
+	decode(t, w, area)
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/area", fmt.Sprintf(`{"id": %d}`, area.ID)), http.StatusOK, "")
+
+	// name of an area in trash is still taken
+	expectStatus(t, ts.do(http.MethodPost, "/v1/area", `{"name": "Hall", "manager": "An"}`), http.StatusConflict, models.ERR_CODE_DUPLICATE)
+	w = ts.do(http.MethodPost, "/v1/trash/restore", fmt.Sprintf(`{"type": "area", "id": "%d"}`, area.ID))
+	expectStatus(t, w, http.StatusOK, "")
+	restored := &models.Area{}
+	decode(t, w, restored)
+	if restored.Name != "Hall" || restored.DeletedAt.Valid || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("got %+v at %s, wanted Hall restored at \"2\"", restored, w.Header().Get("ETag"))
+	}
+	expectStatus(t, ts.do(http.MethodGet, fmt.Sprintf("/v1/area/%d", area.ID), ""), http.StatusOK, "")
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
-	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"net/http"
+
 	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
 )
 
 type HandlerOptions struct {
@@ -14,9 +16,22 @@ type HandlerOptions struct {
 	UserAccessHandler    *UserAccessHandler
 	PackageAccessHandler *PackageAccessHandler
 	OperationLogHandler  *OperationLogHandler
+	FirmwareHandler      *FirmwareHandler
+	RolloutHandler       *RolloutHandler
+	UHFConfigHandler     *UHFConfigHandler
+	IngestionHandler     *IngestionHandler
+	DeadLetterHandler    *DeadLetterHandler
+	TagReadErrorHandler  *TagReadErrorHandler
+	TenantHandler        *TenantHandler
+	SystemHandler        *SystemHandler
+	ImportHandler        *ImportHandler
+	TrashHandler         *TrashHandler
+	Metrics              http.Handler
 }
 
 type HandlerDependencies struct {
-	SvcOpts    *models.ServiceOptions
-	MqttClient mqtt.Client
+	SvcOpts   *models.ServiceOptions
+	Publisher *mqttSvc.Publisher
+	Ingestion *mqttSvc.Ingestion
+	Monitor   *mqttSvc.ConnectionMonitor
 }
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
 	"net/http"
+	"strconv"
+	"time"
 
 	//"github.com/ecoprohcm/DMS_BackendServer/models"
 	//"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
@@ -31,13 +34,9 @@ func NewUHFHandler(deps *HandlerDependencies) *UHFHandler {
 // @Router /v1/uhfs [get]
 func (h *UHFHandler) FindAllUHFs(c *gin.Context) {
 	test := h.deps
-	dlList, err := test.SvcOpts.UHFSvc.FindAllUHF(c)
+	dlList, err := test.SvcOpts.UHFSvc.FindAllUHF(c.Request.Context())
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all UHfs failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all UHfs failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, dlList)
@@ -50,158 +49,308 @@ func (h *UHFHandler) FindAllUHFs(c *gin.Context) {
 // @Produce json
 // @Param        id	path	string	true	"UHF ID"
 // @Success 200 {object} models.UHF
+// @Header 200 {string} ETag "Revision of UHF, send it back in If-Match"
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/uhf/{id} [get]
 func (h *UHFHandler) FindUHFByID(c *gin.Context) {
 	id := c.Param("id")
 
-	dl, err := h.deps.SvcOpts.UHFSvc.FindUHFByID(c, id)
+	dl, err := h.deps.SvcOpts.UHFSvc.FindUHFByID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get UHF failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get UHF failed", err)
 		return
 	}
+	c.Header("ETag", dl.ETag())
 	utils.ResponseJson(c, http.StatusOK, dl)
 }
 
+// Find UHFs out of sync
+// @Summary Find Drifted UHFs
+// @Schemes
+// @Description find UHFs whose reported state differs from desired state
+// @Produce json
+// @Success 200 {array} []models.UHF
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/uhfs/drift [get]
+func (h *UHFHandler) FindDriftedUHFs(c *gin.Context) {
+	dlList, err := h.deps.SvcOpts.UHFSvc.FindDriftedUHFs(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get drifted UHFs failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dlList)
+}
+
 // Update UHF
 // @Summary Update UHF By UHF Address and GatewayID
 // @Schemes
-// @Description Update UHF, must have "gatewayId" and "UHFAddress" field. Send updated info to MQTT broker
+// @Description Update UHF, must have "gatewayId" and "UHFAddress" field and If-Match header with ETag of the UHF. "active_state" or "desired_state" sets the desired state. Send updated info to MQTT broker
 // @Accept  json
 // @Produce json
+// @Param	If-Match	header	string	true	"ETag of UHF as read, * for any"
 // @Param	data	body	models.UHF	true	"Fields need to update a UHF"
 // @Success 200 {boolean} true
+// @Header 200 {string} ETag "Revision of updated UHF, send it back in If-Match"
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 412 {object} utils.ErrorResponse
+// @Failure 428 {object} utils.ErrorResponse
 // @Router /v1/uhf [patch]
 func (h *UHFHandler) UpdateUHF(c *gin.Context) {
 	dl := &models.UHF{}
 	err := c.ShouldBind(dl)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Invalid req body",
-			ErrorMsg:   err.Error(),
-		})
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	revision, ok := ifMatch(c)
+	if !ok {
 		return
 	}
+	dl.Revision = models.Revision{Revision: revision}
 
-	existing_uhf, _ := h.deps.SvcOpts.UHFSvc.FindUHFByAddress(c.Request.Context(), dl.UHFAddress, dl.GatewayID)
+	existing_uhf, err := h.deps.SvcOpts.UHFSvc.FindUHFByAddress(c.Request.Context(), dl.UHFAddress, dl.GatewayID)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "There is no UHF",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "There is no UHF", err)
 		return
 	}
 	if existing_uhf.AreaId == "" {
 		if dl.AreaId == "" {
-			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-				StatusCode: http.StatusBadRequest,
-				Msg:        "Please input AreaID",
-				ErrorMsg:   "Please input AreaID",
-			})
+			responseError(c, "Please input AreaID",
+				models.NewValidationError("area_id", "area_id is required while UHF has no area"))
 			return
 		}
 		_, err = h.deps.SvcOpts.AreaSvc.FindAreaByID(c.Request.Context(), dl.AreaId)
 		if err != nil {
-			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-				StatusCode: http.StatusBadRequest,
-				Msg:        "This area ID does not exist",
-				ErrorMsg:   "This area ID does not exist",
-			})
+			responseError(c, "This area ID does not exist",
+				models.NewValidationError("area_id", "area %s does not exist", dl.AreaId))
 			return
 		}
 	}
 
-	isSuccess, err := h.deps.SvcOpts.UHFSvc.UpdateUHF(c.Request.Context(), dl)
+	// "active_state" in req body is kept as desired state, only gateway reports may change the applied one
+	desiredState := dl.DesiredState
+	if desiredState == "" {
+		desiredState = dl.ActiveState
+	}
+	dl.DeviceTwin = models.DeviceTwin{}
+	dl.ActiveState = ""
+	// reader config has its own validated endpoint
+	dl.ConfigTemplateID = nil
+	dl.DesiredConfig = nil
+	dl.ReportedConfig = nil
+
+	var isSuccess bool
+	if desiredState != "" {
+		isSuccess, err = h.deps.SvcOpts.UHFSvc.UpdateUHFWithDesiredState(c.Request.Context(), dl, desiredState)
+	} else {
+		isSuccess, err = h.deps.SvcOpts.UHFSvc.UpdateUHF(c.Request.Context(), dl)
+	}
 	if err != nil || !isSuccess {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Update uhf failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Update uhf failed", err)
 		return
 	}
+	if desiredState != "" {
+		var new_UHF_status_log_state = &models.UHFStatusLog{}
+		new_UHF_status_log_state.GatewayID = dl.GatewayID
+		new_UHF_status_log_state.UHFAddress = dl.UHFAddress
+		new_UHF_status_log_state.StateType = "Desired State"
+		new_UHF_status_log_state.StateValue = desiredState
+		new_UHF_status_log_state.Time = time.Now()
+		h.deps.SvcOpts.UHFStatusLogSvc.CreateUHFStatusLog(c.Request.Context(), new_UHF_status_log_state)
+	}
 	updated_UHF, err := h.deps.SvcOpts.UHFSvc.FindUHFByAddress(c.Request.Context(), dl.UHFAddress, dl.GatewayID)
+	if err != nil {
+		responseError(c, "There is no UHF", err)
+		return
+	}
 
-	var new_UHF_status_log_state = &models.UHFStatusLog{}
-	new_UHF_status_log_state.GatewayID = updated_UHF.GatewayID
-	new_UHF_status_log_state.UHFAddress = updated_UHF.UHFAddress
-	new_UHF_status_log_state.StateType = "Active State"
-	new_UHF_status_log_state.StateValue = dl.ActiveState
-	h.deps.SvcOpts.UHFStatusLogSvc.CreateUHFStatusLog(c.Request.Context(), new_UHF_status_log_state)
-
-	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_UHF_U, 1, false,
-		mqttSvc.ServerUpdateUHFPayload(dl))
+	t := h.deps.Publisher.Publish(c.Request.Context(), mqttSvc.TOPIC_SV_UHF_U, updated_UHF.GatewayID,
+		mqttSvc.ServerUpdateUHFPayload(updated_UHF))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Update uhf mqtt failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Update uhf mqtt failed", err)
 		return
 	}
 
+	c.Header("ETag", updated_UHF.ETag())
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 }
 
 // Delete UHF
 // @Summary Delete UHF By ID
 // @Schemes
-// @Description Delete UHF using "id" field. Send deleted info to MQTT broker
+// @Description Move UHF to trash using "id" field and If-Match header with ETag of the UHF. Send deleted info to MQTT broker
 // @Accept  json
 // @Produce json
+// @Param	If-Match	header	string	true	"ETag of UHF as read, * for any"
 // @Param	data	body	object{id=int}	true	"UHF Delete payload"
 // @Success 200 {boolean} true
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 412 {object} utils.ErrorResponse
+// @Failure 428 {object} utils.ErrorResponse
 // @Router /v1/uhf [delete]
 func (h *UHFHandler) DeleteUHF(c *gin.Context) {
 	dl := &models.UHFDelete{}
 	err := c.ShouldBind(dl)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Invalid req body",
-			ErrorMsg:   err.Error(),
-		})
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	revision, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
 	uhf, err := h.deps.SvcOpts.UHFSvc.FindUHFByID(c.Request.Context(), dl.ID)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Find UHF fail",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Find UHF fail", err)
 		return
 	}
 
-	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_UHF_D, 1, false,
+	// gateway is told first, an outdated revision must not reach it
+	if revision != 0 && uhf.Revision.Revision != revision {
+		responseError(c, "Delete UHF failed",
+			models.NewPreconditionFailedError("revision %d is outdated, fetch it again", revision))
+		return
+	}
+
+	t := h.deps.Publisher.Publish(c.Request.Context(), mqttSvc.TOPIC_SV_UHF_D, uhf.GatewayID,
 		mqttSvc.ServerDeleteUHFPayload(uhf))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Delete UHF mqtt failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Delete UHF mqtt failed", err)
 		return
 	}
 
-	isSuccess, err := h.deps.SvcOpts.UHFSvc.DeleteUHF(c.Request.Context(), dl.ID)
+	isSuccess, err := h.deps.SvcOpts.UHFSvc.DeleteUHF(c.Request.Context(), dl.ID, revision)
 	if err != nil || !isSuccess {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Delete UHF failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Delete UHF failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 
 }
+
+// Bulk operation on UHFs
+// @Summary Bulk Update UHFs
+// @Schemes
+// @Description Apply "action" (activate, deactivate, reassign_area or delete) to every UHF matching "selector" (area_id, gateway_id, family, version). "area_id" is the new area of reassign_area. Every UHF gets its own result. Changes are sent to MQTT broker before they are stored, one message per UHF or a single uhf/batch message per gateway when MQTT_UHF_BATCH is set, UHFs whose message could not be sent fail unchanged
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.BulkRequest	true	"Selector and action"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /v1/uhfs/bulk [post]
+func (h *UHFHandler) BulkUpdateUHFs(c *gin.Context) {
+	req := &models.BulkRequest{}
+	err := c.ShouldBind(req)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	if err := req.Validate(false); err != nil {
+		responseError(c, "Invalid bulk request", err)
+		return
+	}
+	ctx := c.Request.Context()
+	if req.Action == models.BULK_REASSIGN_AREA {
+		if _, err := h.deps.SvcOpts.AreaSvc.FindAreaByID(ctx, req.AreaID); err != nil {
+			responseError(c, "This area ID does not exist",
+				models.NewValidationError("area_id", "area %s does not exist", req.AreaID))
+			return
+		}
+	}
+	uhfs, err := h.deps.SvcOpts.UHFSvc.FindUHFsBySelector(ctx, req.Selector)
+	if err != nil {
+		responseError(c, "Get selected UHFs failed", err)
+		return
+	}
+
+	// Like single changes, gateways are told first and UHFs whose message
+	// could not be sent are left as they were
+	planErrs := map[uint]error{}
+	gwIds := []string{}
+	updated := map[string][]models.UHF{}
+	deleted := map[string][]models.UHF{}
+	for _, uhf := range uhfs {
+		planned, err := planBulkUHF(req, uhf)
+		if err != nil {
+			planErrs[uhf.ID] = err
+			continue
+		}
+		if _, ok := updated[uhf.GatewayID]; !ok {
+			gwIds = append(gwIds, uhf.GatewayID)
+			updated[uhf.GatewayID] = []models.UHF{}
+		}
+		if req.Action == models.BULK_DELETE {
+			deleted[uhf.GatewayID] = append(deleted[uhf.GatewayID], planned)
+		} else {
+			updated[uhf.GatewayID] = append(updated[uhf.GatewayID], planned)
+		}
+	}
+	publishErrs := map[uint]error{}
+	for _, gwId := range gwIds {
+		for id, err := range h.deps.Publisher.PublishUHFChanges(ctx, gwId, updated[gwId], deleted[gwId]) {
+			publishErrs[id] = err
+		}
+	}
+
+	result := models.NewBulkResult(req.Action)
+	for i := range uhfs {
+		uhf := &uhfs[i]
+		err := planErrs[uhf.ID]
+		if err == nil {
+			err = publishErrs[uhf.ID]
+		}
+		if err == nil {
+			err = h.bulkUpdateUHF(ctx, req, uhf)
+		}
+		result.Add(models.BulkItemResult{ID: uhf.ID, GatewayID: uhf.GatewayID, UHFAddress: uhf.UHFAddress}, err)
+	}
+	utils.ResponseJson(c, http.StatusOK, result)
+}
+
+// planBulkUHF checks action of req on uhf and returns uhf as it will be
+func planBulkUHF(req *models.BulkRequest, uhf models.UHF) (models.UHF, error) {
+	switch req.Action {
+	case models.BULK_DELETE:
+	case models.BULK_REASSIGN_AREA:
+		uhf.AreaId = req.AreaID
+	default:
+		if uhf.AreaId == "" {
+			return uhf, models.NewValidationError("area_id", "area_id is required while UHF has no area")
+		}
+		uhf.DesiredState = req.DesiredState()
+	}
+	return uhf, nil
+}
+
+// bulkUpdateUHF stores action of req on uhf, its gateway was told already
+func (h *UHFHandler) bulkUpdateUHF(ctx context.Context, req *models.BulkRequest, uhf *models.UHF) error {
+	switch req.Action {
+	case models.BULK_DELETE:
+		_, err := h.deps.SvcOpts.UHFSvc.DeleteUHF(ctx, strconv.FormatUint(uint64(uhf.ID), 10), uhf.Revision.Revision)
+		return err
+	case models.BULK_REASSIGN_AREA:
+		_, err := h.deps.SvcOpts.UHFSvc.UpdateUHF(ctx, &models.UHF{
+			GatewayID:  uhf.GatewayID,
+			UHFAddress: uhf.UHFAddress,
+			AreaId:     req.AreaID,
+			Revision:   uhf.Revision,
+		})
+		return err
+	default:
+		state := req.DesiredState()
+		_, err := h.deps.SvcOpts.UHFSvc.UpdateUHFDesiredState(ctx, uhf.UHFAddress, uhf.GatewayID, state)
+		if err != nil {
+			return err
+		}
+		h.deps.SvcOpts.UHFStatusLogSvc.CreateUHFStatusLog(ctx, &models.UHFStatusLog{
+			GatewayID:  uhf.GatewayID,
+			UHFAddress: uhf.UHFAddress,
+			StateType:  "Desired State",
+			StateValue: state,
+			Time:       time.Now(),
+		})
+		return nil
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type UHFConfigHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewUHFConfigHandler(deps *HandlerDependencies) *UHFConfigHandler {
+	return &UHFConfigHandler{
+		deps,
+	}
+}
+
+// Find all UHF config templates
+// @Summary Find All UHF Config Template
+// @Schemes
+// @Description find all UHF reader config templates
+// @Produce json
+// @Success 200 {array} []models.UHFConfigTemplate
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/uhf_config_templates [get]
+func (h *UHFConfigHandler) FindAllTemplate(c *gin.Context) {
+	tList, err := h.deps.SvcOpts.UHFConfigSvc.FindAllTemplate(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get all UHF config templates failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, tList)
+}
+
+// Find UHF config template by id
+// @Summary Find UHF Config Template By ID
+// @Schemes
+// @Description find UHF reader config template by id
+// @Produce json
+// @Param        id	path	string	true	"Template ID"
+// @Success 200 {object} models.UHFConfigTemplate
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/uhf_config_template/{id} [get]
+func (h *UHFConfigHandler) FindTemplateByID(c *gin.Context) {
+	id := c.Param("id")
+	t, err := h.deps.SvcOpts.UHFConfigSvc.FindTemplateByID(c.Request.Context(), id)
+	if err != nil {
+		responseError(c, "Get UHF config template failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, t)
+}
+
+// Create UHF config template
+// @Summary Create UHF Config Template
+// @Schemes
+// @Description Create UHF reader config template
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.UHFConfigTemplate	true	"Fields need to create a template"
+// @Success 200 {object} models.UHFConfigTemplate
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/uhf_config_template [post]
+func (h *UHFConfigHandler) CreateTemplate(c *gin.Context) {
+	t := &models.UHFConfigTemplate{}
+	err := c.ShouldBind(t)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	t, err = h.deps.SvcOpts.UHFConfigSvc.CreateTemplate(c.Request.Context(), t)
+	if err != nil {
+		responseError(c, "Create UHF config template failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, t)
+}
+
+// Update UHF config template
+// @Summary Update UHF Config Template By ID
+// @Schemes
+// @Description Update UHF reader config template, must have "id" field. UHFs using it are not changed until it is applied again
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.UHFConfigTemplate	true	"Fields need to update a template"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/uhf_config_template [patch]
+func (h *UHFConfigHandler) UpdateTemplate(c *gin.Context) {
+	t := &models.UHFConfigTemplate{}
+	err := c.ShouldBind(t)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.UHFConfigSvc.UpdateTemplate(c.Request.Context(), t)
+	if err != nil || !isSuccess {
+		responseError(c, "Update UHF config template failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Delete UHF config template
+// @Summary Delete UHF Config Template By ID
+// @Schemes
+// @Description Delete UHF reader config template using "id" field
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Template ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/uhf_config_template [delete]
+func (h *UHFConfigHandler) DeleteTemplate(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.UHFConfigSvc.DeleteTemplate(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		responseError(c, "Delete UHF config template failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Apply UHF config template
+// @Summary Apply UHF Config Template
+// @Schemes
+// @Description Set template config as desired config of every UHF in "uhf_ids" and push it to gateways. An ETag in If-Match limits a single UHF to that revision, * applies to any number of UHFs whatever their revision
+// @Accept  json
+// @Produce json
+// @Param	If-Match	header	string	true	"ETag of UHF as read, * for any"
+// @Param	data	body	models.ApplyUHFConfigTemplate	true	"Template ID and UHF IDs"
+// @Success 200 {array} []models.UHFConfigResult
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 428 {object} utils.ErrorResponse
+// @Router /v1/uhf_config_template/apply [post]
+func (h *UHFConfigHandler) ApplyTemplate(c *gin.Context) {
+	req := &models.ApplyUHFConfigTemplate{}
+	err := c.ShouldBind(req)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	revision, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if revision != 0 && len(req.UHFIDs) > 1 {
+		responseError(c, "Invalid If-Match header",
+			models.NewValidationError("If-Match", "ETag in If-Match fits a single UHF, send * to apply to several"))
+		return
+	}
+	t, err := h.deps.SvcOpts.UHFConfigSvc.FindTemplateByID(c.Request.Context(), fmt.Sprint(req.TemplateID))
+	if err != nil {
+		responseError(c, "Get UHF config template failed", err)
+		return
+	}
+
+	results := make([]models.UHFConfigResult, 0, len(req.UHFIDs))
+	for _, uhfId := range req.UHFIDs {
+		_, err := h.pushUHFConfig(c, uhfId, revision, t.Config, &t.ID)
+		result := models.UHFConfigResult{UHFID: uhfId, Success: err == nil}
+		if err != nil {
+			result.Message = err.Error()
+		}
+		results = append(results, result)
+	}
+	utils.ResponseJson(c, http.StatusOK, results)
+}
+
+// Update UHF config
+// @Summary Update UHF Config By UHF Address and GatewayID
+// @Schemes
+// @Description Set desired config of a UHF and push it to gateway, needs If-Match header with ETag of the UHF
+// @Accept  json
+// @Produce json
+// @Param	If-Match	header	string	true	"ETag of UHF as read, * for any"
+// @Param	data	body	models.UpdateUHFConfig	true	"UHF and its config"
+// @Success 200 {boolean} true
+// @Header 200 {string} ETag "Revision of updated UHF, send it back in If-Match"
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 412 {object} utils.ErrorResponse
+// @Failure 428 {object} utils.ErrorResponse
+// @Router /v1/uhf/config [patch]
+func (h *UHFConfigHandler) UpdateUHFConfig(c *gin.Context) {
+	req := &models.UpdateUHFConfig{}
+	err := c.ShouldBind(req)
+	if err != nil {
+		responseBindError(c, "Invalid req body", err)
+		return
+	}
+	revision, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	uhf, err := h.deps.SvcOpts.UHFSvc.FindUHFByAddress(c.Request.Context(), req.UHFAddress, req.GatewayID)
+	if err != nil {
+		responseError(c, "There is no UHF", err)
+		return
+	}
+	uhf, err = h.pushUHFConfig(c, uhf.ID, revision, req.Config, nil)
+	if err != nil {
+		responseError(c, "Update UHF config failed", err)
+		return
+	}
+	c.Header("ETag", uhf.ETag())
+	utils.ResponseJson(c, http.StatusOK, true)
+}
+
+// Find UHFs with config mismatch
+// @Summary Find UHFs With Config Mismatch
+// @Schemes
+// @Description find UHFs whose reported config differs from desired config
+// @Produce json
+// @Success 200 {array} []models.UHF
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/uhfs/config_drift [get]
+func (h *UHFConfigHandler) FindConfigMismatchedUHFs(c *gin.Context) {
+	dlList, err := h.deps.SvcOpts.UHFConfigSvc.FindConfigMismatchedUHFs(c.Request.Context())
+	if err != nil {
+		responseError(c, "Get UHFs with config mismatch failed", err)
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dlList)
+}
+
+// Store desired config of UHF still at revision then send it to its gateway,
+// returns UHF as stored
+func (h *UHFConfigHandler) pushUHFConfig(c *gin.Context, uhfId uint, revision uint, config models.UHFReaderConfig, templateId *uint) (*models.UHF, error) {
+	_, err := h.deps.SvcOpts.UHFConfigSvc.UpdateUHFDesiredConfig(c.Request.Context(), uhfId, revision, config, templateId)
+	if err != nil {
+		return nil, err
+	}
+	uhf, err := h.deps.SvcOpts.UHFSvc.FindUHFByID(c.Request.Context(), fmt.Sprint(uhfId))
+	if err != nil {
+		return nil, err
+	}
+	t := h.deps.Publisher.Publish(c.Request.Context(), mqttSvc.TOPIC_SV_UHF_CONFIG, uhf.GatewayID,
+		mqttSvc.ServerUpdateUHFConfigPayload(uhf))
+	return uhf, mqttSvc.HandleMqttErr(t)
+}
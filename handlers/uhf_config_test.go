@@ -0,0 +1,135 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+const testReaderConfig string = `{"rf_power": 30, "antennas": [1, 2], "session": 1, "q": 4, "target": "A"}`
+
+func TestUHFConfigTemplate(t *testing.T) {
+	ts := newTestServer(t)
+
+	tmpl := &models.UHFConfigTemplate{}
+	w := ts.do(http.MethodPost, "/v1/uhf_config_template", `{"name": "dock", "config": `+testReaderConfig+`}`)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, tmpl)
+	expectStatus(t, ts.do(http.MethodPost, "/v1/uhf_config_template", `{"name": "dock", "config": `+testReaderConfig+`}`),
+		http.StatusConflict, models.ERR_CODE_DUPLICATE)
+	org, err := ts.svc.TenantSvc.CreateOrganization(context.Background(), &models.Organization{Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, ts.doAs(fmt.Sprint(org.ID), http.MethodPost, "/v1/uhf_config_template", `{"name": "dock", "config": `+testReaderConfig+`}`),
+		http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodPost, "/v1/uhf_config_template", `{"name": "gate", "config": {"rf_power": 40}}`),
+		http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+
+	tList := []models.UHFConfigTemplate{}
+	w = ts.do(http.MethodGet, "/v1/uhf_config_templates", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &tList)
+	if len(tList) != 2 {
+		t.Errorf("got %d templates, wanted 1", len(tList))
+	}
+	expectStatus(t, ts.do(http.MethodGet, fmt.Sprintf("/v1/uhf_config_template/%d", tmpl.ID), ""), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodGet, "/v1/uhf_config_template/999", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	body := fmt.Sprintf(`{"id": %d, "description": "loading dock", "config": %s}`, tmpl.ID, testReaderConfig)
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/uhf_config_template", body), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/uhf_config_template", `{"id": 999, "config": `+testReaderConfig+`}`),
+		http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	body = fmt.Sprintf(`{"id": %d}`, tmpl.ID)
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/uhf_config_template", body), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/uhf_config_template", body), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+}
+
+func TestApplyUHFConfigTemplate(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01", "1")
+	uhf, _ := ts.svc.UHFSvc.FindUHFByAddress(context.Background(), "1", "gw01")
+	tmpl := &models.UHFConfigTemplate{}
+	w := ts.do(http.MethodPost, "/v1/uhf_config_template", `{"name": "dock", "config": `+testReaderConfig+`}`)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, tmpl)
+
+	body := fmt.Sprintf(`{"template_id": %d, "uhf_ids": [%d, 999]}`, tmpl.ID, uhf.ID)
+	expectStatus(t, ts.do(http.MethodPost, "/v1/uhf_config_template/apply", body),
+		http.StatusPreconditionRequired, ERR_CODE_PRECONDITION_REQUIRED)
+	expectStatus(t, ts.doIfMatch(uhf.ETag(), http.MethodPost, "/v1/uhf_config_template/apply", body),
+		http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+
+	results := []models.UHFConfigResult{}
+	w = ts.doIfMatch("*", http.MethodPost, "/v1/uhf_config_template/apply", body)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &results)
+	if len(results) != 2 || !results[0].Success || results[1].Success {
+		t.Errorf("got %+v, wanted first UHF only applied", results)
+	}
+	updated, _ := ts.svc.UHFSvc.FindUHFByID(context.Background(), fmt.Sprint(uhf.ID))
+	if updated.ConfigTemplateID == nil || *updated.ConfigTemplateID != tmpl.ID || updated.DesiredConfig == nil ||
+		updated.Revision.Revision != uhf.Revision.Revision+1 {
+		t.Errorf("got %+v, wanted desired config of template", updated)
+	}
+	published := ts.client.Published()
+	if len(published) != 1 || published[0].Topic != mqttSvc.TOPIC_SV_UHF_CONFIG {
+		t.Errorf("got %+v, wanted UHF config", published)
+	}
+
+	// UHF changed since it was read is left alone
+	results = []models.UHFConfigResult{}
+	body = fmt.Sprintf(`{"template_id": %d, "uhf_ids": [%d]}`, tmpl.ID, uhf.ID)
+	w = ts.doIfMatch(uhf.ETag(), http.MethodPost, "/v1/uhf_config_template/apply", body)
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &results)
+	if len(results) != 1 || results[0].Success || len(ts.client.Published()) != 1 {
+		t.Errorf("got %+v, wanted outdated UHF refused", results)
+	}
+
+	expectStatus(t, ts.doIfMatch("*", http.MethodPost, "/v1/uhf_config_template/apply", `{"template_id": 999, "uhf_ids": [1]}`),
+		http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	drifted := []models.UHF{}
+	w = ts.do(http.MethodGet, "/v1/uhfs/config_drift", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &drifted)
+	if len(drifted) != 1 {
+		t.Errorf("got %d UHFs, wanted UHF without reported config", len(drifted))
+	}
+}
+
+func TestUpdateUHFConfig(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01", "1")
+	uhf, _ := ts.svc.UHFSvc.FindUHFByAddress(context.Background(), "1", "gw01")
+
+	body := `{"gateway_id": "gw01", "uhf_address": "1", "config": ` + testReaderConfig + `}`
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/uhf/config", body), http.StatusPreconditionRequired, ERR_CODE_PRECONDITION_REQUIRED)
+	w := ts.doIfMatch(uhf.ETag(), http.MethodPatch, "/v1/uhf/config", body)
+	expectStatus(t, w, http.StatusOK, "")
+	if published := ts.client.Published(); len(published) != 1 || published[0].Topic != mqttSvc.TOPIC_SV_UHF_CONFIG {
+		t.Errorf("got %+v, wanted UHF config", published)
+	}
+	updated, _ := ts.svc.UHFSvc.FindUHFByAddress(context.Background(), "1", "gw01")
+	if w.Header().Get("ETag") != updated.ETag() || updated.Revision.Revision != uhf.Revision.Revision+1 {
+		t.Errorf("got ETag %s, wanted %s", w.Header().Get("ETag"), updated.ETag())
+	}
+
+	// a PATCH /v1/uhf meanwhile makes ETag read before outdated
+	expectStatus(t, ts.doIfMatch(uhf.ETag(), http.MethodPatch, "/v1/uhf/config", body), http.StatusPreconditionFailed, models.ERR_CODE_PRECONDITION)
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/uhf/config", `{"gateway_id": "gw01", "uhf_address": "1", "config": {"q": 20}}`),
+		http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/uhf/config", `{"gateway_id": "gw01", "uhf_address": "9", "config": `+testReaderConfig+`}`),
+		http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/uhf/config", `{"gateway_id": "gw01"}`),
+		http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+}
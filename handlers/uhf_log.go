@@ -29,13 +29,9 @@ func NewUHFStatusLogHandler(deps *HandlerDependencies) *UHFStatusLogHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/uhf_logs [get]
 func (h *UHFStatusLogHandler) GetAllUHFStatusLogs(c *gin.Context) {
-	uhflList, err := h.deps.SvcOpts.UHFStatusLogSvc.GetAllUHFStatusLogs(c)
+	uhflList, err := h.deps.SvcOpts.UHFStatusLogSvc.GetAllUHFStatusLogs(c.Request.Context())
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all UHF logs failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all UHF logs failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, uhflList)
@@ -52,13 +48,9 @@ func (h *UHFStatusLogHandler) GetAllUHFStatusLogs(c *gin.Context) {
 func (h *UHFStatusLogHandler) GetUHFStatusLogByUHFAddress(c *gin.Context) {
 	uhf_address := c.Param("uhf_address")
 	gateway_id := c.Param("gateway_id")
-	uhfl, err := h.deps.SvcOpts.UHFStatusLogSvc.GetUHFStatusLogByUHFAddress(c, uhf_address, gateway_id)
+	uhfl, err := h.deps.SvcOpts.UHFStatusLogSvc.GetUHFStatusLogByUHFAddress(c.Request.Context(), uhf_address, gateway_id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get UHF log failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get UHF log failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, uhfl)
@@ -74,13 +66,9 @@ func (h *UHFStatusLogHandler) GetUHFStatusLogByUHFAddress(c *gin.Context) {
 // @Router /v1/uhf_logs/{id} [get]
 func (h *UHFStatusLogHandler) GetUHFStatusLogsByID(c *gin.Context) {
 	Id := c.Param("id")
-	uhfl, err := h.deps.SvcOpts.UHFStatusLogSvc.GetUHFStatusLogByID(c, Id)
+	uhfl, err := h.deps.SvcOpts.UHFStatusLogSvc.GetUHFStatusLogByID(c.Request.Context(), Id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get UHF log failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get UHF log failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, uhfl)
@@ -101,13 +89,10 @@ func (h *UHFStatusLogHandler) GetUHFStatusLogInTimeRange(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	dlslList, err := h.deps.SvcOpts.UHFStatusLogSvc.GetUHFStatusLogInTimeRange(fromFormatted, toFormatted)
+	dlslList, err := h.deps.SvcOpts.UHFStatusLogSvc.GetUHFStatusLogInTimeRange(c.Request.Context(), fromFormatted, toFormatted)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to get UHF status logs",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to get UHF status logs", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, dlslList)
 }
@@ -129,13 +114,10 @@ func (h *UHFStatusLogHandler) GetUHFStatusLogBYGatewayIDAndUHFAddressInTimeRange
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	dlslList, err := h.deps.SvcOpts.UHFStatusLogSvc.GetUHFStatusLogBYGatewayIDAndUHFAddressInTimeRange(fromFormatted, toFormatted, gateway_id, uhf_address)
+	dlslList, err := h.deps.SvcOpts.UHFStatusLogSvc.GetUHFStatusLogBYGatewayIDAndUHFAddressInTimeRange(c.Request.Context(), fromFormatted, toFormatted, gateway_id, uhf_address)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to get UHF status logs",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to get UHF status logs", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, dlslList)
 }
@@ -157,13 +139,9 @@ func (h *UHFStatusLogHandler) DeleteUHFLogInTimeRange(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	isSuccess, err := h.deps.SvcOpts.UHFStatusLogSvc.DeleteUHFLogInTimeRange(fromFormatted, toFormatted)
+	isSuccess, err := h.deps.SvcOpts.UHFStatusLogSvc.DeleteUHFLogInTimeRange(c.Request.Context(), fromFormatted, toFormatted)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to delete uhf status logs",
-			ErrorMsg:   "There is no record in this time range",
-		})
+		responseError(c, "Failed to delete uhf status logs", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
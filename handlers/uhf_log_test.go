@@ -0,0 +1,60 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestUHFStatusLog(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now()
+	for i, address := range []string{"1", "1", "2"} {
+		_, err := ts.svc.UHFStatusLogSvc.CreateUHFStatusLog(context.Background(), &models.UHFStatusLog{
+			GatewayID:  "gw01",
+			UHFAddress: address,
+			StateType:  "ConnectState",
+			StateValue: "connected",
+			Time:       now.Add(-time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lastHalfHour := period(now.Add(-30*time.Minute), now.Add(time.Minute))
+	cases := []struct {
+		path string
+		n    int
+	}{
+		{"/v1/uhf_logs", 3},
+		{"/v1/uhf_logs/gateway_id/gw01/uhf_address/1", 2},
+		{"/v1/uhf_logs/gateway_id/gw01/uhf_address/1" + lastHalfHour, 1},
+		{"/v1/uhf_logs" + period(now.Add(-3*time.Hour), now.Add(time.Minute)), 3},
+	}
+	for _, c := range cases {
+		ulList := []models.UHFStatusLog{}
+		w := ts.do(http.MethodGet, c.path, "")
+		expectStatus(t, w, http.StatusOK, "")
+		decode(t, w, &ulList)
+		if len(ulList) != c.n {
+			t.Errorf("%s: got %d logs, wanted %d", c.path, len(ulList), c.n)
+		}
+	}
+
+	ulList, _ := ts.svc.UHFStatusLogSvc.GetAllUHFStatusLogs(context.Background())
+	expectStatus(t, ts.do(http.MethodGet, fmt.Sprintf("/v1/uhf_logs/%d", ulList[0].ID), ""), http.StatusOK, "")
+	expectStatus(t, ts.do(http.MethodGet, "/v1/uhf_logs/999", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/uhf_logs"+lastHalfHour, ""), http.StatusOK, "")
+	if ulList, _ = ts.svc.UHFStatusLogSvc.GetAllUHFStatusLogs(context.Background()); len(ulList) != 2 {
+		t.Errorf("got %d logs, wanted 2 older than half an hour", len(ulList))
+	}
+}
@@ -0,0 +1,266 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func TestFindUHF(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01", "1", "2")
+	if _, err := ts.svc.UHFSvc.UpdateUHFReportedState(context.Background(), "2", "gw01", "active"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.svc.UHFSvc.UpdateUHFDesiredState(context.Background(), "2", "gw01", "inactive"); err != nil {
+		t.Fatal(err)
+	}
+
+	uhfs := []models.UHF{}
+	w := ts.do(http.MethodGet, "/v1/uhfs", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &uhfs)
+	if len(uhfs) != 2 {
+		t.Fatalf("got %d UHFs, wanted 2", len(uhfs))
+	}
+
+	uhf := &models.UHF{}
+	w = ts.do(http.MethodGet, fmt.Sprintf("/v1/uhf/%d", uhfs[0].ID), "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, uhf)
+	if uhf.UHFAddress != uhfs[0].UHFAddress {
+		t.Errorf("got %s, wanted %s", uhf.UHFAddress, uhfs[0].UHFAddress)
+	}
+	expectStatus(t, ts.do(http.MethodGet, "/v1/uhf/999", ""), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	drifted := []models.UHF{}
+	w = ts.do(http.MethodGet, "/v1/uhfs/drift", "")
+	expectStatus(t, w, http.StatusOK, "")
+	decode(t, w, &drifted)
+	if len(drifted) != 1 || drifted[0].UHFAddress != "2" {
+		t.Errorf("got %+v, wanted only UHF 2", drifted)
+	}
+}
+
+func TestUpdateUHF(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01", "1")
+	area, err := ts.svc.AreaSvc.CreateArea(&models.Area{Name: "Hall", Manager: "Lan"}, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/uhf", `{"gateway_id": "gw01", "uhf_address": "1"}`),
+		http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/uhf", `{"gateway_id": "gw01", "uhf_address": "1", "area_id": "999"}`),
+		http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+	expectStatus(t, ts.doIfMatch("*", http.MethodPatch, "/v1/uhf", `{"gateway_id": "gw01", "uhf_address": "9", "area_id": "1"}`),
+		http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+
+	before, err := ts.svc.UHFSvc.FindUHFByAddress(context.Background(), "1", "gw01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := fmt.Sprintf(`{"gateway_id": "gw01", "uhf_address": "1", "area_id": "%d", "active_state": "inactive"}`, area.ID)
+	w := ts.doIfMatch("*", http.MethodPatch, "/v1/uhf", body)
+	expectStatus(t, w, http.StatusOK, "")
+
+	uhf, err := ts.svc.UHFSvc.FindUHFByAddress(context.Background(), "1", "gw01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// area and desired state are one update
+	if uhf.Revision.Revision != before.Revision.Revision+1 || w.Header().Get("ETag") != uhf.ETag() {
+		t.Errorf("got revision %d at %s, wanted %d at %s", uhf.Revision.Revision, w.Header().Get("ETag"),
+			before.Revision.Revision+1, uhf.ETag())
+	}
+	if uhf.AreaId != fmt.Sprint(area.ID) || uhf.DesiredState != "inactive" || uhf.ActiveState != "" {
+		t.Errorf("got %s %s %s, wanted area set and inactive desired", uhf.AreaId, uhf.DesiredState, uhf.ActiveState)
+	}
+	logs, _ := ts.svc.UHFStatusLogSvc.GetUHFStatusLogByUHFAddress(context.Background(), "1", "gw01")
+	if len(logs) != 1 || logs[0].StateValue != "inactive" {
+		t.Errorf("got %+v, wanted desired state log", logs)
+	}
+	published := ts.client.Published()
+	if len(published) != 1 || published[0].Topic != mqttSvc.TOPIC_SV_UHF_U {
+		t.Errorf("got %+v, wanted UHF update", published)
+	}
+}
+
+func TestUHFRevision(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01", "1")
+	area, err := ts.svc.AreaSvc.CreateArea(&models.Area{Name: "Hall", Manager: "Lan"}, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	uhf, err := ts.svc.UHFSvc.FindUHFByAddress(context.Background(), "1", "gw01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := ts.do(http.MethodGet, fmt.Sprintf("/v1/uhf/%d", uhf.ID), "")
+	etag := w.Header().Get("ETag")
+	if etag != uhf.ETag() {
+		t.Fatalf("got ETag %s, wanted %s", etag, uhf.ETag())
+	}
+
+	// revision sent in body is ignored, If-Match decides
+	body := fmt.Sprintf(`{"gateway_id": "gw01", "uhf_address": "1", "area_id": "%d", "revision": 99}`, area.ID)
+	expectStatus(t, ts.do(http.MethodPatch, "/v1/uhf", body), http.StatusPreconditionRequired, ERR_CODE_PRECONDITION_REQUIRED)
+	expectStatus(t, ts.doIfMatch(etag, http.MethodPatch, "/v1/uhf", body), http.StatusOK, "")
+	expectStatus(t, ts.doIfMatch(etag, http.MethodPatch, "/v1/uhf", body), http.StatusPreconditionFailed, models.ERR_CODE_PRECONDITION)
+
+	sent := len(ts.client.Published())
+	deleteBody := fmt.Sprintf(`{"id": "%d"}`, uhf.ID)
+	expectStatus(t, ts.doIfMatch(etag, http.MethodDelete, "/v1/uhf", deleteBody), http.StatusPreconditionFailed, models.ERR_CODE_PRECONDITION)
+	if published := ts.client.Published(); len(published) != sent {
+		t.Errorf("got %+v, wanted outdated delete kept from gateway", published[sent:])
+	}
+	etag = ts.do(http.MethodGet, fmt.Sprintf("/v1/uhf/%d", uhf.ID), "").Header().Get("ETag")
+	expectStatus(t, ts.doIfMatch(etag, http.MethodDelete, "/v1/uhf", deleteBody), http.StatusOK, "")
+}
+
+func TestDeleteUHF(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedGateway(t, context.Background(), "gw01", "1")
+	uhf, err := ts.svc.UHFSvc.FindUHFByAddress(context.Background(), "1", "gw01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.client.FailPublish(fmt.Errorf("not connected"))
+	body := fmt.Sprintf(`{"id": "%d"}`, uhf.ID)
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/uhf", body), http.StatusServiceUnavailable, models.ERR_CODE_BROKER_UNAVAILABLE)
+	if _, err := ts.svc.UHFSvc.FindUHFByID(context.Background(), fmt.Sprint(uhf.ID)); err != nil {
+		t.Errorf("got %v, wanted UHF kept when publish fails", err)
+	}
+
+	ts.client.FailPublish(nil)
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/uhf", body), http.StatusOK, "")
+	published := ts.client.Published()
+	if len(published) != 1 || published[0].Topic != mqttSvc.TOPIC_SV_UHF_D {
+		t.Errorf("got %+v, wanted UHF delete", published)
+	}
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/uhf", body), http.StatusNotFound, models.ERR_CODE_NOT_FOUND)
+	expectStatus(t, ts.doIfMatch("*", http.MethodDelete, "/v1/uhf", `{}`), http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+}
+
+// bulkUHFs posts a bulk request on UHFs and decodes its result
+func (ts *testServer) bulkUHFs(t *testing.T, body string) *models.BulkResult {
+	t.Helper()
+	w := ts.do(http.MethodPost, "/v1/uhfs/bulk", body)
+	expectStatus(t, w, http.StatusOK, "")
+	result := &models.BulkResult{}
+	decode(t, w, result)
+	return result
+}
+
+func TestBulkUpdateUHFs(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	ts.seedGateway(t, ctx, "gw01", "1", "2", "3")
+	ts.seedGateway(t, ctx, "gw02", "1")
+	hall, err := ts.svc.AreaSvc.CreateArea(&models.Area{Name: "Hall", Manager: "an"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lobby, err := ts.svc.AreaSvc.CreateArea(&models.Area{Name: "Lobby", Manager: "binh"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uhf := range []models.UHF{{GatewayID: "gw01", UHFAddress: "1"}, {GatewayID: "gw01", UHFAddress: "2"}, {GatewayID: "gw02", UHFAddress: "1"}} {
+		uhf.AreaId = fmt.Sprint(hall.ID)
+		if _, err := ts.svc.UHFSvc.UpdateUHF(ctx, &uhf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// UHF 3 has no area and can't be activated, the others get a message each
+	result := ts.bulkUHFs(t, `{"selector": {"gateway_id": "gw01"}, "action": "activate"}`)
+	if result.Matched != 3 || result.Succeeded != 2 || result.Failed != 1 {
+		t.Fatalf("got %+v, wanted 2 of 3 UHFs activated", result)
+	}
+	if r := result.Results[2]; r.UHFAddress != "3" || r.Status != models.BULK_FAILED || r.Code != models.ERR_CODE_VALIDATION {
+		t.Errorf("got %+v, wanted UHF 3 failed validation", r)
+	}
+	if uhf, _ := ts.svc.UHFSvc.FindUHFByAddress(ctx, "2", "gw01"); uhf.DesiredState != "active" {
+		t.Errorf("got %s, wanted UHF 2 desired active", uhf.DesiredState)
+	}
+	if logs, _ := ts.svc.UHFStatusLogSvc.GetUHFStatusLogByUHFAddress(ctx, "1", "gw01"); len(logs) != 1 {
+		t.Errorf("got %d logs, wanted desired state log", len(logs))
+	}
+	published := ts.client.Published()
+	if len(published) != 2 || !strings.HasSuffix(published[1].Topic, mqttSvc.TOPIC_SV_UHF_U) ||
+		!strings.Contains(published[1].Payload, `{"address":"2","state":"active"}`) {
+		t.Fatalf("got %+v, wanted an update per UHF", published)
+	}
+
+	// gateways taking uhf/batch get a message each
+	ts.publisher.SetUHFBatch(true)
+	body := fmt.Sprintf(`{"selector": {"area_id": "%d"}, "action": "reassign_area", "area_id": "%d"}`, hall.ID, lobby.ID)
+	if result := ts.bulkUHFs(t, body); result.Succeeded != 3 {
+		t.Errorf("got %+v, wanted 3 UHFs moved", result)
+	}
+	if uhf, _ := ts.svc.UHFSvc.FindUHFByAddress(ctx, "1", "gw02"); uhf.AreaId != fmt.Sprint(lobby.ID) {
+		t.Errorf("got area %s, wanted Lobby", uhf.AreaId)
+	}
+	published = ts.client.Published()
+	if len(published) != 4 || !strings.HasSuffix(published[2].Topic, mqttSvc.TOPIC_SV_UHF_BATCH) {
+		t.Fatalf("got %+v, wanted one batch per gateway", published[2:])
+	}
+	batch := &mqttSvc.UHFBatchPayload{}
+	if err := json.Unmarshal([]byte(published[2].Payload), &mqttSvc.Envelope{Message: batch}); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Updates) != 2 || batch.Updates[1].State != "active" || len(batch.Deletes) != 0 {
+		t.Errorf("got %+v, wanted UHFs 1 and 2 of gw01", batch)
+	}
+
+	// changes are dropped when their message can't be sent
+	ts.client.FailPublish(fmt.Errorf("not connected"))
+	result = ts.bulkUHFs(t, `{"selector": {"gateway_id": "gw02"}, "action": "delete"}`)
+	if result.Failed != 1 || result.Results[0].Code != models.ERR_CODE_BROKER_UNAVAILABLE {
+		t.Errorf("got %+v, wanted UHF failed with broker unavailable", result)
+	}
+	if _, err := ts.svc.UHFSvc.FindUHFByAddress(ctx, "1", "gw02"); err != nil {
+		t.Errorf("got %v, wanted UHF kept", err)
+	}
+	ts.client.FailPublish(nil)
+
+	result = ts.bulkUHFs(t, `{"selector": {"gateway_id": "gw02"}, "action": "delete"}`)
+	if result.Succeeded != 1 {
+		t.Errorf("got %+v, wanted UHF deleted", result)
+	}
+	if _, err := ts.svc.UHFSvc.FindUHFByAddress(ctx, "1", "gw02"); models.AsDomainError(err) == nil {
+		t.Errorf("got %v, wanted UHF gone", err)
+	}
+	published = ts.client.Published()
+	batch = &mqttSvc.UHFBatchPayload{}
+	json.Unmarshal([]byte(published[len(published)-1].Payload), &mqttSvc.Envelope{Message: batch})
+	if len(batch.Deletes) != 1 || batch.Deletes[0].Address != "1" {
+		t.Errorf("got %+v, wanted UHF 1 removed", batch)
+	}
+	if result := ts.bulkUHFs(t, `{"selector": {"family": "none"}, "action": "delete"}`); result.Matched != 0 {
+		t.Errorf("got %+v, wanted nothing matched", result)
+	}
+
+	for _, body := range []string{
+		`{"selector": {}, "action": "delete"}`,
+		`{"selector": {"gateway_id": "gw01"}, "action": "explode"}`,
+		`{"selector": {"gateway_id": "gw01"}, "action": "reassign_area"}`,
+		`{"selector": {"gateway_id": "gw01"}, "action": "reassign_area", "area_id": "999"}`,
+	} {
+		expectStatus(t, ts.do(http.MethodPost, "/v1/uhfs/bulk", body), http.StatusUnprocessableEntity, models.ERR_CODE_VALIDATION)
+	}
+	expectStatus(t, ts.do(http.MethodPost, "/v1/uhfs/bulk", `{"selector": {"gateway_id": "gw01"}}`),
+		http.StatusBadRequest, ERR_CODE_INVALID_REQUEST)
+}
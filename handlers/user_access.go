@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"github.com/gin-gonic/gin"
@@ -29,13 +28,9 @@ func NewUserAccessHandler(deps *HandlerDependencies) *UserAccessHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/user_accesses [get]
 func (h *UserAccessHandler) FindAllAccesses(c *gin.Context) {
-	gwList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccess(c)
+	gwList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccess(c.Request.Context())
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all accesses failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all accesses failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, gwList)
@@ -51,13 +46,9 @@ func (h *UserAccessHandler) FindAllAccesses(c *gin.Context) {
 // @Router /v1/user_accesses/user_id/{id} [get]
 func (h *UserAccessHandler) FindUserAccessByUserID(c *gin.Context) {
 	id := c.Param("id")
-	accesslist, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByUserID(context.Background(), id)
+	accesslist, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByUserID(c.Request.Context(), id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all accesses failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all accesses failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, accesslist)
@@ -79,14 +70,11 @@ func (h *UserAccessHandler) FindUserAccessByUserIDAndTimeRange(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.UserAccessSvc.FindUserAccessesByUserIDAndTimeRange(user_id, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.UserAccessSvc.FindUserAccessesByUserIDAndTimeRange(c.Request.Context(), user_id, fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -102,13 +90,9 @@ func (h *UserAccessHandler) FindUserAccessByUserIDAndTimeRange(c *gin.Context) {
 func (h *UserAccessHandler) FindUserAccessByUserIDAndAreaID(c *gin.Context) {
 	id := c.Param("id")
 	area_id := c.Param("area_id")
-	accesslist, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByUserIDAndAreaID(context.Background(), id, area_id)
+	accesslist, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByUserIDAndAreaID(c.Request.Context(), id, area_id)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Get all accesses failed",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Get all accesses failed", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, accesslist)
@@ -131,14 +115,11 @@ func (h *UserAccessHandler) FindAllUserAccessByUserIDAndAreaIDinTimeRange(c *gin
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByUserIDAndAreaIDinTimeRange(context.Background(), user_id, area_id, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByUserIDAndAreaIDinTimeRange(c.Request.Context(), user_id, area_id, fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -153,14 +134,11 @@ func (h *UserAccessHandler) FindAllUserAccessByUserIDAndAreaIDinTimeRange(c *gin
 // @Router /v1/user_accesses/area_id/{area_id} [get]
 func (h *UserAccessHandler) FindAllUserAccessByAreaID(c *gin.Context) {
 	area_id := c.Param("area_id")
-	glList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByAreaID(context.Background(), area_id)
+	glList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByAreaID(c.Request.Context(), area_id)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -181,14 +159,11 @@ func (h *UserAccessHandler) FindAllUserAccessByAreaIDAndTimeRange(c *gin.Context
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByAreaIDAndTimeRange(context.Background(), area_id, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessByAreaIDAndTimeRange(c.Request.Context(), area_id, fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -208,14 +183,11 @@ func (h *UserAccessHandler) FindAllUserAccessTimeRange(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessTimeRange(context.Background(), fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.UserAccessSvc.FindAllUserAccessTimeRange(c.Request.Context(), fromFormatted, toFormatted)
 
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to user access",
-			ErrorMsg:   err.Error(),
-		})
+		responseError(c, "Failed to user access", err)
+		return
 	}
 	utils.ResponseJson(c, http.StatusOK, glList)
 }
@@ -237,13 +209,9 @@ func (h *UserAccessHandler) DeleteUserAccessTimeRange(c *gin.Context) {
 	toInt, _ := strconv.ParseInt(to, 10, 64)
 	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
 	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	isSuccess, err := h.deps.SvcOpts.UserAccessSvc.DeleteUserAccessTimeRange(fromFormatted, toFormatted)
+	isSuccess, err := h.deps.SvcOpts.UserAccessSvc.DeleteUserAccessTimeRange(c.Request.Context(), fromFormatted, toFormatted)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
-			Msg:        "Failed to delete user accesses logs",
-			ErrorMsg:   "There is no record in this time range",
-		})
+		responseError(c, "Failed to delete user accesses logs", err)
 		return
 	}
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
@@ -0,0 +1,55 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestUserAccess(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now()
+	seeds := []models.UserAccess{
+		{UserID: "u1", AreaID: "1", Time: now},
+		{UserID: "u1", AreaID: "2", Time: now.Add(-time.Hour)},
+		{UserID: "u2", AreaID: "1", Time: now.Add(-2 * time.Hour)},
+	}
+	if err := ts.svc.UserAccessSvc.CreateUserAccesses(context.Background(), seeds, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	lastHalfHour := period(now.Add(-30*time.Minute), now.Add(time.Minute))
+	cases := []struct {
+		path string
+		n    int
+	}{
+		{"/v1/user_accesses", 3},
+		{"/v1/user_accesses/user_id/u1", 2},
+		{"/v1/user_accesses/user_id/u1/area_id/2", 1},
+		{"/v1/user_accesses/user_id/u1" + lastHalfHour, 1},
+		{"/v1/user_accesses/user_id/u1/area_id/2" + lastHalfHour, 0},
+		{"/v1/user_accesses/area_id/1", 2},
+		{"/v1/user_accesses/area_id/1" + lastHalfHour, 1},
+		{"/v1/user_accesses" + period(now.Add(-90*time.Minute), now.Add(time.Minute)), 2},
+	}
+	for _, c := range cases {
+		uaList := []models.UserAccess{}
+		w := ts.do(http.MethodGet, c.path, "")
+		expectStatus(t, w, http.StatusOK, "")
+		decode(t, w, &uaList)
+		if len(uaList) != c.n {
+			t.Errorf("%s: got %d accesses, wanted %d", c.path, len(uaList), c.n)
+		}
+	}
+
+	expectStatus(t, ts.do(http.MethodDelete, "/v1/user_accesses"+lastHalfHour, ""), http.StatusOK, "")
+	if uaList, _ := ts.svc.UserAccessSvc.FindAllUserAccess(context.Background()); len(uaList) != 2 {
+		t.Errorf("got %d accesses, wanted 2 older than half an hour", len(uaList))
+	}
+}
@@ -1,5 +1,7 @@
 package initializers
 
+import "time"
+
 type Config struct {
 	ServerHost string `envconfig:"SERVER_HOST"`
 	DbHost     string `envconfig:"DB_HOST"`
@@ -10,5 +12,59 @@ type Config struct {
 	MqttHost   string `envconfig:"MQTT_HOST"`
 	MqttPort   string `envconfig:"MQTT_PORT"`
 	MqttClient string `envconfig:"MQTT_CLIENT"`
+	MqttUser   string `envconfig:"MQTT_USERNAME"`
+	MqttPass   string `envconfig:"MQTT_PASSWORD"`
 	SvLogPath  string `envconfig:"SV_LOG_FILE"`
+
+	LogFormat     string `envconfig:"LOG_FORMAT" default:"text"` // text, json
+	LogLevel      string `envconfig:"LOG_LEVEL" default:"debug"`
+	LogLevels     string `envconfig:"LOG_LEVELS"` // comma separated COMPONENT=level, e.g. MQTT=info,GIN_ROUTER=warn
+	LogMaxSizeMB  int    `envconfig:"LOG_MAX_SIZE_MB" default:"100"`
+	LogMaxAgeDays int    `envconfig:"LOG_MAX_AGE_DAYS" default:"30"`
+	LogMaxBackups int    `envconfig:"LOG_MAX_BACKUPS" default:"10"`
+	LogCompress   bool   `envconfig:"LOG_COMPRESS" default:"true"`
+
+	LogRotateInterval time.Duration `envconfig:"LOG_ROTATE_INTERVAL" default:"24h"` // 0 rotates on size only
+
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"15s"`          // HTTP requests in flight get this long to finish
+	MqttQuiesce     time.Duration `envconfig:"MQTT_DISCONNECT_QUIESCE" default:"250ms"` // wait for unsubscribe and pending publishes
+
+	AdminApiKeys []string `envconfig:"ADMIN_API_KEYS"` // comma separated, authenticate as every organization
+
+	TracingExporter    string  `envconfig:"TRACING_EXPORTER" default:"none"` // none, stdout, otlp
+	TracingEndpoint    string  `envconfig:"OTEL_EXPORTER_OTLP_ENDPOINT" default:"localhost:4317"`
+	TracingSampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
+
+	MqttTopicPrefix      string   `envconfig:"MQTT_TOPIC_PREFIX" default:"uams"`
+	MqttSites            []string `envconfig:"MQTT_SITES"` // comma separated, + for all sites, empty keeps topics without site
+	MqttSchemaValidation bool     `envconfig:"MQTT_SCHEMA_VALIDATION" default:"true"`
+	MqttCaptureFile      string   `envconfig:"MQTT_CAPTURE_FILE"`              // record inbound messages for cmd/replay, empty disables
+	MqttUHFBatch         bool     `envconfig:"MQTT_UHF_BATCH" default:"false"` // gateway firmware takes bulk UHF changes on uhf/batch
+
+	MqttBrokerEmbedded        bool   `envconfig:"MQTT_BROKER_EMBEDDED" default:"false"` // run broker in process, MQTT_HOST and MQTT_PORT are then unused
+	MqttBrokerAddress         string `envconfig:"MQTT_BROKER_ADDRESS" default:":1883"`
+	MqttBrokerGatewayPassword string `envconfig:"MQTT_BROKER_GATEWAY_PASSWORD"`                       // required unless MQTT_BROKER_INSECURE
+	MqttBrokerAllowUnknown    bool   `envconfig:"MQTT_BROKER_ALLOW_UNKNOWN_GATEWAYS" default:"false"` // let gateways not registered yet boot up
+	MqttBrokerInsecure        bool   `envconfig:"MQTT_BROKER_INSECURE" default:"false"`               // accept any gateway password when none is set, development only
+
+	TwinReconcileInterval time.Duration `envconfig:"TWIN_RECONCILE_INTERVAL" default:"30s"`
+	TwinMaxSyncAttempts   int           `envconfig:"TWIN_MAX_SYNC_ATTEMPTS" default:"10"` // 0 retries forever
+	TwinMaxBackoff        time.Duration `envconfig:"TWIN_MAX_BACKOFF" default:"30m"`
+	TagDebounceWindow     time.Duration `envconfig:"TAG_DEBOUNCE_WINDOW" default:"30s"`
+	TagMemEncoding        string        `envconfig:"TAG_MEM_ENCODING" default:"auto"`      // auto, ascii, hex
+	TagTypes              string        `envconfig:"TAG_TYPES" default:"U:user,P:package"` // prefix:kind, kind is user or package
+
+	IngestWorkers        int           `envconfig:"INGEST_WORKERS" default:"4"`
+	IngestQueueSize      int           `envconfig:"INGEST_QUEUE_SIZE" default:"1000"`
+	IngestEnqueueTimeout time.Duration `envconfig:"INGEST_ENQUEUE_TIMEOUT" default:"100ms"`
+	IngestBatchSize      int           `envconfig:"INGEST_BATCH_SIZE" default:"100"`
+	IngestFlushInterval  time.Duration `envconfig:"INGEST_FLUSH_INTERVAL" default:"1s"`
+	IngestMaxPending     int           `envconfig:"INGEST_MAX_PENDING" default:"10000"`
+
+	FirmwareDir          string        `envconfig:"FIRMWARE_DIR" default:"./firmware"`
+	FirmwareBaseUrl      string        `envconfig:"FIRMWARE_BASE_URL"`   // default http://SERVER_HOST:8079
+	FirmwareUrlSecret    string        `envconfig:"FIRMWARE_URL_SECRET"` // signs artifact URLs, random on every start when empty
+	FirmwareUrlTTL       time.Duration `envconfig:"FIRMWARE_URL_TTL" default:"1h"`
+	RolloutInterval      time.Duration `envconfig:"ROLLOUT_INTERVAL" default:"10s"`
+	RolloutTargetTimeout time.Duration `envconfig:"ROLLOUT_TARGET_TIMEOUT" default:"30m"`
 }
@@ -1,15 +1,19 @@
 package initializers
 
 import (
+	"context"
 	"fmt"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/ecoprohcm/DMS_BackendServer/handlers"
 	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
+	"github.com/prometheus/client_golang/prometheus"
 	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 )
@@ -18,7 +22,11 @@ type ContextContainer struct {
 	Config         Config
 	Db             *gorm.DB
 	MqttClient     mqtt.Client
+	Ingestion      *mqttSvc.Ingestion
 	HandlerOptions *handlers.HandlerOptions
+	TwinReconciler *mqttSvc.TwinReconciler
+	RolloutManager *mqttSvc.RolloutManager
+	Tracing        *tracing.Provider
 }
 
 func ProvideConfig(envFilePath string) (Config, error) {
@@ -33,22 +41,86 @@ func ProvideConfig(envFilePath string) (Config, error) {
 		return cfg, err
 	}
 
-	logger.InitLogger(cfg.SvLogPath)
+	componentLevels, err := logger.ParseComponentLevels(cfg.LogLevels)
+	if err != nil {
+		return cfg, err
+	}
+	err = logger.InitLogger(logger.LoggerOptions{
+		FilePath:       cfg.SvLogPath,
+		Format:         cfg.LogFormat,
+		Level:          cfg.LogLevel,
+		Components:     componentLevels,
+		MaxSizeMB:      cfg.LogMaxSizeMB,
+		RotateInterval: cfg.LogRotateInterval,
+		MaxAgeDays:     cfg.LogMaxAgeDays,
+		MaxBackups:     cfg.LogMaxBackups,
+		Compress:       cfg.LogCompress,
+	})
+	if err != nil {
+		return cfg, err
+	}
 	return cfg, nil
 }
 
-func ProvideGormDb(config Config) (*gorm.DB, error) {
+// Cleanup flushes spans still batched
+func ProvideTracing(config Config) (*tracing.Provider, func(), error) {
+	provider, err := tracing.Init(context.Background(), tracing.Options{
+		Exporter:     config.TracingExporter,
+		OtlpEndpoint: config.TracingEndpoint,
+		SampleRatio:  config.TracingSampleRatio,
+		ServiceName:  "uams-be",
+		Version:      handlers.BuildVersion,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			logger.LogfWithoutFields(logger.UAMSSERVER, logger.ErrorLevel, "Flush spans failed, err %s", err.Error())
+		}
+	}
+	return provider, cleanup, nil
+}
+
+func ProvideGormDb(config Config) (*gorm.DB, func(), error) {
 	dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s", config.DbUser, config.DbPass, config.DbHost, config.DbPort, config.DbName)
 	db, err := gorm.Open(sqlserver.Open(dsn), &gorm.Config{})
 	if err != nil {
 		fmt.Println("failed to connect database")
-		return nil, err
+		return nil, nil, err
+	}
+	sqlDb, err := db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		if err := sqlDb.Close(); err != nil {
+			logger.LogfWithoutFields(logger.SQLSERVER, logger.ErrorLevel, "Close database failed, err %s", err.Error())
+		}
+	}
+	if err := models.RegisterTenantCallbacks(db); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := models.RegisterRevisionCallbacks(db); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := models.RegisterMetricsCallbacks(db); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := models.RegisterTracingCallbacks(db); err != nil {
+		cleanup()
+		return nil, nil, err
 	}
 	models.Migrate(db)
-	return db, nil
+	return db, cleanup, nil
 }
 
-func ProvideSvcOptions(db *gorm.DB) *models.ServiceOptions {
+func ProvideSvcOptions(config Config, db *gorm.DB) *models.ServiceOptions {
 	return &models.ServiceOptions{
 		GatewaySvc:       models.NewGatewaySvc(db),
 		AreaSvc:          models.NewAreaSvc(db),
@@ -59,22 +131,186 @@ func ProvideSvcOptions(db *gorm.DB) *models.ServiceOptions {
 		PackageAccessSvc: models.NewPackageAccessSvc(db),
 		SystemLogSvc:     models.NewSystemLogSvc(db),
 		OperationLogSvc:  models.NewOperationLogSvc(db),
+		FirmwareSvc:      models.NewFirmwareSvc(db, config.FirmwareDir),
+		RolloutSvc:       models.NewRolloutSvc(db),
+		UHFConfigSvc:     models.NewUHFConfigSvc(db),
+		DeadLetterSvc:    models.NewDeadLetterSvc(db),
+		TagReadErrorSvc:  models.NewTagReadErrorSvc(db),
+		TenantSvc:        models.NewTenantSvc(db),
+		SchemaSvc:        models.NewSchemaSvc(db),
+		ImportSvc:        models.NewImportSvc(db),
+		TrashSvc:         models.NewTrashSvc(db),
+	}
+}
+
+func ProvideReadDebouncer(config Config) *mqttSvc.ReadDebouncer {
+	return mqttSvc.NewReadDebouncer(config.TagDebounceWindow)
+}
+
+func ProvideTagDecoder(config Config) (*mqttSvc.TagDecoder, error) {
+	return mqttSvc.NewTagDecoder(config.TagMemEncoding, config.TagTypes)
+}
+
+// Validation of gateway payloads is skipped when MQTT_SCHEMA_VALIDATION is false
+func ProvideSchemaValidator(config Config) (*mqttSvc.SchemaValidator, error) {
+	if !config.MqttSchemaValidation {
+		return nil, nil
+	}
+	return mqttSvc.NewSchemaValidator()
+}
+
+func ProvideTopics(config Config) (*mqttSvc.Topics, error) {
+	return mqttSvc.NewTopics(config.MqttTopicPrefix, config.MqttSites)
+}
+
+func ProvideIngestion(
+	config Config,
+	topics *mqttSvc.Topics,
+	svcOptions *models.ServiceOptions,
+	debouncer *mqttSvc.ReadDebouncer,
+	tagDecoder *mqttSvc.TagDecoder,
+	schemaValidator *mqttSvc.SchemaValidator,
+) (*mqttSvc.Ingestion, func(), error) {
+	writer := mqttSvc.NewBatchWriter(svcOptions,
+		config.IngestBatchSize, config.IngestFlushInterval, config.IngestMaxPending)
+	ingestion := mqttSvc.NewIngestion(mqttSvc.IngestionOptions{
+		Workers:        config.IngestWorkers,
+		QueueSize:      config.IngestQueueSize,
+		EnqueueTimeout: config.IngestEnqueueTimeout,
+	}, topics, debouncer, tagDecoder, schemaValidator, writer, svcOptions.DeadLetterSvc)
+	if config.MqttCaptureFile != "" {
+		capture, err := mqttSvc.NewCapture(config.MqttCaptureFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		ingestion.Capture = capture
+		logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel, "Capturing inbound messages to %s", config.MqttCaptureFile)
+	}
+	ingestion.Start()
+	return ingestion, func() {
+		ingestion.Stop()
+		if ingestion.Capture != nil {
+			ingestion.Capture.Close()
+		}
+	}, nil
+}
+
+// Domain gauges and ingestion queue length are collected on scrape
+func ProvideMetrics(svcOptions *models.ServiceOptions, ingestion *mqttSvc.Ingestion) (*prometheus.Registry, error) {
+	queueLength := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metrics.NAMESPACE,
+		Subsystem: "ingestion",
+		Name:      "queue_length",
+		Help:      "Gateway messages waiting for an ingestion worker.",
+	}, func() float64 {
+		return float64(ingestion.QueueLength())
+	})
+	if err := metrics.Register(models.NewDomainCollector(svcOptions), queueLength); err != nil {
+		return nil, err
 	}
+	return metrics.Registry, nil
+}
+
+func ProvideConnectionMonitor() *mqttSvc.ConnectionMonitor {
+	return mqttSvc.NewConnectionMonitor()
 }
 
-func ProvideMqttClient(config Config, svcOptions *models.ServiceOptions) mqtt.Client {
-	return mqttSvc.MqttClient(
+// Broker is nil unless MQTT_BROKER_EMBEDDED is set
+func ProvideBroker(config Config, topics *mqttSvc.Topics, svcOptions *models.ServiceOptions) (*mqttSvc.Broker, func(), error) {
+	if !config.MqttBrokerEmbedded {
+		return nil, func() {}, nil
+	}
+	broker, err := mqttSvc.NewBroker(mqttSvc.BrokerOptions{
+		Address:         config.MqttBrokerAddress,
+		GatewayPassword: config.MqttBrokerGatewayPassword,
+		AllowUnknown:    config.MqttBrokerAllowUnknown,
+		Insecure:        config.MqttBrokerInsecure,
+	}, topics, svcOptions.GatewaySvc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return broker, func() {
+		if err := broker.Close(); err != nil {
+			logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Close embedded broker failed, err %s", err.Error())
+		}
+	}, nil
+}
+
+// Client cleanup drains ingestion before disconnecting, ingestion cleanup is then a no-op.
+// With embedded broker client connects to it instead of MQTT_HOST.
+func ProvideMqttClient(
+	config Config,
+	svcOptions *models.ServiceOptions,
+	ingestion *mqttSvc.Ingestion,
+	monitor *mqttSvc.ConnectionMonitor,
+	broker *mqttSvc.Broker,
+) (mqtt.Client, func()) {
+	host, port, user, pass := config.MqttHost, config.MqttPort, config.MqttUser, config.MqttPass
+	if broker != nil {
+		host, port = broker.ClientAddress()
+		user, pass = broker.ServerCredentials()
+	}
+	client := mqttSvc.MqttClient(
 		config.MqttClient,
-		config.MqttHost,
-		config.MqttPort,
+		host,
+		port,
+		user,
+		pass,
 		svcOptions,
+		ingestion,
+		monitor,
 	)
+	return client, func() {
+		mqttSvc.Disconnect(client, ingestion, config.MqttQuiesce)
+	}
+}
+
+func ProvidePublisher(config Config, mqttClient mqtt.Client, topics *mqttSvc.Topics, svcOptions *models.ServiceOptions) *mqttSvc.Publisher {
+	publisher := mqttSvc.NewPublisher(mqttClient, topics, svcOptions.GatewaySvc)
+	publisher.SetUHFBatch(config.MqttUHFBatch)
+	return publisher
+}
+
+func ProvideTwinReconciler(config Config, publisher *mqttSvc.Publisher, svcOptions *models.ServiceOptions) (*mqttSvc.TwinReconciler, func()) {
+	reconciler := mqttSvc.NewTwinReconciler(publisher, svcOptions,
+		config.TwinReconcileInterval, config.TwinMaxSyncAttempts, config.TwinMaxBackoff)
+	reconciler.Start()
+	return reconciler, reconciler.Stop
+}
+
+func ProvideArtifactSigner(config Config) (*models.ArtifactSigner, error) {
+	if config.FirmwareUrlSecret == "" {
+		logger.LogWithoutFields(logger.UAMSSERVER, logger.WarnLevel,
+			"FIRMWARE_URL_SECRET is not set, artifact URLs sent before a restart stop working after it")
+	}
+	return models.NewArtifactSigner(config.FirmwareUrlSecret, config.FirmwareUrlTTL)
+}
+
+func ProvideRolloutManager(config Config, publisher *mqttSvc.Publisher, svcOptions *models.ServiceOptions, signer *models.ArtifactSigner) (*mqttSvc.RolloutManager, func()) {
+	artifactUrl := config.FirmwareBaseUrl
+	if artifactUrl == "" {
+		artifactUrl = fmt.Sprintf("http://%s:8079", config.ServerHost)
+	}
+	manager := mqttSvc.NewRolloutManager(publisher, svcOptions,
+		config.RolloutInterval, config.RolloutTargetTimeout, artifactUrl, signer)
+	manager.Start()
+	return manager, manager.Stop
 }
 
-func ProvideHandlerOptions(svcOptions *models.ServiceOptions, mqttClient mqtt.Client) *handlers.HandlerOptions {
+func ProvideHandlerOptions(
+	config Config,
+	svcOptions *models.ServiceOptions,
+	publisher *mqttSvc.Publisher,
+	ingestion *mqttSvc.Ingestion,
+	monitor *mqttSvc.ConnectionMonitor,
+	registry *prometheus.Registry,
+	signer *models.ArtifactSigner,
+) *handlers.HandlerOptions {
 	deps := &handlers.HandlerDependencies{
-		SvcOpts:    svcOptions,
-		MqttClient: mqttClient,
+		SvcOpts:   svcOptions,
+		Publisher: publisher,
+		Ingestion: ingestion,
+		Monitor:   monitor,
 	}
 
 	return &handlers.HandlerOptions{
@@ -86,14 +322,38 @@ func ProvideHandlerOptions(svcOptions *models.ServiceOptions, mqttClient mqtt.Cl
 		UserAccessHandler:    handlers.NewUserAccessHandler(deps),
 		OperationLogHandler:  handlers.NewOperationLogHandler(deps),
 		PackageAccessHandler: handlers.NewPackageAccessHandler(deps),
+		FirmwareHandler:      handlers.NewFirmwareHandler(deps, signer),
+		RolloutHandler:       handlers.NewRolloutHandler(deps),
+		UHFConfigHandler:     handlers.NewUHFConfigHandler(deps),
+		IngestionHandler:     handlers.NewIngestionHandler(deps),
+		DeadLetterHandler:    handlers.NewDeadLetterHandler(deps),
+		TagReadErrorHandler:  handlers.NewTagReadErrorHandler(deps),
+		TenantHandler:        handlers.NewTenantHandler(deps, config.AdminApiKeys),
+		SystemHandler:        handlers.NewSystemHandler(deps),
+		ImportHandler:        handlers.NewImportHandler(deps),
+		TrashHandler:         handlers.NewTrashHandler(deps),
+		Metrics:              metrics.Handler(registry),
 	}
 }
 
-func ProvideAppInfrastructure(config Config, db *gorm.DB, mqttClient mqtt.Client, handlerOpts *handlers.HandlerOptions) *ContextContainer {
+func ProvideAppInfrastructure(
+	config Config,
+	tracingProvider *tracing.Provider,
+	db *gorm.DB,
+	mqttClient mqtt.Client,
+	ingestion *mqttSvc.Ingestion,
+	handlerOpts *handlers.HandlerOptions,
+	twinReconciler *mqttSvc.TwinReconciler,
+	rolloutManager *mqttSvc.RolloutManager,
+) *ContextContainer {
 	return &ContextContainer{
 		Config:         config,
 		Db:             db,
 		MqttClient:     mqttClient,
+		Ingestion:      ingestion,
 		HandlerOptions: handlerOpts,
+		TwinReconciler: twinReconciler,
+		RolloutManager: rolloutManager,
+		Tracing:        tracingProvider,
 	}
 }
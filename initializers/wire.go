@@ -9,9 +9,22 @@ import (
 
 var ApplicationSet = wire.NewSet(
 	ProvideConfig,
+	ProvideTracing,
 	ProvideGormDb,
 	ProvideSvcOptions,
+	ProvideReadDebouncer,
+	ProvideTagDecoder,
+	ProvideSchemaValidator,
+	ProvideTopics,
+	ProvideIngestion,
+	ProvideMetrics,
+	ProvideConnectionMonitor,
+	ProvideBroker,
 	ProvideMqttClient,
+	ProvidePublisher,
+	ProvideTwinReconciler,
+	ProvideArtifactSigner,
+	ProvideRolloutManager,
 	ProvideHandlerOptions,
 	ProvideAppInfrastructure,
 )
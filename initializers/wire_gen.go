@@ -17,15 +17,80 @@ func InitApplication(envFilePath string) (*ContextContainer, func(), error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	db, err := ProvideGormDb(config)
+	provider, cleanup, err := ProvideTracing(config)
 	if err != nil {
 		return nil, nil, err
 	}
-	serviceOptions := ProvideSvcOptions(db)
-	client := ProvideMqttClient(config, serviceOptions)
-	handlerOptions := ProvideHandlerOptions(serviceOptions, client)
-	contextContainer := ProvideAppInfrastructure(config, db, client, handlerOptions)
+	db, cleanup2, err := ProvideGormDb(config)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	serviceOptions := ProvideSvcOptions(config, db)
+	readDebouncer := ProvideReadDebouncer(config)
+	tagDecoder, err := ProvideTagDecoder(config)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	schemaValidator, err := ProvideSchemaValidator(config)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	topics, err := ProvideTopics(config)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	ingestion, cleanup3, err := ProvideIngestion(config, topics, serviceOptions, readDebouncer, tagDecoder, schemaValidator)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	registry, err := ProvideMetrics(serviceOptions, ingestion)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	connectionMonitor := ProvideConnectionMonitor()
+	broker, cleanup4, err := ProvideBroker(config, topics, serviceOptions)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	client, cleanup5 := ProvideMqttClient(config, serviceOptions, ingestion, connectionMonitor, broker)
+	publisher := ProvidePublisher(config, client, topics, serviceOptions)
+	twinReconciler, cleanup6 := ProvideTwinReconciler(config, publisher, serviceOptions)
+	artifactSigner, err := ProvideArtifactSigner(config)
+	if err != nil {
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	rolloutManager, cleanup7 := ProvideRolloutManager(config, publisher, serviceOptions, artifactSigner)
+	handlerOptions := ProvideHandlerOptions(config, serviceOptions, publisher, ingestion, connectionMonitor, registry, artifactSigner)
+	contextContainer := ProvideAppInfrastructure(config, provider, db, client, ingestion, handlerOptions, twinReconciler, rolloutManager)
 	return contextContainer, func() {
+		cleanup7()
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
 	}, nil
 }
 
@@ -33,9 +98,22 @@ func InitApplication(envFilePath string) (*ContextContainer, func(), error) {
 
 var ApplicationSet = wire.NewSet(
 	ProvideConfig,
+	ProvideTracing,
 	ProvideGormDb,
 	ProvideSvcOptions,
+	ProvideReadDebouncer,
+	ProvideTagDecoder,
+	ProvideSchemaValidator,
+	ProvideTopics,
+	ProvideIngestion,
+	ProvideMetrics,
+	ProvideConnectionMonitor,
+	ProvideBroker,
 	ProvideMqttClient,
+	ProvidePublisher,
+	ProvideTwinReconciler,
+	ProvideArtifactSigner,
+	ProvideRolloutManager,
 	ProvideHandlerOptions,
 	ProvideAppInfrastructure,
 )
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Components whose level can be set apart from the default level
+var Components = []ServerComponent{MQTT, SQLSERVER, UAMSSERVER, GINROUTER}
+
+// LogLevels is the default level and levels of components that differ from it
+type LogLevels struct {
+	Level      string            `json:"level" example:"info"`
+	Components map[string]string `json:"components"`
+}
+
+// levels filters entries per component, logrus itself logs every level
+type levels struct {
+	mu         sync.RWMutex
+	level      log.Level
+	components map[ServerComponent]log.Level
+}
+
+var componentLevels = &levels{
+	level:      log.DebugLevel,
+	components: map[ServerComponent]log.Level{},
+}
+
+func (l *levels) enabled(comp ServerComponent, level log.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if compLevel, ok := l.components[comp]; ok {
+		return level <= compLevel
+	}
+	return level <= l.level
+}
+
+// Enabled reports whether entries of comp at level are written
+func Enabled(comp ServerComponent, level log.Level) bool {
+	return componentLevels.enabled(comp, level)
+}
+
+// SetLevels replaces default level when set and levels of given components,
+// a component set to "" falls back to default level
+func SetLevels(lv LogLevels) error {
+	var level log.Level
+	var err error
+	if lv.Level != "" {
+		if level, err = log.ParseLevel(lv.Level); err != nil {
+			return err
+		}
+	}
+	comps := map[ServerComponent]*log.Level{}
+	for name, levelName := range lv.Components {
+		comp, err := ParseComponent(name)
+		if err != nil {
+			return err
+		}
+		if levelName == "" {
+			comps[comp] = nil
+			continue
+		}
+		compLevel, err := log.ParseLevel(levelName)
+		if err != nil {
+			return err
+		}
+		comps[comp] = &compLevel
+	}
+
+	componentLevels.mu.Lock()
+	defer componentLevels.mu.Unlock()
+	if lv.Level != "" {
+		componentLevels.level = level
+	}
+	for comp, compLevel := range comps {
+		if compLevel == nil {
+			delete(componentLevels.components, comp)
+			continue
+		}
+		componentLevels.components[comp] = *compLevel
+	}
+	return nil
+}
+
+func Levels() LogLevels {
+	componentLevels.mu.RLock()
+	defer componentLevels.mu.RUnlock()
+	lv := LogLevels{
+		Level:      componentLevels.level.String(),
+		Components: make(map[string]string, len(componentLevels.components)),
+	}
+	for comp, level := range componentLevels.components {
+		lv.Components[string(comp)] = level.String()
+	}
+	return lv
+}
+
+func ParseComponent(name string) (ServerComponent, error) {
+	for _, comp := range Components {
+		if strings.EqualFold(string(comp), name) {
+			return comp, nil
+		}
+	}
+	names := make([]string, 0, len(Components))
+	for _, comp := range Components {
+		names = append(names, string(comp))
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown log component %s, wanted one of %s", name, strings.Join(names, ", "))
+}
+
+// ParseComponentLevels parses comma separated COMPONENT=level pairs
+func ParseComponentLevels(s string) (map[string]string, error) {
+	comps := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid log level %q, wanted COMPONENT=level", pair)
+		}
+		comps[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return comps, nil
+}
@@ -0,0 +1,48 @@
+//go:build unit
+// +build unit
+
+package logger
+
+import (
+	"testing"
+)
+
+func TestComponentLevels(t *testing.T) {
+	defer SetLevels(LogLevels{Level: "debug", Components: map[string]string{"MQTT": "", "gin_router": ""}})
+
+	if err := SetLevels(LogLevels{Level: "info", Components: map[string]string{"MQTT": "debug", "gin_router": "warn"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !Enabled(MQTT, DebugLevel) || Enabled(UAMSSERVER, DebugLevel) || !Enabled(UAMSSERVER, InfoLevel) {
+		t.Errorf("got MQTT debug %v, UAMS_SERVER debug %v info %v, wanted true false true",
+			Enabled(MQTT, DebugLevel), Enabled(UAMSSERVER, DebugLevel), Enabled(UAMSSERVER, InfoLevel))
+	}
+	if Enabled(GINROUTER, InfoLevel) || !Enabled(GINROUTER, WarnLevel) {
+		t.Errorf("got GIN_ROUTER below warn enabled")
+	}
+
+	// Empty level returns component to default level
+	if err := SetLevels(LogLevels{Components: map[string]string{"MQTT": ""}}); err != nil {
+		t.Fatal(err)
+	}
+	if lv := Levels(); lv.Level != "info" || lv.Components["MQTT"] != "" || lv.Components["GIN_ROUTER"] != "warning" {
+		t.Errorf("got %+v, wanted info with GIN_ROUTER warn", lv)
+	}
+
+	if err := SetLevels(LogLevels{Components: map[string]string{"BROKER": "debug"}}); err == nil {
+		t.Errorf("got no error for unknown component")
+	}
+	if err := SetLevels(LogLevels{Level: "loud"}); err == nil {
+		t.Errorf("got no error for unknown level")
+	}
+}
+
+func TestParseComponentLevels(t *testing.T) {
+	comps, err := ParseComponentLevels(" MQTT=info, GIN_ROUTER=warn ,")
+	if err != nil || comps["MQTT"] != "info" || comps["GIN_ROUTER"] != "warn" || len(comps) != 2 {
+		t.Errorf("got %v %v, wanted MQTT info and GIN_ROUTER warn", comps, err)
+	}
+	if _, err := ParseComponentLevels("MQTT"); err == nil {
+		t.Errorf("got no error for pair without level")
+	}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math"
@@ -8,13 +9,18 @@ import (
 	"os"
 	"time"
 
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 const (
 	LOGGER_TIME_FORMAT string = "2006-01-02 15:04:05.999999999 -07:00"
 
+	LOG_FORMAT_TEXT string = "text"
+	LOG_FORMAT_JSON string = "json"
+
 	// Logger LEVEL reference from logrus.Level
 	PanicLevel = log.PanicLevel
 	FatalLevel = log.FatalLevel
@@ -48,17 +54,68 @@ type (
 	LoggerFields    log.Fields
 )
 
-func InitLogger(logFilePath string) {
-	logger.SetFormatter(&LogFormatter{
-		TimestampFormat: LOGGER_TIME_FORMAT,
-		WithCallerField: true,
-	})
-	logFile, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
-	if err != nil {
-		Fatal("Cannot open ", logFilePath)
+type LoggerOptions struct {
+	FilePath   string
+	Format     string // text or json
+	Level      string // default level of components
+	Components map[string]string
+
+	// Log file is rotated once it reaches MaxSizeMB or every RotateInterval,
+	// rotated files are removed after MaxAgeDays or beyond MaxBackups, 0 keeps them
+	MaxSizeMB      int
+	RotateInterval time.Duration
+	MaxAgeDays     int
+	MaxBackups     int
+	Compress       bool
+}
+
+func InitLogger(opts LoggerOptions) error {
+	switch opts.Format {
+	case "", LOG_FORMAT_TEXT:
+		logger.SetFormatter(&LogFormatter{
+			TimestampFormat: LOGGER_TIME_FORMAT,
+			WithCallerField: true,
+		})
+	case LOG_FORMAT_JSON:
+		logger.SetFormatter(&log.JSONFormatter{
+			TimestampFormat: LOGGER_TIME_FORMAT,
+		})
+	default:
+		return fmt.Errorf("unknown log format %s, wanted %s or %s", opts.Format, LOG_FORMAT_TEXT, LOG_FORMAT_JSON)
+	}
+	if err := SetLevels(LogLevels{Level: opts.Level, Components: opts.Components}); err != nil {
+		return err
+	}
+	// Entries are filtered per component before they reach logrus
+	logger.SetLevel(log.TraceLevel)
+
+	if opts.FilePath == "" {
+		logger.SetOutput(os.Stdout)
+		return nil
+	}
+	logFile := &lumberjack.Logger{
+		Filename:   opts.FilePath,
+		MaxSize:    opts.MaxSizeMB,
+		MaxAge:     opts.MaxAgeDays,
+		MaxBackups: opts.MaxBackups,
+		Compress:   opts.Compress,
+		LocalTime:  true,
 	}
-	logger.SetLevel(log.DebugLevel)
 	logger.SetOutput(io.MultiWriter(os.Stdout, logFile))
+	if opts.RotateInterval > 0 {
+		go rotateEvery(logFile, opts.RotateInterval)
+	}
+	return nil
+}
+
+func rotateEvery(logFile *lumberjack.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := logFile.Rotate(); err != nil {
+			LogfWithoutFields(UAMSSERVER, ErrorLevel, "Rotate log file failed, err %s", err.Error())
+		}
+	}
 }
 
 func createFieldsWithComponent(comp ServerComponent, fields LoggerFields) (logFields map[string]interface{}) {
@@ -76,6 +133,9 @@ func createFieldsWithComponent(comp ServerComponent, fields LoggerFields) (logFi
 }
 
 func (n *NOOPLogger) Println(v ...interface{}) {
+	if !Enabled(MQTT, n.level) {
+		return
+	}
 	entry := &loggerEntry{
 		entry: logger.WithFields(createFieldsWithComponent(MQTT, LoggerFields{
 			"MqttLevel": n.prefix,
@@ -85,6 +145,9 @@ func (n *NOOPLogger) Println(v ...interface{}) {
 }
 
 func (n *NOOPLogger) Printf(format string, v ...interface{}) {
+	if !Enabled(MQTT, n.level) {
+		return
+	}
 	entry := &loggerEntry{
 		entry: logger.WithFields(createFieldsWithComponent(MQTT, LoggerFields{
 			"MqttLevel": n.prefix,
@@ -139,18 +202,30 @@ func Fatal(args ...interface{}) {
 }
 
 func Debug(args ...interface{}) {
+	if !Enabled("", DebugLevel) {
+		return
+	}
 	logger.Debugln(args...)
 }
 
 func Info(args ...interface{}) {
+	if !Enabled("", InfoLevel) {
+		return
+	}
 	logger.Infoln(args...)
 }
 
 func Error(args ...interface{}) {
+	if !Enabled("", ErrorLevel) {
+		return
+	}
 	logger.Errorln(args...)
 }
 
 func Warn(args ...interface{}) {
+	if !Enabled("", WarnLevel) {
+		return
+	}
 	logger.Warnln(args...)
 }
 
@@ -159,6 +234,9 @@ func Panic(args ...interface{}) {
 }
 
 func Trace(args ...interface{}) {
+	if !Enabled("", log.TraceLevel) {
+		return
+	}
 	logger.Traceln(args...)
 }
 
@@ -208,24 +286,35 @@ func GinLogger(notLogged ...string) gin.HandlerFunc {
 			"dataLength": dataLength,
 			"userAgent":  userAgent,
 		})
-		entry := logger.WithFields(ginLogFields)
+		for k, v := range tracing.Fields(c.Request.Context()) {
+			ginLogFields[k] = v
+		}
+		entry := &loggerEntry{
+			entry: logger.WithFields(ginLogFields),
+		}
 
 		if len(c.Errors) > 0 {
-			entry.Error(c.Errors.ByType(gin.ErrorTypePrivate).String())
-		} else {
-			msg := fmt.Sprintf("%s - %s \"%s %s\" %d %d \"%s\" \"%s\" (%dms)", clientIP, hostname, c.Request.Method, path, statusCode, dataLength, referer, userAgent, latency)
-			if statusCode >= http.StatusInternalServerError {
-				entry.Error(msg)
-			} else if statusCode >= http.StatusBadRequest {
-				entry.Warn(msg)
-			} else {
-				entry.Info(msg)
+			if Enabled(GINROUTER, ErrorLevel) {
+				entry.log(ErrorLevel, c.Errors.ByType(gin.ErrorTypePrivate).String())
 			}
+			return
+		}
+		level := InfoLevel
+		if statusCode >= http.StatusInternalServerError {
+			level = ErrorLevel
+		} else if statusCode >= http.StatusBadRequest {
+			level = WarnLevel
+		}
+		if Enabled(GINROUTER, level) {
+			entry.log(level, fmt.Sprintf("%s - %s \"%s %s\" %d %d \"%s\" \"%s\" (%dms)", clientIP, hostname, c.Request.Method, path, statusCode, dataLength, referer, userAgent, latency))
 		}
 	}
 }
 
 func LogWithFields(comp ServerComponent, level log.Level, fields LoggerFields, message ...interface{}) {
+	if !Enabled(comp, level) {
+		return
+	}
 	logFields := createFieldsWithComponent(comp, fields)
 	entry := &loggerEntry{
 		entry: logger.WithFields(logFields),
@@ -234,6 +323,9 @@ func LogWithFields(comp ServerComponent, level log.Level, fields LoggerFields, m
 }
 
 func LogfWithFields(comp ServerComponent, level log.Level, fields LoggerFields, messageFormat string, args ...interface{}) {
+	if !Enabled(comp, level) {
+		return
+	}
 	logFields := createFieldsWithComponent(comp, fields)
 	entry := &loggerEntry{
 		entry: logger.WithFields(logFields),
@@ -242,6 +334,9 @@ func LogfWithFields(comp ServerComponent, level log.Level, fields LoggerFields,
 }
 
 func LogWithoutFields(comp ServerComponent, level log.Level, message ...interface{}) {
+	if !Enabled(comp, level) {
+		return
+	}
 	entry := &loggerEntry{
 		entry: logger.WithFields(createFieldsWithComponent(comp, nil)),
 	}
@@ -249,8 +344,26 @@ func LogWithoutFields(comp ServerComponent, level log.Level, message ...interfac
 }
 
 func LogfWithoutFields(comp ServerComponent, level log.Level, messageFormat string, args ...interface{}) {
+	if !Enabled(comp, level) {
+		return
+	}
 	entry := &loggerEntry{
 		entry: logger.WithFields(createFieldsWithComponent(comp, nil)),
 	}
 	entry.logf(level, messageFormat, args...)
 }
+
+// LogfWithContext adds correlation and trace IDs of ctx to fields
+func LogfWithContext(ctx context.Context, comp ServerComponent, level log.Level, fields LoggerFields, messageFormat string, args ...interface{}) {
+	if !Enabled(comp, level) {
+		return
+	}
+	logFields := createFieldsWithComponent(comp, fields)
+	for k, v := range tracing.Fields(ctx) {
+		logFields[k] = v
+	}
+	entry := &loggerEntry{
+		entry: logger.WithFields(logFields),
+	}
+	entry.logf(level, messageFormat, args...)
+}
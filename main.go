@@ -2,10 +2,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
 	_ "github.com/ecoprohcm/DMS_BackendServer/docs"
 	"github.com/ecoprohcm/DMS_BackendServer/handlers"
-	"os"
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
 
 	"github.com/ecoprohcm/DMS_BackendServer/initializers"
 	"github.com/gin-gonic/gin"                 // swagger embed files
@@ -22,16 +29,40 @@ import (
 // @BasePath  /v1
 
 func main() {
-	cc, _, err := initializers.InitApplication("./.env")
+	cc, cleanup, err := initializers.InitApplication("./.env")
 	if err != nil {
 		fmt.Printf("failed to create event: %s\n", err)
 		os.Exit(2)
 	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// HTTP Serve
 	r := handlers.SetupRouter(cc.HandlerOptions)
 	initSwagger(r)
-	r.Run(":8079")
-	cc.MqttClient.Disconnect(250)
+	srv := &http.Server{
+		Addr:    ":8079",
+		Handler: r,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.LogfWithoutFields(logger.UAMSSERVER, logger.ErrorLevel, "HTTP server failed, err %s", err.Error())
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	logger.LogWithoutFields(logger.UAMSSERVER, logger.InfoLevel, "Shutting down")
+
+	// Requests in flight finish before MQTT, ingestion and database they use go away
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cc.Config.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.LogfWithoutFields(logger.UAMSSERVER, logger.ErrorLevel, "HTTP server shutdown failed, err %s", err.Error())
+	}
+	cleanup()
+	logger.LogWithoutFields(logger.UAMSSERVER, logger.InfoLevel, "Stopped")
 }
 
 func initSwagger(r *gin.Engine) {
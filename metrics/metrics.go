@@ -0,0 +1,92 @@
+// Package metrics holds Prometheus collectors of the server, exposed on /metrics
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const NAMESPACE string = "uams"
+
+// Registry only holds collectors of this package and those passed to Register
+var Registry = prometheus.NewRegistry()
+
+var (
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	MqttMessagesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "messages_received_total",
+		Help:      "Gateway messages received by topic without site and gateway.",
+	}, []string{"topic"})
+
+	MqttMessagesDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "messages_dropped_total",
+		Help:      "Gateway messages dropped on a full or stopped ingestion queue by topic.",
+	}, []string{"topic"})
+
+	MqttHandlerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "handler_errors_total",
+		Help:      "Gateway messages rejected or failed by their subscriber by topic and reason.",
+	}, []string{"topic", "reason"})
+
+	MqttPublishFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "publish_failures_total",
+		Help:      "MQTT tokens completed with an error.",
+	})
+
+	DbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of database statements by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HttpRequestDuration,
+		MqttMessagesReceived,
+		MqttMessagesDropped,
+		MqttHandlerErrors,
+		MqttPublishFailures,
+		DbQueryDuration,
+	)
+}
+
+// Register adds collectors of other packages, a collector registered
+// before is kept so the application may be initialized again in tests
+func Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := Registry.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func Handler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"context"
+	"errors"
+	"strconv"
 
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"gorm.io/gorm"
@@ -9,9 +11,48 @@ import (
 
 type Area struct {
 	GormModel
-	Name    string `gorm:"unique;not null" json:"name"`
+	TenantModel
+	SoftDeleteModel
+	Revision
+	Name    string `gorm:"type:nvarchar(256);not null" json:"name"` // unique in its organization, see tenantUniqueNames
 	Manager string `gorm:"not null" json:"manager"`
 }
+
+// What happens to gateways and UHFs of a deleted area
+const (
+	AREA_DEVICES_RESTRICT string = "restrict" // area can't be deleted while it has devices
+	AREA_DEVICES_REASSIGN string = "reassign" // devices move to another area
+	AREA_DEVICES_UNASSIGN string = "unassign" // devices are left without area, UHFs are deactivated
+)
+
+// Struct defines HTTP request payload for deleting area
+type DeleteArea struct {
+	ID      uint   `json:"id"`
+	Devices string `json:"devices"` // restrict when empty
+	AreaID  string `json:"area_id"` // area devices move to with reassign
+}
+
+// Validate checks rule of d for devices of the area
+func (d *DeleteArea) Validate() error {
+	switch d.Devices {
+	case "", AREA_DEVICES_RESTRICT, AREA_DEVICES_UNASSIGN:
+	case AREA_DEVICES_REASSIGN:
+		if d.AreaID == "" {
+			return NewValidationError("area_id", "area_id is required to reassign devices")
+		}
+		if _, err := strconv.ParseUint(d.AreaID, 10, 0); err != nil {
+			return NewValidationError("area_id", "area_id must be an area id")
+		}
+		if d.AreaID == strconv.FormatUint(uint64(d.ID), 10) {
+			return NewValidationError("area_id", "area_id must be another area")
+		}
+	default:
+		return NewValidationError("devices", "devices must be %s, %s or %s",
+			AREA_DEVICES_RESTRICT, AREA_DEVICES_REASSIGN, AREA_DEVICES_UNASSIGN)
+	}
+	return nil
+}
+
 type AreaSvc struct {
 	db *gorm.DB
 }
@@ -23,7 +64,7 @@ func NewAreaSvc(db *gorm.DB) *AreaSvc {
 }
 
 func (as *AreaSvc) FindAllArea(ctx context.Context) (aList []Area, err error) {
-	result := as.db.Find(&aList)
+	result := as.db.WithContext(ctx).Find(&aList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -32,7 +73,7 @@ func (as *AreaSvc) FindAllArea(ctx context.Context) (aList []Area, err error) {
 }
 
 func (as *AreaSvc) FindAreaByID(ctx context.Context, id string) (a *Area, err error) {
-	result := as.db.First(&a, id)
+	result := as.db.WithContext(ctx).First(&a, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -41,19 +82,81 @@ func (as *AreaSvc) FindAreaByID(ctx context.Context, id string) (a *Area, err er
 }
 
 func (as *AreaSvc) CreateArea(a *Area, ctx context.Context) (*Area, error) {
-	if err := as.db.Create(&a).Error; err != nil {
+	if err := as.db.WithContext(ctx).Create(&a).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return a, nil
 }
 
+// UpdateArea updates area a.ID when it is still at a.Revision, any revision when 0
 func (as *AreaSvc) UpdateArea(ctx context.Context, a *Area) (bool, error) {
-	result := as.db.Model(&a).Where("id = ?", a.ID).Updates(a)
-	return utils.ReturnBoolStateFromResult(result)
+	db := as.db.WithContext(ctx)
+	result := whereRevision(db.Model(&a).Where("id = ?", a.ID), a.Revision.Revision).Updates(a)
+	return revisionResult(db, &Area{}, result, a.Revision.Revision, "id = ?", a.ID)
 }
 
-func (as *AreaSvc) DeleteArea(ctx context.Context, areaId uint) (bool, error) {
-	result := as.db.Unscoped().Where("id = ?", areaId).Delete(&Area{})
-	return utils.ReturnBoolStateFromResult(result)
+// DeleteArea moves area d.ID to trash when it is still at revision, any
+// revision when 0. Gateways and UHFs of the area follow d.Devices, trashed
+// ones as well. UHFs unassigned are returned so gateways can be told.
+func (as *AreaSvc) DeleteArea(ctx context.Context, d *DeleteArea, revision uint) ([]UHF, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	areaId := strconv.FormatUint(uint64(d.ID), 10)
+	unassigned := []UHF{}
+	err := as.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := whereRevision(tx.Where("id = ?", d.ID), revision).Delete(&Area{})
+		if _, err := revisionResult(tx, &Area{}, result, revision, "id = ?", d.ID); err != nil {
+			return err
+		}
+		switch d.Devices {
+		case AREA_DEVICES_REASSIGN:
+			err := tx.Where("id = ?", d.AreaID).First(&Area{}).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return NewValidationError("area_id", "area %s does not exist", d.AreaID)
+			} else if err != nil {
+				return err
+			}
+			return moveAreaDevices(tx, areaId, map[string]interface{}{"area_id": d.AreaID}, map[string]interface{}{"area_id": d.AreaID})
+		case AREA_DEVICES_UNASSIGN:
+			if err := tx.Where("area_id = ?", areaId).Find(&unassigned).Error; err != nil {
+				return err
+			}
+			return moveAreaDevices(tx, areaId, map[string]interface{}{"area_id": ""}, map[string]interface{}{
+				"area_id":       "",
+				"desired_state": "inactive",
+				"sync_attempts": 0,
+			})
+		}
+		var gwCnt, uhfCnt int64
+		if err := tx.Model(&Gateway{}).Where("area_id = ?", areaId).Count(&gwCnt).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&UHF{}).Where("area_id = ?", areaId).Count(&uhfCnt).Error; err != nil {
+			return err
+		}
+		if gwCnt > 0 || uhfCnt > 0 {
+			return NewConflictError("area %d still has %d gateways and %d UHFs, reassign or unassign them", d.ID, gwCnt, uhfCnt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	for i := range unassigned {
+		unassigned[i].AreaId = ""
+		unassigned[i].DesiredState = "inactive"
+		unassigned[i].SyncAttempts = 0
+	}
+	return unassigned, nil
+}
+
+// moveAreaDevices updates gateways and UHFs of area areaId, trashed ones too
+// so they don't come back in a deleted area
+func moveAreaDevices(tx *gorm.DB, areaId string, gateways map[string]interface{}, uhfs map[string]interface{}) error {
+	if err := tx.Unscoped().Model(&Gateway{}).Where("area_id = ?", areaId).Updates(gateways).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&UHF{}).Where("area_id = ?", areaId).Updates(uhfs).Error
 }
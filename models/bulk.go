@@ -0,0 +1,161 @@
+package models
+
+import "gorm.io/gorm"
+
+// Actions of bulk requests
+const (
+	BULK_ACTIVATE      string = "activate"
+	BULK_DEACTIVATE    string = "deactivate"
+	BULK_REASSIGN_AREA string = "reassign_area"
+	BULK_DELETE        string = "delete"
+)
+
+// Outcome of a device in a bulk request
+const (
+	BULK_SUCCEEDED string = "succeeded"
+	BULK_FAILED    string = "failed"
+)
+
+// BulkSelector picks devices of a bulk request, every field set must match.
+// Family selects UHFs only, Version is version of a UHF or software version
+// of a gateway.
+type BulkSelector struct {
+	AreaID    string `json:"area_id"`
+	GatewayID string `json:"gateway_id"`
+	Family    string `json:"family"`
+	Version   string `json:"version"`
+}
+
+// Struct defines HTTP request payload for bulk operations on UHFs or gateways
+type BulkRequest struct {
+	Selector BulkSelector `json:"selector"`
+	Action   string       `json:"action" binding:"required"`
+	AreaID   string       `json:"area_id"` // area devices move to with reassign_area
+}
+
+// BulkItemResult is the outcome of a single device. PublishError is set when
+// the change is stored but its MQTT message could not be sent.
+type BulkItemResult struct {
+	ID           uint   `json:"id"`
+	GatewayID    string `json:"gateway_id"`
+	UHFAddress   string `json:"uhf_address,omitempty"`
+	Status       string `json:"status"`
+	Code         string `json:"code,omitempty"`
+	Error        string `json:"error,omitempty"`
+	PublishError string `json:"publish_error,omitempty"`
+}
+
+type BulkResult struct {
+	Action    string           `json:"action"`
+	Matched   int              `json:"matched"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// Validate checks action and selector of req, gateways tells whether it
+// targets gateways instead of UHFs
+func (req *BulkRequest) Validate(gateways bool) error {
+	switch req.Action {
+	case BULK_ACTIVATE, BULK_DEACTIVATE, BULK_DELETE:
+	case BULK_REASSIGN_AREA:
+		if req.AreaID == "" {
+			return NewValidationError("area_id", "area_id is required to reassign area")
+		}
+	default:
+		return NewValidationError("action", "action must be %s, %s, %s or %s",
+			BULK_ACTIVATE, BULK_DEACTIVATE, BULK_REASSIGN_AREA, BULK_DELETE)
+	}
+	s := req.Selector
+	if s.AreaID == "" && s.GatewayID == "" && s.Family == "" && s.Version == "" {
+		return NewValidationError("selector", "selector must set area_id, gateway_id, family or version")
+	}
+	if gateways && s.Family != "" {
+		return NewValidationError("selector.family", "family selects UHFs only")
+	}
+	return nil
+}
+
+// State devices are asked for by activate and deactivate
+func (req *BulkRequest) DesiredState() string {
+	if req.Action == BULK_ACTIVATE {
+		return "active"
+	}
+	return "inactive"
+}
+
+func (s BulkSelector) MatchUHF(uhf *UHF) bool {
+	return (s.AreaID == "" || s.AreaID == uhf.AreaId) &&
+		(s.GatewayID == "" || s.GatewayID == uhf.GatewayID) &&
+		(s.Family == "" || s.Family == uhf.Family) &&
+		(s.Version == "" || s.Version == uhf.Version)
+}
+
+func (s BulkSelector) MatchGateway(gw *Gateway) bool {
+	return (s.AreaID == "" || s.AreaID == gw.AreaID) &&
+		(s.GatewayID == "" || s.GatewayID == gw.GatewayID) &&
+		(s.Version == "" || s.Version == gw.SoftwareVersion)
+}
+
+// UHFScope narrows a UHF query to devices MatchUHF accepts
+func (s BulkSelector) UHFScope(db *gorm.DB) *gorm.DB {
+	if s.AreaID != "" {
+		db = db.Where("area_id = ?", s.AreaID)
+	}
+	if s.GatewayID != "" {
+		db = db.Where("gateway_id = ?", s.GatewayID)
+	}
+	if s.Family != "" {
+		db = db.Where("family = ?", s.Family)
+	}
+	if s.Version != "" {
+		db = db.Where("version = ?", s.Version)
+	}
+	return db
+}
+
+// GatewayScope narrows a gateway query to gateways MatchGateway accepts
+func (s BulkSelector) GatewayScope(db *gorm.DB) *gorm.DB {
+	if s.AreaID != "" {
+		db = db.Where("area_id = ?", s.AreaID)
+	}
+	if s.GatewayID != "" {
+		db = db.Where("gateway_id = ?", s.GatewayID)
+	}
+	if s.Version != "" {
+		db = db.Where("software_version = ?", s.Version)
+	}
+	return db
+}
+
+func NewBulkResult(action string) *BulkResult {
+	return &BulkResult{Action: action, Results: []BulkItemResult{}}
+}
+
+// Add records outcome of a device, err fails it with code of its domain error
+func (r *BulkResult) Add(item BulkItemResult, err error) {
+	r.Matched++
+	if err == nil {
+		item.Status = BULK_SUCCEEDED
+		r.Succeeded++
+	} else {
+		item.Status = BULK_FAILED
+		item.Code = ERR_CODE_INTERNAL
+		if de := AsDomainError(err); de != nil {
+			item.Code = de.Code
+		}
+		item.Error = err.Error()
+		r.Failed++
+	}
+	r.Results = append(r.Results, item)
+}
+
+// PublishFailed marks devices of gateway gwId that succeeded with err of
+// publishing their change
+func (r *BulkResult) PublishFailed(gwId string, err error) {
+	for i := range r.Results {
+		if r.Results[i].GatewayID == gwId && r.Results[i].Status == BULK_SUCCEEDED {
+			r.Results[i].PublishError = err.Error()
+		}
+	}
+}
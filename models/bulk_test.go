@@ -0,0 +1,64 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestBulkRequestValidate(t *testing.T) {
+	tests := []struct {
+		req      BulkRequest
+		gateways bool
+		field    string
+	}{
+		{BulkRequest{Selector: BulkSelector{AreaID: "1"}, Action: BULK_ACTIVATE}, false, ""},
+		{BulkRequest{Selector: BulkSelector{Family: "r2000"}, Action: BULK_REASSIGN_AREA, AreaID: "2"}, false, ""},
+		{BulkRequest{Selector: BulkSelector{Family: "r2000"}, Action: BULK_DELETE}, true, "selector.family"},
+		{BulkRequest{Selector: BulkSelector{Version: "1.0.0"}, Action: BULK_REASSIGN_AREA}, true, "area_id"},
+		{BulkRequest{Action: BULK_DELETE}, false, "selector"},
+		{BulkRequest{Selector: BulkSelector{AreaID: "1"}, Action: "reboot"}, false, "action"},
+	}
+	for _, tt := range tests {
+		err := tt.req.Validate(tt.gateways)
+		de := AsDomainError(err)
+		switch {
+		case tt.field == "" && err != nil:
+			t.Errorf("%+v: got %v, wanted valid", tt.req, err)
+		case tt.field != "" && (de == nil || len(de.Fields) != 1 || de.Fields[0].Field != tt.field):
+			t.Errorf("%+v: got %v, wanted invalid %s", tt.req, err, tt.field)
+		}
+	}
+}
+
+func TestBulkSelectorMatch(t *testing.T) {
+	uhf := &UHF{AreaId: "1", GatewayID: "gw01", Family: "r2000", Version: "2.1"}
+	if !(BulkSelector{AreaID: "1", Family: "r2000"}).MatchUHF(uhf) {
+		t.Errorf("got UHF not matched by area and family")
+	}
+	if (BulkSelector{AreaID: "1", Version: "2.0"}).MatchUHF(uhf) {
+		t.Errorf("got UHF matched by another version")
+	}
+	gw := &Gateway{AreaID: "1", GatewayID: "gw01", SoftwareVersion: "1.0.0"}
+	if !(BulkSelector{GatewayID: "gw01", Version: "1.0.0"}).MatchGateway(gw) {
+		t.Errorf("got gateway not matched by ID and software version")
+	}
+	if (BulkSelector{AreaID: "2"}).MatchGateway(gw) {
+		t.Errorf("got gateway matched by another area")
+	}
+}
+
+func TestBulkSelectorScope(t *testing.T) {
+	db := newDryRunDb(t).WithContext(WithTenant(context.Background(), 1))
+	sql := db.Scopes(BulkSelector{AreaID: "1", Family: "r2000"}.UHFScope).Find(&[]UHF{}).Statement.SQL.String()
+	if !strings.Contains(sql, "area_id = @p") || !strings.Contains(sql, "family = @p") || strings.Contains(sql, "version") {
+		t.Errorf("got %s, wanted UHFs of area and family", sql)
+	}
+	sql = db.Scopes(BulkSelector{GatewayID: "gw01", Version: "1.0.0"}.GatewayScope).Find(&[]Gateway{}).Statement.SQL.String()
+	if !strings.Contains(sql, "gateway_id = @p") || !strings.Contains(sql, "software_version = @p") || strings.Contains(sql, "area_id") {
+		t.Errorf("got %s, wanted gateways of gateway ID and version", sql)
+	}
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// Reasons a gateway message is rejected
+const (
+	DEAD_LETTER_INVALID_PAYLOAD     string = "invalid_payload"
+	DEAD_LETTER_SCHEMA_VIOLATION    string = "schema_violation"
+	DEAD_LETTER_UNSUPPORTED_VERSION string = "unsupported_version"
+	DEAD_LETTER_INVALID_TOPIC       string = "invalid_topic"
+	DEAD_LETTER_SITE_MISMATCH       string = "site_mismatch"
+	DEAD_LETTER_UNKNOWN_SITE        string = "unknown_site"
+	DEAD_LETTER_UNKNOWN_GATEWAY     string = "unknown_gateway"
+	DEAD_LETTER_TRASHED_GATEWAY     string = "trashed_gateway"
+	DEAD_LETTER_UNKNOWN_UHF         string = "unknown_uhf"
+	DEAD_LETTER_UHF_NO_AREA         string = "uhf_no_area"
+	DEAD_LETTER_HANDLER_PANIC       string = "handler_panic"
+	DEAD_LETTER_HANDLER_ERROR       string = "handler_error"
+)
+
+// DeadLetter keeps a gateway message the server rejected so it can be
+// inspected, replayed after the cause is fixed, or discarded
+type DeadLetter struct {
+	GormModel
+	Topic        string     `gorm:"type:varchar(256);index" json:"topic"`
+	GatewayID    string     `gorm:"type:varchar(256);index" json:"gateway_id"`
+	Site         string     `gorm:"type:varchar(256);index" json:"site"`
+	Payload      string     `gorm:"type:nvarchar(max)" json:"payload"`
+	Reason       string     `gorm:"type:varchar(64);index" json:"reason"`
+	Detail       string     `json:"detail"`
+	ReceivedAt   time.Time  `json:"received_at"`
+	ReplayCount  int        `json:"replay_count"`
+	LastReplayAt *time.Time `json:"last_replay_at"`
+}
+
+// Struct defines HTTP query to filter dead letters
+type DeadLetterFilter struct {
+	Topic     string `form:"topic"`
+	GatewayID string `form:"gateway_id"`
+	Site      string `form:"site"`
+	Reason    string `form:"reason"`
+}
+
+// Struct defines HTTP request payload for replaying dead letters
+type ReplayDeadLetters struct {
+	IDs []uint `json:"ids" binding:"required"`
+}
+
+// Struct defines HTTP response payload of replay result per dead letter
+type DeadLetterResult struct {
+	ID      uint   `json:"id"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+type DeadLetterSvc struct {
+	db *gorm.DB
+}
+
+func NewDeadLetterSvc(db *gorm.DB) *DeadLetterSvc {
+	return &DeadLetterSvc{
+		db: db,
+	}
+}
+
+func (dls *DeadLetterSvc) FindDeadLetters(ctx context.Context, filter DeadLetterFilter) (dlList []DeadLetter, err error) {
+	query := dls.db.WithContext(ctx).Order("received_at DESC")
+	if filter.Topic != "" {
+		query = query.Where("topic = ?", filter.Topic)
+	}
+	if filter.GatewayID != "" {
+		query = query.Where("gateway_id = ?", filter.GatewayID)
+	}
+	if filter.Site != "" {
+		query = query.Where("site = ?", filter.Site)
+	}
+	if filter.Reason != "" {
+		query = query.Where("reason = ?", filter.Reason)
+	}
+	result := query.Find(&dlList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dlList, nil
+}
+
+func (dls *DeadLetterSvc) FindDeadLetterByID(ctx context.Context, id string) (dl *DeadLetter, err error) {
+	result := dls.db.WithContext(ctx).First(&dl, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dl, nil
+}
+
+func (dls *DeadLetterSvc) CreateDeadLetter(ctx context.Context, dl *DeadLetter) (*DeadLetter, error) {
+	if err := dls.db.WithContext(ctx).Create(&dl).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dl, nil
+}
+
+// Record a replay that was rejected again with its new reason
+func (dls *DeadLetterSvc) MarkDeadLetterReplayed(ctx context.Context, id uint, reason string, detail string) (bool, error) {
+	result := dls.db.WithContext(ctx).Model(&DeadLetter{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"reason":         reason,
+		"detail":         detail,
+		"replay_count":   gorm.Expr("COALESCE(replay_count, 0) + 1"),
+		"last_replay_at": time.Now(),
+	})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (dls *DeadLetterSvc) DeleteDeadLetter(ctx context.Context, id uint) (bool, error) {
+	result := dls.db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&DeadLetter{})
+	return utils.ReturnBoolStateFromResult(result)
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// Kinds of domain errors, match them with errors.Is
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("unavailable")
+	// Row changed since client read it
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// Stable error codes of API problem responses
+const (
+	ERR_CODE_NOT_FOUND          string = "not_found"
+	ERR_CODE_CONFLICT           string = "conflict"
+	ERR_CODE_DUPLICATE          string = "duplicate_record"
+	ERR_CODE_REFERENCE          string = "reference_violation"
+	ERR_CODE_VALIDATION         string = "validation_failed"
+	ERR_CODE_INVALID_FIELD      string = "invalid_field"
+	ERR_CODE_UNAVAILABLE        string = "unavailable"
+	ERR_CODE_DB_UNAVAILABLE     string = "database_unavailable"
+	ERR_CODE_BROKER_UNAVAILABLE string = "broker_unavailable"
+	ERR_CODE_INTERNAL           string = "internal_error"
+	ERR_CODE_PRECONDITION       string = "precondition_failed"
+)
+
+// SQL Server error numbers
+const (
+	mssqlUniqueIndex      int32 = 2601
+	mssqlUniqueConstraint int32 = 2627
+	mssqlConstraint       int32 = 547
+)
+
+// DomainError tells handlers how a service call failed, Kind is one of
+// ErrNotFound, ErrConflict, ErrValidation, ErrPreconditionFailed and ErrUnavailable
+type DomainError struct {
+	Kind   error
+	Code   string
+	Msg    string
+	Fields []utils.FieldError
+	Err    error
+}
+
+func (e *DomainError) Error() string {
+	if e.Msg == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Msg
+}
+
+func (e *DomainError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DomainError) Is(target error) bool {
+	return e.Kind == target
+}
+
+func NewNotFoundError(format string, args ...interface{}) *DomainError {
+	return &DomainError{Kind: ErrNotFound, Code: ERR_CODE_NOT_FOUND, Msg: fmt.Sprintf(format, args...)}
+}
+
+func NewConflictError(format string, args ...interface{}) *DomainError {
+	return &DomainError{Kind: ErrConflict, Code: ERR_CODE_CONFLICT, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Validation error of a single field, named as in JSON
+func NewValidationError(field string, format string, args ...interface{}) *DomainError {
+	msg := fmt.Sprintf(format, args...)
+	return &DomainError{
+		Kind: ErrValidation,
+		Code: ERR_CODE_VALIDATION,
+		Msg:  msg,
+		Fields: []utils.FieldError{
+			{Field: field, Code: ERR_CODE_INVALID_FIELD, Message: msg},
+		},
+	}
+}
+
+func NewPreconditionFailedError(format string, args ...interface{}) *DomainError {
+	return &DomainError{Kind: ErrPreconditionFailed, Code: ERR_CODE_PRECONDITION, Msg: fmt.Sprintf(format, args...)}
+}
+
+func NewUnavailableError(code string, err error) *DomainError {
+	return &DomainError{Kind: ErrUnavailable, Code: code, Err: err}
+}
+
+// AsDomainError classifies err, errors of ORM and database driver get their
+// kind from what happened. Nil is returned for unexpected errors.
+func AsDomainError(err error) *DomainError {
+	if err == nil {
+		return nil
+	}
+	var de *DomainError
+	if errors.As(err, &de) {
+		return de
+	}
+	if errors.Is(err, utils.ErrRecordNotFound) || errors.Is(err, utils.ErrNoRecordAffected) ||
+		errors.Is(err, gorm.ErrRecordNotFound) {
+		return &DomainError{Kind: ErrNotFound, Code: ERR_CODE_NOT_FOUND, Err: err}
+	}
+	var sqlErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &sqlErr) {
+		switch sqlErr.SQLErrorNumber() {
+		case mssqlUniqueIndex, mssqlUniqueConstraint:
+			return &DomainError{Kind: ErrConflict, Code: ERR_CODE_DUPLICATE, Err: err}
+		case mssqlConstraint:
+			return &DomainError{Kind: ErrConflict, Code: ERR_CODE_REFERENCE, Err: err}
+		}
+		return nil
+	}
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return NewUnavailableError(ERR_CODE_DB_UNAVAILABLE, err)
+	}
+	return nil
+}
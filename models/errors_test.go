@@ -0,0 +1,85 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+type fakeSqlError struct {
+	number int32
+}
+
+func (e fakeSqlError) Error() string {
+	return fmt.Sprintf("mssql: error %d", e.number)
+}
+
+func (e fakeSqlError) SQLErrorNumber() int32 {
+	return e.number
+}
+
+func TestAsDomainError(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind error
+		code string
+	}{
+		{utils.ErrRecordNotFound, ErrNotFound, ERR_CODE_NOT_FOUND},
+		{utils.ErrNoRecordAffected, ErrNotFound, ERR_CODE_NOT_FOUND},
+		{fmt.Errorf("create: %w", fakeSqlError{2627}), ErrConflict, ERR_CODE_DUPLICATE},
+		{fakeSqlError{2601}, ErrConflict, ERR_CODE_DUPLICATE},
+		{fakeSqlError{547}, ErrConflict, ERR_CODE_REFERENCE},
+		{driver.ErrBadConn, ErrUnavailable, ERR_CODE_DB_UNAVAILABLE},
+		{NewConflictError("rollout is running"), ErrConflict, ERR_CODE_CONFLICT},
+	}
+	for i, c := range cases {
+		de := AsDomainError(c.err)
+		if de == nil {
+			t.Errorf("case %d: got %v, wanted domain error", i, de)
+			continue
+		}
+		if !errors.Is(de, c.kind) || de.Code != c.code {
+			t.Errorf("case %d: got %v %s, wanted %v %s", i, de.Kind, de.Code, c.kind, c.code)
+		}
+	}
+
+	for _, err := range []error{nil, errors.New("boom"), fakeSqlError{8152}} {
+		if de := AsDomainError(err); de != nil {
+			t.Errorf("got %v, wanted %v", de, nil)
+		}
+	}
+}
+
+func TestDomainErrorWrapsCause(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := fmt.Errorf("publish: %w", NewUnavailableError(ERR_CODE_BROKER_UNAVAILABLE, cause))
+
+	if !errors.Is(err, ErrUnavailable) || !errors.Is(err, cause) {
+		t.Errorf("got %v, wanted unavailable wrapping %v", err, cause)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, wanted not %v", err, ErrNotFound)
+	}
+	if err.Error() != "publish: connection refused" {
+		t.Errorf("got %v, wanted %v", err.Error(), "publish: connection refused")
+	}
+}
+
+func TestValidateReportsField(t *testing.T) {
+	config := validReaderConfig()
+	config.Filters[0].Mask = "zz"
+
+	de := AsDomainError(config.Validate())
+	if de == nil || !errors.Is(de, ErrValidation) {
+		t.Fatalf("got %v, wanted validation error", de)
+	}
+	if len(de.Fields) != 1 || de.Fields[0].Field != "filters[0].mask" {
+		t.Errorf("got %v, wanted field %v", de.Fields, "filters[0].mask")
+	}
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+type Firmware struct {
+	GormModel
+	Version      string `gorm:"type:varchar(256);unique;not null" json:"version"`
+	Description  string `json:"description"`
+	FileName     string `json:"file_name"`
+	Size         int64  `json:"size"`
+	Checksum     string `gorm:"type:varchar(64);not null" json:"checksum"` // sha256 hex of artifact
+	ArtifactPath string `json:"-"`
+}
+
+type FirmwareSvc struct {
+	db          *gorm.DB
+	artifactDir string
+}
+
+func NewFirmwareSvc(db *gorm.DB, artifactDir string) *FirmwareSvc {
+	return &FirmwareSvc{
+		db:          db,
+		artifactDir: artifactDir,
+	}
+}
+
+func (fs *FirmwareSvc) FindAllFirmware(ctx context.Context) (fwList []Firmware, err error) {
+	result := fs.db.WithContext(ctx).Find(&fwList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return fwList, nil
+}
+
+func (fs *FirmwareSvc) FindFirmwareByID(ctx context.Context, id string) (fw *Firmware, err error) {
+	result := fs.db.WithContext(ctx).First(&fw, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return fw, nil
+}
+
+// Store artifact under firmware dir, checksum is computed while copying
+func (fs *FirmwareSvc) CreateFirmware(ctx context.Context, fw *Firmware, artifact io.Reader) (*Firmware, error) {
+	dir := filepath.Join(fs.artifactDir, filepath.Base(fw.Version))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, filepath.Base(fw.FileName))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("store firmware artifact failed: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), artifact)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("store firmware artifact failed: %w", err)
+	}
+
+	fw.Size = size
+	fw.Checksum = hex.EncodeToString(hash.Sum(nil))
+	fw.ArtifactPath = path
+	if err := fs.db.WithContext(ctx).Create(&fw).Error; err != nil {
+		os.Remove(path)
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return fw, nil
+}
+
+func (fs *FirmwareSvc) DeleteFirmware(ctx context.Context, id uint) (bool, error) {
+	fw := &Firmware{}
+	if err := fs.db.WithContext(ctx).First(fw, id).Error; err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	// pending and paused campaigns would still send it once started
+	var cnt int64
+	if err := fs.db.WithContext(ctx).Model(&RolloutCampaign{}).
+		Where("firmware_id = ? AND status NOT IN ?", id, []string{ROLLOUT_HALTED, ROLLOUT_COMPLETED}).
+		Count(&cnt).Error; err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	if cnt > 0 {
+		return false, NewConflictError("firmware is used by an unfinished rollout")
+	}
+	result := fs.db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&Firmware{})
+	if result.Error == nil {
+		os.Remove(fw.ArtifactPath)
+	}
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+var (
+	ErrArtifactUrlExpired   = errors.New("artifact URL expired")
+	ErrArtifactUrlSignature = errors.New("artifact URL signature invalid")
+)
+
+// ArtifactSigner signs artifact URLs sent in upgrade commands. Artifacts are
+// downloaded without API key, a signed URL lets only gateways told to upgrade
+// download firmware and only until it expires.
+type ArtifactSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// Empty secret gets a random one, URLs signed before a restart then stop working
+func NewArtifactSigner(secret string, ttl time.Duration) (*ArtifactSigner, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &ArtifactSigner{secret: key, ttl: ttl}, nil
+}
+
+func (s *ArtifactSigner) signature(fwId uint, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%d:%d", fwId, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns query of artifact URL of firmware fwId, valid for ttl from now
+func (s *ArtifactSigner) Sign(fwId uint, now time.Time) url.Values {
+	expires := now.Add(s.ttl).Unix()
+	return url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {s.signature(fwId, expires)},
+	}
+}
+
+// Verify checks query of artifact URL of firmware fwId was signed and has not expired
+func (s *ArtifactSigner) Verify(fwId uint, query url.Values, now time.Time) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrArtifactUrlSignature
+	}
+	expected := s.signature(fwId, expires)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(query.Get("signature"))) != 1 {
+		return ErrArtifactUrlSignature
+	}
+	if now.Unix() > expires {
+		return ErrArtifactUrlExpired
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestArtifactSigner(t *testing.T) {
+	s, _ := NewArtifactSigner("secret", time.Hour)
+	now := time.Now()
+	query := s.Sign(7, now)
+
+	if err := s.Verify(7, query, now.Add(59*time.Minute)); err != nil {
+		t.Errorf("got %v, wanted valid URL", err)
+	}
+	if err := s.Verify(7, query, now.Add(61*time.Minute)); !errors.Is(err, ErrArtifactUrlExpired) {
+		t.Errorf("got %v, wanted %v", err, ErrArtifactUrlExpired)
+	}
+	if err := s.Verify(8, query, now); !errors.Is(err, ErrArtifactUrlSignature) {
+		t.Errorf("other firmware: got %v, wanted %v", err, ErrArtifactUrlSignature)
+	}
+	extended := s.Sign(7, now)
+	extended.Set("expires", "9999999999")
+	if err := s.Verify(7, extended, now); !errors.Is(err, ErrArtifactUrlSignature) {
+		t.Errorf("extended expiry: got %v, wanted %v", err, ErrArtifactUrlSignature)
+	}
+
+	other, _ := NewArtifactSigner("other", time.Hour)
+	random, _ := NewArtifactSigner("", time.Hour)
+	for _, signer := range []*ArtifactSigner{other, random} {
+		if err := signer.Verify(7, query, now); !errors.Is(err, ErrArtifactUrlSignature) {
+			t.Errorf("other secret: got %v, wanted %v", err, ErrArtifactUrlSignature)
+		}
+	}
+}
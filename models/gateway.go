@@ -2,18 +2,25 @@ package models
 
 import (
 	"context"
-	"fmt"
+	"time"
+
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"gorm.io/gorm"
 )
 
 type Gateway struct {
 	GormModel
+	TenantModel
+	SoftDeleteModel
+	Revision
+	DeviceTwin
 	AreaID          string `json:"area_id"`
+	Site            string `gorm:"type:varchar(256);index" json:"site"` // site topics of gateway carry, empty when topics are not per site
 	GatewayID       string `gorm:"type:varchar(256);unique;not null;" json:"gateway_id"`
 	Name            string `json:"name"`
 	ConnectState    string `json:"connect_state"`
 	SoftwareVersion string `json:"software_version"`
+	TrashedBy       string `gorm:"type:varchar(16)" json:"trashed_by,omitempty"` // who moved gateway to trash, TRASHED_BY_SYSTEM or TRASHED_BY_OPERATOR
 	UHFs            []UHF  `gorm:"foreignKey:GatewayID;references:GatewayID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"uhfs"`
 }
 
@@ -41,7 +48,16 @@ func NewGatewaySvc(db *gorm.DB) *GatewaySvc {
 }
 
 func (gs *GatewaySvc) FindAllGateway(ctx context.Context) (gwList []Gateway, err error) {
-	result := gs.db.Preload("UHFs").Find(&gwList)
+	result := gs.db.WithContext(ctx).Preload("UHFs").Find(&gwList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return gwList, nil
+}
+
+func (gs *GatewaySvc) FindGatewaysBySelector(ctx context.Context, s BulkSelector) (gwList []Gateway, err error) {
+	result := gs.db.WithContext(ctx).Preload("UHFs").Scopes(s.GatewayScope).Find(&gwList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -50,7 +66,7 @@ func (gs *GatewaySvc) FindAllGateway(ctx context.Context) (gwList []Gateway, err
 }
 
 func (gs *GatewaySvc) FindGatewayByID(ctx context.Context, id string) (gw *Gateway, err error) {
-	result := gs.db.Preload("UHFs").First(&gw, id)
+	result := gs.db.WithContext(ctx).Preload("UHFs").First(&gw, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -60,37 +76,90 @@ func (gs *GatewaySvc) FindGatewayByID(ctx context.Context, id string) (gw *Gatew
 
 func (gs *GatewaySvc) FindGatewayByGatewayID(ctx context.Context, id string) (gw *Gateway, err error) {
 	var cnt int64
-	result := gs.db.Preload("UHFs").Where("gateway_id = ?", id).Find(&gw).Count(&cnt)
+	result := gs.db.WithContext(ctx).Preload("UHFs").Where("gateway_id = ?", id).Find(&gw).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
 	if cnt <= 0 {
-		return nil, fmt.Errorf("find no records")
+		return nil, NewNotFoundError("gateway %s not found", id)
 	}
 
 	return gw, nil
 }
 
+// Find site and organization of gateway without loading its UHFs, gateways
+// in trash keep theirs so a bootup restores them where they were
+func (gs *GatewaySvc) FindGatewayTenant(ctx context.Context, id string) (site string, orgId uint, err error) {
+	var gw Gateway
+	result := gs.db.WithContext(ctx).Unscoped().Select("site", "organization_id").Where("gateway_id = ?", id).First(&gw)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return "", 0, err
+	}
+	return gw.Site, gw.OrganizationID, nil
+}
+
+// Count gateways of every connect state, gateways never connected count as ""
+func (gs *GatewaySvc) CountGatewaysByConnectState(ctx context.Context) (map[string]int64, error) {
+	rows := []struct {
+		ConnectState string
+		Cnt          int64
+	}{}
+	result := gs.db.WithContext(ctx).Model(&Gateway{}).Select("COALESCE(connect_state, '') AS connect_state, COUNT(*) AS cnt").
+		Group("connect_state").Scan(&rows)
+	if err := result.Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	counts := map[string]int64{}
+	for _, row := range rows {
+		counts[row.ConnectState] += row.Cnt
+	}
+	return counts, nil
+}
+
+// UpdateGateway updates gateway g.GatewayID when it is still at g.Revision, any revision when 0
 func (gs *GatewaySvc) UpdateGateway(ctx context.Context, g *Gateway) (bool, error) {
 	var cnt int64
-	gateway := gs.db.Model(&g).Where("gateway_id = ?", g.GatewayID)
+	db := gs.db.WithContext(ctx)
+	gateway := db.Model(&g).Where("gateway_id = ?", g.GatewayID)
 	gateway.Count(&cnt)
 	if cnt <= 0 {
-		return false, fmt.Errorf("No gateway found")
+		return false, NewNotFoundError("gateway %s not found", g.GatewayID)
 	}
-	result := gateway.Updates(g)
-	return utils.ReturnBoolStateFromResult(result)
+	result := whereRevision(gateway, g.Revision.Revision).Updates(g)
+	return revisionResult(db, &Gateway{}, result, g.Revision.Revision, "gateway_id = ?", g.GatewayID)
 }
 
-func (gs *GatewaySvc) DeleteGateway(ctx context.Context, gwID string) (bool, error) {
-	result := gs.db.Unscoped().Where("gateway_id = ?", gwID).Delete(&Gateway{})
-	return utils.ReturnBoolStateFromResult(result)
+// DeleteGateway moves gateway gwID and its UHFs to trash when it is still at
+// revision, any revision when 0, recording trashedBy. They share deletion
+// time so restoring the gateway brings back the UHFs deleted with it.
+func (gs *GatewaySvc) DeleteGateway(ctx context.Context, gwID string, revision uint, trashedBy string) (bool, error) {
+	now := time.Now()
+	isSuccess := false
+	db := gs.db.WithContext(ctx).Session(&gorm.Session{NowFunc: func() time.Time { return now }})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := whereRevision(tx.Where("gateway_id = ?", gwID), revision).Delete(&Gateway{})
+		ok, err := revisionResult(tx, &Gateway{}, result, revision, "gateway_id = ?", gwID)
+		if err != nil {
+			return err
+		}
+		isSuccess = ok
+		if err := tx.WithContext(WithoutRevision(ctx)).Unscoped().Model(&Gateway{}).
+			Where("gateway_id = ?", gwID).Update("trashed_by", trashedBy).Error; err != nil {
+			return err
+		}
+		return tx.Where("gateway_id = ?", gwID).Delete(&UHF{}).Error
+	})
+	if err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	return isSuccess, nil
 }
 
 func (gs *GatewaySvc) DeleteGatewayUHF(ctx context.Context, gw *Gateway, d *UHF) (*Gateway, error) {
-	if err := gs.db.Model(&gw).Association("UHFs").Delete(d); err != nil {
+	if err := gs.db.WithContext(ctx).Model(&gw).Association("UHFs").Delete(d); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -98,7 +167,7 @@ func (gs *GatewaySvc) DeleteGatewayUHF(ctx context.Context, gw *Gateway, d *UHF)
 }
 
 func (gs *GatewaySvc) UpdateGatewayConnectState(ctx context.Context, gwId string, state string) (bool, error) {
-	if err := gs.db.Model(&Gateway{}).Where("gateway_id = ?", gwId).Update("connect_state", state).Error; err != nil {
+	if err := gs.db.WithContext(ctx).Model(&Gateway{}).Where("gateway_id = ?", gwId).Update("connect_state", state).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return false, err
 	}
@@ -106,9 +175,46 @@ func (gs *GatewaySvc) UpdateGatewayConnectState(ctx context.Context, gwId string
 }
 
 func (gs *GatewaySvc) CreateGateway(ctx context.Context, g *Gateway) (*Gateway, error) {
-	if err := gs.db.Create(&g).Error; err != nil {
+	if err := gs.db.WithContext(ctx).Create(&g).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return g, nil
 }
+
+func (gs *GatewaySvc) FindDriftedGateways(ctx context.Context) (gwList []Gateway, err error) {
+	result := gs.db.WithContext(ctx).Where(driftedQuery).Find(&gwList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return gwList, nil
+}
+
+// Set the state gateway should apply, reconciler will retry until it is reported back
+func (gs *GatewaySvc) UpdateGatewayDesiredState(ctx context.Context, gwId string, state string) (bool, error) {
+	result := gs.db.WithContext(ctx).Model(&Gateway{}).Where("gateway_id = ?", gwId).Updates(map[string]interface{}{
+		"desired_state": state,
+		"sync_attempts": 0,
+	})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Record state gateway reports, attempts start over only once it is the
+// desired state
+func (gs *GatewaySvc) UpdateGatewayReportedState(ctx context.Context, gwId string, state string) (bool, error) {
+	result := gs.db.WithContext(ctx).Model(&Gateway{}).Where("gateway_id = ?", gwId).Updates(map[string]interface{}{
+		"reported_state": state,
+		"reported_at":    time.Now(),
+		"sync_attempts":  syncAttemptsOnReport(state),
+	})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (gs *GatewaySvc) MarkGatewaySyncAttempt(ctx context.Context, gwId string) (bool, error) {
+	result := gs.db.WithContext(ctx).Model(&Gateway{}).Where("gateway_id = ?", gwId).Updates(map[string]interface{}{
+		"sync_attempts": gorm.Expr("COALESCE(sync_attempts, 0) + 1"),
+		"last_sync_at":  time.Now(),
+	})
+	return utils.ReturnBoolStateFromResult(result)
+}
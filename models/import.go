@@ -0,0 +1,314 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// Kinds of import rows, in column "type"
+const (
+	IMPORT_AREA    string = "area"
+	IMPORT_GATEWAY string = "gateway"
+	IMPORT_UHF     string = "uhf"
+)
+
+// Columns of an import file, header row names them in any order
+var ImportColumns = []string{"type", "name", "manager", "gateway_id", "uhf_address", "area"}
+
+// ImportRow is a row of an import file. An area row creates area Name or
+// changes its Manager. A gateway row renames gateway GatewayID and assigns it
+// to Area. A UHF row assigns UHF at UHFAddress of GatewayID to Area. Areas
+// are referenced by name and may be created by an earlier row of the file.
+type ImportRow struct {
+	Row        int    `json:"row"` // row number in file, header is row 1
+	Type       string `json:"type"`
+	Name       string `json:"name,omitempty"`
+	Manager    string `json:"manager,omitempty"`
+	GatewayID  string `json:"gateway_id,omitempty"`
+	UHFAddress string `json:"uhf_address,omitempty"`
+	Area       string `json:"area,omitempty"`
+}
+
+type ImportGateway struct {
+	GatewayID string `json:"gateway_id"`
+	Name      string `json:"name,omitempty"`
+	Area      string `json:"area,omitempty"`
+}
+
+type ImportUHF struct {
+	GatewayID  string `json:"gateway_id"`
+	UHFAddress string `json:"uhf_address"`
+	Area       string `json:"area"`
+}
+
+// ImportPlan is what an import changes, areas are referenced by name
+type ImportPlan struct {
+	NewAreas     []Area          `json:"new_areas"`
+	UpdatedAreas []Area          `json:"updated_areas"`
+	Gateways     []ImportGateway `json:"gateways"`
+	UHFs         []ImportUHF     `json:"uhfs"`
+}
+
+// ImportReport answers an import, PublishErrors lists gateways that could
+// not be told about changes already stored
+type ImportReport struct {
+	DryRun        bool        `json:"dry_run"`
+	Rows          int         `json:"rows"`
+	Plan          *ImportPlan `json:"plan"`
+	PublishErrors []string    `json:"publish_errors"`
+}
+
+// ImportResult holds gateways and UHFs an import changed, as stored after it
+type ImportResult struct {
+	Gateways []Gateway
+	UHFs     []UHF
+}
+
+// ParseImportRows maps rows of an import file to ImportRow by its header,
+// rows without any value are skipped
+func ParseImportRows(table [][]string) ([]ImportRow, []utils.FieldError) {
+	if len(table) == 0 {
+		return nil, []utils.FieldError{importError(1, "type", ERR_CODE_INVALID_FIELD, "file is empty")}
+	}
+	columns := map[string]int{}
+	for i, name := range table[0] {
+		columns[strings.ToLower(name)] = i
+	}
+	if _, ok := columns["type"]; !ok {
+		return nil, []utils.FieldError{importError(1, "type", ERR_CODE_INVALID_FIELD,
+			"header must name columns %s", strings.Join(ImportColumns, ", "))}
+	}
+	cell := func(row []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+
+	rows := []ImportRow{}
+	for i, row := range table[1:] {
+		if strings.Join(row, "") == "" {
+			continue
+		}
+		rows = append(rows, ImportRow{
+			Row:        i + 2,
+			Type:       strings.ToLower(cell(row, "type")),
+			Name:       cell(row, "name"),
+			Manager:    cell(row, "manager"),
+			GatewayID:  cell(row, "gateway_id"),
+			UHFAddress: cell(row, "uhf_address"),
+			Area:       cell(row, "area"),
+		})
+	}
+	return rows, nil
+}
+
+// PlanImport validates rows against existing areas, gateways and UHFs of
+// the organization and returns what they change. Every invalid row is
+// reported, the plan is only usable when there is none.
+func PlanImport(rows []ImportRow, areas []Area, gateways []Gateway, uhfs []UHF) (*ImportPlan, []utils.FieldError) {
+	plan := &ImportPlan{NewAreas: []Area{}, UpdatedAreas: []Area{}, Gateways: []ImportGateway{}, UHFs: []ImportUHF{}}
+	errs := []utils.FieldError{}
+
+	areaByName := map[string]Area{}
+	for _, a := range areas {
+		areaByName[a.Name] = a
+	}
+	knownGateways := map[string]bool{}
+	for _, gw := range gateways {
+		knownGateways[gw.GatewayID] = true
+	}
+	knownUHFs := map[string]bool{}
+	for _, uhf := range uhfs {
+		knownUHFs[uhf.GatewayID+"/"+uhf.UHFAddress] = true
+	}
+	// Areas created by rows, then row that first touched an area, gateway or UHF
+	newAreas := map[string]bool{}
+	seen := map[string]int{}
+	duplicate := func(row ImportRow, key string, field string) bool {
+		if first, ok := seen[key]; ok {
+			errs = append(errs, importError(row.Row, field, ERR_CODE_DUPLICATE, "already imported by row %d", first))
+			return true
+		}
+		seen[key] = row.Row
+		return false
+	}
+	checkArea := func(row ImportRow) bool {
+		if _, ok := areaByName[row.Area]; ok || newAreas[row.Area] {
+			return true
+		}
+		errs = append(errs, importError(row.Row, "area", ERR_CODE_NOT_FOUND, "area %s does not exist", row.Area))
+		return false
+	}
+
+	// Areas first so other rows may reference areas of any row
+	for _, row := range rows {
+		if row.Type != IMPORT_AREA {
+			continue
+		}
+		if row.Name == "" {
+			errs = append(errs, importError(row.Row, "name", ERR_CODE_INVALID_FIELD, "name is required"))
+			continue
+		}
+		if duplicate(row, "area/"+row.Name, "name") {
+			continue
+		}
+		existing, ok := areaByName[row.Name]
+		switch {
+		case !ok && row.Manager == "":
+			errs = append(errs, importError(row.Row, "manager", ERR_CODE_INVALID_FIELD, "manager is required for new area %s", row.Name))
+		case !ok:
+			newAreas[row.Name] = true
+			plan.NewAreas = append(plan.NewAreas, Area{Name: row.Name, Manager: row.Manager})
+		case row.Manager != "" && row.Manager != existing.Manager:
+			existing.Manager = row.Manager
+			plan.UpdatedAreas = append(plan.UpdatedAreas, existing)
+		}
+	}
+
+	for _, row := range rows {
+		switch row.Type {
+		case IMPORT_AREA:
+		case IMPORT_GATEWAY:
+			switch {
+			case row.GatewayID == "":
+				errs = append(errs, importError(row.Row, "gateway_id", ERR_CODE_INVALID_FIELD, "gateway_id is required"))
+			case !knownGateways[row.GatewayID]:
+				errs = append(errs, importError(row.Row, "gateway_id", ERR_CODE_NOT_FOUND, "gateway %s does not exist", row.GatewayID))
+			case row.Name == "" && row.Area == "":
+				errs = append(errs, importError(row.Row, "area", ERR_CODE_INVALID_FIELD, "name or area is required"))
+			case duplicate(row, "gateway/"+row.GatewayID, "gateway_id"):
+			case row.Area == "" || checkArea(row):
+				plan.Gateways = append(plan.Gateways, ImportGateway{GatewayID: row.GatewayID, Name: row.Name, Area: row.Area})
+			}
+		case IMPORT_UHF:
+			key := row.GatewayID + "/" + row.UHFAddress
+			switch {
+			case row.GatewayID == "":
+				errs = append(errs, importError(row.Row, "gateway_id", ERR_CODE_INVALID_FIELD, "gateway_id is required"))
+			case row.UHFAddress == "":
+				errs = append(errs, importError(row.Row, "uhf_address", ERR_CODE_INVALID_FIELD, "uhf_address is required"))
+			case !knownUHFs[key]:
+				errs = append(errs, importError(row.Row, "uhf_address", ERR_CODE_NOT_FOUND,
+					"UHF %s of gateway %s does not exist", row.UHFAddress, row.GatewayID))
+			case row.Area == "":
+				errs = append(errs, importError(row.Row, "area", ERR_CODE_INVALID_FIELD, "area is required"))
+			case duplicate(row, "uhf/"+key, "uhf_address"):
+			case checkArea(row):
+				plan.UHFs = append(plan.UHFs, ImportUHF{GatewayID: row.GatewayID, UHFAddress: row.UHFAddress, Area: row.Area})
+			}
+		default:
+			errs = append(errs, importError(row.Row, "type", ERR_CODE_INVALID_FIELD,
+				"type must be %s, %s or %s", IMPORT_AREA, IMPORT_GATEWAY, IMPORT_UHF))
+		}
+	}
+	return plan, errs
+}
+
+// Error of a cell, field is named rows[<row>].<column>
+func importError(row int, column string, code string, format string, args ...interface{}) utils.FieldError {
+	return utils.FieldError{
+		Field:   fmt.Sprintf("rows[%d].%s", row, column),
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+type ImportSvc struct {
+	db *gorm.DB
+}
+
+func NewImportSvc(db *gorm.DB) *ImportSvc {
+	return &ImportSvc{
+		db: db,
+	}
+}
+
+// ApplyImport applies plan in a single transaction, nothing is changed when
+// any step fails
+func (is *ImportSvc) ApplyImport(ctx context.Context, plan *ImportPlan) (*ImportResult, error) {
+	result := &ImportResult{Gateways: []Gateway{}, UHFs: []UHF{}}
+	err := is.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range plan.NewAreas {
+			a := plan.NewAreas[i]
+			if err := tx.Create(&a).Error; err != nil {
+				return err
+			}
+		}
+		for _, a := range plan.UpdatedAreas {
+			res := tx.Model(&Area{}).Where("id = ?", a.ID).Update("manager", a.Manager)
+			if _, err := utils.ReturnBoolStateFromResult(res); errors.Is(err, utils.ErrNoRecordAffected) {
+				return NewNotFoundError("area %s not found", a.Name)
+			} else if err != nil {
+				return err
+			}
+		}
+
+		areaIds := map[string]string{}
+		names := []string{}
+		for _, gw := range plan.Gateways {
+			if gw.Area != "" {
+				names = append(names, gw.Area)
+			}
+		}
+		for _, uhf := range plan.UHFs {
+			names = append(names, uhf.Area)
+		}
+		if len(names) > 0 {
+			aList := []Area{}
+			if err := tx.Where("name IN ?", names).Find(&aList).Error; err != nil {
+				return err
+			}
+			for _, a := range aList {
+				areaIds[a.Name] = strconv.FormatUint(uint64(a.ID), 10)
+			}
+		}
+
+		for _, gw := range plan.Gateways {
+			values := map[string]interface{}{}
+			if gw.Name != "" {
+				values["name"] = gw.Name
+			}
+			if gw.Area != "" {
+				values["area_id"] = areaIds[gw.Area]
+			}
+			res := tx.Model(&Gateway{}).Where("gateway_id = ?", gw.GatewayID).Updates(values)
+			if _, err := utils.ReturnBoolStateFromResult(res); errors.Is(err, utils.ErrNoRecordAffected) {
+				return NewNotFoundError("gateway %s not found", gw.GatewayID)
+			} else if err != nil {
+				return err
+			}
+			updated := Gateway{}
+			if err := tx.Where("gateway_id = ?", gw.GatewayID).First(&updated).Error; err != nil {
+				return err
+			}
+			result.Gateways = append(result.Gateways, updated)
+		}
+		for _, uhf := range plan.UHFs {
+			res := tx.Model(&UHF{}).Where("gateway_id = ? AND uhf_address = ?", uhf.GatewayID, uhf.UHFAddress).
+				Update("area_id", areaIds[uhf.Area])
+			if _, err := utils.ReturnBoolStateFromResult(res); errors.Is(err, utils.ErrNoRecordAffected) {
+				return NewNotFoundError("uhf %s of gateway %s not found", uhf.UHFAddress, uhf.GatewayID)
+			} else if err != nil {
+				return err
+			}
+			updated := UHF{}
+			if err := tx.Where("gateway_id = ? AND uhf_address = ?", uhf.GatewayID, uhf.UHFAddress).First(&updated).Error; err != nil {
+				return err
+			}
+			result.UHFs = append(result.UHFs, updated)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return result, nil
+}
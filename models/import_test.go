@@ -0,0 +1,85 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"testing"
+)
+
+func TestParseImportRows(t *testing.T) {
+	rows, errs := ParseImportRows([][]string{
+		{"Area", "Type", "Name"},
+		{"Hall", "UHF"},
+		{"", "", ""},
+		{"", "area", "Lobby"},
+	})
+	if len(errs) != 0 {
+		t.Fatalf("got %v, wanted no error", errs)
+	}
+	if len(rows) != 2 || rows[0].Row != 2 || rows[0].Type != IMPORT_UHF || rows[0].Area != "Hall" || rows[0].Name != "" {
+		t.Fatalf("got %+v, wanted uhf row 2 of area Hall", rows)
+	}
+	if rows[1].Row != 4 || rows[1].Name != "Lobby" {
+		t.Errorf("got %+v, wanted area row 4 named Lobby", rows[1])
+	}
+
+	if _, errs := ParseImportRows([][]string{{"name", "area"}}); len(errs) != 1 || errs[0].Field != "rows[1].type" {
+		t.Errorf("got %v, wanted missing type column", errs)
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	areas := []Area{{Name: "Hall", Manager: "an"}}
+	areas[0].ID = 1
+	gateways := []Gateway{{GatewayID: "gw01"}}
+	uhfs := []UHF{{GatewayID: "gw01", UHFAddress: "1"}, {GatewayID: "gw01", UHFAddress: "2"}}
+
+	rows := []ImportRow{
+		{Row: 2, Type: IMPORT_UHF, GatewayID: "gw01", UHFAddress: "1", Area: "Lobby"},
+		{Row: 3, Type: IMPORT_AREA, Name: "Lobby", Manager: "binh"},
+		{Row: 4, Type: IMPORT_AREA, Name: "Hall", Manager: "chi"},
+		{Row: 5, Type: IMPORT_GATEWAY, GatewayID: "gw01", Name: "Entrance", Area: "Hall"},
+		{Row: 6, Type: IMPORT_UHF, GatewayID: "gw01", UHFAddress: "2", Area: "Hall"},
+	}
+	plan, errs := PlanImport(rows, areas, gateways, uhfs)
+	if len(errs) != 0 {
+		t.Fatalf("got %v, wanted no error", errs)
+	}
+	if len(plan.NewAreas) != 1 || plan.NewAreas[0].Name != "Lobby" {
+		t.Errorf("got %+v, wanted new area Lobby", plan.NewAreas)
+	}
+	if len(plan.UpdatedAreas) != 1 || plan.UpdatedAreas[0].ID != 1 || plan.UpdatedAreas[0].Manager != "chi" {
+		t.Errorf("got %+v, wanted manager of Hall changed", plan.UpdatedAreas)
+	}
+	if len(plan.Gateways) != 1 || len(plan.UHFs) != 2 || plan.UHFs[0].Area != "Lobby" {
+		t.Errorf("got %+v %+v, wanted gw01 and both UHFs", plan.Gateways, plan.UHFs)
+	}
+
+	tests := []struct {
+		name  string
+		row   ImportRow
+		field string
+		code  string
+	}{
+		{"unknown type", ImportRow{Type: "door"}, "type", ERR_CODE_INVALID_FIELD},
+		{"area without name", ImportRow{Type: IMPORT_AREA}, "name", ERR_CODE_INVALID_FIELD},
+		{"new area without manager", ImportRow{Type: IMPORT_AREA, Name: "Roof"}, "manager", ERR_CODE_INVALID_FIELD},
+		{"unknown gateway", ImportRow{Type: IMPORT_GATEWAY, GatewayID: "gw09", Area: "Hall"}, "gateway_id", ERR_CODE_NOT_FOUND},
+		{"gateway without change", ImportRow{Type: IMPORT_GATEWAY, GatewayID: "gw01"}, "area", ERR_CODE_INVALID_FIELD},
+		{"unknown UHF", ImportRow{Type: IMPORT_UHF, GatewayID: "gw01", UHFAddress: "9", Area: "Hall"}, "uhf_address", ERR_CODE_NOT_FOUND},
+		{"UHF without area", ImportRow{Type: IMPORT_UHF, GatewayID: "gw01", UHFAddress: "1"}, "area", ERR_CODE_INVALID_FIELD},
+		{"unknown area", ImportRow{Type: IMPORT_UHF, GatewayID: "gw01", UHFAddress: "1", Area: "Roof"}, "area", ERR_CODE_NOT_FOUND},
+		{"duplicate UHF", ImportRow{Type: IMPORT_UHF, GatewayID: "gw01", UHFAddress: "2", Area: "Hall"}, "uhf_address", ERR_CODE_DUPLICATE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.row.Row = 7
+			rows := []ImportRow{{Row: 2, Type: IMPORT_UHF, GatewayID: "gw01", UHFAddress: "2", Area: "Hall"}, tt.row}
+			_, errs := PlanImport(rows, areas, gateways, uhfs)
+			if len(errs) != 1 || errs[0].Field != "rows[7]."+tt.field || errs[0].Code != tt.code {
+				t.Errorf("got %v, wanted %s on rows[7].%s", errs, tt.code, tt.field)
+			}
+		})
+	}
+}
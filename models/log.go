@@ -10,7 +10,7 @@ import (
 )
 
 const (
-	DEFAULT_TIME_FORMAT       string        = "2006-01-02 15:04:05.999999999 -07:00" // Sync with SQL format
+	DEFAULT_TIME_FORMAT string = "2006-01-02 15:04:05.999999999 -07:00" // Sync with SQL format
 	// DEFAULT_CLEAN_LOGS_PERIOD time.Duration = time.Hour * 24 * 7                     // 1 week
 )
 
@@ -21,6 +21,8 @@ type GatewayLogTime struct {
 	Second int `json:"second"`
 }
 type GatewayLog struct {
+	TenantModel
+	CorrelationModel
 	ID         uint      `gorm:"primarykey;" json:"id"`
 	GatewayID  string    `json:"gateway_id"`
 	StateType  string    `json:"state_type"`
@@ -44,7 +46,7 @@ type LogSvc struct {
 
 func NewLogSvc(db *gorm.DB) *LogSvc {
 	logSvc := &LogSvc{
-		db:          db,
+		db: db,
 		// cleanTicker: newCleanTicker(DEFAULT_CLEAN_LOGS_PERIOD, db),
 	}
 	// logSvc.cleanTicker.start()
@@ -52,7 +54,7 @@ func NewLogSvc(db *gorm.DB) *LogSvc {
 }
 
 func (ls *LogSvc) FindAllGatewayLog(ctx context.Context) (glList []GatewayLog, err error) {
-	result := ls.db.Find(&glList)
+	result := ls.db.WithContext(ctx).Find(&glList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -61,7 +63,7 @@ func (ls *LogSvc) FindAllGatewayLog(ctx context.Context) (glList []GatewayLog, e
 }
 
 func (ls *LogSvc) FindGatewayLogByID(ctx context.Context, id string) (gl *GatewayLog, err error) {
-	result := ls.db.First(&gl, id)
+	result := ls.db.WithContext(ctx).First(&gl, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -70,7 +72,7 @@ func (ls *LogSvc) FindGatewayLogByID(ctx context.Context, id string) (gl *Gatewa
 }
 
 func (ls *LogSvc) FindGatewayByGatewayID(ctx context.Context, gatewayId string) (gl *[]GatewayLog, err error) {
-	result := ls.db.Where("gateway_id = ?", gatewayId).Find(&gl)
+	result := ls.db.WithContext(ctx).Where("gateway_id = ?", gatewayId).Find(&gl)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -79,15 +81,25 @@ func (ls *LogSvc) FindGatewayByGatewayID(ctx context.Context, gatewayId string)
 }
 
 func (ls *LogSvc) CreateGatewayLog(ctx context.Context, gl *GatewayLog) (*GatewayLog, error) {
-	if err := ls.db.Create(&gl).Error; err != nil {
+	if err := ls.db.WithContext(ctx).Create(&gl).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return gl, nil
 }
 
-func (ls *LogSvc) FindGatewayLogsByGatewayIDAndTime(gatewayId string, from string, to string) (glList *[]GatewayLog, err error) {
-	result := ls.db.Where("gateway_id = ? AND log_time >= ? AND log_time <= ?", gatewayId, from, to).Find(&glList)
+func (ls *LogSvc) CreateGatewayLogs(ctx context.Context, glList []GatewayLog, batchSize int) error {
+	if len(glList) == 0 {
+		return nil
+	}
+	if err := ls.db.WithContext(ctx).CreateInBatches(glList, batchSize).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	return nil
+}
+
+func (ls *LogSvc) FindGatewayLogsByGatewayIDAndTime(ctx context.Context, gatewayId string, from string, to string) (glList *[]GatewayLog, err error) {
+	result := ls.db.WithContext(ctx).Where("gateway_id = ? AND log_time >= ? AND log_time <= ?", gatewayId, from, to).Find(&glList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -95,8 +107,8 @@ func (ls *LogSvc) FindGatewayLogsByGatewayIDAndTime(gatewayId string, from strin
 	return glList, nil
 }
 
-func (ls *LogSvc) FindGatewayLogsByTime(from string, to string) (glList *[]GatewayLog, err error) {
-	result := ls.db.Where("log_time >= ? AND log_time <= ?", from, to).Find(&glList)
+func (ls *LogSvc) FindGatewayLogsByTime(ctx context.Context, from string, to string) (glList *[]GatewayLog, err error) {
+	result := ls.db.WithContext(ctx).Where("log_time >= ? AND log_time <= ?", from, to).Find(&glList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -198,7 +210,7 @@ func (glt *GatewayLogTime) toTimeDuration() time.Duration {
 	return period
 }
 
-func (ls *LogSvc) DeleteGatewayLogInTimeRange(from string, to string) (bool, error) {
-	result := ls.db.Unscoped().Where("log_time >= ? AND log_time <= ?", from, to).Delete(&GatewayLog{})
+func (ls *LogSvc) DeleteGatewayLogInTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	result := ls.db.WithContext(ctx).Unscoped().Where("log_time >= ? AND log_time <= ?", from, to).Delete(&GatewayLog{})
 	return utils.ReturnBoolStateFromResult(result)
 }
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+const metricsStartKey = "metrics:start"
+
+// Domain gauges are queried on every scrape and give up after this long
+const METRICS_QUERY_TIMEOUT = 5 * time.Second
+
+// RegisterMetricsCallbacks times every statement into metrics.DbQueryDuration
+func RegisterMetricsCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("*").Register("metrics:before_create", metricsStart); err != nil {
+		return err
+	}
+	if err := cb.Create().After("*").Register("metrics:after_create", metricsObserve("create")); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("*").Register("metrics:before_query", metricsStart); err != nil {
+		return err
+	}
+	if err := cb.Query().After("*").Register("metrics:after_query", metricsObserve("query")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("*").Register("metrics:before_update", metricsStart); err != nil {
+		return err
+	}
+	if err := cb.Update().After("*").Register("metrics:after_update", metricsObserve("update")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("*").Register("metrics:before_delete", metricsStart); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("*").Register("metrics:after_delete", metricsObserve("delete")); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("*").Register("metrics:before_row", metricsStart); err != nil {
+		return err
+	}
+	if err := cb.Row().After("*").Register("metrics:after_row", metricsObserve("row")); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("*").Register("metrics:before_raw", metricsStart); err != nil {
+		return err
+	}
+	return cb.Raw().After("*").Register("metrics:after_raw", metricsObserve("raw"))
+}
+
+func metricsStart(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+func metricsObserve(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		metrics.DbQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	}
+}
+
+// DomainCollector reports gateways, UHFs and accesses of all organizations
+type DomainCollector struct {
+	svcOpts *ServiceOptions
+
+	gateways *prometheus.Desc
+	uhfs     *prometheus.Desc
+	accesses *prometheus.Desc
+	errors   *prometheus.Desc
+}
+
+func NewDomainCollector(svcOpts *ServiceOptions) *DomainCollector {
+	return &DomainCollector{
+		svcOpts: svcOpts,
+		gateways: prometheus.NewDesc("uams_gateways", "Gateways by connect state.",
+			[]string{"connect_state"}, nil),
+		uhfs: prometheus.NewDesc("uams_uhfs_active", "UHF readers in active state.",
+			nil, nil),
+		accesses: prometheus.NewDesc("uams_accesses_last_minute", "User and package accesses of the last minute by area.",
+			[]string{"area_id", "kind"}, nil),
+		errors: prometheus.NewDesc("uams_domain_metrics_errors", "Domain gauges that failed to query on this scrape.",
+			[]string{"gauge"}, nil),
+	}
+}
+
+func (dc *DomainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dc.gateways
+	ch <- dc.uhfs
+	ch <- dc.accesses
+	ch <- dc.errors
+}
+
+func (dc *DomainCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(WithSystemTenant(context.Background()), METRICS_QUERY_TIMEOUT)
+	defer cancel()
+	failed := func(gauge string) {
+		ch <- prometheus.MustNewConstMetric(dc.errors, prometheus.GaugeValue, 1, gauge)
+	}
+
+	if counts, err := dc.svcOpts.GatewaySvc.CountGatewaysByConnectState(ctx); err != nil {
+		failed("gateways")
+	} else {
+		for state, cnt := range counts {
+			ch <- prometheus.MustNewConstMetric(dc.gateways, prometheus.GaugeValue, float64(cnt), state)
+		}
+	}
+
+	if cnt, err := dc.svcOpts.UHFSvc.CountActiveUHFs(ctx); err != nil {
+		failed("uhfs_active")
+	} else {
+		ch <- prometheus.MustNewConstMetric(dc.uhfs, prometheus.GaugeValue, float64(cnt))
+	}
+
+	since := time.Now().Add(-time.Minute)
+	if counts, err := dc.svcOpts.UserAccessSvc.CountUserAccessesByArea(ctx, since); err != nil {
+		failed("user_accesses")
+	} else {
+		for area, cnt := range counts {
+			ch <- prometheus.MustNewConstMetric(dc.accesses, prometheus.GaugeValue, float64(cnt), area, "user")
+		}
+	}
+	if counts, err := dc.svcOpts.PackageAccessSvc.CountPackageAccessesByArea(ctx, since); err != nil {
+		failed("package_accesses")
+	} else {
+		for area, cnt := range counts {
+			ch <- prometheus.MustNewConstMetric(dc.accesses, prometheus.GaugeValue, float64(cnt), area, "package")
+		}
+	}
+}
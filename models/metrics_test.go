@@ -0,0 +1,33 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCallbacksObserveStatements(t *testing.T) {
+	db := newDryRunDb(t)
+	if err := RegisterMetricsCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithSystemTenant(context.Background())
+
+	before := testutil.CollectAndCount(metrics.DbQueryDuration)
+	db.WithContext(ctx).Find(&[]Area{})
+	db.WithContext(ctx).Where("gateway_id = ?", "gw").Delete(&GatewayLog{})
+	if got := testutil.CollectAndCount(metrics.DbQueryDuration); got != before+2 {
+		t.Errorf("got %d series, wanted %d", got, before+2)
+	}
+	if !metrics.DbQueryDuration.DeleteLabelValues("query", "areas") {
+		t.Errorf("got no series for query of areas")
+	}
+	if !metrics.DbQueryDuration.DeleteLabelValues("delete", "gateway_logs") {
+		t.Errorf("got no series for delete of gateway_logs")
+	}
+}
@@ -1,11 +1,17 @@
 package models
 
 import (
+	"context"
+
 	"gorm.io/gorm"
 )
 
 func Migrate(db *gorm.DB) {
+	db = db.WithContext(WithSystemTenant(context.Background()))
 	err := db.AutoMigrate(
+		&Organization{},
+		&Site{},
+		&ApiKey{},
 		&Area{},
 		&Gateway{},
 		&UHF{},
@@ -15,8 +21,24 @@ func Migrate(db *gorm.DB) {
 		&SystemLog{},
 		&UHFStatusLog{},
 		&OperationLog{},
+		&Firmware{},
+		&RolloutCampaign{},
+		&RolloutTarget{},
+		&UHFConfigTemplate{},
+		&DeadLetter{},
+		&TagReadError{},
+		&SchemaMigration{},
 	)
 	if err != nil {
 		panic(err)
 	}
+	if err := MigrateTenants(db); err != nil {
+		panic(err)
+	}
+	if err := MigrateTenantNames(db); err != nil {
+		panic(err)
+	}
+	if err := recordSchemaVersion(db); err != nil {
+		panic(err)
+	}
 }
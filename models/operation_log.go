@@ -9,6 +9,8 @@ import (
 )
 
 type OperationLog struct {
+	TenantModel
+	CorrelationModel
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Time      time.Time `swaggerignore:"true" json:"time"`
 	GatewayID string    `json:"gateway_id"`
@@ -26,7 +28,7 @@ func NewOperationLogSvc(db *gorm.DB) *OperationLogSvc {
 }
 
 func (dlsls *OperationLogSvc) GetAllOperationLogs(ctx context.Context) (dlslList []OperationLog, err error) {
-	result := dlsls.db.Find(&dlslList)
+	result := dlsls.db.WithContext(ctx).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -35,7 +37,7 @@ func (dlsls *OperationLogSvc) GetAllOperationLogs(ctx context.Context) (dlslList
 }
 
 func (dlsls *OperationLogSvc) GetOperationLogByID(ctx context.Context, id string) (ol *OperationLog, err error) {
-	result := dlsls.db.First(&ol, id)
+	result := dlsls.db.WithContext(ctx).First(&ol, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -44,7 +46,7 @@ func (dlsls *OperationLogSvc) GetOperationLogByID(ctx context.Context, id string
 }
 
 func (dlsls *OperationLogSvc) GetOperationLogByGatewayID(ctx context.Context, doorId string) (dlslList []OperationLog, err error) {
-	result := dlsls.db.Where("gateway_id = ?", doorId).Find(&dlslList)
+	result := dlsls.db.WithContext(ctx).Where("gateway_id = ?", doorId).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -52,8 +54,8 @@ func (dlsls *OperationLogSvc) GetOperationLogByGatewayID(ctx context.Context, do
 	return dlslList, nil
 }
 
-func (dlsls *OperationLogSvc) FindOperationLogsByGatewayIDAndTime(gateway_id string, from string, to string) (dlslList *[]OperationLog, err error) {
-	result := dlsls.db.Where("gateway_id = ? AND time >= ? AND time <= ?", gateway_id, from, to).Find(&dlslList)
+func (dlsls *OperationLogSvc) FindOperationLogsByGatewayIDAndTime(ctx context.Context, gateway_id string, from string, to string) (dlslList *[]OperationLog, err error) {
+	result := dlsls.db.WithContext(ctx).Where("gateway_id = ? AND time >= ? AND time <= ?", gateway_id, from, to).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -61,8 +63,8 @@ func (dlsls *OperationLogSvc) FindOperationLogsByGatewayIDAndTime(gateway_id str
 	return dlslList, nil
 }
 
-func (dlsls *OperationLogSvc) FindOperationLogsByTime(from string, to string) (dlslList *[]OperationLog, err error) {
-	result := dlsls.db.Where("time >= ? AND time <= ?", from, to).Find(&dlslList)
+func (dlsls *OperationLogSvc) FindOperationLogsByTime(ctx context.Context, from string, to string) (dlslList *[]OperationLog, err error) {
+	result := dlsls.db.WithContext(ctx).Where("time >= ? AND time <= ?", from, to).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -71,14 +73,24 @@ func (dlsls *OperationLogSvc) FindOperationLogsByTime(from string, to string) (d
 }
 
 func (dlsls *OperationLogSvc) CreateOperationLog(ctx context.Context, dlsl *OperationLog) (*OperationLog, error) {
-	if err := dlsls.db.Create(&dlsl).Error; err != nil {
+	if err := dlsls.db.WithContext(ctx).Create(&dlsl).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return dlsl, nil
 }
 
-func (dlsls *OperationLogSvc) DeleteOperationLogInTimeRange(from string, to string) (bool, error) {
-	result := dlsls.db.Unscoped().Where("time >= ? AND time <= ?", from, to).Delete(&OperationLog{})
+func (dlsls *OperationLogSvc) CreateOperationLogs(ctx context.Context, dlslList []OperationLog, batchSize int) error {
+	if len(dlslList) == 0 {
+		return nil
+	}
+	if err := dlsls.db.WithContext(ctx).CreateInBatches(dlslList, batchSize).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	return nil
+}
+
+func (dlsls *OperationLogSvc) DeleteOperationLogInTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	result := dlsls.db.WithContext(ctx).Unscoped().Where("time >= ? AND time <= ?", from, to).Delete(&OperationLog{})
 	return utils.ReturnBoolStateFromResult(result)
 }
@@ -8,6 +8,7 @@ import (
 )
 
 type PackageAccess struct {
+	TenantModel
 	ID        uint      `gorm:"primarykey;" json:"id"`
 	PackageID string    `gorm:"type:varchar(256);" json:"package_id"`
 	Random    string    `gorm:"type:varchar(50);" json:"random"`
@@ -27,15 +28,25 @@ func NewPackageAccessSvc(db *gorm.DB) *PackageAccessSvc {
 }
 
 func (gwns *PackageAccessSvc) CreatePackageAccess(ctx context.Context, package_acesses *PackageAccess) (*PackageAccess, error) {
-	if err := gwns.db.Create(&package_acesses).Error; err != nil {
+	if err := gwns.db.WithContext(ctx).Create(&package_acesses).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return package_acesses, nil
 }
 
+func (gwns *PackageAccessSvc) CreatePackageAccesses(ctx context.Context, package_acesses []PackageAccess, batchSize int) error {
+	if len(package_acesses) == 0 {
+		return nil
+	}
+	if err := gwns.db.WithContext(ctx).CreateInBatches(package_acesses, batchSize).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	return nil
+}
+
 func (gwns *PackageAccessSvc) FindAllPackageAccess(ctx context.Context) (package_acesses []PackageAccess, err error) {
-	result := gwns.db.Find(&package_acesses)
+	result := gwns.db.WithContext(ctx).Find(&package_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -44,7 +55,7 @@ func (gwns *PackageAccessSvc) FindAllPackageAccess(ctx context.Context) (package
 }
 
 func (gwns *PackageAccessSvc) FindAllPackageAccessByPackageID(ctx context.Context, id string) (package_acesses []PackageAccess, err error) {
-	result := gwns.db.Where("package_id = ?", id).Find(&package_acesses)
+	result := gwns.db.WithContext(ctx).Where("package_id = ?", id).Find(&package_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -53,7 +64,7 @@ func (gwns *PackageAccessSvc) FindAllPackageAccessByPackageID(ctx context.Contex
 }
 
 func (ls *PackageAccessSvc) FindPackageAccessByPackageIDAndTimeRange(ctx context.Context, package_id string, from string, to string) (package_acesses *[]PackageAccess, err error) {
-	result := ls.db.Where("package_id = ? AND time >= ? AND time <= ?", package_id, from, to).Find(&package_acesses)
+	result := ls.db.WithContext(ctx).Where("package_id = ? AND time >= ? AND time <= ?", package_id, from, to).Find(&package_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -62,7 +73,7 @@ func (ls *PackageAccessSvc) FindPackageAccessByPackageIDAndTimeRange(ctx context
 }
 
 func (ls *PackageAccessSvc) FindPackageAccessByPackageIDAndAreaID(ctx context.Context, package_id string, area_id string) (package_acesses *[]PackageAccess, err error) {
-	result := ls.db.Where("package_id = ? AND area_id = ?", package_id, area_id).Find(&package_acesses)
+	result := ls.db.WithContext(ctx).Where("package_id = ? AND area_id = ?", package_id, area_id).Find(&package_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -71,7 +82,7 @@ func (ls *PackageAccessSvc) FindPackageAccessByPackageIDAndAreaID(ctx context.Co
 }
 
 func (ls *PackageAccessSvc) FindAllPackageAccessByPackageIDAndAreaIDinTimeRange(ctx context.Context, package_id string, area_id string, from string, to string) (package_acesses *[]PackageAccess, err error) {
-	result := ls.db.Where("package_id = ? AND area_id = ? AND time >= ? AND time <= ?", package_id, area_id, from, to).Find(&package_acesses)
+	result := ls.db.WithContext(ctx).Where("package_id = ? AND area_id = ? AND time >= ? AND time <= ?", package_id, area_id, from, to).Find(&package_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -80,7 +91,7 @@ func (ls *PackageAccessSvc) FindAllPackageAccessByPackageIDAndAreaIDinTimeRange(
 }
 
 func (ls *PackageAccessSvc) FindAllUserAccessByAreaID(ctx context.Context, package_id string, area_id string, from string, to string) (package_acesses *[]PackageAccess, err error) {
-	result := ls.db.Where("package_id = ? AND area_id = ? AND time >= ? AND time <= ?", package_id, area_id, from, to).Find(&package_acesses)
+	result := ls.db.WithContext(ctx).Where("package_id = ? AND area_id = ? AND time >= ? AND time <= ?", package_id, area_id, from, to).Find(&package_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -89,7 +100,7 @@ func (ls *PackageAccessSvc) FindAllUserAccessByAreaID(ctx context.Context, packa
 }
 
 func (ls *PackageAccessSvc) FindAllPackageAccessByAreaID(ctx context.Context, area_id string) (package_acesses *[]PackageAccess, err error) {
-	result := ls.db.Where("area_id = ?", area_id).Find(&package_acesses)
+	result := ls.db.WithContext(ctx).Where("area_id = ?", area_id).Find(&package_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -98,7 +109,7 @@ func (ls *PackageAccessSvc) FindAllPackageAccessByAreaID(ctx context.Context, ar
 }
 
 func (ls *PackageAccessSvc) FindAllPackageAccessByAreaIDAndTimeRange(ctx context.Context, area_id string, from string, to string) (package_acesses *[]PackageAccess, err error) {
-	result := ls.db.Where("area_id = ? AND time >= ? AND time <= ?", area_id, from, to).Find(&package_acesses)
+	result := ls.db.WithContext(ctx).Where("area_id = ? AND time >= ? AND time <= ?", area_id, from, to).Find(&package_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -107,7 +118,7 @@ func (ls *PackageAccessSvc) FindAllPackageAccessByAreaIDAndTimeRange(ctx context
 }
 
 func (ls *PackageAccessSvc) FindAllPackageAccessTimeRange(ctx context.Context, from string, to string) (package_acesses *[]PackageAccess, err error) {
-	result := ls.db.Where("time >= ? AND time <= ?", from, to).Find(&package_acesses)
+	result := ls.db.WithContext(ctx).Where("time >= ? AND time <= ?", from, to).Find(&package_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -115,7 +126,25 @@ func (ls *PackageAccessSvc) FindAllPackageAccessTimeRange(ctx context.Context, f
 	return package_acesses, nil
 }
 
-func (dlsls *PackageAccessSvc) DeletePackageAccessTimeRange(from string, to string) (bool, error) {
-	result := dlsls.db.Unscoped().Where("time >= ? AND time <= ?", from, to).Delete(&PackageAccess{})
+// CountPackageAccessesByArea counts accesses since given time by area
+func (ls *PackageAccessSvc) CountPackageAccessesByArea(ctx context.Context, since time.Time) (map[string]int64, error) {
+	rows := []struct {
+		AreaID string
+		Cnt    int64
+	}{}
+	result := ls.db.WithContext(ctx).Model(&PackageAccess{}).Select("area_id, COUNT(*) AS cnt").
+		Where("time >= ?", since).Group("area_id").Scan(&rows)
+	if err := result.Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	counts := map[string]int64{}
+	for _, row := range rows {
+		counts[row.AreaID] += row.Cnt
+	}
+	return counts, nil
+}
+
+func (dlsls *PackageAccessSvc) DeletePackageAccessTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	result := dlsls.db.WithContext(ctx).Unscoped().Where("time >= ? AND time <= ?", from, to).Delete(&PackageAccess{})
 	return utils.ReturnBoolStateFromResult(result)
 }
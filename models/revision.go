@@ -0,0 +1,154 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+)
+
+// Revision counts writes of a row. GET responses carry it as ETag and PATCH
+// or DELETE send it back in If-Match, so a row changed meanwhile by another
+// operator or a gateway is refused instead of overwritten.
+type Revision struct {
+	Revision uint `gorm:"not null;default:1" json:"revision"`
+}
+
+// ETag is the strong entity tag of revision, e.g. "3"
+func (r Revision) ETag() string {
+	return strconv.Quote(strconv.FormatUint(uint64(r.Revision), 10))
+}
+
+// ParseETag returns revision of an entity tag made by ETag
+func ParseETag(tag string) (uint, bool) {
+	s, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0, false
+	}
+	rev, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || rev == 0 {
+		return 0, false
+	}
+	return uint(rev), true
+}
+
+type revisionCtxKey struct{}
+
+// WithoutRevision marks writes of ctx as gateway reports or background
+// bookkeeping, they leave revision as it is so ETags held by operators only
+// go stale when a row is changed through the API
+func WithoutRevision(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revisionCtxKey{}, true)
+}
+
+// KeepsRevision tells whether writes of ctx leave revision as it is
+func KeepsRevision(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	keep, _ := ctx.Value(revisionCtxKey{}).(bool)
+	return keep
+}
+
+// RegisterRevisionCallbacks makes every create of a model with Revision
+// start it at 1 and every update increase it, whatever columns the update
+// sets, unless its context is WithoutRevision. Register it after tenant callbacks, SET is built here and must see
+// their omits.
+func RegisterRevisionCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("revision:create", revisionCreate); err != nil {
+		return err
+	}
+	return cb.Update().After("tenant:update").Before("gorm:update").Register("revision:update", revisionUpdate)
+}
+
+// revisionCreate sets revision of created rows so callers hold the ETag of
+// what they created
+func revisionCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField("Revision")
+	if field == nil {
+		return
+	}
+	first := func(rv reflect.Value) {
+		if _, zero := field.ValueOf(rv); zero {
+			db.AddError(field.Set(rv, uint(1)))
+		}
+	}
+	switch rv := reflect.Indirect(db.Statement.ReflectValue); rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			first(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		first(rv)
+	}
+}
+
+// revisionUpdate builds the UPDATE as gorm:update would, with revision + 1
+// in place of any revision value the update carries
+func revisionUpdate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.SQL.Len() > 0 || KeepsRevision(stmt.Context) {
+		return
+	}
+	field := stmt.Schema.LookUpField("Revision")
+	if field == nil {
+		return
+	}
+	if !stmt.Unscoped {
+		for _, c := range stmt.Schema.UpdateClauses {
+			stmt.AddClause(c)
+		}
+	}
+	set := callbacks.ConvertToAssignments(stmt)
+	if len(set) == 0 {
+		return
+	}
+	assignments := clause.Set{}
+	for _, a := range set {
+		if a.Column.Name != field.DBName {
+			assignments = append(assignments, a)
+		}
+	}
+	assignments = append(assignments, clause.Assignment{
+		Column: clause.Column{Name: field.DBName},
+		Value:  clause.Expr{SQL: "? + 1", Vars: []interface{}{clause.Column{Name: field.DBName}}},
+	})
+	stmt.AddClauseIfNotExists(clause.Update{})
+	stmt.AddClause(assignments)
+	stmt.Build(stmt.BuildClauses...)
+}
+
+// whereRevision limits tx to rows at revision, 0 matches any
+func whereRevision(tx *gorm.DB, revision uint) *gorm.DB {
+	if revision == 0 {
+		return tx
+	}
+	return tx.Where("revision = ?", revision)
+}
+
+// revisionResult reports result of a write limited by whereRevision. When no
+// row was written but one matching query exists, its revision moved on and
+// ErrPreconditionFailed is returned instead of not found.
+func revisionResult(db *gorm.DB, model interface{}, result *gorm.DB, revision uint, query string, args ...interface{}) (bool, error) {
+	isSuccess, err := utils.ReturnBoolStateFromResult(result)
+	if revision == 0 || !errors.Is(err, utils.ErrNoRecordAffected) {
+		return isSuccess, err
+	}
+	var cnt int64
+	if err := db.Model(model).Where(query, args...).Count(&cnt).Error; err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	if cnt > 0 {
+		return false, NewPreconditionFailedError("revision %d is outdated, fetch it again", revision)
+	}
+	return isSuccess, err
+}
@@ -0,0 +1,70 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	r := Revision{Revision: 3}
+	if r.ETag() != `"3"` {
+		t.Errorf("got %s, wanted \"3\"", r.ETag())
+	}
+	if rev, ok := ParseETag(r.ETag()); !ok || rev != 3 {
+		t.Errorf("got %d %v, wanted 3", rev, ok)
+	}
+	for _, tag := range []string{"3", `W/"3"`, `"0"`, `"x"`, `"3", "4"`} {
+		if _, ok := ParseETag(tag); ok {
+			t.Errorf("%s: got valid, wanted invalid", tag)
+		}
+	}
+}
+
+func TestRevisionCallbacks(t *testing.T) {
+	db := newDryRunDb(t)
+	if err := RegisterRevisionCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithTenant(context.Background(), 7)
+
+	gw := &Gateway{GatewayID: "gw", Name: "Entrance"}
+	gw.Revision.Revision = 5
+	stmt := whereRevision(db.WithContext(ctx).Model(gw).Where("gateway_id = ?", "gw"), 5).Updates(gw).Statement
+	sql := stmt.SQL.String()
+	if !strings.Contains(sql, `SET "updated_at"=@p1,"gateway_id"=@p2,"name"=@p3,"revision"="revision" + 1 WHERE`) {
+		t.Errorf("update: got %s, wanted revision increased instead of set", sql)
+	}
+	if !strings.Contains(sql, "AND revision = @p5") || stmt.Vars[4] != uint(5) {
+		t.Errorf("update: got %s %v, wanted revision 5 in WHERE", sql, stmt.Vars)
+	}
+	if strings.Contains(strings.SplitN(sql, "WHERE", 2)[0], "organization_id") {
+		t.Errorf("update: got %s, wanted organization_id still omitted", sql)
+	}
+
+	// updates of a single column move revision on as well
+	stmt = db.WithContext(ctx).Model(&UHF{}).Where("uhf_address = ?", "1").Update("desired_state", "active").Statement
+	if !strings.Contains(stmt.SQL.String(), `"revision"="revision" + 1 WHERE`) {
+		t.Errorf("update column: got %s, wanted revision increased", stmt.SQL.String())
+	}
+	// gateway reports leave it
+	stmt = db.WithContext(WithoutRevision(ctx)).Model(&UHF{}).Where("uhf_address = ?", "1").Update("connect_state", "connect").Statement
+	if strings.Contains(stmt.SQL.String(), "revision") {
+		t.Errorf("report: got %s, wanted revision kept", stmt.SQL.String())
+	}
+	stmt = db.WithContext(ctx).Model(&GatewayLog{}).Where("gateway_id = ?", "gw").Update("state_value", "x").Statement
+	if strings.Contains(stmt.SQL.String(), "revision") {
+		t.Errorf("log update: got %s, wanted no revision", stmt.SQL.String())
+	}
+
+	area := &Area{Name: "Hall", Manager: "an"}
+	if err := db.WithContext(ctx).Create(area).Error; err != nil {
+		t.Fatal(err)
+	}
+	if area.Revision.Revision != 1 {
+		t.Errorf("create: got revision %d, wanted 1", area.Revision.Revision)
+	}
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// Rollout campaign status
+	ROLLOUT_PENDING   string = "pending"
+	ROLLOUT_RUNNING   string = "running"
+	ROLLOUT_PAUSED    string = "paused"
+	ROLLOUT_HALTED    string = "halted" // failure rate passed threshold
+	ROLLOUT_COMPLETED string = "completed"
+
+	// Rollout target status, in-progress values come from gateway reports
+	TARGET_PENDING     string = "pending"
+	TARGET_SENT        string = "sent"
+	TARGET_DOWNLOADING string = "downloading"
+	TARGET_INSTALLING  string = "installing"
+	TARGET_SUCCEEDED   string = "succeeded"
+	TARGET_FAILED      string = "failed"
+)
+
+type RolloutCampaign struct {
+	GormModel
+	Name             string          `gorm:"not null" json:"name"`
+	FirmwareID       uint            `gorm:"not null" json:"firmware_id"`
+	Firmware         *Firmware       `json:"firmware,omitempty"`
+	TargetAreaID     string          `json:"target_area_id"`    // empty: every area
+	TargetVersion    string          `json:"target_version"`    // empty: every software version
+	BatchSize        int             `json:"batch_size"`        // gateways upgrading at the same time
+	FailureThreshold float64         `json:"failure_threshold"` // halt when failed/finished ratio passes it, 0..1
+	MinFinished      int             `json:"min_finished"`      // finished targets needed before threshold applies
+	Status           string          `json:"status"`
+	StartedAt        *time.Time      `swaggerignore:"true" json:"started_at"`
+	FinishedAt       *time.Time      `swaggerignore:"true" json:"finished_at"`
+	HaltReason       string          `json:"halt_reason"`
+	Progress         RolloutProgress `gorm:"-" json:"progress,omitempty"`
+	Targets          []RolloutTarget `gorm:"foreignKey:CampaignID;constraint:OnDelete:CASCADE;" json:"targets,omitempty"`
+}
+
+type RolloutTarget struct {
+	GormModel
+	CampaignID  uint       `gorm:"index;not null" json:"campaign_id"`
+	GatewayID   string     `gorm:"type:varchar(256);not null" json:"gateway_id"`
+	FromVersion string     `json:"from_version"`
+	Status      string     `json:"status"`
+	Message     string     `json:"message"`
+	SentAt      *time.Time `swaggerignore:"true" json:"sent_at"`
+}
+
+// Struct defines HTTP request payload for creating rollout campaign
+type CreateRollout struct {
+	Name             string  `json:"name" binding:"required"`
+	FirmwareID       uint    `json:"firmware_id" binding:"required"`
+	TargetAreaID     string  `json:"target_area_id"`
+	TargetVersion    string  `json:"target_version"`
+	BatchSize        int     `json:"batch_size"`
+	FailureThreshold float64 `json:"failure_threshold"`
+	MinFinished      int     `json:"min_finished"`
+}
+
+// Struct defines HTTP request payload for controlling rollout campaign
+type RolloutCmd struct {
+	ID     uint   `json:"id" binding:"required"`
+	Action string `json:"action" binding:"required"` // start, pause, resume
+}
+
+// Rollout progress counted by target status
+type RolloutProgress map[string]int
+
+func (p RolloutProgress) Finished() int {
+	return p[TARGET_SUCCEEDED] + p[TARGET_FAILED]
+}
+
+func (p RolloutProgress) InFlight() int {
+	return p[TARGET_SENT] + p[TARGET_DOWNLOADING] + p[TARGET_INSTALLING]
+}
+
+func (p RolloutProgress) FailureRate() float64 {
+	if p.Finished() == 0 {
+		return 0
+	}
+	return float64(p[TARGET_FAILED]) / float64(p.Finished())
+}
+
+type RolloutSvc struct {
+	db *gorm.DB
+}
+
+func NewRolloutSvc(db *gorm.DB) *RolloutSvc {
+	return &RolloutSvc{
+		db: db,
+	}
+}
+
+func (rs *RolloutSvc) FindAllRollout(ctx context.Context) (rList []RolloutCampaign, err error) {
+	result := rs.db.WithContext(ctx).Preload("Firmware").Find(&rList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return rList, nil
+}
+
+func (rs *RolloutSvc) FindRolloutByID(ctx context.Context, id string) (r *RolloutCampaign, err error) {
+	result := rs.db.WithContext(ctx).Preload("Firmware").Preload("Targets").First(&r, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return r, nil
+}
+
+func (rs *RolloutSvc) FindRolloutsByStatus(ctx context.Context, status string) (rList []RolloutCampaign, err error) {
+	result := rs.db.WithContext(ctx).Preload("Firmware").Where("status = ?", status).Find(&rList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return rList, nil
+}
+
+// Create campaign and snapshot target gateways matching area and software version
+func (rs *RolloutSvc) CreateRollout(ctx context.Context, r *RolloutCampaign) (*RolloutCampaign, error) {
+	fw := &Firmware{}
+	if err := rs.db.WithContext(ctx).First(fw, r.FirmwareID).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+
+	query := rs.db.WithContext(ctx).Model(&Gateway{}).Where("(software_version IS NULL OR software_version <> ?)", fw.Version)
+	if r.TargetAreaID != "" {
+		query = query.Where("area_id = ?", r.TargetAreaID)
+	}
+	if r.TargetVersion != "" {
+		query = query.Where("software_version = ?", r.TargetVersion)
+	}
+	gwList := []Gateway{}
+	if err := query.Find(&gwList).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	if len(gwList) == 0 {
+		return nil, NewValidationError("target_area_id", "no gateway matches rollout target")
+	}
+
+	r.Status = ROLLOUT_PENDING
+	r.Targets = make([]RolloutTarget, 0, len(gwList))
+	for _, gw := range gwList {
+		r.Targets = append(r.Targets, RolloutTarget{
+			GatewayID:   gw.GatewayID,
+			FromVersion: gw.SoftwareVersion,
+			Status:      TARGET_PENDING,
+		})
+	}
+	if err := rs.db.WithContext(ctx).Create(&r).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	r.Firmware = fw
+	return r, nil
+}
+
+func (rs *RolloutSvc) UpdateRolloutStatus(ctx context.Context, id uint, status string, reason string) (bool, error) {
+	values := map[string]interface{}{
+		"status":      status,
+		"halt_reason": reason,
+	}
+	now := time.Now()
+	switch status {
+	case ROLLOUT_RUNNING:
+		values["started_at"] = gorm.Expr("COALESCE(started_at, ?)", now)
+	case ROLLOUT_HALTED, ROLLOUT_COMPLETED:
+		values["finished_at"] = now
+	}
+	result := rs.db.WithContext(ctx).Model(&RolloutCampaign{}).Where("id = ?", id).Updates(values)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (rs *RolloutSvc) DeleteRollout(ctx context.Context, id uint) (bool, error) {
+	r := &RolloutCampaign{}
+	if err := rs.db.WithContext(ctx).First(r, id).Error; err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	if r.Status == ROLLOUT_RUNNING {
+		return false, NewConflictError("rollout is running, pause it first")
+	}
+	if err := rs.db.WithContext(ctx).Where("campaign_id = ?", id).Delete(&RolloutTarget{}).Error; err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	result := rs.db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&RolloutCampaign{})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (rs *RolloutSvc) GetRolloutProgress(ctx context.Context, campaignId uint) (RolloutProgress, error) {
+	rows := []struct {
+		Status string
+		Cnt    int
+	}{}
+	result := rs.db.WithContext(ctx).Model(&RolloutTarget{}).Select("status, COUNT(*) AS cnt").
+		Where("campaign_id = ?", campaignId).Group("status").Scan(&rows)
+	if err := result.Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	progress := RolloutProgress{}
+	for _, row := range rows {
+		progress[row.Status] = row.Cnt
+	}
+	return progress, nil
+}
+
+func (rs *RolloutSvc) FindPendingTargets(ctx context.Context, campaignId uint, limit int) (tList []RolloutTarget, err error) {
+	result := rs.db.WithContext(ctx).Where("campaign_id = ? AND status = ?", campaignId, TARGET_PENDING).Order("id").Limit(limit).Find(&tList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return tList, nil
+}
+
+func (rs *RolloutSvc) MarkTargetSent(ctx context.Context, targetId uint) (bool, error) {
+	result := rs.db.WithContext(ctx).Model(&RolloutTarget{}).Where("id = ?", targetId).Updates(map[string]interface{}{
+		"status":  TARGET_SENT,
+		"sent_at": time.Now(),
+	})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Fail targets that were sent before deadline and never finished
+func (rs *RolloutSvc) ExpireTargets(ctx context.Context, campaignId uint, deadline time.Time) (int64, error) {
+	result := rs.db.WithContext(ctx).Model(&RolloutTarget{}).
+		Where("campaign_id = ? AND status IN ? AND sent_at < ?", campaignId,
+			[]string{TARGET_SENT, TARGET_DOWNLOADING, TARGET_INSTALLING}, deadline).
+		Updates(map[string]interface{}{
+			"status":  TARGET_FAILED,
+			"message": "timeout",
+		})
+	if err := result.Error; err != nil {
+		return 0, utils.HandleQueryError(err)
+	}
+	return result.RowsAffected, nil
+}
+
+// Apply a gateway upgrade report to its target in the given campaign, finished targets are left untouched
+func (rs *RolloutSvc) UpdateTargetStatus(ctx context.Context, campaignId uint, gwId string, status string, message string) (bool, error) {
+	result := rs.db.WithContext(ctx).Model(&RolloutTarget{}).
+		Where("campaign_id = ? AND gateway_id = ? AND status NOT IN ?", campaignId, gwId,
+			[]string{TARGET_SUCCEEDED, TARGET_FAILED}).
+		Updates(map[string]interface{}{
+			"status":  status,
+			"message": message,
+		})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Gateway booted with the firmware version, confirm in-progress targets of running campaigns
+func (rs *RolloutSvc) ConfirmGatewayVersion(ctx context.Context, gwId string, version string) error {
+	campaignIds := rs.db.WithContext(ctx).Model(&RolloutCampaign{}).Select("rollout_campaigns.id").
+		Joins("JOIN firmwares ON firmwares.id = rollout_campaigns.firmware_id").
+		Where("rollout_campaigns.status = ? AND firmwares.version = ?", ROLLOUT_RUNNING, version)
+	result := rs.db.WithContext(ctx).Model(&RolloutTarget{}).
+		Where("gateway_id = ? AND campaign_id IN (?) AND status IN ?", gwId, campaignIds,
+			[]string{TARGET_SENT, TARGET_DOWNLOADING, TARGET_INSTALLING}).
+		Updates(map[string]interface{}{
+			"status":  TARGET_SUCCEEDED,
+			"message": "confirmed on bootup",
+		})
+	return result.Error
+}
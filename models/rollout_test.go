@@ -0,0 +1,34 @@
+//go:build unit
+// +build unit
+
+package models
+
+import "testing"
+
+func TestRolloutProgress(t *testing.T) {
+	progress := RolloutProgress{
+		TARGET_PENDING:     3,
+		TARGET_SENT:        1,
+		TARGET_DOWNLOADING: 1,
+		TARGET_SUCCEEDED:   3,
+		TARGET_FAILED:      1,
+	}
+
+	if progress.Finished() != 4 {
+		t.Errorf("got %v, wanted %v", progress.Finished(), 4)
+	}
+	if progress.InFlight() != 2 {
+		t.Errorf("got %v, wanted %v", progress.InFlight(), 2)
+	}
+	if progress.FailureRate() != 0.25 {
+		t.Errorf("got %v, wanted %v", progress.FailureRate(), 0.25)
+	}
+}
+
+func TestRolloutProgressEmpty(t *testing.T) {
+	progress := RolloutProgress{}
+
+	if progress.FailureRate() != 0 {
+		t.Errorf("got %v, wanted %v", progress.FailureRate(), 0)
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// Version of the schema Migrate builds, bump it whenever models change
+// tables so readiness waits until the database is migrated
+const SCHEMA_VERSION int = 7
+
+// SchemaMigration records every schema version Migrate has applied
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+type SchemaSvc struct {
+	db *gorm.DB
+}
+
+func NewSchemaSvc(db *gorm.DB) *SchemaSvc {
+	return &SchemaSvc{
+		db: db,
+	}
+}
+
+func (ss *SchemaSvc) Ping(ctx context.Context) error {
+	sqlDb, err := ss.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.PingContext(ctx)
+}
+
+// SchemaVersion returns latest version migrated, 0 before first migration
+func (ss *SchemaSvc) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	result := ss.db.WithContext(ctx).Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version)
+	if err := result.Error; err != nil {
+		return 0, utils.HandleQueryError(err)
+	}
+	return version, nil
+}
+
+func recordSchemaVersion(db *gorm.DB) error {
+	m := SchemaMigration{Version: SCHEMA_VERSION}
+	return db.Where(&m).Attrs(SchemaMigration{AppliedAt: time.Now()}).FirstOrCreate(&m).Error
+}
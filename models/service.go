@@ -0,0 +1,245 @@
+package models
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Services are used through these interfaces so handlers and MQTT subscribers
+// can run against in-memory fakes, see package fakes
+
+// GatewayService manages gateways and their device twin
+type GatewayService interface {
+	FindAllGateway(ctx context.Context) ([]Gateway, error)
+	FindGatewaysBySelector(ctx context.Context, s BulkSelector) ([]Gateway, error)
+	FindGatewayByID(ctx context.Context, id string) (*Gateway, error)
+	FindGatewayByGatewayID(ctx context.Context, id string) (*Gateway, error)
+	FindGatewayTenant(ctx context.Context, id string) (string, uint, error)
+	CountGatewaysByConnectState(ctx context.Context) (map[string]int64, error)
+	UpdateGateway(ctx context.Context, g *Gateway) (bool, error)
+	DeleteGateway(ctx context.Context, gwID string, revision uint, trashedBy string) (bool, error)
+	DeleteGatewayUHF(ctx context.Context, gw *Gateway, d *UHF) (*Gateway, error)
+	UpdateGatewayConnectState(ctx context.Context, gwId string, state string) (bool, error)
+	CreateGateway(ctx context.Context, g *Gateway) (*Gateway, error)
+	FindDriftedGateways(ctx context.Context) ([]Gateway, error)
+	UpdateGatewayDesiredState(ctx context.Context, gwId string, state string) (bool, error)
+	UpdateGatewayReportedState(ctx context.Context, gwId string, state string) (bool, error)
+	MarkGatewaySyncAttempt(ctx context.Context, gwId string) (bool, error)
+}
+
+// AreaService manages areas UHFs are installed in
+type AreaService interface {
+	FindAllArea(ctx context.Context) ([]Area, error)
+	FindAreaByID(ctx context.Context, id string) (*Area, error)
+	CreateArea(a *Area, ctx context.Context) (*Area, error)
+	UpdateArea(ctx context.Context, a *Area) (bool, error)
+	DeleteArea(ctx context.Context, d *DeleteArea, revision uint) ([]UHF, error)
+}
+
+// LogService keeps state changes of gateways
+type LogService interface {
+	FindAllGatewayLog(ctx context.Context) ([]GatewayLog, error)
+	FindGatewayLogByID(ctx context.Context, id string) (*GatewayLog, error)
+	FindGatewayByGatewayID(ctx context.Context, gatewayId string) (*[]GatewayLog, error)
+	CreateGatewayLog(ctx context.Context, gl *GatewayLog) (*GatewayLog, error)
+	CreateGatewayLogs(ctx context.Context, glList []GatewayLog, batchSize int) error
+	FindGatewayLogsByGatewayIDAndTime(ctx context.Context, gatewayId string, from string, to string) (*[]GatewayLog, error)
+	FindGatewayLogsByTime(ctx context.Context, from string, to string) (*[]GatewayLog, error)
+	DeleteGatewayLogInTimeRange(ctx context.Context, from string, to string) (bool, error)
+}
+
+// UHFStatusLogService keeps state changes of UHFs
+type UHFStatusLogService interface {
+	GetAllUHFStatusLogs(ctx context.Context) ([]UHFStatusLog, error)
+	GetUHFStatusLogByID(ctx context.Context, id string) (*UHFStatusLog, error)
+	GetUHFStatusLogByUHFAddress(ctx context.Context, uhf_address string, gateway_id string) ([]UHFStatusLog, error)
+	CreateUHFStatusLog(ctx context.Context, dlsl *UHFStatusLog) (*UHFStatusLog, error)
+	CreateUHFStatusLogs(ctx context.Context, dlslList []UHFStatusLog, batchSize int) error
+	GetUHFStatusLogBYGatewayIDAndUHFAddressInTimeRange(ctx context.Context, from string, to string, gateway_id string, uhf_address string) (*[]UHFStatusLog, error)
+	GetUHFStatusLogInTimeRange(ctx context.Context, from string, to string) (*[]UHFStatusLog, error)
+	DeleteUHFLogInTimeRange(ctx context.Context, from string, to string) (bool, error)
+}
+
+// UHFService manages UHF readers and their device twin
+type UHFService interface {
+	FindAllUHF(ctx context.Context) ([]UHF, error)
+	FindUHFsBySelector(ctx context.Context, s BulkSelector) ([]UHF, error)
+	FindUHFByID(ctx context.Context, id string) (*UHF, error)
+	FindUHFByAddress(ctx context.Context, address string, gwID string) (*UHF, error)
+	UpdateUHF(ctx context.Context, dl *UHF) (bool, error)
+	UpdateUHFWithDesiredState(ctx context.Context, dl *UHF, state string) (bool, error)
+	UpdateUHFByAddress(ctx context.Context, dl *UHF) (bool, error)
+	DeleteUHF(ctx context.Context, id string, revision uint) (bool, error)
+	FindAllUHFByRoomID(ctx context.Context, roomId string) ([]*UHF, error)
+	FindAllUHFByGatewayID(ctx context.Context, gwId string) ([]UHF, error)
+	CreateUHF(ctx context.Context, dl *UHF) (*UHF, error)
+	FindDriftedUHFs(ctx context.Context) ([]UHF, error)
+	CountActiveUHFs(ctx context.Context) (int64, error)
+	UpdateUHFDesiredState(ctx context.Context, address string, gwID string, state string) (bool, error)
+	UpdateUHFReportedState(ctx context.Context, address string, gwID string, state string) (bool, error)
+	MarkUHFSyncAttempt(ctx context.Context, address string, gwID string) (bool, error)
+}
+
+// UserAccessService keeps user tag reads
+type UserAccessService interface {
+	CreateUserAccess(ctx context.Context, user_acesses *UserAccess) (*UserAccess, error)
+	CreateUserAccesses(ctx context.Context, user_acesses []UserAccess, batchSize int) error
+	FindAllUserAccess(ctx context.Context) ([]UserAccess, error)
+	FindAllUserAccessByUserID(ctx context.Context, id string) ([]UserAccess, error)
+	FindAllUserAccessByUserIDAndAreaID(ctx context.Context, id string, area_id string) ([]UserAccess, error)
+	FindUserAccessesByUserIDAndTimeRange(ctx context.Context, user_id string, from string, to string) (*[]UserAccess, error)
+	FindAllUserAccessByUserIDAndAreaIDinTimeRange(ctx context.Context, id string, area_id string, from string, to string) ([]UserAccess, error)
+	FindAllUserAccessByAreaID(ctx context.Context, area_id string) ([]UserAccess, error)
+	FindAllUserAccessByAreaIDAndTimeRange(ctx context.Context, area_id string, from string, to string) ([]UserAccess, error)
+	FindAllUserAccessTimeRange(ctx context.Context, from string, to string) ([]UserAccess, error)
+	CountUserAccessesByArea(ctx context.Context, since time.Time) (map[string]int64, error)
+	DeleteUserAccessTimeRange(ctx context.Context, from string, to string) (bool, error)
+}
+
+// PackageAccessService keeps package tag reads
+type PackageAccessService interface {
+	CreatePackageAccess(ctx context.Context, package_acesses *PackageAccess) (*PackageAccess, error)
+	CreatePackageAccesses(ctx context.Context, package_acesses []PackageAccess, batchSize int) error
+	FindAllPackageAccess(ctx context.Context) ([]PackageAccess, error)
+	FindAllPackageAccessByPackageID(ctx context.Context, id string) ([]PackageAccess, error)
+	FindPackageAccessByPackageIDAndTimeRange(ctx context.Context, package_id string, from string, to string) (*[]PackageAccess, error)
+	FindPackageAccessByPackageIDAndAreaID(ctx context.Context, package_id string, area_id string) (*[]PackageAccess, error)
+	FindAllPackageAccessByPackageIDAndAreaIDinTimeRange(ctx context.Context, package_id string, area_id string, from string, to string) (*[]PackageAccess, error)
+	FindAllUserAccessByAreaID(ctx context.Context, package_id string, area_id string, from string, to string) (*[]PackageAccess, error)
+	FindAllPackageAccessByAreaID(ctx context.Context, area_id string) (*[]PackageAccess, error)
+	FindAllPackageAccessByAreaIDAndTimeRange(ctx context.Context, area_id string, from string, to string) (*[]PackageAccess, error)
+	FindAllPackageAccessTimeRange(ctx context.Context, from string, to string) (*[]PackageAccess, error)
+	CountPackageAccessesByArea(ctx context.Context, since time.Time) (map[string]int64, error)
+	DeletePackageAccessTimeRange(ctx context.Context, from string, to string) (bool, error)
+}
+
+// SystemLogService keeps system logs of gateways
+type SystemLogService interface {
+	FindAllGatewayLog(ctx context.Context) ([]GatewayLog, error)
+	FindGatewayLogByID(ctx context.Context, id string) (*GatewayLog, error)
+	CreateSystemLog(ctx context.Context, gl *SystemLog) (*SystemLog, error)
+}
+
+// OperationLogService keeps operation logs gateways publish
+type OperationLogService interface {
+	GetAllOperationLogs(ctx context.Context) ([]OperationLog, error)
+	GetOperationLogByID(ctx context.Context, id string) (*OperationLog, error)
+	GetOperationLogByGatewayID(ctx context.Context, doorId string) ([]OperationLog, error)
+	FindOperationLogsByGatewayIDAndTime(ctx context.Context, gateway_id string, from string, to string) (*[]OperationLog, error)
+	FindOperationLogsByTime(ctx context.Context, from string, to string) (*[]OperationLog, error)
+	CreateOperationLog(ctx context.Context, dlsl *OperationLog) (*OperationLog, error)
+	CreateOperationLogs(ctx context.Context, dlslList []OperationLog, batchSize int) error
+	DeleteOperationLogInTimeRange(ctx context.Context, from string, to string) (bool, error)
+}
+
+// FirmwareService stores firmware artifacts
+type FirmwareService interface {
+	FindAllFirmware(ctx context.Context) ([]Firmware, error)
+	FindFirmwareByID(ctx context.Context, id string) (*Firmware, error)
+	CreateFirmware(ctx context.Context, fw *Firmware, artifact io.Reader) (*Firmware, error)
+	DeleteFirmware(ctx context.Context, id uint) (bool, error)
+}
+
+// RolloutService manages firmware rollout campaigns and their targets
+type RolloutService interface {
+	FindAllRollout(ctx context.Context) ([]RolloutCampaign, error)
+	FindRolloutByID(ctx context.Context, id string) (*RolloutCampaign, error)
+	FindRolloutsByStatus(ctx context.Context, status string) ([]RolloutCampaign, error)
+	CreateRollout(ctx context.Context, r *RolloutCampaign) (*RolloutCampaign, error)
+	UpdateRolloutStatus(ctx context.Context, id uint, status string, reason string) (bool, error)
+	DeleteRollout(ctx context.Context, id uint) (bool, error)
+	GetRolloutProgress(ctx context.Context, campaignId uint) (RolloutProgress, error)
+	FindPendingTargets(ctx context.Context, campaignId uint, limit int) ([]RolloutTarget, error)
+	MarkTargetSent(ctx context.Context, targetId uint) (bool, error)
+	ExpireTargets(ctx context.Context, campaignId uint, deadline time.Time) (int64, error)
+	UpdateTargetStatus(ctx context.Context, campaignId uint, gwId string, status string, message string) (bool, error)
+	ConfirmGatewayVersion(ctx context.Context, gwId string, version string) error
+}
+
+// UHFConfigService manages reader config templates and desired config of UHFs
+type UHFConfigService interface {
+	FindAllTemplate(ctx context.Context) ([]UHFConfigTemplate, error)
+	FindTemplateByID(ctx context.Context, id string) (*UHFConfigTemplate, error)
+	CreateTemplate(ctx context.Context, t *UHFConfigTemplate) (*UHFConfigTemplate, error)
+	UpdateTemplate(ctx context.Context, t *UHFConfigTemplate) (bool, error)
+	DeleteTemplate(ctx context.Context, id uint) (bool, error)
+	UpdateUHFDesiredConfig(ctx context.Context, uhfId uint, revision uint, config UHFReaderConfig, templateId *uint) (bool, error)
+	UpdateUHFReportedConfig(ctx context.Context, address string, gwID string, config UHFReaderConfig) (bool, error)
+	FindConfigMismatchedUHFs(ctx context.Context) ([]UHF, error)
+}
+
+// DeadLetterService keeps gateway messages ingestion rejected
+type DeadLetterService interface {
+	FindDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]DeadLetter, error)
+	FindDeadLetterByID(ctx context.Context, id string) (*DeadLetter, error)
+	CreateDeadLetter(ctx context.Context, dl *DeadLetter) (*DeadLetter, error)
+	MarkDeadLetterReplayed(ctx context.Context, id uint, reason string, detail string) (bool, error)
+	DeleteDeadLetter(ctx context.Context, id uint) (bool, error)
+}
+
+// TagReadErrorService keeps tags that could not be decoded
+type TagReadErrorService interface {
+	FindTagReadErrors(ctx context.Context, filter TagReadErrorFilter) ([]TagReadError, error)
+	CreateTagReadErrors(ctx context.Context, teList []TagReadError, batchSize int) error
+	DeleteTagReadErrorInTimeRange(ctx context.Context, from string, to string) (bool, error)
+}
+
+// TenantService manages organizations, sites and API keys
+type TenantService interface {
+	FindAllOrganization(ctx context.Context) ([]Organization, error)
+	FindDefaultOrganization(ctx context.Context) (*Organization, error)
+	CreateOrganization(ctx context.Context, org *Organization) (*Organization, error)
+	UpdateOrganization(ctx context.Context, org *Organization) (bool, error)
+	DeleteOrganization(ctx context.Context, orgId uint) (bool, error)
+	FindAllSite(ctx context.Context) ([]Site, error)
+	FindSiteByCode(ctx context.Context, code string) (*Site, error)
+	CreateSite(ctx context.Context, site *Site) (*Site, error)
+	UpdateSite(ctx context.Context, site *Site) (bool, error)
+	DeleteSite(ctx context.Context, siteId uint) (bool, error)
+	FindAllApiKey(ctx context.Context) ([]ApiKey, error)
+	CreateApiKey(ctx context.Context, key *ApiKey) (*CreatedApiKey, error)
+	DeleteApiKey(ctx context.Context, keyId uint) (bool, error)
+	AuthenticateApiKey(ctx context.Context, raw string) (*ApiKey, error)
+}
+
+// SchemaService reports database health and schema version
+type SchemaService interface {
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (int, error)
+}
+
+// ImportService applies bulk imports of areas, gateways and UHF assignments
+type ImportService interface {
+	ApplyImport(ctx context.Context, plan *ImportPlan) (*ImportResult, error)
+}
+
+type TrashService interface {
+	FindTrash(ctx context.Context) (*Trash, error)
+	RestoreArea(ctx context.Context, id string) (*Area, error)
+	FindTrashedGateway(ctx context.Context, gwId string) (*Gateway, error)
+	RestoreGateway(ctx context.Context, gwId string) (*Gateway, error)
+	RestoreUHF(ctx context.Context, id string) (*UHF, error)
+	PurgeTrash(ctx context.Context, item *TrashItem) (bool, error)
+}
+
+var (
+	_ GatewayService       = (*GatewaySvc)(nil)
+	_ AreaService          = (*AreaSvc)(nil)
+	_ LogService           = (*LogSvc)(nil)
+	_ UHFStatusLogService  = (*UHFStatusLogSvc)(nil)
+	_ UHFService           = (*UHFSvc)(nil)
+	_ UserAccessService    = (*UserAccessSvc)(nil)
+	_ PackageAccessService = (*PackageAccessSvc)(nil)
+	_ SystemLogService     = (*SystemLogSvc)(nil)
+	_ OperationLogService  = (*OperationLogSvc)(nil)
+	_ FirmwareService      = (*FirmwareSvc)(nil)
+	_ RolloutService       = (*RolloutSvc)(nil)
+	_ UHFConfigService     = (*UHFConfigSvc)(nil)
+	_ DeadLetterService    = (*DeadLetterSvc)(nil)
+	_ TagReadErrorService  = (*TagReadErrorSvc)(nil)
+	_ TenantService        = (*TenantSvc)(nil)
+	_ SchemaService        = (*SchemaSvc)(nil)
+	_ ImportService        = (*ImportSvc)(nil)
+	_ TrashService         = (*TrashSvc)(nil)
+)
@@ -8,6 +8,7 @@ type SwagCreateGateway struct {
 
 type SwagUpateGateway struct {
 	SwagCreateGateway
+	DesiredState string `json:"desired_state"`
 }
 
 type SwagCreateArea struct {
@@ -9,6 +9,7 @@ import (
 
 type SystemLog struct {
 	GormModel
+	TenantModel
 	GatewayID string `json:"gatewayId"`
 	LogType   string `json:"-"` // info, warn, debug, error, fatal
 	Content   string `json:"content"`
@@ -25,7 +26,7 @@ func NewSystemLogSvc(db *gorm.DB) *SystemLogSvc {
 }
 
 func (ls *SystemLogSvc) FindAllGatewayLog(ctx context.Context) (glList []GatewayLog, err error) {
-	result := ls.db.Find(&glList)
+	result := ls.db.WithContext(ctx).Find(&glList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -34,7 +35,7 @@ func (ls *SystemLogSvc) FindAllGatewayLog(ctx context.Context) (glList []Gateway
 }
 
 func (ls *SystemLogSvc) FindGatewayLogByID(ctx context.Context, id string) (gl *GatewayLog, err error) {
-	result := ls.db.Preload("Doorlocks").First(&gl, id)
+	result := ls.db.WithContext(ctx).Preload("Doorlocks").First(&gl, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -43,7 +44,7 @@ func (ls *SystemLogSvc) FindGatewayLogByID(ctx context.Context, id string) (gl *
 }
 
 func (ls *SystemLogSvc) CreateSystemLog(ctx context.Context, gl *SystemLog) (*SystemLog, error) {
-	if err := ls.db.Create(&gl).Error; err != nil {
+	if err := ls.db.WithContext(ctx).Create(&gl).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// TagReadError keeps a tag read whose memory could not be decoded
+type TagReadError struct {
+	TenantModel
+	ID         uint      `gorm:"primarykey;" json:"id"`
+	Time       time.Time `swaggerignore:"true" json:"time"`
+	GatewayID  string    `gorm:"type:varchar(256);index" json:"gateway_id"`
+	UHFAddress string    `gorm:"type:varchar(256);" json:"uhf_address"`
+	AreaID     string    `gorm:"type:varchar(256);" json:"area_id"`
+	EPC        string    `gorm:"type:varchar(256);" json:"epc"`
+	Mem        string    `gorm:"type:nvarchar(max);" json:"mem"`
+	Reason     string    `gorm:"type:varchar(64);index" json:"reason"`
+	Detail     string    `json:"detail"`
+}
+
+// Struct defines HTTP query to filter tag read errors
+type TagReadErrorFilter struct {
+	GatewayID string `form:"gateway_id"`
+	Reason    string `form:"reason"`
+}
+
+type TagReadErrorSvc struct {
+	db *gorm.DB
+}
+
+func NewTagReadErrorSvc(db *gorm.DB) *TagReadErrorSvc {
+	return &TagReadErrorSvc{
+		db: db,
+	}
+}
+
+func (ts *TagReadErrorSvc) FindTagReadErrors(ctx context.Context, filter TagReadErrorFilter) (teList []TagReadError, err error) {
+	query := ts.db.WithContext(ctx).Order("time DESC")
+	if filter.GatewayID != "" {
+		query = query.Where("gateway_id = ?", filter.GatewayID)
+	}
+	if filter.Reason != "" {
+		query = query.Where("reason = ?", filter.Reason)
+	}
+	result := query.Find(&teList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return teList, nil
+}
+
+func (ts *TagReadErrorSvc) CreateTagReadErrors(ctx context.Context, teList []TagReadError, batchSize int) error {
+	if len(teList) == 0 {
+		return nil
+	}
+	if err := ts.db.WithContext(ctx).CreateInBatches(teList, batchSize).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	return nil
+}
+
+func (ts *TagReadErrorSvc) DeleteTagReadErrorInTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	result := ts.db.WithContext(ctx).Unscoped().Where("time >= ? AND time <= ?", from, to).Delete(&TagReadError{})
+	return utils.ReturnBoolStateFromResult(result)
+}
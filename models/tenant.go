@@ -0,0 +1,400 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Organization existing records are moved to when tenants are introduced,
+// and gateways booting without a site belong to
+const DEFAULT_ORGANIZATION_NAME string = "default"
+
+var ErrNoTenant = errors.New("no tenant in context")
+
+// Organization is a tenant, every TenantModel belongs to exactly one
+type Organization struct {
+	GormModel
+	Name        string `gorm:"type:nvarchar(256);unique;not null" json:"name"`
+	Description string `json:"description"`
+}
+
+// Site is a campus of an organization, its code is the site of MQTT topics
+type Site struct {
+	GormModel
+	TenantModel
+	Code string `gorm:"type:varchar(64);unique;not null" json:"code"`
+	Name string `json:"name"`
+}
+
+// ApiKey authenticates requests as its organization, only its hash is kept
+type ApiKey struct {
+	GormModel
+	TenantModel
+	Name       string     `json:"name"`
+	Prefix     string     `gorm:"type:varchar(16)" json:"prefix"` // first characters of key to tell keys apart
+	KeyHash    string     `gorm:"type:varchar(64);unique;not null" json:"-"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Struct defines HTTP response payload of a created API key, Key is only shown once
+type CreatedApiKey struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+// TenantModel scopes a model to an organization. Queries on models embedding
+// it only see rows of the organization in context, see WithTenant.
+type TenantModel struct {
+	OrganizationID uint `gorm:"index" json:"organization_id"`
+}
+
+// Tenant is the organization queries run for. System tenants see every
+// organization, they are used by background jobs and administrators.
+type Tenant struct {
+	OrganizationID uint
+	System         bool
+}
+
+type tenantCtxKey struct{}
+
+func WithTenant(ctx context.Context, orgId uint) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, Tenant{OrganizationID: orgId})
+}
+
+func WithSystemTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, Tenant{System: true})
+}
+
+func TenantFromContext(ctx context.Context) (Tenant, bool) {
+	if ctx == nil {
+		return Tenant{}, false
+	}
+	t, ok := ctx.Value(tenantCtxKey{}).(Tenant)
+	return t, ok
+}
+
+// RegisterTenantCallbacks makes every query on a TenantModel filter by
+// organization of its context and every create stamp it. Statements
+// without tenant in context fail with ErrNoTenant.
+func RegisterTenantCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("tenant:query", tenantWhere); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("tenant:row", tenantWhere); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:update", tenantUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tenant:delete", tenantWhere); err != nil {
+		return err
+	}
+	return cb.Create().Before("gorm:create").Register("tenant:create", tenantCreate)
+}
+
+func tenantField(db *gorm.DB) (string, bool) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return "", false
+	}
+	field := db.Statement.Schema.LookUpField("OrganizationID")
+	if field == nil {
+		return "", false
+	}
+	return field.DBName, true
+}
+
+func tenantWhere(db *gorm.DB) {
+	column, ok := tenantField(db)
+	if !ok {
+		return
+	}
+	t, ok := TenantFromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(fmt.Errorf("%w: %s", ErrNoTenant, db.Statement.Table))
+		return
+	}
+	if t.System {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: db.Statement.Table, Name: column}, Value: t.OrganizationID},
+	}})
+}
+
+// tenantUpdate keeps tenants from moving rows to another organization
+func tenantUpdate(db *gorm.DB) {
+	tenantWhere(db)
+	column, ok := tenantField(db)
+	if !ok {
+		return
+	}
+	if t, _ := TenantFromContext(db.Statement.Context); !t.System {
+		db.Statement.Omits = append(db.Statement.Omits, column)
+	}
+}
+
+func tenantCreate(db *gorm.DB) {
+	if _, ok := tenantField(db); !ok {
+		return
+	}
+	t, ok := TenantFromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(fmt.Errorf("%w: %s", ErrNoTenant, db.Statement.Table))
+		return
+	}
+	field := db.Statement.Schema.LookUpField("OrganizationID")
+	stamp := func(rv reflect.Value) {
+		if t.System {
+			// system creates on behalf of an organization, it must be set
+			if _, zero := field.ValueOf(rv); zero {
+				db.AddError(fmt.Errorf("%w: %s without organization_id", ErrNoTenant, db.Statement.Table))
+			}
+			return
+		}
+		db.AddError(field.Set(rv, t.OrganizationID))
+	}
+	switch rv := reflect.Indirect(db.Statement.ReflectValue); rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			stamp(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		stamp(rv)
+	}
+}
+
+// Models scoped to an organization, existing rows are moved to default organization
+var tenantModels = []interface{}{
+	&Site{},
+	&ApiKey{},
+	&Area{},
+	&Gateway{},
+	&UHF{},
+	&GatewayLog{},
+	&UserAccess{},
+	&PackageAccess{},
+	&SystemLog{},
+	&UHFStatusLog{},
+	&OperationLog{},
+	&UHFConfigTemplate{},
+	&TagReadError{},
+}
+
+// Models whose name is unique in its organization only, with the index
+// making it so. TenantModel is shared and can't carry the composite index tag.
+var tenantUniqueNames = []struct {
+	model interface{}
+	index string
+}{
+	{&Area{}, "idx_areas_organization_name"},
+	{&UHFConfigTemplate{}, "idx_uhf_config_templates_organization_name"},
+}
+
+// MigrateTenantNames replaces unique constraints on name, from before models
+// were scoped to organizations, with a unique index on organization and name
+func MigrateTenantNames(db *gorm.DB) error {
+	for _, u := range tenantUniqueNames {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(u.model); err != nil {
+			return err
+		}
+		var constraints []string
+		err := db.Raw(`SELECT kc.name FROM sys.key_constraints kc
+			JOIN sys.index_columns ic ON ic.object_id = kc.parent_object_id AND ic.index_id = kc.unique_index_id
+			JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+			WHERE kc.type = 'UQ' AND kc.parent_object_id = OBJECT_ID(?) AND c.name = 'name'`, stmt.Schema.Table).
+			Scan(&constraints).Error
+		if err != nil {
+			return err
+		}
+		for _, name := range constraints {
+			if err := db.Migrator().DropConstraint(u.model, name); err != nil {
+				return err
+			}
+		}
+		if db.Migrator().HasIndex(u.model, u.index) {
+			continue
+		}
+		err = db.Exec("CREATE UNIQUE INDEX ? ON ? (organization_id, name)",
+			clause.Column{Name: u.index}, clause.Table{Name: stmt.Schema.Table}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateTenants creates default organization and gives it rows without organization
+func MigrateTenants(db *gorm.DB) error {
+	ctx := WithSystemTenant(context.Background())
+	org := Organization{Name: DEFAULT_ORGANIZATION_NAME}
+	if err := db.WithContext(ctx).Where(&org).FirstOrCreate(&org).Error; err != nil {
+		return err
+	}
+	for _, m := range tenantModels {
+		err := db.WithContext(ctx).Model(m).
+			Where("organization_id IS NULL OR organization_id = 0").
+			Update("organization_id", org.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type TenantSvc struct {
+	db *gorm.DB
+}
+
+func NewTenantSvc(db *gorm.DB) *TenantSvc {
+	return &TenantSvc{
+		db: db,
+	}
+}
+
+func (ts *TenantSvc) FindAllOrganization(ctx context.Context) (orgList []Organization, err error) {
+	query := ts.db.WithContext(ctx)
+	if t, _ := TenantFromContext(ctx); !t.System {
+		query = query.Where("id = ?", t.OrganizationID)
+	}
+	result := query.Find(&orgList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return orgList, nil
+}
+
+func (ts *TenantSvc) FindDefaultOrganization(ctx context.Context) (org *Organization, err error) {
+	result := ts.db.WithContext(ctx).Where("name = ?", DEFAULT_ORGANIZATION_NAME).First(&org)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return org, nil
+}
+
+func (ts *TenantSvc) CreateOrganization(ctx context.Context, org *Organization) (*Organization, error) {
+	if err := ts.db.WithContext(ctx).Create(&org).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return org, nil
+}
+
+func (ts *TenantSvc) UpdateOrganization(ctx context.Context, org *Organization) (bool, error) {
+	result := ts.db.WithContext(ctx).Model(&org).Where("id = ?", org.ID).Updates(org)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Organization is only deleted once it owns no site or gateway, gateways in
+// trash included
+func (ts *TenantSvc) DeleteOrganization(ctx context.Context, orgId uint) (bool, error) {
+	orgCtx := WithTenant(ctx, orgId)
+	var cnt int64
+	ts.db.WithContext(orgCtx).Model(&Site{}).Count(&cnt)
+	if cnt > 0 {
+		return false, NewConflictError("organization still has %d sites", cnt)
+	}
+	ts.db.WithContext(orgCtx).Unscoped().Model(&Gateway{}).Count(&cnt)
+	if cnt > 0 {
+		return false, NewConflictError("organization still has %d gateways", cnt)
+	}
+	ts.db.WithContext(orgCtx).Unscoped().Where("1 = 1").Delete(&ApiKey{})
+	result := ts.db.WithContext(ctx).Unscoped().Where("id = ?", orgId).Delete(&Organization{})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (ts *TenantSvc) FindAllSite(ctx context.Context) (siteList []Site, err error) {
+	result := ts.db.WithContext(ctx).Find(&siteList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return siteList, nil
+}
+
+func (ts *TenantSvc) FindSiteByCode(ctx context.Context, code string) (site *Site, err error) {
+	result := ts.db.WithContext(ctx).Where("code = ?", code).First(&site)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return site, nil
+}
+
+func (ts *TenantSvc) CreateSite(ctx context.Context, site *Site) (*Site, error) {
+	if err := ts.db.WithContext(ctx).Create(&site).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return site, nil
+}
+
+func (ts *TenantSvc) UpdateSite(ctx context.Context, site *Site) (bool, error) {
+	result := ts.db.WithContext(ctx).Model(&site).Where("id = ?", site.ID).Updates(site)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (ts *TenantSvc) DeleteSite(ctx context.Context, siteId uint) (bool, error) {
+	result := ts.db.WithContext(ctx).Unscoped().Where("id = ?", siteId).Delete(&Site{})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (ts *TenantSvc) FindAllApiKey(ctx context.Context) (keyList []ApiKey, err error) {
+	result := ts.db.WithContext(ctx).Find(&keyList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return keyList, nil
+}
+
+// CreateApiKey generates a random key, only its hash is stored
+func (ts *TenantSvc) CreateApiKey(ctx context.Context, key *ApiKey) (*CreatedApiKey, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	raw := hex.EncodeToString(b)
+	key.Prefix = raw[:8]
+	key.KeyHash = HashApiKey(raw)
+	if err := ts.db.WithContext(ctx).Create(&key).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return &CreatedApiKey{ApiKey: *key, Key: raw}, nil
+}
+
+func (ts *TenantSvc) DeleteApiKey(ctx context.Context, keyId uint) (bool, error) {
+	result := ts.db.WithContext(ctx).Unscoped().Where("id = ?", keyId).Delete(&ApiKey{})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// AuthenticateApiKey finds key of any organization, it runs before tenant is known
+func (ts *TenantSvc) AuthenticateApiKey(ctx context.Context, raw string) (key *ApiKey, err error) {
+	ctx = WithSystemTenant(ctx)
+	result := ts.db.WithContext(ctx).Where("key_hash = ?", HashApiKey(raw)).First(&key)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	ts.db.WithContext(ctx).Model(&ApiKey{}).Where("id = ?", key.ID).Update("last_used_at", time.Now())
+	return key, nil
+}
+
+func HashApiKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
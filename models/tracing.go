@@ -0,0 +1,122 @@
+package models
+
+import (
+	"reflect"
+
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const tracingSpanKey = "tracing:span"
+
+// CorrelationModel ties a log row to the request or gateway message that wrote it
+type CorrelationModel struct {
+	CorrelationID string `gorm:"type:varchar(64);index" json:"correlation_id,omitempty"`
+}
+
+// RegisterTracingCallbacks runs every statement in a span of its context and
+// stamps CorrelationID of created records from context
+func RegisterTracingCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("*").Register("tracing:before_create", tracingStart("create")); err != nil {
+		return err
+	}
+	if err := cb.Create().Before("gorm:create").Register("tracing:correlation", correlationCreate); err != nil {
+		return err
+	}
+	if err := cb.Create().After("*").Register("tracing:after_create", tracingEnd); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("*").Register("tracing:before_query", tracingStart("query")); err != nil {
+		return err
+	}
+	if err := cb.Query().After("*").Register("tracing:after_query", tracingEnd); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("*").Register("tracing:before_update", tracingStart("update")); err != nil {
+		return err
+	}
+	if err := cb.Update().After("*").Register("tracing:after_update", tracingEnd); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("*").Register("tracing:before_delete", tracingStart("delete")); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("*").Register("tracing:after_delete", tracingEnd); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("*").Register("tracing:before_row", tracingStart("row")); err != nil {
+		return err
+	}
+	if err := cb.Row().After("*").Register("tracing:after_row", tracingEnd); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("*").Register("tracing:before_raw", tracingStart("raw")); err != nil {
+		return err
+	}
+	return cb.Raw().After("*").Register("tracing:after_raw", tracingEnd)
+}
+
+func tracingStart(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		// Statements outside any trace would only start new root spans
+		if !trace.SpanContextFromContext(db.Statement.Context).IsValid() {
+			return
+		}
+		_, span := tracing.Start(db.Statement.Context, "db."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("db.system", "mssql"), attribute.String("db.operation", operation)))
+		db.InstanceSet(tracingSpanKey, span)
+	}
+}
+
+func tracingEnd(db *gorm.DB) {
+	v, ok := db.InstanceGet(tracingSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	if db.Error != nil {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+	span.End()
+}
+
+// Records of batches carry their own ID, only empty ones take ID of context
+func correlationCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField("CorrelationID")
+	id := tracing.CorrelationID(db.Statement.Context)
+	if field == nil || id == "" {
+		return
+	}
+	stamp := func(rv reflect.Value) {
+		if _, zero := field.ValueOf(rv); zero {
+			db.AddError(field.Set(rv, id))
+		}
+	}
+	switch rv := reflect.Indirect(db.Statement.ReflectValue); rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			stamp(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		stamp(rv)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
+)
+
+func TestTracingStampsCorrelationID(t *testing.T) {
+	db := newDryRunDb(t)
+	if err := RegisterTracingCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	ctx := tracing.WithCorrelationID(WithSystemTenant(context.Background()), "req-1")
+
+	logs := []UHFStatusLog{
+		{TenantModel: TenantModel{OrganizationID: 1}},
+		{TenantModel: TenantModel{OrganizationID: 1}, CorrelationModel: CorrelationModel{CorrelationID: "gw-1"}},
+	}
+	if err := db.WithContext(ctx).CreateInBatches(&logs, 10).Error; err != nil {
+		t.Fatal(err)
+	}
+	if logs[0].CorrelationID != "req-1" || logs[1].CorrelationID != "gw-1" {
+		t.Errorf("got %s and %s, wanted req-1 of context and gw-1 kept",
+			logs[0].CorrelationID, logs[1].CorrelationID)
+	}
+}
+
+func TestTracingStampsGatewayLog(t *testing.T) {
+	db := newDryRunDb(t)
+	if err := RegisterTracingCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	ctx := tracing.WithCorrelationID(WithSystemTenant(context.Background()), "req-1")
+
+	gl := &GatewayLog{TenantModel: TenantModel{OrganizationID: 1}, GatewayID: "gw01"}
+	if err := db.WithContext(ctx).Create(gl).Error; err != nil {
+		t.Fatal(err)
+	}
+	logs := []GatewayLog{
+		{TenantModel: TenantModel{OrganizationID: 1}},
+		{TenantModel: TenantModel{OrganizationID: 1}, CorrelationModel: CorrelationModel{CorrelationID: "gw-1"}},
+	}
+	if err := db.WithContext(ctx).CreateInBatches(&logs, 10).Error; err != nil {
+		t.Fatal(err)
+	}
+	if gl.CorrelationID != "req-1" || logs[0].CorrelationID != "req-1" || logs[1].CorrelationID != "gw-1" {
+		t.Errorf("got %s, %s and %s, wanted req-1 of context twice and gw-1 kept",
+			gl.CorrelationID, logs[0].CorrelationID, logs[1].CorrelationID)
+	}
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// Kinds of records in trash
+const (
+	TRASH_AREA    string = "area"
+	TRASH_GATEWAY string = "gateway"
+	TRASH_UHF     string = "uhf"
+)
+
+// Who moved a gateway to trash. Only gateways the system trashed when they
+// shut down come back by themselves when they boot up again.
+const (
+	TRASHED_BY_SYSTEM   string = "system"
+	TRASHED_BY_OPERATOR string = "operator"
+)
+
+// TrashItem names a record in trash, ID is gateway_id for gateways
+type TrashItem struct {
+	Type string `json:"type" binding:"required"`
+	ID   string `json:"id" binding:"required"`
+}
+
+func (ti *TrashItem) Validate() error {
+	switch ti.Type {
+	case TRASH_AREA, TRASH_GATEWAY, TRASH_UHF:
+		return nil
+	}
+	return NewValidationError("type", "type must be %s, %s or %s", TRASH_AREA, TRASH_GATEWAY, TRASH_UHF)
+}
+
+// Trash lists deleted records that can still be restored
+type Trash struct {
+	Areas    []Area    `json:"areas"`
+	Gateways []Gateway `json:"gateways"`
+	UHFs     []UHF     `json:"uhfs"`
+}
+
+type TrashSvc struct {
+	db *gorm.DB
+}
+
+func NewTrashSvc(db *gorm.DB) *TrashSvc {
+	return &TrashSvc{
+		db: db,
+	}
+}
+
+// trashed limits a query to deleted rows
+func trashed(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+func (ts *TrashSvc) FindTrash(ctx context.Context) (*Trash, error) {
+	t := &Trash{}
+	db := ts.db.WithContext(ctx).Scopes(trashed).Order("deleted_at DESC")
+	if err := db.Find(&t.Areas).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	if err := db.Find(&t.Gateways).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	if err := db.Find(&t.UHFs).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return t, nil
+}
+
+// findTrashed loads model out of trash, a live or unknown record is not found
+func findTrashed(tx *gorm.DB, model interface{}, what string, query string, id string) error {
+	err := tx.Scopes(trashed).Where(query, id).First(model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NewNotFoundError("%s %s is not in trash", what, id)
+	}
+	return err
+}
+
+func (ts *TrashSvc) RestoreArea(ctx context.Context, id string) (*Area, error) {
+	a := &Area{}
+	err := ts.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := findTrashed(tx, a, TRASH_AREA, "id = ?", id); err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(a).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	a = &Area{}
+	if err := ts.db.WithContext(ctx).Where("id = ?", id).First(a).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return a, nil
+}
+
+// FindTrashedGateway loads gateway gwId from trash, a live or unknown gateway
+// is not found
+func (ts *TrashSvc) FindTrashedGateway(ctx context.Context, gwId string) (*Gateway, error) {
+	gw := &Gateway{}
+	if err := findTrashed(ts.db.WithContext(ctx), gw, TRASH_GATEWAY, "gateway_id = ?", gwId); err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return gw, nil
+}
+
+// RestoreGateway brings back gateway gwId with UHFs deleted along with it,
+// UHFs deleted before the gateway stay in trash
+func (ts *TrashSvc) RestoreGateway(ctx context.Context, gwId string) (*Gateway, error) {
+	gw := &Gateway{}
+	err := ts.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := findTrashed(tx, gw, TRASH_GATEWAY, "gateway_id = ?", gwId); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&UHF{}).
+			Where("gateway_id = ? AND deleted_at = ?", gwId, gw.DeletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(gw).Updates(map[string]interface{}{"deleted_at": nil, "trashed_by": ""}).Error
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	gw = &Gateway{}
+	if err := ts.db.WithContext(ctx).Preload("UHFs").Where("gateway_id = ?", gwId).First(gw).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return gw, nil
+}
+
+// RestoreUHF brings back UHF id to its gateway, which must be live and not
+// have got another UHF at the same address meanwhile
+func (ts *TrashSvc) RestoreUHF(ctx context.Context, id string) (*UHF, error) {
+	uhf := &UHF{}
+	err := ts.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := findTrashed(tx, uhf, TRASH_UHF, "id = ?", id); err != nil {
+			return err
+		}
+		var cnt int64
+		if err := tx.Model(&Gateway{}).Where("gateway_id = ?", uhf.GatewayID).Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt == 0 {
+			return NewConflictError("gateway %s of UHF %s is not live, restore it first", uhf.GatewayID, id)
+		}
+		if err := tx.Model(&UHF{}).Where("gateway_id = ? AND uhf_address = ?", uhf.GatewayID, uhf.UHFAddress).Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt > 0 {
+			return NewConflictError("gateway %s already has a UHF at address %s", uhf.GatewayID, uhf.UHFAddress)
+		}
+		return tx.Unscoped().Model(uhf).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	uhf = &UHF{}
+	if err := ts.db.WithContext(ctx).Where("id = ?", id).First(uhf).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return uhf, nil
+}
+
+// PurgeTrash deletes item for good, a gateway takes the UHFs deleted along
+// with it
+func (ts *TrashSvc) PurgeTrash(ctx context.Context, item *TrashItem) (bool, error) {
+	if err := item.Validate(); err != nil {
+		return false, err
+	}
+	err := ts.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		switch item.Type {
+		case TRASH_AREA:
+			a := &Area{}
+			if err := findTrashed(tx, a, item.Type, "id = ?", item.ID); err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(a).Error
+		case TRASH_GATEWAY:
+			gw := &Gateway{}
+			if err := findTrashed(tx, gw, item.Type, "gateway_id = ?", item.ID); err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("gateway_id = ? AND deleted_at = ?", gw.GatewayID, gw.DeletedAt).Delete(&UHF{}).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(gw).Error
+		default:
+			uhf := &UHF{}
+			if err := findTrashed(tx, uhf, item.Type, "id = ?", item.ID); err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(uhf).Error
+		}
+	})
+	if err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,59 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSoftDelete(t *testing.T) {
+	db := newDryRunDb(t)
+	ctx := WithTenant(context.Background(), 7)
+
+	stmt := db.WithContext(ctx).Where("gateway_id = ?", "gw").Delete(&Gateway{}).Statement
+	sql := stmt.SQL.String()
+	if !strings.HasPrefix(sql, `UPDATE "gateways" SET "deleted_at"=@p1`) || !strings.Contains(sql, `"gateways"."deleted_at" IS NULL`) {
+		t.Errorf("delete: got %s, wanted live gateway moved to trash", sql)
+	}
+	stmt = db.WithContext(ctx).Find(&[]UHF{}).Statement
+	if !strings.Contains(stmt.SQL.String(), `"uhfs"."deleted_at" IS NULL`) {
+		t.Errorf("query: got %s, wanted trash skipped", stmt.SQL.String())
+	}
+	stmt = db.WithContext(ctx).Scopes(trashed).Find(&[]Area{}).Statement
+	sql = stmt.SQL.String()
+	if !strings.Contains(sql, "deleted_at IS NOT NULL") || strings.Contains(sql, "deleted_at\" IS NULL") || !strings.Contains(sql, "organization_id") {
+		t.Errorf("trash: got %s, wanted only trash of organization", sql)
+	}
+}
+
+func TestDeleteAreaValidate(t *testing.T) {
+	cases := []struct {
+		d     DeleteArea
+		field string
+	}{
+		{DeleteArea{ID: 1}, ""},
+		{DeleteArea{ID: 1, Devices: AREA_DEVICES_UNASSIGN}, ""},
+		{DeleteArea{ID: 1, Devices: AREA_DEVICES_REASSIGN, AreaID: "2"}, ""},
+		{DeleteArea{ID: 1, Devices: AREA_DEVICES_REASSIGN}, "area_id"},
+		{DeleteArea{ID: 1, Devices: AREA_DEVICES_REASSIGN, AreaID: "1"}, "area_id"},
+		{DeleteArea{ID: 1, Devices: AREA_DEVICES_REASSIGN, AreaID: "hall"}, "area_id"},
+		{DeleteArea{ID: 1, Devices: "cascade"}, "devices"},
+	}
+	for _, c := range cases {
+		err := c.d.Validate()
+		if c.field == "" {
+			if err != nil {
+				t.Errorf("%+v: got %v, wanted valid", c.d, err)
+			}
+			continue
+		}
+		var de *DomainError
+		if !errors.As(err, &de) || len(de.Fields) != 1 || de.Fields[0].Field != c.field {
+			t.Errorf("%+v: got %v, wanted invalid %s", c.d, err, c.field)
+		}
+	}
+}
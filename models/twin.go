@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeviceTwin keeps the state the server wants a device to be in apart from
+// the state the device last reported over MQTT, so a REST update is never
+// mistaken for a change the gateway actually applied.
+type DeviceTwin struct {
+	DesiredState  string     `json:"desired_state"`
+	ReportedState string     `json:"reported_state"`
+	ReportedAt    *time.Time `swaggerignore:"true" json:"reported_at"`
+	LastSyncAt    *time.Time `swaggerignore:"true" json:"last_sync_at"`
+	SyncAttempts  int        `json:"sync_attempts"`
+	InSync        bool       `gorm:"-" json:"in_sync"`
+}
+
+// Drifted reports whether the device has not yet applied its desired state.
+// A device without a desired state is never considered drifted, neither is
+// one that never reported a state: its firmware predates "state" in MQTT
+// messages, so what it applied is unknown rather than different.
+func (t *DeviceTwin) Drifted() bool {
+	return t.DesiredState != "" && t.ReportedAt != nil && t.DesiredState != t.ReportedState
+}
+
+func (t *DeviceTwin) AfterFind(tx *gorm.DB) error {
+	t.InSync = !t.Drifted()
+	return nil
+}
+
+const driftedQuery = "desired_state <> '' AND reported_at IS NOT NULL AND (reported_state IS NULL OR desired_state <> reported_state)"
+
+// syncAttemptsOnReport resets sync attempts of a device reporting state only
+// when it is the desired one. Resetting on any report would let a device
+// stuck in another state retry forever.
+func syncAttemptsOnReport(state string) clause.Expr {
+	return gorm.Expr("CASE WHEN desired_state = ? THEN 0 ELSE sync_attempts END", state)
+}
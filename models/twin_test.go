@@ -0,0 +1,53 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceTwinDrifted(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		twin     DeviceTwin
+		expected bool
+	}{
+		{DeviceTwin{}, false},
+		{DeviceTwin{DesiredState: "active", ReportedAt: &now}, true},
+		{DeviceTwin{DesiredState: "active", ReportedState: "inactive", ReportedAt: &now}, true},
+		{DeviceTwin{DesiredState: "active", ReportedState: "active", ReportedAt: &now}, false},
+		{DeviceTwin{ReportedState: "inactive", ReportedAt: &now}, false},
+		// firmware without "state" never reports, drift is unknown
+		{DeviceTwin{DesiredState: "active"}, false},
+	}
+
+	for _, c := range cases {
+		if got := c.twin.Drifted(); got != c.expected {
+			t.Errorf("%+v: got %v, wanted %v", c.twin, got, c.expected)
+		}
+	}
+}
+
+func TestDeviceTwinAfterFind(t *testing.T) {
+	twin := &DeviceTwin{DesiredState: "active", ReportedState: "active"}
+	twin.AfterFind(nil)
+	if !twin.InSync {
+		t.Errorf("got %v, wanted %v", twin.InSync, true)
+	}
+}
+
+func TestSyncAttemptsOnReport(t *testing.T) {
+	db := newDryRunDb(t)
+	stmt := db.WithContext(WithTenant(context.Background(), 1)).Model(&UHF{}).Where("uhf_address = ?", "1").Updates(map[string]interface{}{
+		"reported_state": "inactive",
+		"sync_attempts":  syncAttemptsOnReport("inactive"),
+	}).Statement
+	sql := stmt.SQL.String()
+	if !strings.Contains(sql, `"sync_attempts"=CASE WHEN desired_state = @p`) || !strings.Contains(sql, "ELSE sync_attempts END") {
+		t.Errorf("got %s, wanted attempts reset only on desired state", sql)
+	}
+}
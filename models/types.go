@@ -3,6 +3,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type GormModel struct {
@@ -11,19 +13,34 @@ type GormModel struct {
 	UpdatedAt time.Time `swaggerignore:"true" json:"updated_at"`
 }
 
+// SoftDeleteModel keeps deleted rows in trash, queries skip them until they
+// are restored
+type SoftDeleteModel struct {
+	DeletedAt gorm.DeletedAt `gorm:"index" swaggerignore:"true" json:"deleted_at"`
+}
+
 type DeleteID struct {
 	ID uint `json:"id"`
 }
 
-// Struct defines all services for our IoC
+// Struct defines all services for our IoC, fakes can stand in for any of them
 type ServiceOptions struct {
-	GatewaySvc       *GatewaySvc
-	AreaSvc          *AreaSvc
-	LogSvc           *LogSvc
-	UHFStatusLogSvc  *UHFStatusLogSvc
-	UHFSvc           *UHFSvc
-	UserAccessSvc    *UserAccessSvc
-	PackageAccessSvc *PackageAccessSvc
-	SystemLogSvc     *SystemLogSvc
-	OperationLogSvc  *OperationLogSvc
+	GatewaySvc       GatewayService
+	AreaSvc          AreaService
+	LogSvc           LogService
+	UHFStatusLogSvc  UHFStatusLogService
+	UHFSvc           UHFService
+	UserAccessSvc    UserAccessService
+	PackageAccessSvc PackageAccessService
+	SystemLogSvc     SystemLogService
+	OperationLogSvc  OperationLogService
+	FirmwareSvc      FirmwareService
+	RolloutSvc       RolloutService
+	UHFConfigSvc     UHFConfigService
+	DeadLetterSvc    DeadLetterService
+	TagReadErrorSvc  TagReadErrorService
+	TenantSvc        TenantService
+	SchemaSvc        SchemaService
+	ImportSvc        ImportService
+	TrashSvc         TrashService
 }
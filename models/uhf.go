@@ -2,13 +2,18 @@ package models
 
 import (
 	"context"
-	"fmt"
+	"time"
+
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"gorm.io/gorm"
 )
 
 type UHF struct {
 	GormModel
+	TenantModel
+	SoftDeleteModel
+	Revision
+	DeviceTwin
 	UHFSerialNumber string `gorm:"type:varchar(256);unique;not null" json:"uhf_serial_number"`
 	Description     string `json:"description"`
 	Family          string `json:"family"`
@@ -17,7 +22,19 @@ type UHF struct {
 	ConnectState    string `json:"connect_state"`
 	AreaId          string `json:"area_id"`
 	UHFAddress      string `json:"uhf_address"`
-	ActiveState     string `json:"active_state"`
+	ActiveState     string `json:"active_state"` // mirrors DeviceTwin.ReportedState
+
+	ConfigTemplateID *uint            `json:"config_template_id"`
+	DesiredConfig    *UHFReaderConfig `gorm:"type:nvarchar(max)" json:"desired_config"`
+	ReportedConfig   *UHFReaderConfig `gorm:"type:nvarchar(max)" json:"reported_config"`
+	ConfigReportedAt *time.Time       `swaggerignore:"true" json:"config_reported_at"`
+	ConfigInSync     bool             `gorm:"-" json:"config_in_sync"`
+}
+
+func (dl *UHF) AfterFind(tx *gorm.DB) error {
+	dl.DeviceTwin.AfterFind(tx)
+	dl.ConfigInSync = dl.DesiredConfig == nil || dl.DesiredConfig.Equal(dl.ReportedConfig)
+	return nil
 }
 
 // Struct defines HTTP request payload for openning doorlock
@@ -59,7 +76,16 @@ func NewUHFSvc(db *gorm.DB) *UHFSvc {
 }
 
 func (uhfs *UHFSvc) FindAllUHF(ctx context.Context) (dlList []UHF, err error) {
-	result := uhfs.db.Find(&dlList)
+	result := uhfs.db.WithContext(ctx).Find(&dlList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dlList, nil
+}
+
+func (uhfs *UHFSvc) FindUHFsBySelector(ctx context.Context, s BulkSelector) (dlList []UHF, err error) {
+	result := uhfs.db.WithContext(ctx).Scopes(s.UHFScope).Find(&dlList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -68,7 +94,7 @@ func (uhfs *UHFSvc) FindAllUHF(ctx context.Context) (dlList []UHF, err error) {
 }
 
 func (uhfs *UHFSvc) FindUHFByID(ctx context.Context, id string) (dl *UHF, err error) {
-	result := uhfs.db.First(&dl, id)
+	result := uhfs.db.WithContext(ctx).First(&dl, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -78,51 +104,81 @@ func (uhfs *UHFSvc) FindUHFByID(ctx context.Context, id string) (dl *UHF, err er
 
 func (uhfs *UHFSvc) FindUHFByAddress(ctx context.Context, address string, gwID string) (dl *UHF, err error) {
 	var cnt int64
-	result := uhfs.db.Where("uhf_address = ? AND gateway_id = ?", address, gwID).Find(&dl).Count(&cnt)
+	result := uhfs.db.WithContext(ctx).Where("uhf_address = ? AND gateway_id = ?", address, gwID).Find(&dl).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
 	if cnt <= 0 {
-		return nil, fmt.Errorf("find no records")
+		return nil, NewNotFoundError("uhf %s of gateway %s not found", address, gwID)
 	}
 
 	return dl, nil
 }
 
+// UpdateUHF updates UHF of dl.UHFAddress and dl.GatewayID when it is still at
+// dl.Revision, any revision when 0
 func (uhfs *UHFSvc) UpdateUHF(ctx context.Context, dl *UHF) (bool, error) {
-	result := uhfs.db.Model(&dl).Where("uhf_address = ? AND gateway_id = ?", dl.UHFAddress, dl.GatewayID).Updates(dl)
-	return utils.ReturnBoolStateFromResult(result)
+	db := uhfs.db.WithContext(ctx)
+	query := db.Model(&dl).Where("uhf_address = ? AND gateway_id = ?", dl.UHFAddress, dl.GatewayID)
+	result := whereRevision(query, dl.Revision.Revision).Updates(dl)
+	return revisionResult(db, &UHF{}, result, dl.Revision.Revision, "uhf_address = ? AND gateway_id = ?", dl.UHFAddress, dl.GatewayID)
+}
+
+// UpdateUHFWithDesiredState updates UHF like UpdateUHF and sets its desired
+// state in the same transaction, revision moves on once
+func (uhfs *UHFSvc) UpdateUHFWithDesiredState(ctx context.Context, dl *UHF, state string) (bool, error) {
+	dl.DesiredState = state
+	isSuccess := false
+	err := uhfs.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&dl).Where("uhf_address = ? AND gateway_id = ?", dl.UHFAddress, dl.GatewayID)
+		result := whereRevision(query, dl.Revision.Revision).Updates(dl)
+		ok, err := revisionResult(tx, &UHF{}, result, dl.Revision.Revision, "uhf_address = ? AND gateway_id = ?", dl.UHFAddress, dl.GatewayID)
+		if err != nil {
+			return err
+		}
+		isSuccess = ok
+		// a new desired state is retried afresh, part of the update above
+		return tx.WithContext(WithoutRevision(ctx)).Model(&UHF{}).
+			Where("uhf_address = ? AND gateway_id = ?", dl.UHFAddress, dl.GatewayID).
+			Update("sync_attempts", 0).Error
+	})
+	if err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	return isSuccess, nil
 }
 
 func (uhfs *UHFSvc) UpdateUHFByAddress(ctx context.Context, dl *UHF) (bool, error) {
-	result := uhfs.db.Model(&dl).Where("gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.UHFAddress).Updates(dl)
+	result := uhfs.db.WithContext(ctx).Model(&dl).Where("gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.UHFAddress).Updates(dl)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
-func (uhfs *UHFSvc) DeleteUHF(ctx context.Context, id string) (bool, error) {
-	result := uhfs.db.Unscoped().Where("id = ?", id).Delete(&UHF{})
-	return utils.ReturnBoolStateFromResult(result)
+// DeleteUHF moves UHF id to trash when it is still at revision, any revision when 0
+func (uhfs *UHFSvc) DeleteUHF(ctx context.Context, id string, revision uint) (bool, error) {
+	db := uhfs.db.WithContext(ctx)
+	result := whereRevision(db.Where("id = ?", id), revision).Delete(&UHF{})
+	return revisionResult(db, &UHF{}, result, revision, "id = ?", id)
 }
 
 func (uhfs *UHFSvc) FindAllUHFByRoomID(ctx context.Context, roomId string) (dl []*UHF, err error) {
 	var cnt int64
-	result := uhfs.db.Model(&UHF{}).Where("room_id = ?", roomId).Find(&dl).Count(&cnt)
+	result := uhfs.db.WithContext(ctx).Model(&UHF{}).Where("room_id = ?", roomId).Find(&dl).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
 	if cnt <= 0 {
-		return nil, fmt.Errorf("find no doorlock records by roomId")
+		return nil, NewNotFoundError("no uhf in room %s", roomId)
 	}
 
 	return dl, nil
 }
 
 func (uhfs *UHFSvc) FindAllUHFByGatewayID(ctx context.Context, gwId string) (dlList []UHF, err error) {
-	result := uhfs.db.Where("gateway_id = ?", gwId).Find(&dlList)
+	result := uhfs.db.WithContext(ctx).Where("gateway_id = ?", gwId).Find(&dlList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -131,9 +187,56 @@ func (uhfs *UHFSvc) FindAllUHFByGatewayID(ctx context.Context, gwId string) (dlL
 }
 
 func (uhfs *UHFSvc) CreateUHF(ctx context.Context, dl *UHF) (*UHF, error) {
-	if err := uhfs.db.Create(&dl).Error; err != nil {
+	if err := uhfs.db.WithContext(ctx).Create(&dl).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return dl, nil
 }
+
+func (uhfs *UHFSvc) FindDriftedUHFs(ctx context.Context) (dlList []UHF, err error) {
+	result := uhfs.db.WithContext(ctx).Where(driftedQuery).Find(&dlList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dlList, nil
+}
+
+func (uhfs *UHFSvc) CountActiveUHFs(ctx context.Context) (int64, error) {
+	var cnt int64
+	result := uhfs.db.WithContext(ctx).Model(&UHF{}).Where("active_state = ?", "active").Count(&cnt)
+	if err := result.Error; err != nil {
+		return 0, utils.HandleQueryError(err)
+	}
+	return cnt, nil
+}
+
+// Set the active state UHF should apply, reconciler will retry until it is reported back
+func (uhfs *UHFSvc) UpdateUHFDesiredState(ctx context.Context, address string, gwID string, state string) (bool, error) {
+	result := uhfs.db.WithContext(ctx).Model(&UHF{}).Where("uhf_address = ? AND gateway_id = ?", address, gwID).Updates(map[string]interface{}{
+		"desired_state": state,
+		"sync_attempts": 0,
+	})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Record state UHF reports, attempts start over only once it is the desired
+// state so a UHF reporting another state on every scan still runs out of them
+func (uhfs *UHFSvc) UpdateUHFReportedState(ctx context.Context, address string, gwID string, state string) (bool, error) {
+	result := uhfs.db.WithContext(ctx).Model(&UHF{}).Where("uhf_address = ? AND gateway_id = ?", address, gwID).Updates(map[string]interface{}{
+		"reported_state": state,
+		"active_state":   state,
+		"reported_at":    time.Now(),
+		"sync_attempts":  syncAttemptsOnReport(state),
+	})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (uhfs *UHFSvc) MarkUHFSyncAttempt(ctx context.Context, address string, gwID string) (bool, error) {
+	result := uhfs.db.WithContext(ctx).Model(&UHF{}).Where("uhf_address = ? AND gateway_id = ?", address, gwID).Updates(map[string]interface{}{
+		"sync_attempts": gorm.Expr("COALESCE(sync_attempts, 0) + 1"),
+		"last_sync_at":  time.Now(),
+	})
+	return utils.ReturnBoolStateFromResult(result)
+}
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	UHF_MAX_RF_POWER int = 33 // dBm
+	UHF_MAX_ANTENNA  int = 8
+	UHF_MAX_SESSION  int = 3
+	UHF_MAX_Q        int = 15
+)
+
+// UHFReadFilter selects tags by a hex mask at an offset of a memory bank
+type UHFReadFilter struct {
+	Bank   string `json:"bank"`   // epc, tid, user
+	Offset int    `json:"offset"` // bit offset in bank
+	Mask   string `json:"mask"`   // hex
+	Action string `json:"action"` // include, exclude
+}
+
+// UHFReaderConfig is the RF and Gen2 inventory configuration of a reader.
+// It is stored as normalized JSON so desired and reported configs can be
+// compared directly in SQL.
+type UHFReaderConfig struct {
+	RFPower  int             `json:"rf_power"` // dBm
+	Antennas []int           `json:"antennas"` // active antenna ports, 1 based
+	Session  int             `json:"session"`  // Gen2 session S0..S3
+	Q        int             `json:"q"`        // Gen2 initial Q
+	Target   string          `json:"target"`   // A, B, AB
+	Filters  []UHFReadFilter `json:"filters"`  // applied in order, a later filter overrides earlier ones
+}
+
+func (rc *UHFReaderConfig) Validate() error {
+	if rc.RFPower < 0 || rc.RFPower > UHF_MAX_RF_POWER {
+		return NewValidationError("rf_power", "rf_power must be between 0 and %d dBm", UHF_MAX_RF_POWER)
+	}
+	if len(rc.Antennas) == 0 {
+		return NewValidationError("antennas", "at least one antenna must be active")
+	}
+	seen := map[int]bool{}
+	for _, a := range rc.Antennas {
+		if a < 1 || a > UHF_MAX_ANTENNA {
+			return NewValidationError("antennas", "antenna %d out of range 1..%d", a, UHF_MAX_ANTENNA)
+		}
+		if seen[a] {
+			return NewValidationError("antennas", "antenna %d is duplicated", a)
+		}
+		seen[a] = true
+	}
+	if rc.Session < 0 || rc.Session > UHF_MAX_SESSION {
+		return NewValidationError("session", "session must be between 0 and %d", UHF_MAX_SESSION)
+	}
+	if rc.Q < 0 || rc.Q > UHF_MAX_Q {
+		return NewValidationError("q", "q must be between 0 and %d", UHF_MAX_Q)
+	}
+	switch rc.Target {
+	case "A", "B", "AB":
+	default:
+		return NewValidationError("target", "target must be one of A, B, AB")
+	}
+	for i, f := range rc.Filters {
+		switch f.Bank {
+		case "epc", "tid", "user":
+		default:
+			return NewValidationError(fmt.Sprintf("filters[%d].bank", i), "filter %d: bank must be one of epc, tid, user", i)
+		}
+		if f.Offset < 0 {
+			return NewValidationError(fmt.Sprintf("filters[%d].offset", i), "filter %d: offset must not be negative", i)
+		}
+		if _, err := hex.DecodeString(f.Mask); err != nil || f.Mask == "" {
+			return NewValidationError(fmt.Sprintf("filters[%d].mask", i), "filter %d: mask must be a hex string", i)
+		}
+		switch f.Action {
+		case "include", "exclude":
+		default:
+			return NewValidationError(fmt.Sprintf("filters[%d].action", i), "filter %d: action must be include or exclude", i)
+		}
+	}
+	return nil
+}
+
+// Normalize makes equal configs serialize to the same JSON. Slices are
+// copied first, copies of rc sharing them are left as they were. Filters keep
+// their order since readers apply them one after another.
+func (rc *UHFReaderConfig) Normalize() {
+	rc.Antennas = append([]int{}, rc.Antennas...)
+	sort.Ints(rc.Antennas)
+	rc.Filters = append([]UHFReadFilter{}, rc.Filters...)
+	for i := range rc.Filters {
+		rc.Filters[i].Mask = strings.ToUpper(rc.Filters[i].Mask)
+	}
+	rc.Target = strings.ToUpper(rc.Target)
+}
+
+func (rc *UHFReaderConfig) Equal(other *UHFReaderConfig) bool {
+	if rc == nil || other == nil {
+		return rc == other
+	}
+	a, _ := rc.Value()
+	b, _ := other.Value()
+	return a == b
+}
+
+func (rc UHFReaderConfig) Value() (driver.Value, error) {
+	rc.Normalize()
+	b, err := json.Marshal(rc)
+	return string(b), err
+}
+
+func (rc *UHFReaderConfig) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, rc)
+	case string:
+		return json.Unmarshal([]byte(v), rc)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("unsupported UHF config value %T", value)
+}
+
+type UHFConfigTemplate struct {
+	GormModel
+	TenantModel
+	Name        string          `gorm:"type:varchar(256);not null" json:"name"` // unique in its organization, see tenantUniqueNames
+	Description string          `json:"description"`
+	Config      UHFReaderConfig `gorm:"type:nvarchar(max);not null" json:"config"`
+}
+
+// Struct defines HTTP request payload for setting config of a UHF
+type UpdateUHFConfig struct {
+	GatewayID  string          `json:"gateway_id" binding:"required"`
+	UHFAddress string          `json:"uhf_address" binding:"required"`
+	Config     UHFReaderConfig `json:"config"`
+}
+
+// Struct defines HTTP request payload for applying template to many UHFs
+type ApplyUHFConfigTemplate struct {
+	TemplateID uint   `json:"template_id" binding:"required"`
+	UHFIDs     []uint `json:"uhf_ids" binding:"required"`
+}
+
+// Struct defines HTTP response payload of config push result per UHF
+type UHFConfigResult struct {
+	UHFID   uint   `json:"uhf_id"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+type UHFConfigSvc struct {
+	db *gorm.DB
+}
+
+func NewUHFConfigSvc(db *gorm.DB) *UHFConfigSvc {
+	return &UHFConfigSvc{
+		db: db,
+	}
+}
+
+func (cs *UHFConfigSvc) FindAllTemplate(ctx context.Context) (tList []UHFConfigTemplate, err error) {
+	result := cs.db.WithContext(ctx).Find(&tList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return tList, nil
+}
+
+func (cs *UHFConfigSvc) FindTemplateByID(ctx context.Context, id string) (t *UHFConfigTemplate, err error) {
+	result := cs.db.WithContext(ctx).First(&t, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return t, nil
+}
+
+func (cs *UHFConfigSvc) CreateTemplate(ctx context.Context, t *UHFConfigTemplate) (*UHFConfigTemplate, error) {
+	if err := t.Config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cs.db.WithContext(ctx).Create(&t).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return t, nil
+}
+
+func (cs *UHFConfigSvc) UpdateTemplate(ctx context.Context, t *UHFConfigTemplate) (bool, error) {
+	if err := t.Config.Validate(); err != nil {
+		return false, err
+	}
+	result := cs.db.WithContext(ctx).Model(&t).Where("id = ?", t.ID).Updates(t)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (cs *UHFConfigSvc) DeleteTemplate(ctx context.Context, id uint) (bool, error) {
+	result := cs.db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&UHFConfigTemplate{})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Set desired config of UHF when it is still at revision, any revision when
+// 0. templateId is nil when config was set by hand.
+func (cs *UHFConfigSvc) UpdateUHFDesiredConfig(ctx context.Context, uhfId uint, revision uint, config UHFReaderConfig, templateId *uint) (bool, error) {
+	if err := config.Validate(); err != nil {
+		return false, err
+	}
+	db := cs.db.WithContext(ctx)
+	result := whereRevision(db.Model(&UHF{}).Where("id = ?", uhfId), revision).Updates(map[string]interface{}{
+		"desired_config":     config,
+		"config_template_id": templateId,
+	})
+	return revisionResult(db, &UHF{}, result, revision, "id = ?", uhfId)
+}
+
+func (cs *UHFConfigSvc) UpdateUHFReportedConfig(ctx context.Context, address string, gwID string, config UHFReaderConfig) (bool, error) {
+	result := cs.db.WithContext(ctx).Model(&UHF{}).Where("uhf_address = ? AND gateway_id = ?", address, gwID).Updates(map[string]interface{}{
+		"reported_config":    config,
+		"config_reported_at": time.Now(),
+	})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (cs *UHFConfigSvc) FindConfigMismatchedUHFs(ctx context.Context) (dlList []UHF, err error) {
+	result := cs.db.WithContext(ctx).Where("desired_config IS NOT NULL AND (reported_config IS NULL OR desired_config <> reported_config)").Find(&dlList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dlList, nil
+}
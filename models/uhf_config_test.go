@@ -0,0 +1,102 @@
+//go:build unit
+// +build unit
+
+package models
+
+import "testing"
+
+func validReaderConfig() UHFReaderConfig {
+	return UHFReaderConfig{
+		RFPower:  30,
+		Antennas: []int{2, 1},
+		Session:  1,
+		Q:        4,
+		Target:   "A",
+		Filters: []UHFReadFilter{
+			{Bank: "epc", Offset: 32, Mask: "55", Action: "include"},
+		},
+	}
+}
+
+func TestUHFReaderConfigValidate(t *testing.T) {
+	config := validReaderConfig()
+	if err := config.Validate(); err != nil {
+		t.Errorf("got %v, wanted %v", err, nil)
+	}
+
+	invalid := []func(c *UHFReaderConfig){
+		func(c *UHFReaderConfig) { c.RFPower = 40 },
+		func(c *UHFReaderConfig) { c.Antennas = nil },
+		func(c *UHFReaderConfig) { c.Antennas = []int{1, 1} },
+		func(c *UHFReaderConfig) { c.Antennas = []int{9} },
+		func(c *UHFReaderConfig) { c.Session = 4 },
+		func(c *UHFReaderConfig) { c.Q = 16 },
+		func(c *UHFReaderConfig) { c.Target = "C" },
+		func(c *UHFReaderConfig) { c.Filters[0].Bank = "reserved" },
+		func(c *UHFReaderConfig) { c.Filters[0].Mask = "zz" },
+		func(c *UHFReaderConfig) { c.Filters[0].Action = "drop" },
+	}
+	for i, mutate := range invalid {
+		config := validReaderConfig()
+		mutate(&config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("case %d: got %v, wanted error", i, err)
+		}
+	}
+}
+
+func TestUHFReaderConfigEqual(t *testing.T) {
+	a := validReaderConfig()
+	b := validReaderConfig()
+	b.Antennas = []int{1, 2}
+	b.Filters[0].Mask = "55"
+
+	if !a.Equal(&b) {
+		t.Errorf("got %v, wanted %v", false, true)
+	}
+
+	b.RFPower = 20
+	if a.Equal(&b) {
+		t.Errorf("got %v, wanted %v", true, false)
+	}
+
+	var none *UHFReaderConfig
+	if a.Equal(none) {
+		t.Errorf("got %v, wanted %v", true, false)
+	}
+
+	// filters apply in order
+	a.Filters = append(a.Filters, UHFReadFilter{Bank: "tid", Offset: 0, Mask: "E2", Action: "exclude"})
+	b = validReaderConfig()
+	b.Filters = append([]UHFReadFilter{a.Filters[1]}, b.Filters...)
+	if a.Equal(&b) {
+		t.Errorf("got %v, wanted %v", true, false)
+	}
+}
+
+func TestUHFReaderConfigValueKeepsSlices(t *testing.T) {
+	a := validReaderConfig()
+	a.Filters[0].Mask = "ab"
+	copied := a
+	copied.Normalize()
+	a.Value()
+	if a.Antennas[0] != 2 || a.Filters[0].Mask != "ab" {
+		t.Errorf("got %v %s, wanted slices of caller untouched", a.Antennas, a.Filters[0].Mask)
+	}
+	if copied.Antennas[0] != 1 || copied.Filters[0].Mask != "AB" {
+		t.Errorf("got %v %s, wanted copy normalized", copied.Antennas, copied.Filters[0].Mask)
+	}
+}
+
+func TestUHFReaderConfigScan(t *testing.T) {
+	a := validReaderConfig()
+	value, _ := a.Value()
+
+	b := UHFReaderConfig{}
+	if err := b.Scan(value); err != nil {
+		t.Fatalf("got %v, wanted %v", err, nil)
+	}
+	if !a.Equal(&b) {
+		t.Errorf("got %+v, wanted %+v", b, a)
+	}
+}
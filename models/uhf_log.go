@@ -15,6 +15,8 @@ type UHFLogTime struct {
 	Second int `json:"second"`
 }
 type UHFStatusLog struct {
+	TenantModel
+	CorrelationModel
 	ID         uint      `gorm:"primaryKey" json:"id"`
 	Time       time.Time `swaggerignore:"true" json:"time"`
 	GatewayID  string    `json:"gateway_id"`
@@ -34,7 +36,7 @@ func NewUHFStatusLogSvc(db *gorm.DB) *UHFStatusLogSvc {
 }
 
 func (dlsls *UHFStatusLogSvc) GetAllUHFStatusLogs(ctx context.Context) (dlslList []UHFStatusLog, err error) {
-	result := dlsls.db.Find(&dlslList)
+	result := dlsls.db.WithContext(ctx).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -43,7 +45,7 @@ func (dlsls *UHFStatusLogSvc) GetAllUHFStatusLogs(ctx context.Context) (dlslList
 }
 
 func (gs *UHFStatusLogSvc) GetUHFStatusLogByID(ctx context.Context, id string) (uhf_log *UHFStatusLog, err error) {
-	result := gs.db.First(&uhf_log, id)
+	result := gs.db.WithContext(ctx).First(&uhf_log, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -52,7 +54,7 @@ func (gs *UHFStatusLogSvc) GetUHFStatusLogByID(ctx context.Context, id string) (
 }
 
 func (dlsls *UHFStatusLogSvc) GetUHFStatusLogByUHFAddress(ctx context.Context, uhf_address string, gateway_id string) (dlslList []UHFStatusLog, err error) {
-	result := dlsls.db.Where("uhf_address = ? AND gateway_id <= ?", uhf_address, gateway_id).Find(&dlslList)
+	result := dlsls.db.WithContext(ctx).Where("uhf_address = ? AND gateway_id <= ?", uhf_address, gateway_id).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -61,15 +63,25 @@ func (dlsls *UHFStatusLogSvc) GetUHFStatusLogByUHFAddress(ctx context.Context, u
 }
 
 func (dlsls *UHFStatusLogSvc) CreateUHFStatusLog(ctx context.Context, dlsl *UHFStatusLog) (*UHFStatusLog, error) {
-	if err := dlsls.db.Create(&dlsl).Error; err != nil {
+	if err := dlsls.db.WithContext(ctx).Create(&dlsl).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return dlsl, nil
 }
 
-func (dlsls *UHFStatusLogSvc) GetUHFStatusLogBYGatewayIDAndUHFAddressInTimeRange(from string, to string, gateway_id string, uhf_address string) (dlslList *[]UHFStatusLog, err error) {
-	result := dlsls.db.Where("time >= ? AND time <= ? AND gateway_id = ? AND uhf_address = ?", from, to, gateway_id, uhf_address).Find(&dlslList)
+func (dlsls *UHFStatusLogSvc) CreateUHFStatusLogs(ctx context.Context, dlslList []UHFStatusLog, batchSize int) error {
+	if len(dlslList) == 0 {
+		return nil
+	}
+	if err := dlsls.db.WithContext(ctx).CreateInBatches(dlslList, batchSize).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	return nil
+}
+
+func (dlsls *UHFStatusLogSvc) GetUHFStatusLogBYGatewayIDAndUHFAddressInTimeRange(ctx context.Context, from string, to string, gateway_id string, uhf_address string) (dlslList *[]UHFStatusLog, err error) {
+	result := dlsls.db.WithContext(ctx).Where("time >= ? AND time <= ? AND gateway_id = ? AND uhf_address = ?", from, to, gateway_id, uhf_address).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -77,8 +89,8 @@ func (dlsls *UHFStatusLogSvc) GetUHFStatusLogBYGatewayIDAndUHFAddressInTimeRange
 	return dlslList, nil
 }
 
-func (dlsls *UHFStatusLogSvc) GetUHFStatusLogInTimeRange(from string, to string) (dlslList *[]UHFStatusLog, err error) {
-	result := dlsls.db.Where("time >= ? AND time <= ?", from, to).Find(&dlslList)
+func (dlsls *UHFStatusLogSvc) GetUHFStatusLogInTimeRange(ctx context.Context, from string, to string) (dlslList *[]UHFStatusLog, err error) {
+	result := dlsls.db.WithContext(ctx).Where("time >= ? AND time <= ?", from, to).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -86,7 +98,7 @@ func (dlsls *UHFStatusLogSvc) GetUHFStatusLogInTimeRange(from string, to string)
 	return dlslList, nil
 }
 
-func (dlsls *UHFStatusLogSvc) DeleteUHFLogInTimeRange(from string, to string) (bool, error) {
-	result := dlsls.db.Unscoped().Where("time >= ? AND time <= ?", from, to).Delete(&UHFStatusLog{})
+func (dlsls *UHFStatusLogSvc) DeleteUHFLogInTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	result := dlsls.db.WithContext(ctx).Unscoped().Where("time >= ? AND time <= ?", from, to).Delete(&UHFStatusLog{})
 	return utils.ReturnBoolStateFromResult(result)
 }
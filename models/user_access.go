@@ -8,6 +8,7 @@ import (
 )
 
 type UserAccess struct {
+	TenantModel
 	ID     uint      `gorm:"primarykey;" json:"id"`
 	UserID string    `gorm:"type:varchar(256);" json:"user_id"`
 	Random string    `gorm:"type:varchar(50);" json:"random"`
@@ -27,15 +28,25 @@ func NewUserAccessSvc(db *gorm.DB) *UserAccessSvc {
 }
 
 func (gwns *UserAccessSvc) CreateUserAccess(ctx context.Context, user_acesses *UserAccess) (*UserAccess, error) {
-	if err := gwns.db.Create(&user_acesses).Error; err != nil {
+	if err := gwns.db.WithContext(ctx).Create(&user_acesses).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return user_acesses, nil
 }
 
+func (gwns *UserAccessSvc) CreateUserAccesses(ctx context.Context, user_acesses []UserAccess, batchSize int) error {
+	if len(user_acesses) == 0 {
+		return nil
+	}
+	if err := gwns.db.WithContext(ctx).CreateInBatches(user_acesses, batchSize).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	return nil
+}
+
 func (gwns *UserAccessSvc) FindAllUserAccess(ctx context.Context) (user_acesses []UserAccess, err error) {
-	result := gwns.db.Find(&user_acesses)
+	result := gwns.db.WithContext(ctx).Find(&user_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -44,7 +55,7 @@ func (gwns *UserAccessSvc) FindAllUserAccess(ctx context.Context) (user_acesses
 }
 
 func (gwns *UserAccessSvc) FindAllUserAccessByUserID(ctx context.Context, id string) (user_acesses []UserAccess, err error) {
-	result := gwns.db.Where("user_id = ?", id).Find(&user_acesses)
+	result := gwns.db.WithContext(ctx).Where("user_id = ?", id).Find(&user_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -53,7 +64,7 @@ func (gwns *UserAccessSvc) FindAllUserAccessByUserID(ctx context.Context, id str
 }
 
 func (gwns *UserAccessSvc) FindAllUserAccessByUserIDAndAreaID(ctx context.Context, id string, area_id string) (user_acesses []UserAccess, err error) {
-	result := gwns.db.Where("user_id = ? AND area_id = ?", id, area_id).Find(&user_acesses)
+	result := gwns.db.WithContext(ctx).Where("user_id = ? AND area_id = ?", id, area_id).Find(&user_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -61,8 +72,8 @@ func (gwns *UserAccessSvc) FindAllUserAccessByUserIDAndAreaID(ctx context.Contex
 	return user_acesses, err
 }
 
-func (ls *UserAccessSvc) FindUserAccessesByUserIDAndTimeRange(user_id string, from string, to string) (user_acesses *[]UserAccess, err error) {
-	result := ls.db.Where("user_id = ? AND time >= ? AND time <= ?", user_id, from, to).Find(&user_acesses)
+func (ls *UserAccessSvc) FindUserAccessesByUserIDAndTimeRange(ctx context.Context, user_id string, from string, to string) (user_acesses *[]UserAccess, err error) {
+	result := ls.db.WithContext(ctx).Where("user_id = ? AND time >= ? AND time <= ?", user_id, from, to).Find(&user_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -71,7 +82,7 @@ func (ls *UserAccessSvc) FindUserAccessesByUserIDAndTimeRange(user_id string, fr
 }
 
 func (gwns *UserAccessSvc) FindAllUserAccessByUserIDAndAreaIDinTimeRange(ctx context.Context, id string, area_id string, from string, to string) (user_acesses []UserAccess, err error) {
-	result := gwns.db.Where("user_id = ? AND area_id = ? AND time >= ? AND time <= ?", id, area_id, from, to).Find(&user_acesses)
+	result := gwns.db.WithContext(ctx).Where("user_id = ? AND area_id = ? AND time >= ? AND time <= ?", id, area_id, from, to).Find(&user_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -80,7 +91,7 @@ func (gwns *UserAccessSvc) FindAllUserAccessByUserIDAndAreaIDinTimeRange(ctx con
 }
 
 func (gwns *UserAccessSvc) FindAllUserAccessByAreaID(ctx context.Context, area_id string) (user_acesses []UserAccess, err error) {
-	result := gwns.db.Where("area_id = ?", area_id).Find(&user_acesses)
+	result := gwns.db.WithContext(ctx).Where("area_id = ?", area_id).Find(&user_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -89,7 +100,7 @@ func (gwns *UserAccessSvc) FindAllUserAccessByAreaID(ctx context.Context, area_i
 }
 
 func (gwns *UserAccessSvc) FindAllUserAccessByAreaIDAndTimeRange(ctx context.Context, area_id string, from string, to string) (user_acesses []UserAccess, err error) {
-	result := gwns.db.Where("area_id = ? AND time >= ? AND time <= ?", area_id, from, to).Find(&user_acesses)
+	result := gwns.db.WithContext(ctx).Where("area_id = ? AND time >= ? AND time <= ?", area_id, from, to).Find(&user_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -98,7 +109,7 @@ func (gwns *UserAccessSvc) FindAllUserAccessByAreaIDAndTimeRange(ctx context.Con
 }
 
 func (gwns *UserAccessSvc) FindAllUserAccessTimeRange(ctx context.Context, from string, to string) (user_acesses []UserAccess, err error) {
-	result := gwns.db.Where("time >= ? AND time <= ?", from, to).Find(&user_acesses)
+	result := gwns.db.WithContext(ctx).Where("time >= ? AND time <= ?", from, to).Find(&user_acesses)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -106,7 +117,25 @@ func (gwns *UserAccessSvc) FindAllUserAccessTimeRange(ctx context.Context, from
 	return user_acesses, err
 }
 
-func (dlsls *UserAccessSvc) DeleteUserAccessTimeRange(from string, to string) (bool, error) {
-	result := dlsls.db.Unscoped().Where("time >= ? AND time <= ?", from, to).Delete(&UserAccess{})
+// CountUserAccessesByArea counts accesses since given time by area
+func (gwns *UserAccessSvc) CountUserAccessesByArea(ctx context.Context, since time.Time) (map[string]int64, error) {
+	rows := []struct {
+		AreaID string
+		Cnt    int64
+	}{}
+	result := gwns.db.WithContext(ctx).Model(&UserAccess{}).Select("area_id, COUNT(*) AS cnt").
+		Where("time >= ?", since).Group("area_id").Scan(&rows)
+	if err := result.Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	counts := map[string]int64{}
+	for _, row := range rows {
+		counts[row.AreaID] += row.Cnt
+	}
+	return counts, nil
+}
+
+func (dlsls *UserAccessSvc) DeleteUserAccessTimeRange(ctx context.Context, from string, to string) (bool, error) {
+	result := dlsls.db.WithContext(ctx).Unscoped().Where("time >= ? AND time <= ?", from, to).Delete(&UserAccess{})
 	return utils.ReturnBoolStateFromResult(result)
 }
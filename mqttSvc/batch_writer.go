@@ -0,0 +1,213 @@
+package mqttSvc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+// BatchWriter buffers accesses and logs produced by subscribers and writes
+// them with CreateInBatches, when a batch is full or every flush interval.
+// Records are dropped once maxPending of them wait for the database.
+type BatchWriter struct {
+	optSvc        *models.ServiceOptions
+	batchSize     int
+	flushInterval time.Duration
+	maxPending    int
+
+	mu              sync.Mutex
+	userAccesses    []models.UserAccess
+	packageAccesses []models.PackageAccess
+	gatewayLogs     []models.GatewayLog
+	uhfStatusLogs   []models.UHFStatusLog
+	operationLogs   []models.OperationLog
+	tagReadErrors   []models.TagReadError
+
+	written uint64
+	failed  uint64
+	dropped uint64
+	flushes uint64
+
+	flushMu  sync.Mutex
+	full     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+type BatchWriterStats struct {
+	BatchSize     int    `json:"batch_size"`
+	FlushInterval string `json:"flush_interval"`
+	Pending       int    `json:"pending"`
+	MaxPending    int    `json:"max_pending"`
+	Written       uint64 `json:"written"`
+	Failed        uint64 `json:"failed"`
+	Dropped       uint64 `json:"dropped"`
+	Flushes       uint64 `json:"flushes"`
+}
+
+func NewBatchWriter(optSvc *models.ServiceOptions, batchSize int, flushInterval time.Duration, maxPending int) *BatchWriter {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if maxPending < batchSize {
+		maxPending = batchSize
+	}
+	return &BatchWriter{
+		optSvc:        optSvc,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		maxPending:    maxPending,
+		full:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+}
+
+func (w *BatchWriter) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		var tick <-chan time.Time
+		if w.flushInterval > 0 {
+			ticker := time.NewTicker(w.flushInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-w.full:
+				w.Flush(context.Background())
+			case <-tick:
+				w.Flush(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop ends the flush loop then writes everything still buffered
+func (w *BatchWriter) Stop() {
+	w.stopOnce.Do(func() { close(w.done) })
+	w.wg.Wait()
+	w.Flush(context.Background())
+}
+
+func (w *BatchWriter) AddUserAccess(ua models.UserAccess) {
+	w.add(func() { w.userAccesses = append(w.userAccesses, ua) })
+}
+
+func (w *BatchWriter) AddPackageAccess(pa models.PackageAccess) {
+	w.add(func() { w.packageAccesses = append(w.packageAccesses, pa) })
+}
+
+func (w *BatchWriter) AddGatewayLog(gl models.GatewayLog) {
+	w.add(func() { w.gatewayLogs = append(w.gatewayLogs, gl) })
+}
+
+func (w *BatchWriter) AddUHFStatusLog(ul models.UHFStatusLog) {
+	w.add(func() { w.uhfStatusLogs = append(w.uhfStatusLogs, ul) })
+}
+
+func (w *BatchWriter) AddOperationLog(ol models.OperationLog) {
+	w.add(func() { w.operationLogs = append(w.operationLogs, ol) })
+}
+
+func (w *BatchWriter) AddTagReadError(te models.TagReadError) {
+	w.add(func() { w.tagReadErrors = append(w.tagReadErrors, te) })
+}
+
+func (w *BatchWriter) add(appendFn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pending := w.pending()
+	if pending >= w.maxPending {
+		w.dropped++
+		return
+	}
+	appendFn()
+	if pending+1 >= w.batchSize {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *BatchWriter) pending() int {
+	return len(w.userAccesses) + len(w.packageAccesses) + len(w.gatewayLogs) +
+		len(w.uhfStatusLogs) + len(w.operationLogs) + len(w.tagReadErrors)
+}
+
+// Flush writes all buffered records, records of a failed batch are counted and dropped.
+// Batches mix organizations, every record carries organization of its gateway.
+func (w *BatchWriter) Flush(ctx context.Context) {
+	ctx = models.WithSystemTenant(ctx)
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	userAccesses, packageAccesses := w.userAccesses, w.packageAccesses
+	gatewayLogs, uhfStatusLogs, operationLogs := w.gatewayLogs, w.uhfStatusLogs, w.operationLogs
+	tagReadErrors := w.tagReadErrors
+	w.userAccesses, w.packageAccesses = nil, nil
+	w.gatewayLogs, w.uhfStatusLogs, w.operationLogs = nil, nil, nil
+	w.tagReadErrors = nil
+	w.mu.Unlock()
+
+	if len(userAccesses) > 0 {
+		w.record("user accesses", len(userAccesses),
+			w.optSvc.UserAccessSvc.CreateUserAccesses(ctx, userAccesses, w.batchSize))
+	}
+	if len(packageAccesses) > 0 {
+		w.record("package accesses", len(packageAccesses),
+			w.optSvc.PackageAccessSvc.CreatePackageAccesses(ctx, packageAccesses, w.batchSize))
+	}
+	if len(gatewayLogs) > 0 {
+		w.record("gateway logs", len(gatewayLogs),
+			w.optSvc.LogSvc.CreateGatewayLogs(ctx, gatewayLogs, w.batchSize))
+	}
+	if len(uhfStatusLogs) > 0 {
+		w.record("UHF status logs", len(uhfStatusLogs),
+			w.optSvc.UHFStatusLogSvc.CreateUHFStatusLogs(ctx, uhfStatusLogs, w.batchSize))
+	}
+	if len(operationLogs) > 0 {
+		w.record("operation logs", len(operationLogs),
+			w.optSvc.OperationLogSvc.CreateOperationLogs(ctx, operationLogs, w.batchSize))
+	}
+	if len(tagReadErrors) > 0 {
+		w.record("tag read errors", len(tagReadErrors),
+			w.optSvc.TagReadErrorSvc.CreateTagReadErrors(ctx, tagReadErrors, w.batchSize))
+	}
+}
+
+func (w *BatchWriter) record(kind string, count int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushes++
+	if err != nil {
+		w.failed += uint64(count)
+		logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel,
+			"Write %d %s failed, err %s", count, kind, err.Error())
+		return
+	}
+	w.written += uint64(count)
+}
+
+func (w *BatchWriter) Stats() BatchWriterStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return BatchWriterStats{
+		BatchSize:     w.batchSize,
+		FlushInterval: w.flushInterval.String(),
+		Pending:       w.pending(),
+		MaxPending:    w.maxPending,
+		Written:       w.written,
+		Failed:        w.failed,
+		Dropped:       w.dropped,
+		Flushes:       w.flushes,
+	}
+}
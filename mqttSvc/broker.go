@@ -0,0 +1,233 @@
+package mqttSvc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/google/uuid"
+	mqttserver "github.com/mochi-co/mqtt/server"
+	"github.com/mochi-co/mqtt/server/events"
+	"github.com/mochi-co/mqtt/server/listeners"
+	"github.com/mochi-co/mqtt/server/system"
+)
+
+// Username of the server client on the embedded broker, its password is
+// made up on every start and never leaves the process
+const BROKER_SERVER_USER string = "uams-server"
+
+type BrokerOptions struct {
+	Address         string // gateways connect here, e.g. :1883
+	GatewayPassword string // password every gateway connects with, required unless Insecure
+	AllowUnknown    bool   // gateways not registered yet may connect, to boot up
+	Insecure        bool   // without GatewayPassword any password is accepted, for development only
+}
+
+// ErrBrokerNoPassword refuses to start a broker any client knowing or
+// guessing a gateway ID could connect to
+var ErrBrokerNoPassword = errors.New("embedded broker needs a gateway password unless insecure")
+
+// Broker is an MQTT broker running inside the server process, so a single
+// binary can serve a site and tests need no outside broker. The server
+// client connects to it over loopback. Gateways connect with their gateway
+// ID as username and must be registered unless AllowUnknown is set. A
+// gateway may only publish gateway messages and subscribe to commands of its
+// own ID when topics are per site, publishes it is not allowed are dropped.
+type Broker struct {
+	server   *mqttserver.Server
+	listener *brokerListener
+	auth     *brokerAuth
+}
+
+func NewBroker(opts BrokerOptions, topics *Topics, gateways models.GatewayService) (*Broker, error) {
+	if opts.GatewayPassword == "" {
+		if !opts.Insecure {
+			return nil, ErrBrokerNoPassword
+		}
+		logger.LogWithoutFields(logger.MQTT, logger.WarnLevel,
+			"Embedded broker is insecure, gateways connect with any password")
+	}
+	if !topics.PerSite() {
+		logger.LogWithoutFields(logger.MQTT, logger.WarnLevel,
+			"Topics are not per site, any gateway on embedded broker may subscribe to commands of every gateway")
+	}
+	auth := &brokerAuth{
+		topics:          topics,
+		gateways:        gateways,
+		gatewayPassword: opts.GatewayPassword,
+		allowUnknown:    opts.AllowUnknown,
+		serverPassword:  uuid.NewString(),
+	}
+	server := mqttserver.NewServer(nil)
+	server.Events.OnConnect = func(cl events.Client, pk events.Packet) {
+		logger.LogfWithoutFields(logger.MQTT, logger.DebugLevel,
+			"Broker client %s connected as %s from %s", cl.ID, string(cl.Username), cl.Remote)
+	}
+	server.Events.OnDisconnect = func(cl events.Client, err error) {
+		if err != nil {
+			logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "Broker client %s lost, err %s", cl.ID, err.Error())
+		}
+	}
+
+	l := &brokerListener{id: "gateways", address: opts.Address}
+	if err := server.AddListener(l, &listeners.Config{Auth: auth}); err != nil {
+		return nil, fmt.Errorf("broker listen on %s failed, err %s", opts.Address, err.Error())
+	}
+	if err := server.Serve(); err != nil {
+		server.Close()
+		return nil, fmt.Errorf("start broker failed, err %s", err.Error())
+	}
+	logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "Embedded broker listening on %s", l.Addr().String())
+	return &Broker{server: server, listener: l, auth: auth}, nil
+}
+
+// ClientAddress returns host and port the server client connects to
+func (b *Broker) ClientAddress() (host string, port string) {
+	_, port, _ = net.SplitHostPort(b.listener.Addr().String())
+	return "127.0.0.1", port
+}
+
+// ServerCredentials returns username and password of the server client
+func (b *Broker) ServerCredentials() (username string, password string) {
+	return BROKER_SERVER_USER, b.auth.serverPassword
+}
+
+// Close disconnects every client and stops listening
+func (b *Broker) Close() error {
+	return b.server.Close()
+}
+
+// brokerAuth ties broker access to the gateway registry
+type brokerAuth struct {
+	topics          *Topics
+	gateways        models.GatewayService
+	gatewayPassword string
+	allowUnknown    bool
+	serverPassword  string
+}
+
+func (a *brokerAuth) Authenticate(user []byte, password []byte) bool {
+	username := string(user)
+	if username == BROKER_SERVER_USER {
+		return subtle.ConstantTimeCompare(password, []byte(a.serverPassword)) == 1
+	}
+	if username == "" {
+		return false
+	}
+	if a.gatewayPassword != "" && subtle.ConstantTimeCompare(password, []byte(a.gatewayPassword)) != 1 {
+		logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel, "Broker refused gateway %s, wrong password", username)
+		return false
+	}
+	_, _, err := a.gateways.FindGatewayTenant(models.WithSystemTenant(context.Background()), username)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, utils.ErrRecordNotFound) || errors.Is(err, models.ErrNotFound):
+		if !a.allowUnknown {
+			logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel, "Broker refused gateway %s, not registered", username)
+		}
+		return a.allowUnknown
+	}
+	logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Broker refused gateway %s, err %s", username, err.Error())
+	return false
+}
+
+func (a *brokerAuth) ACL(user []byte, topic string, write bool) bool {
+	username := string(user)
+	if username == BROKER_SERVER_USER {
+		return true
+	}
+	if write {
+		gwTopic, ok := a.topics.Parse(topic)
+		return ok && (gwTopic.GatewayID == "" || gwTopic.GatewayID == username)
+	}
+	return a.commandFilter(topic, username)
+}
+
+// commandFilter tells whether a filter only matches commands of gateway gwId
+func (a *brokerAuth) commandFilter(filter string, gwId string) bool {
+	prefix := a.topics.Prefix() + "/"
+	if !strings.HasPrefix(filter, prefix) {
+		return false
+	}
+	rest := strings.TrimPrefix(filter, prefix)
+	if !a.topics.PerSite() {
+		return strings.HasPrefix(rest, topicServerDir+"/")
+	}
+	parts := strings.SplitN(rest, "/", 4)
+	return len(parts) == 4 && parts[1] == topicServerDir && parts[2] == gwId &&
+		!strings.ContainsAny(parts[0], "+#") && a.topics.servesSite(parts[0])
+}
+
+// brokerListener is a TCP listener that reports the address it is bound to,
+// so the broker can listen on port 0 in tests
+type brokerListener struct {
+	id      string
+	address string
+
+	mu     sync.RWMutex
+	config *listeners.Config
+	listen net.Listener
+	closed bool
+}
+
+func (l *brokerListener) SetConfig(config *listeners.Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = config
+}
+
+func (l *brokerListener) Listen(s *system.Info) error {
+	listen, err := net.Listen("tcp", l.address)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listen = listen
+	return nil
+}
+
+func (l *brokerListener) Serve(establish listeners.EstablishFunc) {
+	for {
+		conn, err := l.listen.Accept()
+		if err != nil {
+			return
+		}
+		l.mu.RLock()
+		closed, auth := l.closed, l.config.Auth
+		l.mu.RUnlock()
+		if closed {
+			conn.Close()
+			return
+		}
+		go establish(l.id, conn, auth)
+	}
+}
+
+func (l *brokerListener) ID() string {
+	return l.id
+}
+
+func (l *brokerListener) Addr() net.Addr {
+	return l.listen.Addr()
+}
+
+func (l *brokerListener) Close(closeClients listeners.CloseFunc) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	l.mu.Unlock()
+	closeClients(l.id)
+	l.listen.Close()
+}
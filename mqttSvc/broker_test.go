@@ -0,0 +1,243 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/fakes"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/tidwall/gjson"
+)
+
+const testGatewayPassword string = "gw-secret"
+
+type brokerTest struct {
+	store  *fakes.Store
+	opts   *models.ServiceOptions
+	topics *Topics
+	broker *Broker
+}
+
+func newBrokerTest(t *testing.T, allowUnknown bool, sites ...string) *brokerTest {
+	t.Helper()
+	store := fakes.NewStore()
+	opts := fakes.NewServiceOptions(store)
+	topics, err := NewTopics(DEFAULT_TOPIC_PREFIX, sites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker, err := NewBroker(BrokerOptions{
+		Address:         "127.0.0.1:0",
+		GatewayPassword: testGatewayPassword,
+		AllowUnknown:    allowUnknown,
+	}, topics, opts.GatewaySvc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	_, err = opts.GatewaySvc.CreateGateway(models.WithTenant(context.Background(), 1), &models.Gateway{GatewayID: "gw01", Site: "hcm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &brokerTest{store: store, opts: opts, topics: topics, broker: broker}
+}
+
+// connect opens a client to broker, it is disconnected when test ends
+func (bt *brokerTest) connect(t *testing.T, username string, password string) (mqtt.Client, error) {
+	t.Helper()
+	host, port := bt.broker.ClientAddress()
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(fmt.Sprintf("tcp://%s:%s", host, port))
+	opts.SetClientID(username)
+	opts.SetUsername(username)
+	opts.SetPassword(password)
+	opts.SetAutoReconnect(false)
+	client := mqtt.NewClient(opts)
+	t.Cleanup(func() { client.Disconnect(0) })
+	token := client.Connect()
+	if !token.WaitTimeout(5 * time.Second) {
+		return nil, errors.New("connect timed out")
+	}
+	return client, token.Error()
+}
+
+func (bt *brokerTest) serverClient(t *testing.T) mqtt.Client {
+	t.Helper()
+	username, password := bt.broker.ServerCredentials()
+	client, err := bt.connect(t, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// received collects payloads of messages matching filter
+func received(t *testing.T, client mqtt.Client, filter string) chan string {
+	t.Helper()
+	ch := make(chan string, 10)
+	token := client.Subscribe(filter, 0, func(c mqtt.Client, msg mqtt.Message) {
+		ch <- string(msg.Payload())
+	})
+	if token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	return ch
+}
+
+func expectMessage(t *testing.T, ch chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != want {
+			t.Errorf("got %s, wanted %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("got nothing, wanted %s", want)
+	}
+}
+
+func expectNoMessage(t *testing.T, ch chan string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		t.Errorf("got %s, wanted nothing", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestBrokerAuthenticate(t *testing.T) {
+	bt := newBrokerTest(t, false)
+	user, _ := bt.broker.ServerCredentials()
+
+	cases := []struct {
+		username string
+		password string
+		ok       bool
+	}{
+		{user, "guess", false},
+		{"gw01", testGatewayPassword, true},
+		{"gw01", "guess", false},
+		{"gw02", testGatewayPassword, false},
+		{"", testGatewayPassword, false},
+	}
+	for i, c := range cases {
+		_, err := bt.connect(t, c.username, c.password)
+		if (err == nil) != c.ok {
+			t.Errorf("case %d: got %v, wanted connected %v", i, err, c.ok)
+		}
+	}
+	bt.serverClient(t)
+
+	bt.store.Fail("GatewaySvc.FindGatewayTenant", errors.New("connection reset"))
+	if _, err := bt.connect(t, "gw01", testGatewayPassword); err == nil {
+		t.Errorf("got gateway connected while registry is down")
+	}
+	bt.store.Fail("GatewaySvc.FindGatewayTenant", nil)
+
+	bt = newBrokerTest(t, true)
+	if _, err := bt.connect(t, "gw02", testGatewayPassword); err != nil {
+		t.Errorf("got %v, wanted unknown gateway connected", err)
+	}
+	if _, err := bt.connect(t, "gw02", "guess"); err == nil {
+		t.Errorf("got unknown gateway connected with wrong password")
+	}
+}
+
+func TestBrokerPassword(t *testing.T) {
+	opts := fakes.NewServiceOptions(fakes.NewStore())
+	topics, err := NewTopics(DEFAULT_TOPIC_PREFIX, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBroker(BrokerOptions{Address: "127.0.0.1:0"}, topics, opts.GatewaySvc); !errors.Is(err, ErrBrokerNoPassword) {
+		t.Fatalf("got %v, wanted %v", err, ErrBrokerNoPassword)
+	}
+
+	broker, err := NewBroker(BrokerOptions{Address: "127.0.0.1:0", Insecure: true}, topics, opts.GatewaySvc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	opts.GatewaySvc.CreateGateway(models.WithTenant(context.Background(), 1), &models.Gateway{GatewayID: "gw01"})
+	bt := &brokerTest{opts: opts, topics: topics, broker: broker}
+	if _, err := bt.connect(t, "gw01", "guess"); err != nil {
+		t.Errorf("got %v, wanted any password accepted when insecure", err)
+	}
+}
+
+func TestBrokerACL(t *testing.T) {
+	bt := newBrokerTest(t, true, "hcm")
+	server := bt.serverClient(t)
+	fromGateways := received(t, server, "uams/+/gateway/#")
+	gw, err := bt.connect(t, "gw01", testGatewayPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw.Publish("uams/hcm/gateway/gw01/log", 0, false, "own").Wait()
+	expectMessage(t, fromGateways, "own")
+	gw.Publish("uams/hcm/gateway/gw02/log", 0, false, "other gateway").Wait()
+	gw.Publish("uams/hn/gateway/gw01/log", 0, false, "site not served").Wait()
+	gw.Publish("uams/hcm/gateway/gw01/unknown", 0, false, "unknown topic").Wait()
+	expectNoMessage(t, fromGateways)
+
+	subscribe := func(filter string) byte {
+		token := gw.Subscribe(filter, 0, func(c mqtt.Client, msg mqtt.Message) {})
+		token.Wait()
+		return token.(*mqtt.SubscribeToken).Result()[filter]
+	}
+	for _, filter := range []string{"uams/hcm/server/gw02/#", "uams/+/server/gw01/#", "uams/hcm/gateway/#", "#"} {
+		if code := subscribe(filter); code != 0x80 {
+			t.Errorf("%s: got %d, wanted subscription refused", filter, code)
+		}
+	}
+	commands := received(t, gw, "uams/hcm/server/gw01/#")
+	server.Publish("uams/hcm/server/gw02/sync", 0, false, "for gw02").Wait()
+	server.Publish("uams/hcm/server/gw01/sync", 0, false, "for gw01").Wait()
+	expectMessage(t, commands, "for gw01")
+	expectNoMessage(t, commands)
+}
+
+func TestMqttClientOnEmbeddedBroker(t *testing.T) {
+	bt := newBrokerTest(t, false)
+	tags, _ := NewTagDecoder(TAG_ENCODING_AUTO, DEFAULT_TAG_TYPES)
+	ing := NewIngestion(IngestionOptions{}, bt.topics, NewReadDebouncer(0), tags, nil,
+		NewBatchWriter(bt.opts, 10, 0, 100), bt.opts.DeadLetterSvc)
+	ing.Start()
+	monitor := NewConnectionMonitor()
+	host, port := bt.broker.ClientAddress()
+	username, password := bt.broker.ServerCredentials()
+	client := MqttClient("uams-test", host, port, username, password, bt.opts, ing, monitor)
+	defer Disconnect(client, ing, 100*time.Millisecond)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !monitor.Status().AllSubscribed && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	gw, err := bt.connect(t, "gw01", testGatewayPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sync := received(t, gw, "uams/server/sync")
+	gw.Publish("uams/gateway/bootup", 1, false, `{"gateway_id": "gw01", "message": {"version": "1.1.0"}}`).Wait()
+
+	select {
+	case payload := <-sync:
+		if gwId := gjson.Get(payload, "gateway_id").String(); gwId != "gw01" {
+			t.Errorf("got sync of %s, wanted gw01", gwId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("got no sync after bootup")
+	}
+	got, err := bt.opts.GatewaySvc.FindGatewayByGatewayID(context.Background(), "gw01")
+	if err != nil || got.SoftwareVersion != "1.1.0" {
+		t.Errorf("got %+v %v, wanted version of bootup", got, err)
+	}
+}
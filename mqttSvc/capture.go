@@ -0,0 +1,175 @@
+package mqttSvc
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/tidwall/gjson"
+)
+
+const CAPTURE_ENCODING_BASE64 string = "base64"
+
+// CapturedMessage is a line of a capture file. Payload is kept as text
+// unless it is not valid UTF-8, then it is base64 encoded.
+type CapturedMessage struct {
+	Time     time.Time `json:"time"`
+	Topic    string    `json:"topic"`
+	QoS      byte      `json:"qos"`
+	Retained bool      `json:"retained,omitempty"`
+	Payload  string    `json:"payload"`
+	Encoding string    `json:"encoding,omitempty"`
+}
+
+func NewCapturedMessage(receivedAt time.Time, msg mqtt.Message) CapturedMessage {
+	captured := CapturedMessage{
+		Time:     receivedAt,
+		Topic:    msg.Topic(),
+		QoS:      msg.Qos(),
+		Retained: msg.Retained(),
+		Payload:  string(msg.Payload()),
+	}
+	if !utf8.Valid(msg.Payload()) {
+		captured.Payload = base64.StdEncoding.EncodeToString(msg.Payload())
+		captured.Encoding = CAPTURE_ENCODING_BASE64
+	}
+	return captured
+}
+
+func (m CapturedMessage) Bytes() ([]byte, error) {
+	if m.Encoding == CAPTURE_ENCODING_BASE64 {
+		return base64.StdEncoding.DecodeString(m.Payload)
+	}
+	return []byte(m.Payload), nil
+}
+
+// Capture records inbound messages to a file, one JSON object per line, so
+// traffic of a misbehaving gateway can be replayed with cmd/replay
+type Capture struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	err  error
+}
+
+// NewCapture appends to file at path, it is created if missing
+func NewCapture(path string) (*Capture, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open capture file failed, err %s", err.Error())
+	}
+	buf := bufio.NewWriter(file)
+	return &Capture{file: file, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+// Record writes message, the first write error is logged and later messages
+// are not recorded
+func (c *Capture) Record(receivedAt time.Time, msg mqtt.Message) {
+	captured := NewCapturedMessage(receivedAt, msg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if err := c.enc.Encode(captured); err != nil {
+		c.err = err
+		logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Capture stopped, err %s", err.Error())
+		return
+	}
+	// Keep capture readable while server runs, messages come at a human pace
+	if err := c.buf.Flush(); err != nil {
+		c.err = err
+		logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Capture stopped, err %s", err.Error())
+	}
+}
+
+func (c *Capture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf.Flush()
+	return c.file.Close()
+}
+
+type ReplayOptions struct {
+	Speed     float64 // 1 keeps original pace, 10 is ten times faster, 0 does not wait
+	GatewayID string  // replay only messages of this gateway, empty for all
+}
+
+type ReplayStats struct {
+	Read     int            `json:"read"`
+	Skipped  int            `json:"skipped"`
+	Handled  int            `json:"handled"`
+	Rejected map[string]int `json:"rejected"` // by dead letter reason
+}
+
+// ReplayCapture runs messages of a capture through subscribers of their topic
+// in order, c receives what subscribers publish. Rejected messages are only
+// logged and counted, they are not kept as dead letters. Subscribers must be
+// registered, see RegisterSubscribers.
+func (in *Ingestion) ReplayCapture(ctx context.Context, r io.Reader, c mqtt.Client, opts ReplayOptions) (ReplayStats, error) {
+	stats := ReplayStats{Rejected: map[string]int{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var first time.Time
+	start := time.Now()
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		captured := CapturedMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), &captured); err != nil {
+			return stats, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		payload, err := captured.Bytes()
+		if err != nil {
+			return stats, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		stats.Read++
+		if opts.GatewayID != "" && gjson.GetBytes(payload, "gateway_id").String() != opts.GatewayID {
+			stats.Skipped++
+			continue
+		}
+
+		if first.IsZero() {
+			first = captured.Time
+		}
+		if opts.Speed > 0 {
+			at := start.Add(time.Duration(float64(captured.Time.Sub(first)) / opts.Speed))
+			select {
+			case <-time.After(time.Until(at)):
+			case <-ctx.Done():
+				return stats, ctx.Err()
+			}
+		} else if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+
+		in.mu.RLock()
+		handler, ok := in.subscribers[in.baseTopic(captured.Topic)]
+		in.mu.RUnlock()
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		msg := &storedMessage{topic: captured.Topic, payload: payload}
+		if err := in.handle(handler, c, msg, captured.Time); err != nil {
+			stats.Rejected[RejectReason(err)]++
+			logger.LogfWithFields(logger.MQTT, logger.WarnLevel, logger.LoggerFields{
+				"GwMsg": string(payload),
+			}, "Replay rejected message of line %d from topic %s, err %s", line, captured.Topic, err.Error())
+			continue
+		}
+		stats.Handled++
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,149 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func TestCaptureRecords(t *testing.T) {
+	st := newSubscriberTest(t)
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	capture, err := NewCapture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.ing.Capture = capture
+
+	topic, _ := st.ing.Topics.Gateway(TOPIC_GW_LOG, "", "gw01")
+	binary := []byte{0xff, 0x00, 0xfe}
+	st.ing.Enqueue(st.client, &storedMessage{topic: topic, payload: []byte(`{"gateway_id": "gw01"}`)}, nil)
+	st.ing.Enqueue(st.client, &storedMessage{topic: topic, payload: binary}, nil)
+	if err := capture.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := []CapturedMessage{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		captured := CapturedMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), &captured); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, captured)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, wanted every enqueued message, even dropped", len(lines))
+	}
+	if lines[0].Topic != topic || lines[0].QoS != 1 || lines[0].Payload != `{"gateway_id": "gw01"}` || lines[0].Time.IsZero() {
+		t.Errorf("got %+v, wanted message as received", lines[0])
+	}
+	if payload, err := lines[1].Bytes(); lines[1].Encoding != CAPTURE_ENCODING_BASE64 || err != nil || !bytes.Equal(payload, binary) {
+		t.Errorf("got %+v, wanted binary payload base64 encoded", lines[1])
+	}
+}
+
+// capture writes messages of gateway topics as a capture file would hold them
+func capture(t *testing.T, topics *Topics, start time.Time, messages ...[3]string) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for i, m := range messages {
+		gwId := m[1]
+		name, err := topics.Gateway(m[0], "", gwId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := start.Add(time.Duration(i) * 100 * time.Millisecond)
+		if err := enc.Encode(NewCapturedMessage(at, &storedMessage{topic: name, payload: []byte(m[2])})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf
+}
+
+func TestReplayCapture(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "")
+	st.seedGateway(t, 1, "", "gw02", "")
+	r := capture(t, st.ing.Topics, time.Now().Add(-time.Hour),
+		[3]string{TOPIC_GW_GW_CONNECT_STATE, "gw01", `{"gateway_id": "gw01", "message": {"connection_state": "disconnect"}}`},
+		[3]string{TOPIC_GW_GW_CONNECT_STATE, "gw02", `{"gateway_id": "gw02", "message": {"connection_state": "disconnect"}}`},
+		[3]string{TOPIC_GW_BOOTUP, "gw01", `{"gateway_id": "gw01", "message": {"version": "1.2.0"}}`},
+		[3]string{TOPIC_GW_GW_CONNECT_STATE, "gw03", `{"gateway_id": "gw03", "message": {"connection_state": "connect"}}`},
+	)
+
+	stats, err := st.ing.ReplayCapture(context.Background(), r, st.client, ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.ing.Writer.Flush(context.Background())
+	if stats.Read != 4 || stats.Handled != 3 || stats.Rejected[models.DEAD_LETTER_UNKNOWN_GATEWAY] != 1 {
+		t.Errorf("got %+v, wanted 3 handled and unknown gateway rejected", stats)
+	}
+	if gw := st.gateway(t, "gw01"); gw.SoftwareVersion != "1.2.0" || gw.ConnectState != "connect" {
+		t.Errorf("got %s %s, wanted gateway state replayed in order", gw.SoftwareVersion, gw.ConnectState)
+	}
+	if len(st.client.Published()) != 1 {
+		t.Errorf("got %v, wanted sync of bootup published", st.client.Published())
+	}
+	if dls, _ := st.opts.DeadLetterSvc.FindDeadLetters(context.Background(), models.DeadLetterFilter{}); len(dls) != 0 {
+		t.Errorf("got %d dead letters, wanted rejections only counted", len(dls))
+	}
+}
+
+func TestReplayCaptureFilterAndSpeed(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "")
+	st.seedGateway(t, 1, "", "gw02", "")
+	messages := [][3]string{}
+	for i := 0; i < 3; i++ {
+		messages = append(messages,
+			[3]string{TOPIC_GW_GW_CONNECT_STATE, "gw02", `{"gateway_id": "gw02", "message": {"connection_state": "disconnect"}}`},
+			[3]string{TOPIC_GW_GW_CONNECT_STATE, "gw01", `{"gateway_id": "gw01", "message": {"connection_state": "disconnect"}}`},
+		)
+	}
+	// gw01 messages are 200ms apart, twice as fast takes about 200ms
+	r := capture(t, st.ing.Topics, time.Now(), messages...)
+	began := time.Now()
+	stats, err := st.ing.ReplayCapture(context.Background(), r, st.client, ReplayOptions{Speed: 2, GatewayID: "gw01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(began); elapsed < 180*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("got replay in %s, wanted about 200ms", elapsed)
+	}
+	if stats.Read != 6 || stats.Skipped != 3 || stats.Handled != 3 {
+		t.Errorf("got %+v, wanted only gw01 replayed", stats)
+	}
+	st.ing.Writer.Flush(context.Background())
+	if gw := st.gateway(t, "gw02"); gw.ConnectState != "connect" {
+		t.Errorf("got gw02 %s, wanted it untouched", gw.ConnectState)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r = capture(t, st.ing.Topics, time.Now(), messages...)
+	if _, err := st.ing.ReplayCapture(ctx, r, st.client, ReplayOptions{}); err != context.Canceled {
+		t.Errorf("got %v, wanted replay canceled", err)
+	}
+	if _, err := st.ing.ReplayCapture(context.Background(), strings.NewReader("{"), st.client, ReplayOptions{}); err == nil {
+		t.Errorf("got malformed capture replayed")
+	}
+}
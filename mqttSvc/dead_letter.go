@@ -0,0 +1,63 @@
+package mqttSvc
+
+import (
+	"errors"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+// Errors subscribers wrap to reject a message, rejected messages go to dead letters
+var (
+	ErrInvalidPayload = errors.New("invalid payload")
+	ErrUnknownGateway = errors.New("unknown gateway")
+	ErrTrashedGateway = errors.New("gateway deleted by operator")
+	ErrUnknownUHF     = errors.New("unknown UHF")
+	ErrUHFNoArea      = errors.New("UHF has no area")
+	ErrHandlerPanic   = errors.New("handler panic")
+	ErrInvalidTopic   = errors.New("invalid topic")
+	ErrSiteMismatch   = errors.New("gateway belongs to another site")
+	ErrUnknownSite    = errors.New("unknown site")
+)
+
+// RejectReason maps error returned by a subscriber to dead letter reason
+func RejectReason(err error) string {
+	switch {
+	case errors.Is(err, ErrSchemaViolation):
+		return models.DEAD_LETTER_SCHEMA_VIOLATION
+	case errors.Is(err, ErrUnsupportedVersion):
+		return models.DEAD_LETTER_UNSUPPORTED_VERSION
+	case errors.Is(err, ErrInvalidTopic):
+		return models.DEAD_LETTER_INVALID_TOPIC
+	case errors.Is(err, ErrSiteMismatch):
+		return models.DEAD_LETTER_SITE_MISMATCH
+	case errors.Is(err, ErrUnknownSite):
+		return models.DEAD_LETTER_UNKNOWN_SITE
+	case errors.Is(err, ErrInvalidPayload):
+		return models.DEAD_LETTER_INVALID_PAYLOAD
+	case errors.Is(err, ErrUnknownGateway):
+		return models.DEAD_LETTER_UNKNOWN_GATEWAY
+	case errors.Is(err, ErrTrashedGateway):
+		return models.DEAD_LETTER_TRASHED_GATEWAY
+	case errors.Is(err, ErrUnknownUHF):
+		return models.DEAD_LETTER_UNKNOWN_UHF
+	case errors.Is(err, ErrUHFNoArea):
+		return models.DEAD_LETTER_UHF_NO_AREA
+	case errors.Is(err, ErrHandlerPanic):
+		return models.DEAD_LETTER_HANDLER_PANIC
+	}
+	return models.DEAD_LETTER_HANDLER_ERROR
+}
+
+// storedMessage is a message rebuilt from a dead letter for replay
+type storedMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *storedMessage) Duplicate() bool   { return false }
+func (m *storedMessage) Qos() byte         { return 1 }
+func (m *storedMessage) Retained() bool    { return false }
+func (m *storedMessage) Topic() string     { return m.topic }
+func (m *storedMessage) MessageID() uint16 { return 0 }
+func (m *storedMessage) Payload() []byte   { return m.payload }
+func (m *storedMessage) Ack()              {}
@@ -0,0 +1,103 @@
+package mqttSvc
+
+import (
+	"sync"
+	"time"
+)
+
+// Tags are only unique within an organization
+type tagKey struct {
+	organizationID uint
+	tag            string
+}
+
+type tagSighting struct {
+	areaID     string
+	recordedAt time.Time
+}
+
+// ReadDebouncer drops repeated reads of a tag staying in one area. A read is
+// recorded when the tag was not recorded within the window or it moved to
+// another area since its last recorded access. The window is measured in
+// server receive time, clocks of gateways may be off or reads replayed.
+type ReadDebouncer struct {
+	window    time.Duration
+	mu        sync.Mutex
+	sightings map[tagKey]tagSighting
+	lastPrune time.Time
+
+	accepted         uint64
+	suppressed       uint64
+	suppressedByArea map[string]uint64
+}
+
+type DebounceStats struct {
+	Window           string            `json:"window"`
+	TrackedTags      int               `json:"tracked_tags"`
+	Accepted         uint64            `json:"accepted"`
+	Suppressed       uint64            `json:"suppressed"`
+	SuppressedByArea map[string]uint64 `json:"suppressed_by_area"`
+}
+
+// Window <= 0 disables debounce, every read is recorded
+func NewReadDebouncer(window time.Duration) *ReadDebouncer {
+	return &ReadDebouncer{
+		window:           window,
+		sightings:        map[tagKey]tagSighting{},
+		suppressedByArea: map[string]uint64{},
+	}
+}
+
+// Allow reports whether read of tag of an organization in area, received at
+// receivedAt, should be recorded
+func (d *ReadDebouncer) Allow(orgId uint, tag string, areaID string, receivedAt time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.window <= 0 {
+		d.accepted++
+		return true
+	}
+	d.prune(receivedAt)
+
+	key := tagKey{organizationID: orgId, tag: tag}
+	last, ok := d.sightings[key]
+	if ok && last.areaID == areaID && receivedAt.Sub(last.recordedAt) < d.window {
+		d.suppressed++
+		d.suppressedByArea[areaID]++
+		return false
+	}
+	d.sightings[key] = tagSighting{areaID: areaID, recordedAt: receivedAt}
+	d.accepted++
+	return true
+}
+
+// Forget sightings that can't suppress any read anymore, at most once per window
+func (d *ReadDebouncer) prune(now time.Time) {
+	if now.Sub(d.lastPrune) < d.window {
+		return
+	}
+	for key, s := range d.sightings {
+		if now.Sub(s.recordedAt) >= d.window {
+			delete(d.sightings, key)
+		}
+	}
+	d.lastPrune = now
+}
+
+func (d *ReadDebouncer) Stats() DebounceStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	byArea := make(map[string]uint64, len(d.suppressedByArea))
+	for area, cnt := range d.suppressedByArea {
+		byArea[area] = cnt
+	}
+	return DebounceStats{
+		Window:           d.window.String(),
+		TrackedTags:      len(d.sightings),
+		Accepted:         d.accepted,
+		Suppressed:       d.suppressed,
+		SuppressedByArea: byArea,
+	}
+}
@@ -0,0 +1,69 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadDebouncerWindow(t *testing.T) {
+	d := NewReadDebouncer(30 * time.Second)
+	start := time.Now()
+
+	if !d.Allow(1, "tag1", "1", start) {
+		t.Errorf("first read: got %v, wanted %v", false, true)
+	}
+	if d.Allow(1, "tag1", "1", start.Add(10*time.Second)) {
+		t.Errorf("read inside window: got %v, wanted %v", true, false)
+	}
+	if !d.Allow(1, "tag1", "1", start.Add(31*time.Second)) {
+		t.Errorf("read after window: got %v, wanted %v", false, true)
+	}
+
+	stats := d.Stats()
+	if stats.Accepted != 2 || stats.Suppressed != 1 || stats.SuppressedByArea["1"] != 1 {
+		t.Errorf("got %+v, wanted 2 accepted and 1 suppressed in area 1", stats)
+	}
+}
+
+func TestReadDebouncerAreaChange(t *testing.T) {
+	d := NewReadDebouncer(30 * time.Second)
+	start := time.Now()
+
+	d.Allow(1, "tag1", "1", start)
+	if !d.Allow(1, "tag1", "2", start.Add(time.Second)) {
+		t.Errorf("read in other area: got %v, wanted %v", false, true)
+	}
+	if !d.Allow(1, "tag1", "1", start.Add(2*time.Second)) {
+		t.Errorf("read back in first area: got %v, wanted %v", false, true)
+	}
+	if !d.Allow(1, "tag2", "1", start.Add(2*time.Second)) {
+		t.Errorf("read of other tag: got %v, wanted %v", false, true)
+	}
+}
+
+func TestReadDebouncerDisabled(t *testing.T) {
+	d := NewReadDebouncer(0)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !d.Allow(1, "tag1", "1", now) {
+			t.Errorf("got %v, wanted %v", false, true)
+		}
+	}
+}
+
+func TestReadDebouncerOrganizations(t *testing.T) {
+	d := NewReadDebouncer(30 * time.Second)
+	now := time.Now()
+
+	d.Allow(1, "tag1", "1", now)
+	if !d.Allow(2, "tag1", "1", now.Add(time.Second)) {
+		t.Errorf("read of other organization: got %v, wanted %v", false, true)
+	}
+	if d.Allow(1, "tag1", "1", now.Add(2*time.Second)) {
+		t.Errorf("read inside window: got %v, wanted %v", true, false)
+	}
+}
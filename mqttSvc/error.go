@@ -3,13 +3,21 @@ package mqttSvc
 import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
 )
 
+// Publish failures are reported as broker unavailable, unless lookup of
+// the gateway already classified them
 func HandleMqttErr(t mqtt.Token) error {
 	if t == nil || t.Error() == nil {
 		return nil
 	}
 
+	metrics.MqttPublishFailures.Inc()
 	logger.LogWithoutFields(logger.MQTT, logger.ErrorLevel, t.Error())
-	return t.Error()
+	if models.AsDomainError(t.Error()) != nil {
+		return t.Error()
+	}
+	return models.NewUnavailableError(models.ERR_CODE_BROKER_UNAVAILABLE, t.Error())
 }
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"testing"
 	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
 )
 
 type MockToken struct {
@@ -36,6 +38,9 @@ func TestHandleMqttErr(t *testing.T) {
 	if err.Error() != "MQTT Error" {
 		t.Errorf("got %v, wanted %v", err.Error(), "MQTT Error")
 	}
+	if !errors.Is(err, models.ErrUnavailable) {
+		t.Errorf("got %v, wanted %v", err, models.ErrUnavailable)
+	}
 }
 
 func TestHandleMqttNilErr(t *testing.T) {
@@ -0,0 +1,400 @@
+package mqttSvc
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
+	"github.com/tidwall/gjson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type IngestionOptions struct {
+	Workers        int
+	QueueSize      int           // total capacity shared by all workers
+	EnqueueTimeout time.Duration // how long paho callback may block on a full queue
+}
+
+type ingestJob struct {
+	handler    GatewaySubscriber
+	client     mqtt.Client
+	msg        mqtt.Message
+	receivedAt time.Time
+}
+
+// Ingestion moves subscriber work off paho's callback goroutine onto a pool
+// of workers. Messages are sharded by gateway ID so every gateway's messages
+// are still handled in order. Topics map topic a message arrived on back to
+// its subscriber, site and gateway. When a shard is full the callback blocks up to
+// EnqueueTimeout, then the message is dropped. Messages failing schema
+// validation or rejected by a subscriber are kept as dead letters and can
+// be replayed later.
+type Ingestion struct {
+	Topics    *Topics
+	Debouncer *ReadDebouncer
+	Tags      *TagDecoder
+	Schemas   *SchemaValidator
+	Writer    *BatchWriter
+	Capture   *Capture // records every inbound message when set
+
+	deadLetters    models.DeadLetterService
+	subscribers    map[string]GatewaySubscriber
+	queues         []chan ingestJob
+	enqueueTimeout time.Duration
+	mu             sync.RWMutex
+	closed         bool
+	wg             sync.WaitGroup
+
+	statsMu        sync.Mutex
+	enqueued       uint64
+	processed      uint64
+	rejected       uint64
+	failed         uint64
+	overflowed     uint64
+	dropped        uint64
+	droppedByTopic map[string]uint64
+}
+
+type IngestionStats struct {
+	Workers        int                   `json:"workers"`
+	QueueCapacity  int                   `json:"queue_capacity"`
+	QueueLength    int                   `json:"queue_length"`
+	Enqueued       uint64                `json:"enqueued"`
+	Processed      uint64                `json:"processed"`
+	Rejected       uint64                `json:"rejected"`
+	Failed         uint64                `json:"failed"`
+	Overflowed     uint64                `json:"overflowed"`
+	Dropped        uint64                `json:"dropped"`
+	DroppedByTopic map[string]uint64     `json:"dropped_by_topic"`
+	Tags           TagDecoderStats       `json:"tags"`
+	Schemas        *SchemaValidatorStats `json:"schemas,omitempty"`
+	Writer         BatchWriterStats      `json:"writer"`
+}
+
+// schemas may be nil to skip validation, deadLetters may be nil so rejected
+// messages are only logged
+func NewIngestion(
+	opts IngestionOptions,
+	topics *Topics,
+	debouncer *ReadDebouncer,
+	tags *TagDecoder,
+	schemas *SchemaValidator,
+	writer *BatchWriter,
+	deadLetters models.DeadLetterService,
+) *Ingestion {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	shardSize := opts.QueueSize / opts.Workers
+	if shardSize <= 0 {
+		shardSize = 1
+	}
+	queues := make([]chan ingestJob, opts.Workers)
+	for i := range queues {
+		queues[i] = make(chan ingestJob, shardSize)
+	}
+	return &Ingestion{
+		Topics:         topics,
+		Debouncer:      debouncer,
+		Tags:           tags,
+		Schemas:        schemas,
+		Writer:         writer,
+		deadLetters:    deadLetters,
+		subscribers:    map[string]GatewaySubscriber{},
+		queues:         queues,
+		enqueueTimeout: opts.EnqueueTimeout,
+		droppedByTopic: map[string]uint64{},
+	}
+}
+
+func (in *Ingestion) Start() {
+	in.Writer.Start()
+	for _, q := range in.queues {
+		in.wg.Add(1)
+		go func(q chan ingestJob) {
+			defer in.wg.Done()
+			for job := range q {
+				in.process(job)
+			}
+		}(q)
+	}
+}
+
+// Stop refuses new messages, drains the queues then flushes the writer
+func (in *Ingestion) Stop() {
+	in.mu.Lock()
+	if !in.closed {
+		in.closed = true
+		for _, q := range in.queues {
+			close(q)
+		}
+	}
+	in.mu.Unlock()
+	in.wg.Wait()
+	in.Writer.Stop()
+}
+
+// Register keeps subscriber of TOPIC_GW_* topic for replay and returns a
+// callback for its subscriptions that only queues the message
+func (in *Ingestion) Register(topic string, handler GatewaySubscriber) mqtt.MessageHandler {
+	in.mu.Lock()
+	in.subscribers[topic] = handler
+	in.mu.Unlock()
+	return func(c mqtt.Client, msg mqtt.Message) {
+		in.Enqueue(c, msg, handler)
+	}
+}
+
+// Enqueue reports whether message was queued for handler
+func (in *Ingestion) Enqueue(c mqtt.Client, msg mqtt.Message, handler GatewaySubscriber) bool {
+	receivedAt := time.Now()
+	metrics.MqttMessagesReceived.WithLabelValues(in.baseTopic(msg.Topic())).Inc()
+	if in.Capture != nil {
+		in.Capture.Record(receivedAt, msg)
+	}
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if in.closed {
+		in.drop(in.baseTopic(msg.Topic()))
+		return false
+	}
+	job := ingestJob{handler: handler, client: c, msg: msg, receivedAt: receivedAt}
+	q := in.queues[in.shard(msg.Payload())]
+
+	select {
+	case q <- job:
+		in.count(&in.enqueued)
+		return true
+	default:
+	}
+	in.count(&in.overflowed)
+	if in.enqueueTimeout > 0 {
+		timer := time.NewTimer(in.enqueueTimeout)
+		defer timer.Stop()
+		select {
+		case q <- job:
+			in.count(&in.enqueued)
+			return true
+		case <-timer.C:
+		}
+	}
+	in.drop(in.baseTopic(msg.Topic()))
+	logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel,
+		"Ingestion queue full, dropped message from topic %s", msg.Topic())
+	return false
+}
+
+func (in *Ingestion) shard(payload []byte) int {
+	h := fnv.New32a()
+	h.Write([]byte(gjson.GetBytes(payload, "gateway_id").String()))
+	return int(h.Sum32() % uint32(len(in.queues)))
+}
+
+func (in *Ingestion) process(job ingestJob) {
+	err := in.handle(job.handler, job.client, job.msg, job.receivedAt)
+	switch {
+	case err == nil:
+		in.count(&in.processed)
+		return
+	case RejectReason(err) == models.DEAD_LETTER_HANDLER_PANIC:
+		in.count(&in.failed)
+	default:
+		in.count(&in.rejected)
+	}
+	metrics.MqttHandlerErrors.WithLabelValues(in.baseTopic(job.msg.Topic()), RejectReason(err)).Inc()
+	in.deadLetter(job.msg, job.receivedAt, err)
+}
+
+// handle parses topic of message, validates it against its schema then runs handler
+func (in *Ingestion) handle(handler GatewaySubscriber, c mqtt.Client, msg mqtt.Message, receivedAt time.Time) (err error) {
+	ctx, span := in.messageSpan(msg)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, RejectReason(err))
+		}
+		span.End()
+	}()
+
+	gwMsg, err := in.gatewayMessage(msg)
+	if err != nil {
+		return err
+	}
+	gwMsg.ctx = ctx
+	gwMsg.ReceivedAt = receivedAt
+	if in.Schemas != nil {
+		if _, err := in.Schemas.Validate(gwMsg.BaseTopic, msg.Payload()); err != nil {
+			return err
+		}
+	}
+	return in.run(handler, c, gwMsg)
+}
+
+// messageSpan continues correlation ID and trace a gateway echoed from the
+// server message it answers, other messages start new ones
+func (in *Ingestion) messageSpan(msg mqtt.Message) (context.Context, trace.Span) {
+	ctx := tracing.EnsureCorrelationID(context.Background(), gjson.GetBytes(msg.Payload(), "correlation_id").String())
+	ctx = tracing.WithTraceParent(ctx, gjson.GetBytes(msg.Payload(), "traceparent").String())
+	return tracing.Start(ctx, "mqtt.handle "+in.baseTopic(msg.Topic()),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("mqtt.topic", msg.Topic()),
+			attribute.String("uams.gateway_id", gjson.GetBytes(msg.Payload(), "gateway_id").String()),
+		))
+}
+
+// gatewayMessage attributes message to site and gateway of its topic, a
+// gateway may only publish on topics of its own ID
+func (in *Ingestion) gatewayMessage(msg mqtt.Message) (*GatewayMessage, error) {
+	gwTopic, ok := in.Topics.Parse(msg.Topic())
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrInvalidTopic, msg.Topic())
+	}
+	if gwTopic.GatewayID != "" {
+		gwId := gjson.GetBytes(msg.Payload(), "gateway_id").String()
+		if gwId != gwTopic.GatewayID {
+			return nil, fmt.Errorf("%w: gateway_id %s differs from gateway %s of topic", ErrInvalidTopic, gwId, gwTopic.GatewayID)
+		}
+	}
+	return &GatewayMessage{
+		Message:   msg,
+		BaseTopic: gwTopic.Topic,
+		Site:      gwTopic.Site,
+		GatewayID: gwTopic.GatewayID,
+	}, nil
+}
+
+// baseTopic returns TOPIC_GW_* topic of a received topic, or the topic itself
+func (in *Ingestion) baseTopic(name string) string {
+	if gwTopic, ok := in.Topics.Parse(name); ok {
+		return gwTopic.Topic
+	}
+	return name
+}
+
+// run calls handler, a panic is returned as ErrHandlerPanic
+func (in *Ingestion) run(handler GatewaySubscriber, c mqtt.Client, msg *GatewayMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+		}
+	}()
+	return handler(c, msg)
+}
+
+func (in *Ingestion) deadLetter(msg mqtt.Message, receivedAt time.Time, err error) {
+	logger.LogfWithFields(logger.MQTT, logger.WarnLevel, logger.LoggerFields{
+		"GwMsg": string(msg.Payload()),
+	}, "Rejected message from topic %s, err %s", msg.Topic(), err.Error())
+	if in.deadLetters == nil {
+		return
+	}
+	gwTopic, _ := in.Topics.Parse(msg.Topic())
+	_, dlErr := in.deadLetters.CreateDeadLetter(context.Background(), &models.DeadLetter{
+		Topic:      msg.Topic(),
+		GatewayID:  gjson.GetBytes(msg.Payload(), "gateway_id").String(),
+		Site:       gwTopic.Site,
+		Payload:    string(msg.Payload()),
+		Reason:     RejectReason(err),
+		Detail:     err.Error(),
+		ReceivedAt: receivedAt,
+	})
+	if dlErr != nil {
+		logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel,
+			"Store dead letter from topic %s failed, err %s", msg.Topic(), dlErr.Error())
+	}
+}
+
+// Replay runs dead letter through subscriber of its topic. It is deleted when
+// handled, otherwise its reason is updated with the new rejection.
+func (in *Ingestion) Replay(ctx context.Context, id uint) error {
+	if in.deadLetters == nil {
+		return fmt.Errorf("dead letters are not stored")
+	}
+	dl, err := in.deadLetters.FindDeadLetterByID(ctx, fmt.Sprint(id))
+	if err != nil {
+		return err
+	}
+	in.mu.RLock()
+	handler, ok := in.subscribers[in.baseTopic(dl.Topic)]
+	in.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no subscriber for topic %s", dl.Topic)
+	}
+
+	err = in.handle(handler, nil, &storedMessage{topic: dl.Topic, payload: []byte(dl.Payload)}, time.Now())
+	if err != nil {
+		in.deadLetters.MarkDeadLetterReplayed(ctx, dl.ID, RejectReason(err), err.Error())
+		return err
+	}
+	_, err = in.deadLetters.DeleteDeadLetter(ctx, dl.ID)
+	return err
+}
+
+func (in *Ingestion) count(counter *uint64) {
+	in.statsMu.Lock()
+	*counter++
+	in.statsMu.Unlock()
+}
+
+func (in *Ingestion) drop(topic string) {
+	in.statsMu.Lock()
+	in.dropped++
+	in.droppedByTopic[topic]++
+	in.statsMu.Unlock()
+	metrics.MqttMessagesDropped.WithLabelValues(topic).Inc()
+}
+
+// QueueLength is number of messages waiting for a worker
+func (in *Ingestion) QueueLength() int {
+	queueLen := 0
+	for _, q := range in.queues {
+		queueLen += len(q)
+	}
+	return queueLen
+}
+
+func (in *Ingestion) Stats() IngestionStats {
+	queueCap, queueLen := 0, 0
+	for _, q := range in.queues {
+		queueCap += cap(q)
+		queueLen += len(q)
+	}
+
+	var schemas *SchemaValidatorStats
+	if in.Schemas != nil {
+		stats := in.Schemas.Stats()
+		schemas = &stats
+	}
+
+	in.statsMu.Lock()
+	defer in.statsMu.Unlock()
+	byTopic := make(map[string]uint64, len(in.droppedByTopic))
+	for topic, cnt := range in.droppedByTopic {
+		byTopic[topic] = cnt
+	}
+	return IngestionStats{
+		Workers:        len(in.queues),
+		QueueCapacity:  queueCap,
+		QueueLength:    queueLen,
+		Enqueued:       in.enqueued,
+		Processed:      in.processed,
+		Rejected:       in.rejected,
+		Failed:         in.failed,
+		Overflowed:     in.overflowed,
+		Dropped:        in.dropped,
+		DroppedByTopic: byTopic,
+		Tags:           in.Tags.Stats(),
+		Schemas:        schemas,
+		Writer:         in.Writer.Stats(),
+	}
+}
@@ -0,0 +1,138 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/tidwall/gjson"
+)
+
+func gwMessage(gwId string, seq int) mqtt.Message {
+	return &storedMessage{
+		topic:   TOPIC_GW_TAG,
+		payload: []byte(fmt.Sprintf(`{"gateway_id":"%s","message":{"seq":%d}}`, gwId, seq)),
+	}
+}
+
+func newTestIngestion(opts IngestionOptions) *Ingestion {
+	tags, _ := NewTagDecoder(TAG_ENCODING_AUTO, DEFAULT_TAG_TYPES)
+	topics, _ := NewTopics(DEFAULT_TOPIC_PREFIX, nil)
+	return NewIngestion(opts, topics, NewReadDebouncer(0), tags, nil, NewBatchWriter(nil, 10, 0, 100), nil)
+}
+
+func TestIngestionKeepsGatewayOrder(t *testing.T) {
+	in := newTestIngestion(IngestionOptions{Workers: 4, QueueSize: 400, EnqueueTimeout: time.Second})
+	in.Start()
+
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	handler := func(c mqtt.Client, msg *GatewayMessage) error {
+		gwId := gjson.GetBytes(msg.Payload(), "gateway_id").String()
+		seq := int(gjson.GetBytes(msg.Payload(), "message.seq").Int())
+		mu.Lock()
+		seen[gwId] = append(seen[gwId], seq)
+		mu.Unlock()
+		return nil
+	}
+	for seq := 0; seq < 50; seq++ {
+		for _, gwId := range []string{"gw01", "gw02", "gw03"} {
+			in.Enqueue(nil, gwMessage(gwId, seq), handler)
+		}
+	}
+	in.Stop()
+
+	for gwId, seqs := range seen {
+		if len(seqs) != 50 {
+			t.Errorf("%s: got %d messages, wanted 50", gwId, len(seqs))
+		}
+		for i, seq := range seqs {
+			if seq != i {
+				t.Errorf("%s: got seq %d at %d, wanted in order", gwId, seq, i)
+				break
+			}
+		}
+	}
+	if stats := in.Stats(); stats.Processed != 150 || stats.Dropped != 0 {
+		t.Errorf("got %+v, wanted 150 processed and none dropped", stats)
+	}
+}
+
+func TestIngestionDropsWhenFull(t *testing.T) {
+	in := newTestIngestion(IngestionOptions{Workers: 1, QueueSize: 1, EnqueueTimeout: 10 * time.Millisecond})
+	in.Start()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	blocking := func(c mqtt.Client, msg *GatewayMessage) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	}
+	in.Enqueue(nil, gwMessage("gw01", 0), blocking)
+	<-started
+	if !in.Enqueue(nil, gwMessage("gw01", 1), blocking) {
+		t.Errorf("second message: got dropped, wanted queued")
+	}
+	if in.Enqueue(nil, gwMessage("gw01", 2), blocking) {
+		t.Errorf("third message: got queued, wanted dropped")
+	}
+	close(release)
+	in.Stop()
+
+	stats := in.Stats()
+	if stats.Processed != 2 || stats.Overflowed != 1 || stats.Dropped != 1 || stats.DroppedByTopic[TOPIC_GW_TAG] != 1 {
+		t.Errorf("got %+v, wanted 2 processed, 1 overflowed and 1 dropped", stats)
+	}
+}
+
+func TestIngestionCountsRejectedMessages(t *testing.T) {
+	in := newTestIngestion(IngestionOptions{Workers: 1, QueueSize: 10})
+	in.Start()
+	in.Enqueue(nil, gwMessage("gw01", 0), func(c mqtt.Client, msg *GatewayMessage) error {
+		var mem string
+		_ = mem[0:1]
+		return nil
+	})
+	in.Enqueue(nil, gwMessage("gw01", 1), func(c mqtt.Client, msg *GatewayMessage) error {
+		return fmt.Errorf("%w gw01", ErrUnknownGateway)
+	})
+	in.Enqueue(nil, gwMessage("gw01", 2), func(c mqtt.Client, msg *GatewayMessage) error { return nil })
+	in.Stop()
+
+	if stats := in.Stats(); stats.Failed != 1 || stats.Rejected != 1 || stats.Processed != 1 {
+		t.Errorf("got %+v, wanted 1 failed, 1 rejected and 1 processed", stats)
+	}
+	if in.Enqueue(nil, gwMessage("gw01", 3), func(c mqtt.Client, msg *GatewayMessage) error { return nil }) {
+		t.Errorf("enqueue after stop: got queued, wanted dropped")
+	}
+}
+
+func TestRejectReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: tags, unexpected end", ErrInvalidPayload), models.DEAD_LETTER_INVALID_PAYLOAD},
+		{fmt.Errorf("%w gw01", ErrUnknownGateway), models.DEAD_LETTER_UNKNOWN_GATEWAY},
+		{fmt.Errorf("%w gw01", ErrTrashedGateway), models.DEAD_LETTER_TRASHED_GATEWAY},
+		{fmt.Errorf("%w 1 of gateway gw01", ErrUnknownUHF), models.DEAD_LETTER_UNKNOWN_UHF},
+		{fmt.Errorf("%w: UHF 1 of gateway gw01", ErrUHFNoArea), models.DEAD_LETTER_UHF_NO_AREA},
+		{fmt.Errorf("%w: index out of range", ErrHandlerPanic), models.DEAD_LETTER_HANDLER_PANIC},
+		{fmt.Errorf("connection refused"), models.DEAD_LETTER_HANDLER_ERROR},
+	}
+	for _, tt := range tests {
+		if got := RejectReason(tt.err); got != tt.want {
+			t.Errorf("%v: got %s, wanted %s", tt.err, got, tt.want)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
 	"github.com/google/uuid"
 	"github.com/tidwall/gjson"
 )
@@ -62,7 +63,11 @@ func MqttClient(
 	clientID string,
 	host string,
 	port string,
+	username string,
+	password string,
 	optSvc *models.ServiceOptions,
+	ing *Ingestion,
+	monitor *ConnectionMonitor,
 ) mqtt.Client {
 
 	mqtt.ERROR = logger.NewMqttLogger("MQTT ERROR", logger.ErrorLevel)
@@ -79,69 +84,200 @@ func MqttClient(
 	//tlsConfig := NewTlsConfig()
 	//opts.SetTLSConfig(tlsConfig)
 
-	// opts.SetUsername("emqx") // Use this when we want to improve security
-	// opts.SetPassword("public") // Use this when we want to improve security
+	opts.SetUsername(username)
+	opts.SetPassword(password)
 	opts.SetDefaultPublishHandler(messagePubHandler)
-	opts.OnConnect = connectHandler
-	opts.OnConnectionLost = connectLostHandler
+	// Broker forgets subscriptions of a clean session, subscribe on every connect
+	var subscriptions map[string]mqtt.MessageHandler
+	opts.OnConnect = func(c mqtt.Client) {
+		connectHandler(c)
+		monitor.Connected()
+		subscribe(c, subscriptions, monitor)
+	}
+	opts.OnConnectionLost = func(c mqtt.Client, err error) {
+		connectLostHandler(c, err)
+		monitor.Lost(err)
+	}
 	client := mqtt.NewClient(opts)
+	subscriptions = subGateway(client, optSvc, ing)
+	expectSubscriptions(monitor, subscriptions)
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
 		logger.LogWithoutFields(logger.MQTT, logger.PanicLevel, token.Error())
 	}
-	subGateway(client, optSvc)
 
 	return client
 }
 
-type GatewaySubscriber = mqtt.MessageHandler
+// GatewaySubscriber handles a gateway message, returned error rejects it
+// and the message is kept as dead letter
+type GatewaySubscriber func(c mqtt.Client, msg *GatewayMessage) error
+
+// GatewayMessage is a gateway message with the site and gateway of its
+// topic, both empty when topics are not per site
+type GatewayMessage struct {
+	mqtt.Message
+	BaseTopic      string // TOPIC_GW_* topic message arrived on
+	Site           string
+	GatewayID      string
+	OrganizationID uint      // organization of gateway, set by tenantGuard
+	ReceivedAt     time.Time // when server received message, set by Ingestion
+
+	ctx context.Context // correlation ID and span of the message, set by Ingestion
+}
 
-// Define all subscribe logic callbacks for payloads that received from gateway
-func subGateway(client mqtt.Client, optSvc *models.ServiceOptions) {
+// Context scopes queries of a subscriber to organization of the gateway and
+// carries correlation ID the gateway echoed, or a new one. What gateways
+// report leaves revisions as they are.
+func (m *GatewayMessage) Context() context.Context {
+	ctx := m.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return models.WithoutRevision(models.WithTenant(ctx, m.OrganizationID))
+}
+
+func (m *GatewayMessage) CorrelationID() string {
+	return tracing.CorrelationID(m.ctx)
+}
+
+// Define all subscribe logic callbacks for payloads that received from gateway,
+// callbacks only queue messages, ingestion workers run the subscribers.
+// With per site topics every topic is subscribed once per site, callbacks
+// are returned by topic filter.
+func subGateway(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) map[string]mqtt.MessageHandler {
 
 	topicSubscriberMap := map[string]GatewaySubscriber{}
 	topicSubscriberMap[TOPIC_GW_SHUTDOWN] = gwShutDownSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_BOOTUP] = gwBootupSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_UHF_CONNECT_STATE] = gwUHFConnectStateSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_UHF_SCAN] = gwUHFScanSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_TAG] = gwAccessSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_LOG] = gwSystemSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_LASTWILL] = gwLastWillSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_GW_CONNECT_STATE] = gwGatewayConnectStateSubscriber(client, optSvc)
-
+	topicSubscriberMap[TOPIC_GW_BOOTUP] = gwBootupSubscriber(client, optSvc, ing)
+	topicSubscriberMap[TOPIC_GW_UHF_CONNECT_STATE] = gwUHFConnectStateSubscriber(client, optSvc, ing)
+	topicSubscriberMap[TOPIC_GW_UHF_SCAN] = gwUHFScanSubscriber(client, optSvc, ing)
+	topicSubscriberMap[TOPIC_GW_TAG] = gwAccessSubscriber(client, optSvc, ing)
+	topicSubscriberMap[TOPIC_GW_LOG] = gwSystemSubscriber(client, optSvc, ing)
+	topicSubscriberMap[TOPIC_GW_LASTWILL] = gwLastWillSubscriber(client, optSvc, ing)
+	topicSubscriberMap[TOPIC_GW_GW_CONNECT_STATE] = gwGatewayConnectStateSubscriber(client, optSvc, ing)
+	topicSubscriberMap[TOPIC_GW_UPGRADE] = gwUpgradeSubscriber(client, optSvc, ing)
+	topicSubscriberMap[TOPIC_GW_UHF_CONFIG] = gwUHFConfigSubscriber(client, optSvc, ing)
+
+	subscriptions := map[string]mqtt.MessageHandler{}
 	for topic, subscriber := range topicSubscriberMap {
-		t := client.Subscribe(topic, 1, subscriber)
-		if err := HandleMqttErr(t); err == nil {
-			logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-INFO] Subscribed to topic %s", topic)
+		subscriber = tenantGuard(optSvc, subscriber)
+		handler := ing.Register(topic, subscriber)
+		for _, filter := range ing.Topics.Subscriptions(topic) {
+			subscriptions[filter] = handler
+		}
+	}
+	return subscriptions
+}
+
+// RegisterSubscribers registers subscribers of every gateway topic with ing
+// without subscribing, to replay captured messages offline
+func RegisterSubscribers(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) {
+	subGateway(client, optSvc, ing)
+}
+
+// expectSubscriptions tells monitor every filter of subscriptions
+func expectSubscriptions(monitor *ConnectionMonitor, subscriptions map[string]mqtt.MessageHandler) {
+	filters := make([]string, 0, len(subscriptions))
+	for filter := range subscriptions {
+		filters = append(filters, filter)
+	}
+	monitor.Expect(filters...)
+}
+
+func subscribe(client mqtt.Client, subscriptions map[string]mqtt.MessageHandler, monitor *ConnectionMonitor) {
+	for filter, handler := range subscriptions {
+		t := client.Subscribe(filter, 1, handler)
+		t.Wait()
+		err := HandleMqttErr(t)
+		if err == nil {
+			logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-INFO] Subscribed to topic %s", filter)
+		}
+		monitor.Subscription(filter, err)
+	}
+}
+
+// tenantGuard attributes message to organization of its gateway. Gateways not
+// known yet belong to organization of the site they publish in, or to default
+// organization when topics are not per site. A known gateway is rejected on
+// topics of another site, except bootup which moves it to the site it boots
+// in as long as the site belongs to its organization.
+func tenantGuard(optSvc *models.ServiceOptions, subscriber GatewaySubscriber) GatewaySubscriber {
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := models.WithSystemTenant(context.Background())
+		gwId := msg.GatewayID
+		if gwId == "" {
+			gwId = gjson.GetBytes(msg.Payload(), "gateway_id").String()
+		}
+		site, orgId, err := optSvc.GatewaySvc.FindGatewayTenant(ctx, gwId)
+		if err != nil {
+			orgId, err = siteOrganization(ctx, optSvc, msg.Site)
+			if err != nil {
+				return err
+			}
+		} else if msg.Site != "" && site != msg.Site {
+			if msg.BaseTopic != TOPIC_GW_BOOTUP && site != "" {
+				return fmt.Errorf("%w: gateway %s belongs to site %s, not %s", ErrSiteMismatch, gwId, site, msg.Site)
+			}
+			siteOrgId, err := siteOrganization(ctx, optSvc, msg.Site)
+			if err != nil {
+				return err
+			}
+			if siteOrgId != orgId {
+				return fmt.Errorf("%w: site %s belongs to another organization than gateway %s", ErrSiteMismatch, msg.Site, gwId)
+			}
 		}
+		msg.OrganizationID = orgId
+		return subscriber(c, msg)
 	}
 }
 
-func gwGatewayConnectStateSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
+// Organization owning site, default organization when topics are not per site
+func siteOrganization(ctx context.Context, optSvc *models.ServiceOptions, site string) (uint, error) {
+	if site == "" {
+		org, err := optSvc.TenantSvc.FindDefaultOrganization(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("find default organization failed, err %s", err.Error())
+		}
+		return org.ID, nil
+	}
+	s, err := optSvc.TenantSvc.FindSiteByCode(ctx, site)
+	if err != nil {
+		return 0, fmt.Errorf("%w %s", ErrUnknownSite, site)
+	}
+	return s.OrganizationID, nil
+}
+
+func gwGatewayConnectStateSubscriber(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) GatewaySubscriber {
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
 		var payloadStr = string(msg.Payload())
 		gwId := gjson.Get(payloadStr, "gateway_id")
 		gw_connect_state := gjson.Get(payloadStr, "message.connection_state")
 		logger.LogfWithFields(logger.MQTT, logger.InfoLevel, logger.LoggerFields{
 			"GwMsg": payloadStr,
 		}, "Connect state of  ID %s", gwId.String())
-		gw, error := optSvc.GatewaySvc.FindGatewayByGatewayID(context.Background(), gwId.String())
+		gw, error := optSvc.GatewaySvc.FindGatewayByGatewayID(ctx, gwId.String())
 		if error != nil {
-			return
+			return fmt.Errorf("%w %s", ErrUnknownGateway, gwId.String())
 		}
 		gw.ConnectState = gw_connect_state.String()
-		optSvc.GatewaySvc.UpdateGateway(context.Background(), gw)
+		optSvc.GatewaySvc.UpdateGateway(ctx, gw)
 		new_gw_log := &models.GatewayLog{}
+		new_gw_log.OrganizationID = msg.OrganizationID
+		new_gw_log.CorrelationID = msg.CorrelationID()
 		new_gw_log.GatewayID = gwId.String()
 		new_gw_log.StateType = "Connect State"
 		new_gw_log.StateValue = gw_connect_state.String()
 		new_gw_log.LogTime = time.Now()
-		optSvc.LogSvc.CreateGatewayLog(context.Background(), new_gw_log)
-		return
+		ing.Writer.AddGatewayLog(*new_gw_log)
+		reportGatewayState(optSvc, ing, msg, gwId.String(), gjson.Get(payloadStr, "message.state"))
+		return nil
 	}
 }
 
-func gwUHFConnectStateSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
+func gwUHFConnectStateSubscriber(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) GatewaySubscriber {
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
 		var payloadStr = string(msg.Payload())
 		gwId := gjson.Get(payloadStr, "gateway_id")
 		uhf_address := gjson.Get(payloadStr, "message.address")
@@ -150,45 +286,87 @@ func gwUHFConnectStateSubscriber(client mqtt.Client, optSvc *models.ServiceOptio
 		logger.LogfWithFields(logger.MQTT, logger.InfoLevel, logger.LoggerFields{
 			"GwMsg": payloadStr,
 		}, "Connect state of  ID %s", gwId.String())
-		uhf, error := optSvc.UHFSvc.FindUHFByAddress(context.Background(), uhf_address.String(), gwId.String())
+		uhf, error := optSvc.UHFSvc.FindUHFByAddress(ctx, uhf_address.String(), gwId.String())
 		if error != nil {
-			return
+			return fmt.Errorf("%w %s of gateway %s", ErrUnknownUHF, uhf_address.String(), gwId.String())
 		}
 		uhf.ConnectState = uhf_connect_state.String()
-		optSvc.UHFSvc.UpdateUHF(context.Background(), uhf)
+		optSvc.UHFSvc.UpdateUHF(ctx, uhf)
 		time_layout := "2006-01-02 15:04:05"
 		var time_stamp_converted, _ = time.ParseInLocation(time_layout, time_stamp.String(), time.Local)
 		new_uhf_log := &models.UHFStatusLog{}
+		new_uhf_log.OrganizationID = msg.OrganizationID
+		new_uhf_log.CorrelationID = msg.CorrelationID()
 		new_uhf_log.GatewayID = gwId.String()
 		new_uhf_log.UHFAddress = uhf_address.String()
 		new_uhf_log.StateType = "Connect State"
 		new_uhf_log.StateValue = uhf_connect_state.String()
 		new_uhf_log.Time = time_stamp_converted
-		optSvc.UHFStatusLogSvc.CreateUHFStatusLog(context.Background(), new_uhf_log)
-		return
+		ing.Writer.AddUHFStatusLog(*new_uhf_log)
+		reportUHFState(optSvc, ing, msg, gwId.String(), uhf_address.String(), gjson.Get(payloadStr, "message.state").String())
+		return nil
+	}
+}
+
+// Gateway reports the config UHF is running, keep it to compare with desired config
+func gwUHFConfigSubscriber(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) GatewaySubscriber {
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
+		var payloadStr = string(msg.Payload())
+		var config models.UHFReaderConfig
+		gwId := gjson.Get(payloadStr, "gateway_id")
+		uhf_address := gjson.Get(payloadStr, "message.address")
+		err := json.Unmarshal([]byte(gjson.Get(payloadStr, "message.config").Raw), &config)
+		if err != nil {
+			logger.LogfWithFields(logger.MQTT, logger.ErrorLevel, logger.LoggerFields{
+				"GwMsg": payloadStr,
+			}, "Invalid UHF config from gateway ID %s", gwId.String())
+			return fmt.Errorf("%w: config, %s", ErrInvalidPayload, err.Error())
+		}
+		config.Normalize()
+		_, err = optSvc.UHFConfigSvc.UpdateUHFReportedConfig(ctx, uhf_address.String(), gwId.String(), config)
+		if err != nil {
+			return fmt.Errorf("%w %s of gateway %s", ErrUnknownUHF, uhf_address.String(), gwId.String())
+		}
+		configJson, _ := json.Marshal(config)
+		new_uhf_log := &models.UHFStatusLog{}
+		new_uhf_log.OrganizationID = msg.OrganizationID
+		new_uhf_log.CorrelationID = msg.CorrelationID()
+		new_uhf_log.GatewayID = gwId.String()
+		new_uhf_log.UHFAddress = uhf_address.String()
+		new_uhf_log.StateType = "Reported Config"
+		new_uhf_log.StateValue = string(configJson)
+		new_uhf_log.Time = time.Now()
+		ing.Writer.AddUHFStatusLog(*new_uhf_log)
+		return nil
 	}
 }
 
-func gwLastWillSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
+func gwLastWillSubscriber(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) GatewaySubscriber {
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
 		var payloadStr = string(msg.Payload())
 		gwId := gjson.Get(payloadStr, "gateway_id")
 		logger.LogfWithoutFields(logger.MQTT, logger.DebugLevel, "Gateway ID %s has disconnect", gwId.String())
-		gw, _ := optSvc.GatewaySvc.FindGatewayByGatewayID(context.Background(), gwId.String())
-		if gw != nil {
-			gw.ConnectState = "disconnect"
-			_, err := optSvc.GatewaySvc.UpdateGatewayConnectState(context.Background(), gw.GatewayID, gw.ConnectState)
-			if err != nil {
-				logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel,
-					"Update connect_state for gateway ID %s failed, err %s", gwId.String(), err.Error())
-			}
-			new_gateway_log := &models.GatewayLog{}
-			new_gateway_log.GatewayID = gwId.String()
-			new_gateway_log.StateType = "Connect State"
-			new_gateway_log.StateValue = "disconnect"
-			new_gateway_log.LogTime = time.Now()
-			optSvc.LogSvc.CreateGatewayLog(context.Background(), new_gateway_log)
+		gw, _ := optSvc.GatewaySvc.FindGatewayByGatewayID(ctx, gwId.String())
+		if gw == nil {
+			return fmt.Errorf("%w %s", ErrUnknownGateway, gwId.String())
 		}
+		gw.ConnectState = "disconnect"
+		_, err := optSvc.GatewaySvc.UpdateGatewayConnectState(ctx, gw.GatewayID, gw.ConnectState)
+		if err != nil {
+			logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel,
+				"Update connect_state for gateway ID %s failed, err %s", gwId.String(), err.Error())
+		}
+		new_gateway_log := &models.GatewayLog{}
+		new_gateway_log.OrganizationID = msg.OrganizationID
+		new_gateway_log.CorrelationID = msg.CorrelationID()
+		new_gateway_log.GatewayID = gwId.String()
+		new_gateway_log.StateType = "Connect State"
+		new_gateway_log.StateValue = "disconnect"
+		new_gateway_log.LogTime = time.Now()
+		ing.Writer.AddGatewayLog(*new_gateway_log)
+		return nil
 	}
 }
 
@@ -211,29 +389,33 @@ func gwLastWillSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqt
 //	}
 //}
 
-func gwSystemSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
+func gwSystemSubscriber(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) GatewaySubscriber {
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
 		var payloadStr = string(msg.Payload())
 		gwId := gjson.Get(payloadStr, "gateway_id")
 		log := gjson.Get(payloadStr, "message.log").String()
 		time_layout := "2006-01-02 15:04:05"
 		var time_stamp, _ = time.ParseInLocation(time_layout, gjson.Get(payloadStr, "message.timestamp").String(), time.Local)
-		_, error := optSvc.GatewaySvc.FindGatewayByGatewayID(context.Background(), gwId.String())
+		_, error := optSvc.GatewaySvc.FindGatewayByGatewayID(ctx, gwId.String())
 		if error != nil {
-			return
+			return fmt.Errorf("%w %s", ErrUnknownGateway, gwId.String())
 		}
 		new_operation_log := &models.OperationLog{}
+		new_operation_log.OrganizationID = msg.OrganizationID
+		new_operation_log.CorrelationID = msg.CorrelationID()
 		new_operation_log.GatewayID = gwId.String()
 		new_operation_log.Content = "DEBUG_MODE"
 		new_operation_log.Content = log
 		new_operation_log.Time = time_stamp
-		optSvc.OperationLogSvc.CreateOperationLog(context.Background(), new_operation_log)
-		return
+		ing.Writer.AddOperationLog(*new_operation_log)
+		return nil
 	}
 }
 
-func gwAccessSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
+func gwAccessSubscriber(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) GatewaySubscriber {
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
 		var payloadStr = string(msg.Payload())
 		var tags_list []UHFTagInfo
 
@@ -245,64 +427,89 @@ func gwAccessSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.
 
 		err := json.Unmarshal([]byte(tags_string), &tags_list)
 		if err != nil {
-			return
+			return fmt.Errorf("%w: tags, %s", ErrInvalidPayload, err.Error())
 		}
-		existing_uhf, error := optSvc.UHFSvc.FindUHFByAddress(context.Background(), uhf_address.String(), gwId.String())
+		existing_uhf, error := optSvc.UHFSvc.FindUHFByAddress(ctx, uhf_address.String(), gwId.String())
 		if error != nil {
-			return
+			return fmt.Errorf("%w %s of gateway %s", ErrUnknownUHF, uhf_address.String(), gwId.String())
 		}
 		if existing_uhf.AreaId == "" {
-			return
+			return fmt.Errorf("%w: UHF %s of gateway %s", ErrUHFNoArea, uhf_address.String(), gwId.String())
 		}
 		for _, item := range tags_list {
 			var mem = item.Mem
-			var time_stamp, _ = time.ParseInLocation(time_layout, item.TimeStamp, time.Local)
-			var access_type = mem[0:1]
-			var access_group = mem[1:3]
-			var access_id = mem[3:13]
-			var access_random = mem[13:16]
-			if access_type == "U" {
+			var time_stamp, err = time.ParseInLocation(time_layout, item.TimeStamp, time.Local)
+			if err != nil {
+				time_stamp = time.Now()
+			}
+			tag := item.EPC
+			if tag == "" {
+				tag = mem
+			}
+			if !ing.Debouncer.Allow(msg.OrganizationID, tag, existing_uhf.AreaId, msg.ReceivedAt) {
+				continue
+			}
+			access, err := ing.Tags.Decode(mem)
+			if err != nil {
+				ing.Writer.AddTagReadError(models.TagReadError{
+					TenantModel: models.TenantModel{OrganizationID: msg.OrganizationID},
+					Time:        time_stamp,
+					GatewayID:   gwId.String(),
+					UHFAddress:  uhf_address.String(),
+					AreaID:      existing_uhf.AreaId,
+					EPC:         item.EPC,
+					Mem:         mem,
+					Reason:      TagErrorReason(err),
+					Detail:      err.Error(),
+				})
+				continue
+			}
+			if access.Kind == TAG_KIND_USER {
 				var new_user_access = &models.UserAccess{}
-				new_user_access.UserID = access_id
-				new_user_access.Random = access_random
-				new_user_access.Group = access_group
+				new_user_access.OrganizationID = msg.OrganizationID
+				new_user_access.UserID = access.ID
+				new_user_access.Random = access.Random
+				new_user_access.Group = access.Group
 				new_user_access.AreaID = existing_uhf.AreaId
 				new_user_access.Time = time_stamp
-				optSvc.UserAccessSvc.CreateUserAccess(context.Background(), new_user_access)
-			} else if access_type == "P" {
+				ing.Writer.AddUserAccess(*new_user_access)
+			} else if access.Kind == TAG_KIND_PACKAGE {
 				var new_package_access = &models.PackageAccess{}
-				new_package_access.PackageID = access_id
-				new_package_access.Random = access_random
-				new_package_access.Group = access_group
+				new_package_access.OrganizationID = msg.OrganizationID
+				new_package_access.PackageID = access.ID
+				new_package_access.Random = access.Random
+				new_package_access.Group = access.Group
 				new_package_access.AreaID = existing_uhf.AreaId
 				new_package_access.Time = time_stamp
-				optSvc.PackageAccessSvc.CreatePackageAccess(context.Background(), new_package_access)
+				ing.Writer.AddPackageAccess(*new_package_access)
 			}
 
 		}
-		return
+		return nil
 	}
 }
 
-func gwUHFScanSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
+func gwUHFScanSubscriber(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) GatewaySubscriber {
+	publisher := NewPublisher(client, ing.Topics, optSvc.GatewaySvc)
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
 		var payloadStr = string(msg.Payload())
 		uhf_list := []map[string]string{}
 		gwId := gjson.Get(payloadStr, "gateway_id")
 		uhfs := gjson.Get(payloadStr, "message.uhfs")
 		err := json.Unmarshal([]byte(uhfs.String()), &uhf_list)
 		if err != nil {
-			return
+			return fmt.Errorf("%w: uhfs, %s", ErrInvalidPayload, err.Error())
 		}
-		_, err = optSvc.GatewaySvc.FindGatewayByGatewayID(context.Background(), gwId.String())
+		_, err = optSvc.GatewaySvc.FindGatewayByGatewayID(ctx, gwId.String())
 		if err != nil {
-			return
+			return fmt.Errorf("%w %s", ErrUnknownGateway, gwId.String())
 		}
 		logger.LogfWithFields(logger.MQTT, logger.DebugLevel, logger.LoggerFields{
 			"payload": payloadStr,
 		}, "Gateway bootup with ID %s and uhfs %s", gwId.String(), uhfs.String())
 		for _, uhf := range uhf_list {
-			existing_uhf, err := optSvc.UHFSvc.FindUHFByAddress(context.Background(), uhf["address"], gwId.String())
+			existing_uhf, err := optSvc.UHFSvc.FindUHFByAddress(ctx, uhf["address"], gwId.String())
 			if err != nil {
 				newUHF := &models.UHF{}
 				newUHF.GatewayID = gwId.String()
@@ -310,26 +517,36 @@ func gwUHFScanSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt
 				newUHF.Version = uhf["version"]
 				newUHF.ConnectState = "connect"
 				newUHF.ActiveState = "inactive"
+				newUHF.DesiredState = "inactive"
+				newUHF.ReportedState = "inactive"
+				if uhf["state"] != "" {
+					now := time.Now()
+					newUHF.ActiveState = uhf["state"]
+					newUHF.ReportedState = uhf["state"]
+					newUHF.ReportedAt = &now
+				}
 				newUHF.UHFAddress = uhf["address"]
 				newUHF.UHFSerialNumber = uuid.New().String()
-				optSvc.UHFSvc.CreateUHF(context.Background(), newUHF)
+				optSvc.UHFSvc.CreateUHF(ctx, newUHF)
 			} else {
 				existing_uhf.Family = uhf["family"]
 				existing_uhf.Version = uhf["version"]
-				optSvc.UHFSvc.UpdateUHF(context.Background(), existing_uhf)
+				optSvc.UHFSvc.UpdateUHF(ctx, existing_uhf)
+				reportUHFState(optSvc, ing, msg, gwId.String(), uhf["address"], uhf["state"])
 			}
 		}
-		checkGw, _ := optSvc.GatewaySvc.FindGatewayByGatewayID(context.Background(), gwId.String())
+		checkGw, _ := optSvc.GatewaySvc.FindGatewayByGatewayID(ctx, gwId.String())
 		uhf_in_db_list := checkGw.UHFs
 		for _, uhf_in_db := range uhf_in_db_list {
 			if check_exist(uhf_in_db, uhf_list) == false {
-				optSvc.UHFSvc.DeleteUHF(context.Background(), strconv.FormatUint(uint64(uhf_in_db.ID), 10))
+				optSvc.UHFSvc.DeleteUHF(ctx, strconv.FormatUint(uint64(uhf_in_db.ID), 10), 0)
 			}
 		}
-		checkGw_again, _ := optSvc.GatewaySvc.FindGatewayByGatewayID(context.Background(), gwId.String())
-		t := client.Publish(TOPIC_SV_SYNC, 1, false, ServerBootupSystemPayload(gwId.String(), checkGw_again.UHFs))
+		checkGw_again, _ := optSvc.GatewaySvc.FindGatewayByGatewayID(ctx, gwId.String())
+		t := publisher.PublishToSite(ctx, TOPIC_SV_SYNC, msg.Site, gwId.String(),
+			ServerBootupSystemPayload(gwId.String(), checkGw_again.UHFs))
 		HandleMqttErr(t)
-		return
+		return nil
 	}
 }
 
@@ -342,20 +559,69 @@ func check_exist(uhf_to_check models.UHF, list_to_check []map[string]string) boo
 	return false
 }
 
-func gwShutDownSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
+func gwShutDownSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) GatewaySubscriber {
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
 		var payloadStr = string(msg.Payload())
 		gwId := gjson.Get(payloadStr, "gateway_id")
 		gwMsg := gjson.Get(payloadStr, "message")
 		logger.LogfWithFields(logger.MQTT, logger.InfoLevel, logger.LoggerFields{
 			"GwMsg": gwMsg.String(),
 		}, "Receive gateway shutdown message with ID %s", gwId.String())
-		optSvc.GatewaySvc.DeleteGateway(context.Background(), gwId.String())
+		_, err := optSvc.GatewaySvc.DeleteGateway(ctx, gwId.String(), 0, models.TRASHED_BY_SYSTEM)
+		if err != nil {
+			return fmt.Errorf("%w %s", ErrUnknownGateway, gwId.String())
+		}
+		return nil
+	}
+}
+
+// Gateway reports firmware upgrade progress: downloading, installing, success, failed
+func gwUpgradeSubscriber(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) GatewaySubscriber {
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
+		var payloadStr = string(msg.Payload())
+		gwId := gjson.Get(payloadStr, "gateway_id").String()
+		campaignId := uint(gjson.Get(payloadStr, "message.campaign_id").Uint())
+		status := gjson.Get(payloadStr, "message.status").String()
+		logger.LogfWithFields(logger.MQTT, logger.InfoLevel, logger.LoggerFields{
+			"GwMsg": payloadStr,
+		}, "Upgrade status of gateway ID %s is %s", gwId, status)
+
+		targetStatus := ""
+		switch status {
+		case "downloading":
+			targetStatus = models.TARGET_DOWNLOADING
+		case "installing":
+			targetStatus = models.TARGET_INSTALLING
+		case "success", "succeeded":
+			targetStatus = models.TARGET_SUCCEEDED
+		case "failed", "error":
+			targetStatus = models.TARGET_FAILED
+		default:
+			return fmt.Errorf("%w: unknown upgrade status %q", ErrInvalidPayload, status)
+		}
+		_, err := optSvc.RolloutSvc.UpdateTargetStatus(ctx, campaignId, gwId, targetStatus,
+			gjson.Get(payloadStr, "message.error").String())
+		if err != nil {
+			return fmt.Errorf("update rollout %d target %s failed, err %s", campaignId, gwId, err.Error())
+		}
+		new_gateway_log := &models.GatewayLog{}
+		new_gateway_log.OrganizationID = msg.OrganizationID
+		new_gateway_log.CorrelationID = msg.CorrelationID()
+		new_gateway_log.GatewayID = gwId
+		new_gateway_log.StateType = "Upgrade State"
+		new_gateway_log.StateValue = targetStatus
+		new_gateway_log.LogTime = time.Now()
+		ing.Writer.AddGatewayLog(*new_gateway_log)
+		return nil
 	}
 }
 
-func gwBootupSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
+func gwBootupSubscriber(client mqtt.Client, optSvc *models.ServiceOptions, ing *Ingestion) GatewaySubscriber {
+	publisher := NewPublisher(client, ing.Topics, optSvc.GatewaySvc)
+	return func(c mqtt.Client, msg *GatewayMessage) error {
+		ctx := msg.Context()
 		var payloadStr = string(msg.Payload())
 		gwId := gjson.Get(payloadStr, "gateway_id")
 		gw_string := gwId.String()
@@ -363,37 +629,111 @@ func gwBootupSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.
 			"payload": payloadStr,
 		}, "Gateway bootup with ID %s", gw_string)
 
-		checkGw, _ := optSvc.GatewaySvc.FindGatewayByGatewayID(context.Background(), gwId.String())
+		checkGw, _ := optSvc.GatewaySvc.FindGatewayByGatewayID(ctx, gwId.String())
+		if checkGw == nil {
+			if trashedGw, _ := optSvc.TrashSvc.FindTrashedGateway(ctx, gw_string); trashedGw != nil {
+				// gateway shut down before comes back from trash with its UHFs,
+				// one an operator deleted stays there until restored by hand
+				if trashedGw.TrashedBy != models.TRASHED_BY_SYSTEM {
+					logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel,
+						"Gateway ID %s booted up while deleted by operator, restore it from trash first", gw_string)
+					return fmt.Errorf("%w %s", ErrTrashedGateway, gw_string)
+				}
+				checkGw, _ = optSvc.TrashSvc.RestoreGateway(ctx, gw_string)
+			}
+		}
 
 		if checkGw == nil {
 			newGw := &models.Gateway{}
 			newGw.GatewayID = gwId.String()
+			newGw.Site = msg.Site
 			newGw.ConnectState = "connect"
 			newGw.SoftwareVersion = gjson.Get(payloadStr, "message.version").String()
-			optSvc.GatewaySvc.CreateGateway(context.Background(), newGw)
+			if state := gjson.Get(payloadStr, "message.state"); state.Exists() {
+				now := time.Now()
+				newGw.ReportedState = state.String()
+				newGw.ReportedAt = &now
+			}
+			optSvc.GatewaySvc.CreateGateway(ctx, newGw)
 			uhfs := []models.UHF{}
 			new_gateway_log := &models.GatewayLog{}
+			new_gateway_log.OrganizationID = msg.OrganizationID
+			new_gateway_log.CorrelationID = msg.CorrelationID()
 			new_gateway_log.GatewayID = gwId.String()
 			new_gateway_log.StateType = "Connect State"
 			new_gateway_log.StateValue = "connect"
 			new_gateway_log.LogTime = time.Now()
-			optSvc.LogSvc.CreateGatewayLog(context.Background(), new_gateway_log)
-			t := client.Publish(TOPIC_SV_SYNC, 1, false, ServerBootupSystemPayload(gwId.String(), uhfs))
+			ing.Writer.AddGatewayLog(*new_gateway_log)
+			t := publisher.PublishToSite(ctx, TOPIC_SV_SYNC, msg.Site, gwId.String(), ServerBootupSystemPayload(gwId.String(), uhfs))
 			HandleMqttErr(t)
-			return
+			return nil
 		}
 		checkGw.SoftwareVersion = gjson.Get(payloadStr, "message.version").String()
+		optSvc.RolloutSvc.ConfirmGatewayVersion(ctx, gwId.String(), checkGw.SoftwareVersion)
 		checkGw.ConnectState = "connect"
-		optSvc.GatewaySvc.UpdateGateway(context.Background(), checkGw)
+		if msg.Site != "" && checkGw.Site != msg.Site {
+			logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel,
+				"Gateway ID %s moved from site %q to %q", gw_string, checkGw.Site, msg.Site)
+			checkGw.Site = msg.Site
+		}
+		optSvc.GatewaySvc.UpdateGateway(ctx, checkGw)
+		reportGatewayState(optSvc, ing, msg, gwId.String(), gjson.Get(payloadStr, "message.state"))
 		uhfs := checkGw.UHFs
-		t := client.Publish(TOPIC_SV_SYNC, 1, false, ServerBootupSystemPayload(gwId.String(), uhfs))
+		t := publisher.PublishToSite(ctx, TOPIC_SV_SYNC, msg.Site, gwId.String(), ServerBootupSystemPayload(gwId.String(), uhfs))
 		HandleMqttErr(t)
 		new_gateway_log := &models.GatewayLog{}
+		new_gateway_log.OrganizationID = msg.OrganizationID
+		new_gateway_log.CorrelationID = msg.CorrelationID()
 		new_gateway_log.GatewayID = gwId.String()
 		new_gateway_log.StateType = "Connect State"
 		new_gateway_log.StateValue = "connect"
 		new_gateway_log.LogTime = time.Now()
-		optSvc.LogSvc.CreateGatewayLog(context.Background(), new_gateway_log)
+		ing.Writer.AddGatewayLog(*new_gateway_log)
+		return nil
+	}
+}
+
+// Keep the state gateway has applied as reported state of its twin
+func reportGatewayState(optSvc *models.ServiceOptions, ing *Ingestion, msg *GatewayMessage, gwId string, state gjson.Result) {
+	if !state.Exists() {
+		return
+	}
+	ctx := msg.Context()
+	_, err := optSvc.GatewaySvc.UpdateGatewayReportedState(ctx, gwId, state.String())
+	if err != nil {
+		logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel,
+			"Update reported state for gateway ID %s failed, err %s", gwId, err.Error())
+		return
+	}
+	new_gateway_log := &models.GatewayLog{}
+	new_gateway_log.OrganizationID = msg.OrganizationID
+	new_gateway_log.CorrelationID = msg.CorrelationID()
+	new_gateway_log.GatewayID = gwId
+	new_gateway_log.StateType = "Reported State"
+	new_gateway_log.StateValue = state.String()
+	new_gateway_log.LogTime = time.Now()
+	ing.Writer.AddGatewayLog(*new_gateway_log)
+}
+
+// Keep the active state UHF has applied as reported state of its twin
+func reportUHFState(optSvc *models.ServiceOptions, ing *Ingestion, msg *GatewayMessage, gwId string, address string, state string) {
+	if state == "" {
+		return
+	}
+	ctx := msg.Context()
+	_, err := optSvc.UHFSvc.UpdateUHFReportedState(ctx, address, gwId, state)
+	if err != nil {
+		logger.LogfWithContext(ctx, logger.MQTT, logger.ErrorLevel, nil,
+			"Update reported state for UHF %s of gateway ID %s failed, err %s", address, gwId, err.Error())
 		return
 	}
+	new_uhf_log := &models.UHFStatusLog{}
+	new_uhf_log.OrganizationID = msg.OrganizationID
+	new_uhf_log.CorrelationID = msg.CorrelationID()
+	new_uhf_log.GatewayID = gwId
+	new_uhf_log.UHFAddress = address
+	new_uhf_log.StateType = "Reported State"
+	new_uhf_log.StateValue = state
+	new_uhf_log.Time = time.Now()
+	ing.Writer.AddUHFStatusLog(*new_uhf_log)
 }
@@ -4,37 +4,569 @@
 package mqttSvc
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ecoprohcm/DMS_BackendServer/fakes"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/tidwall/gjson"
 )
 
-func TestParseDoorlockPayload(t *testing.T) {
-	payload := fmt.Sprintf(`{
-		"gateway_id":"%s",
-		"message": {
-		"doorlock_address":"%s",
-		"doorlock_active_state":"%s",
+// subscriberTest runs every gateway subscriber against fake services the way
+// ingestion workers do, behind tenantGuard
+type subscriberTest struct {
+	store         *fakes.Store
+	opts          *models.ServiceOptions
+	client        *fakes.Client
+	ing           *Ingestion
+	subscriptions int
+}
+
+func newSubscriberTest(t *testing.T, sites ...string) *subscriberTest {
+	t.Helper()
+	store := fakes.NewStore()
+	opts := fakes.NewServiceOptions(store)
+	topics, err := NewTopics(DEFAULT_TOPIC_PREFIX, sites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := NewTagDecoder(TAG_ENCODING_AUTO, DEFAULT_TAG_TYPES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &subscriberTest{
+		store:  store,
+		opts:   opts,
+		client: fakes.NewClient(),
+		ing: NewIngestion(IngestionOptions{}, topics, NewReadDebouncer(0), tags, nil,
+			NewBatchWriter(opts, 10, 0, 100), opts.DeadLetterSvc),
+	}
+	st.subscriptions = len(subGateway(st.client, opts, st.ing))
+	return st
+}
+
+// deliver handles payload of gateway on topic of site, then writes what the
+// subscriber buffered
+func (st *subscriberTest) deliver(t *testing.T, topic string, site string, payload string) error {
+	t.Helper()
+	name, err := st.ing.Topics.Gateway(topic, site, gjson.Get(payload, "gateway_id").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.ing.mu.RLock()
+	handler := st.ing.subscribers[topic]
+	st.ing.mu.RUnlock()
+	err = st.ing.handle(handler, st.client, &storedMessage{topic: name, payload: []byte(payload)}, time.Now())
+	st.ing.Writer.Flush(context.Background())
+	return err
+}
+
+// seedGateway creates gateway in organization orgId with UHFs in area areaId
+func (st *subscriberTest) seedGateway(t *testing.T, orgId uint, site string, gwId string, areaId string, addresses ...string) {
+	t.Helper()
+	ctx := models.WithTenant(context.Background(), orgId)
+	_, err := st.opts.GatewaySvc.CreateGateway(ctx, &models.Gateway{
+		GatewayID:       gwId,
+		Site:            site,
+		ConnectState:    "connect",
+		SoftwareVersion: "1.0.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range addresses {
+		_, err := st.opts.UHFSvc.CreateUHF(ctx, &models.UHF{
+			GatewayID:       gwId,
+			UHFAddress:      address,
+			UHFSerialNumber: gwId + "-" + address,
+			AreaId:          areaId,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (st *subscriberTest) gateway(t *testing.T, gwId string) *models.Gateway {
+	t.Helper()
+	gw, err := st.opts.GatewaySvc.FindGatewayByGatewayID(context.Background(), gwId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gw
+}
+
+func (st *subscriberTest) uhf(t *testing.T, gwId string, address string) *models.UHF {
+	t.Helper()
+	uhf, err := st.opts.UHFSvc.FindUHFByAddress(context.Background(), address, gwId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uhf
+}
+
+// gatewayLogs returns state values of gateway logs of stateType
+func (st *subscriberTest) gatewayLogs(gwId string, stateType string) []string {
+	values := []string{}
+	logs, _ := st.opts.LogSvc.FindGatewayByGatewayID(context.Background(), gwId)
+	for _, gl := range *logs {
+		if gl.StateType == stateType {
+			values = append(values, gl.StateValue)
+		}
+	}
+	return values
+}
+
+func TestSubscribeMonitorsEveryFilter(t *testing.T) {
+	st := newSubscriberTest(t, "hcm", "hn")
+	monitor := NewConnectionMonitor()
+	subscriptions := subGateway(st.client, st.opts, st.ing)
+	expectSubscriptions(monitor, subscriptions)
+	if monitor.Status().AllSubscribed {
+		t.Fatalf("got all subscribed before subscribing")
+	}
+	subscribe(st.client, subscriptions, monitor)
+
+	status := monitor.Status()
+	if !status.AllSubscribed || len(status.Subscribed) != st.subscriptions || st.subscriptions != 2*len(gatewayTopics) {
+		t.Errorf("got %d of %d subscribed, wanted every topic of both sites", len(status.Subscribed), st.subscriptions)
+	}
+	if !st.client.Subscribed("uams/hcm/gateway/+/uhf/tag") {
+		t.Errorf("tag topic of site hcm not subscribed")
+	}
+}
+
+func TestGatewayConnectStateSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "")
+	revision := st.gateway(t, "gw01").Revision.Revision
+
+	err := st.deliver(t, TOPIC_GW_GW_CONNECT_STATE, "",
+		`{"gateway_id": "gw01", "correlation_id": "req-1", "message": {"connection_state": "disconnect", "state": "active"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := st.gateway(t, "gw01")
+	if gw.ConnectState != "disconnect" || gw.ReportedState != "active" {
+		t.Errorf("got %s %s, wanted disconnect active", gw.ConnectState, gw.ReportedState)
+	}
+	if gw.Revision.Revision != revision {
+		t.Errorf("got revision %d, wanted %d kept by gateway report", gw.Revision.Revision, revision)
+	}
+	if logs := st.gatewayLogs("gw01", "Connect State"); len(logs) != 1 || logs[0] != "disconnect" {
+		t.Errorf("got %v, wanted connect state logged", logs)
+	}
+	if logs := st.gatewayLogs("gw01", "Reported State"); len(logs) != 1 || logs[0] != "active" {
+		t.Errorf("got %v, wanted reported state logged", logs)
+	}
+	logs, _ := st.opts.LogSvc.FindGatewayByGatewayID(context.Background(), "gw01")
+	for _, gl := range *logs {
+		if gl.CorrelationID != "req-1" {
+			t.Errorf("got %q for %s, wanted correlation ID of message", gl.CorrelationID, gl.StateType)
+		}
+	}
+
+	err = st.deliver(t, TOPIC_GW_GW_CONNECT_STATE, "", `{"gateway_id": "gw02", "message": {"connection_state": "connect"}}`)
+	if !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("got %v, wanted %v", err, ErrUnknownGateway)
+	}
+}
+
+func TestUHFConnectStateSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "", "1")
+
+	err := st.deliver(t, TOPIC_GW_UHF_CONNECT_STATE, "",
+		`{"gateway_id": "gw01", "message": {"address": "1", "connection_state": "disconnect", "timestamp": "2022-03-01 08:00:00", "state": "inactive"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uhf := st.uhf(t, "gw01", "1")
+	if uhf.ConnectState != "disconnect" || uhf.ReportedState != "inactive" {
+		t.Errorf("got %s %s, wanted disconnect inactive", uhf.ConnectState, uhf.ReportedState)
+	}
+	logs, _ := st.opts.UHFStatusLogSvc.GetUHFStatusLogByUHFAddress(context.Background(), "1", "gw01")
+	if len(logs) != 2 || logs[0].Time.Format("2006-01-02 15:04:05") != "2022-03-01 08:00:00" {
+		t.Errorf("got %+v, wanted connect state logged at its timestamp and reported state", logs)
+	}
+
+	err = st.deliver(t, TOPIC_GW_UHF_CONNECT_STATE, "", `{"gateway_id": "gw01", "message": {"address": "9"}}`)
+	if !errors.Is(err, ErrUnknownUHF) {
+		t.Errorf("got %v, wanted %v", err, ErrUnknownUHF)
+	}
+}
+
+func TestUHFConfigSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "", "1")
+
+	err := st.deliver(t, TOPIC_GW_UHF_CONFIG, "",
+		`{"gateway_id": "gw01", "message": {"address": "1", "config": {"rf_power": 30, "antennas": [2, 1], "session": 1, "q": 4, "target": "A"}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uhf := st.uhf(t, "gw01", "1")
+	if uhf.ReportedConfig == nil || uhf.ReportedConfig.RFPower != 30 || uhf.ConfigReportedAt == nil {
+		t.Errorf("got %+v, wanted reported config kept", uhf.ReportedConfig)
+	}
+
+	err = st.deliver(t, TOPIC_GW_UHF_CONFIG, "", `{"gateway_id": "gw01", "message": {"address": "1", "config": "full power"}}`)
+	if !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("got %v, wanted %v", err, ErrInvalidPayload)
+	}
+	err = st.deliver(t, TOPIC_GW_UHF_CONFIG, "", `{"gateway_id": "gw01", "message": {"address": "9", "config": {}}}`)
+	if !errors.Is(err, ErrUnknownUHF) {
+		t.Errorf("got %v, wanted %v", err, ErrUnknownUHF)
+	}
+}
+
+func TestLastWillSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "")
+
+	if err := st.deliver(t, TOPIC_GW_LASTWILL, "", `{"gateway_id": "gw01"}`); err != nil {
+		t.Fatal(err)
+	}
+	if gw := st.gateway(t, "gw01"); gw.ConnectState != "disconnect" {
+		t.Errorf("got %s, wanted disconnect", gw.ConnectState)
+	}
+	if logs := st.gatewayLogs("gw01", "Connect State"); len(logs) != 1 {
+		t.Errorf("got %v, wanted disconnect logged", logs)
+	}
+
+	if err := st.deliver(t, TOPIC_GW_LASTWILL, "", `{"gateway_id": "gw02"}`); !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("got %v, wanted %v", err, ErrUnknownGateway)
+	}
+}
+
+func TestSystemSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "")
+
+	err := st.deliver(t, TOPIC_GW_LOG, "", `{"gateway_id": "gw01", "message": {"log": "reader 1 reset", "timestamp": "2022-03-01 08:00:00"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	olList, _ := st.opts.OperationLogSvc.GetOperationLogByGatewayID(context.Background(), "gw01")
+	if len(olList) != 1 || olList[0].Content != "reader 1 reset" || olList[0].OrganizationID != 1 {
+		t.Errorf("got %+v, wanted log of gateway kept in its organization", olList)
+	}
+
+	if err := st.deliver(t, TOPIC_GW_LOG, "", `{"gateway_id": "gw02", "message": {"log": "boot"}}`); !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("got %v, wanted %v", err, ErrUnknownGateway)
+	}
+}
+
+func TestAccessSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "7", "1")
+	st.seedGateway(t, 1, "", "gw02", "", "1")
+
+	err := st.deliver(t, TOPIC_GW_TAG, "", `{"gateway_id": "gw01", "message": {"address": "1", "tags": [
+		{"epc": "e1", "mem": "U01ABCDE12345XYZ", "timestamp": "2022-03-01 08:00:00"},
+		{"epc": "e2", "mem": "P020000000042R01", "timestamp": "2022-03-01 08:00:01"},
+		{"epc": "e3", "mem": "X01ABCDE12345XYZ", "timestamp": "2022-03-01 08:00:02"}
+	]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaList, _ := st.opts.UserAccessSvc.FindAllUserAccessByAreaID(context.Background(), "7")
+	if len(uaList) != 1 || uaList[0].UserID != "ABCDE12345" {
+		t.Errorf("got %+v, wanted user access in area of UHF", uaList)
 	}
-		
-	}`, "test", "test", "test")
-	dl := parseDoorlockPayload(payload)
-	expected := &models.Doorlock{
-		GatewayID:       "test",
-		DoorlockAddress: "test",
-		ActiveState:     "test",
+	paList, _ := st.opts.PackageAccessSvc.FindAllPackageAccessByAreaID(context.Background(), "7")
+	if len(*paList) != 1 || (*paList)[0].PackageID != "0000000042" {
+		t.Errorf("got %+v, wanted package access in area of UHF", *paList)
 	}
+	teList, _ := st.opts.TagReadErrorSvc.FindTagReadErrors(context.Background(), models.TagReadErrorFilter{})
+	if len(teList) != 1 || teList[0].EPC != "e3" {
+		t.Errorf("got %+v, wanted unknown tag type kept as read error", teList)
+	}
+
+	cases := []struct {
+		payload string
+		err     error
+	}{
+		{`{"gateway_id": "gw01", "message": {"address": "1", "tags": "e1"}}`, ErrInvalidPayload},
+		{`{"gateway_id": "gw01", "message": {"address": "9", "tags": []}}`, ErrUnknownUHF},
+		{`{"gateway_id": "gw02", "message": {"address": "1", "tags": []}}`, ErrUHFNoArea},
+	}
+	for i, c := range cases {
+		if err := st.deliver(t, TOPIC_GW_TAG, "", c.payload); !errors.Is(err, c.err) {
+			t.Errorf("case %d: got %v, wanted %v", i, err, c.err)
+		}
+	}
+}
 
-	if expected.GatewayID != dl.GatewayID {
-		t.Errorf("got %+v, wanted %+v", dl.GatewayID, expected.GatewayID)
+func TestAccessSubscriberDebounce(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.ing.Debouncer = NewReadDebouncer(time.Minute)
+	st.seedGateway(t, 1, "", "gw01", "7", "1")
+	st.seedGateway(t, 2, "", "gw02", "7", "1")
+
+	// Timestamps of gateways don't move the window, reads of the same tag
+	// in other organizations are recorded
+	reads := []struct {
+		gwId      string
+		timestamp string
+	}{
+		{"gw01", "2099-01-01 08:00:00"},
+		{"gw01", "2099-01-01 09:00:00"},
+		{"gw01", "2022-03-01 08:00:00"},
+		{"gw02", "2022-03-01 08:00:00"},
+	}
+	for _, r := range reads {
+		payload := fmt.Sprintf(`{"gateway_id": "%s", "message": {"address": "1", "tags": [
+			{"epc": "e1", "mem": "U01ABCDE12345XYZ", "timestamp": "%s"}
+		]}}`, r.gwId, r.timestamp)
+		if err := st.deliver(t, TOPIC_GW_TAG, "", payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, orgId := range []uint{1, 2} {
+		ctx := models.WithTenant(context.Background(), orgId)
+		uaList, _ := st.opts.UserAccessSvc.FindAllUserAccessByAreaID(ctx, "7")
+		if len(uaList) != 1 {
+			t.Errorf("organization %d: got %d user accesses, wanted 1", orgId, len(uaList))
+		}
 	}
+}
+
+func TestUHFScanSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "", "1", "2")
 
-	if expected.DoorlockAddress != dl.DoorlockAddress {
-		t.Errorf("got %+v, wanted %+v", dl.DoorlockAddress, expected.DoorlockAddress)
+	err := st.deliver(t, TOPIC_GW_UHF_SCAN, "", `{"gateway_id": "gw01", "message": {"uhfs": [
+		{"address": "1", "family": "R2000", "version": "2.1", "state": "active"},
+		{"address": "3", "family": "R2000", "version": "2.0"}
+	]}}`)
+	if err != nil {
+		t.Fatal(err)
 	}
+	gw := st.gateway(t, "gw01")
+	addresses := []string{}
+	for _, uhf := range gw.UHFs {
+		addresses = append(addresses, uhf.UHFAddress)
+	}
+	if strings.Join(addresses, ",") != "1,3" {
+		t.Errorf("got %v, wanted UHFs 1 and 3 after scan", addresses)
+	}
+	if uhf := st.uhf(t, "gw01", "1"); uhf.Version != "2.1" || uhf.ReportedState != "active" {
+		t.Errorf("got %s %s, wanted scanned version and state", uhf.Version, uhf.ReportedState)
+	}
+	if uhf := st.uhf(t, "gw01", "3"); uhf.DesiredState != "inactive" || uhf.OrganizationID != 1 {
+		t.Errorf("got %s %d, wanted new UHF inactive in organization of gateway", uhf.DesiredState, uhf.OrganizationID)
+	}
+	published := st.client.Published()
+	if len(published) != 1 || published[0].Topic != "uams/server/sync" {
+		t.Errorf("got %+v, wanted sync", published)
+	}
+
+	if err := st.deliver(t, TOPIC_GW_UHF_SCAN, "", `{"gateway_id": "gw01", "message": {"uhfs": 1}}`); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("got %v, wanted %v", err, ErrInvalidPayload)
+	}
+	if err := st.deliver(t, TOPIC_GW_UHF_SCAN, "", `{"gateway_id": "gw02", "message": {"uhfs": []}}`); !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("got %v, wanted %v", err, ErrUnknownGateway)
+	}
+}
+
+func TestShutDownSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "")
+
+	if err := st.deliver(t, TOPIC_GW_SHUTDOWN, "", `{"gateway_id": "gw01", "message": {}}`); err != nil {
+		t.Fatal(err)
+	}
+	if gw, _ := st.opts.GatewaySvc.FindGatewayByGatewayID(context.Background(), "gw01"); gw != nil {
+		t.Errorf("got %+v, wanted gateway deleted", gw)
+	}
+	if err := st.deliver(t, TOPIC_GW_SHUTDOWN, "", `{"gateway_id": "gw01", "message": {}}`); !errors.Is(err, ErrUnknownGateway) {
+		t.Errorf("got %v, wanted %v", err, ErrUnknownGateway)
+	}
+}
+
+func TestUpgradeSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "")
+	ctx := context.Background()
+	fw, err := st.opts.FirmwareSvc.CreateFirmware(ctx, &models.Firmware{Version: "1.1.0"}, strings.NewReader("firmware"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := st.opts.RolloutSvc.CreateRollout(ctx, &models.RolloutCampaign{Name: "q3", FirmwareID: fw.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := func() string {
+		targets, _ := st.opts.RolloutSvc.FindRolloutByID(ctx, fmt.Sprint(r.ID))
+		for _, target := range targets.Targets {
+			return target.Status
+		}
+		return ""
+	}
+
+	cases := []struct {
+		status string
+		want   string
+	}{
+		{"downloading", models.TARGET_DOWNLOADING},
+		{"installing", models.TARGET_INSTALLING},
+		{"success", models.TARGET_SUCCEEDED},
+	}
+	for _, c := range cases {
+		payload := fmt.Sprintf(`{"gateway_id": "gw01", "message": {"campaign_id": %d, "status": "%s"}}`, r.ID, c.status)
+		if err := st.deliver(t, TOPIC_GW_UPGRADE, "", payload); err != nil {
+			t.Fatal(err)
+		}
+		if got := status(); got != c.want {
+			t.Errorf("%s: got %s, wanted %s", c.status, got, c.want)
+		}
+	}
+	if logs := st.gatewayLogs("gw01", "Upgrade State"); len(logs) != 3 {
+		t.Errorf("got %v, wanted every upgrade state logged", logs)
+	}
+
+	err = st.deliver(t, TOPIC_GW_UPGRADE, "", `{"gateway_id": "gw01", "message": {"campaign_id": 1, "status": "rebooting"}}`)
+	if !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("got %v, wanted %v", err, ErrInvalidPayload)
+	}
+	if err := st.deliver(t, TOPIC_GW_UPGRADE, "", `{"gateway_id": "gw01", "message": {"campaign_id": 999, "status": "failed"}}`); err == nil {
+		t.Errorf("got nil, wanted error for unknown campaign")
+	}
+}
 
-	if expected.ActiveState != dl.ActiveState {
-		t.Errorf("got %+v, wanted %+v", dl.ActiveState, expected.ActiveState)
+func TestBootupSubscriber(t *testing.T) {
+	st := newSubscriberTest(t)
+
+	if err := st.deliver(t, TOPIC_GW_BOOTUP, "", `{"gateway_id": "gw01", "message": {"version": "1.0.0", "state": "active"}}`); err != nil {
+		t.Fatal(err)
+	}
+	gw := st.gateway(t, "gw01")
+	if gw.SoftwareVersion != "1.0.0" || gw.ReportedState != "active" || gw.OrganizationID != 1 {
+		t.Errorf("got %+v, wanted new gateway in default organization", gw)
+	}
+
+	st.opts.UHFSvc.CreateUHF(models.WithTenant(context.Background(), 1), &models.UHF{GatewayID: "gw01", UHFAddress: "1", UHFSerialNumber: "s1"})
+	if err := st.deliver(t, TOPIC_GW_BOOTUP, "", `{"gateway_id": "gw01", "message": {"version": "1.1.0", "state": "inactive"}}`); err != nil {
+		t.Fatal(err)
+	}
+	gw = st.gateway(t, "gw01")
+	if gw.SoftwareVersion != "1.1.0" || gw.ReportedState != "inactive" {
+		t.Errorf("got %s %s, wanted version and state of bootup", gw.SoftwareVersion, gw.ReportedState)
+	}
+	if logs := st.gatewayLogs("gw01", "Connect State"); len(logs) != 2 {
+		t.Errorf("got %v, wanted connect logged on every bootup", logs)
+	}
+	published := st.client.Published()
+	if len(published) != 2 || !strings.Contains(published[1].Payload, `"1"`) {
+		t.Errorf("got %+v, wanted sync with UHFs of gateway", published)
+	}
+}
+
+func TestBootupRestoresGateway(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "7", "1", "2")
+	if err := st.deliver(t, TOPIC_GW_SHUTDOWN, "", `{"gateway_id": "gw01", "message": {}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	// configuration of a gateway shut down comes back when it boots again
+	if err := st.deliver(t, TOPIC_GW_BOOTUP, "", `{"gateway_id": "gw01", "message": {"version": "1.1.0", "state": "active"}}`); err != nil {
+		t.Fatal(err)
+	}
+	gw := st.gateway(t, "gw01")
+	if len(gw.UHFs) != 2 || gw.UHFs[0].AreaId != "7" || gw.SoftwareVersion != "1.1.0" || gw.TrashedBy != "" {
+		t.Errorf("got %+v, wanted gateway restored with its UHFs in area 7", gw)
+	}
+	trash, _ := st.opts.TrashSvc.FindTrash(context.Background())
+	if len(trash.Gateways)+len(trash.UHFs) != 0 {
+		t.Errorf("got %+v, wanted trash empty", trash)
+	}
+	published := st.client.Published()
+	if sync := published[len(published)-1]; !strings.Contains(sync.Payload, `"1"`) || !strings.Contains(sync.Payload, `"2"`) {
+		t.Errorf("got %+v, wanted sync with restored UHFs", sync)
+	}
+}
+
+func TestBootupKeepsOperatorTrash(t *testing.T) {
+	st := newSubscriberTest(t)
+	st.seedGateway(t, 1, "", "gw01", "7", "1")
+	if _, err := st.opts.GatewaySvc.DeleteGateway(context.Background(), "gw01", 0, models.TRASHED_BY_OPERATOR); err != nil {
+		t.Fatal(err)
+	}
+	published := len(st.client.Published())
+
+	// a gateway deleted by an operator stays in trash when it boots again
+	err := st.deliver(t, TOPIC_GW_BOOTUP, "", `{"gateway_id": "gw01", "message": {"version": "1.1.0"}}`)
+	if !errors.Is(err, ErrTrashedGateway) || RejectReason(err) != models.DEAD_LETTER_TRASHED_GATEWAY {
+		t.Fatalf("got %v, wanted %v", err, ErrTrashedGateway)
+	}
+	if gw, _ := st.opts.GatewaySvc.FindGatewayByGatewayID(context.Background(), "gw01"); gw != nil {
+		t.Errorf("got %+v, wanted gateway left in trash", gw)
+	}
+	trash, _ := st.opts.TrashSvc.FindTrash(context.Background())
+	if len(trash.Gateways) != 1 || trash.Gateways[0].TrashedBy != models.TRASHED_BY_OPERATOR || len(trash.UHFs) != 1 {
+		t.Errorf("got %+v, wanted gateway and its UHF in trash", trash)
+	}
+	if len(st.client.Published()) != published {
+		t.Errorf("got %+v, wanted nothing sent to gateway", st.client.Published())
+	}
+}
+
+func TestTenantGuard(t *testing.T) {
+	st := newSubscriberTest(t, TOPIC_ALL_SITES)
+	ctx := context.Background()
+	acme, _ := st.opts.TenantSvc.CreateOrganization(ctx, &models.Organization{Name: "acme"})
+	beta, _ := st.opts.TenantSvc.CreateOrganization(ctx, &models.Organization{Name: "beta"})
+	for _, site := range []models.Site{
+		{TenantModel: models.TenantModel{OrganizationID: acme.ID}, Code: "hcm"},
+		{TenantModel: models.TenantModel{OrganizationID: acme.ID}, Code: "dn"},
+		{TenantModel: models.TenantModel{OrganizationID: beta.ID}, Code: "hn"},
+	} {
+		site := site
+		if _, err := st.opts.TenantSvc.CreateSite(ctx, &site); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// unknown gateway joins organization of the site it boots in
+	if err := st.deliver(t, TOPIC_GW_BOOTUP, "hcm", `{"gateway_id": "gw01", "message": {"version": "1.0.0"}}`); err != nil {
+		t.Fatal(err)
+	}
+	if gw := st.gateway(t, "gw01"); gw.OrganizationID != acme.ID || gw.Site != "hcm" {
+		t.Errorf("got %d %s, wanted gateway in site hcm of acme", gw.OrganizationID, gw.Site)
+	}
+
+	cases := []struct {
+		topic string
+		site  string
+		err   error
+	}{
+		{TOPIC_GW_LOG, "hn", ErrSiteMismatch},
+		{TOPIC_GW_LOG, "dn", ErrSiteMismatch},
+		{TOPIC_GW_BOOTUP, "hn", ErrSiteMismatch},
+		{TOPIC_GW_BOOTUP, "hue", ErrUnknownSite},
+		{TOPIC_GW_LOG, "hcm", nil},
+	}
+	for i, c := range cases {
+		err := st.deliver(t, c.topic, c.site, `{"gateway_id": "gw01", "message": {"version": "1.0.0"}}`)
+		if !errors.Is(err, c.err) {
+			t.Errorf("case %d: got %v, wanted %v", i, err, c.err)
+		}
+	}
+
+	// bootup moves gateway to another site of its organization
+	if err := st.deliver(t, TOPIC_GW_BOOTUP, "dn", `{"gateway_id": "gw01", "message": {"version": "1.0.0"}}`); err != nil {
+		t.Fatal(err)
+	}
+	if gw := st.gateway(t, "gw01"); gw.Site != "dn" {
+		t.Errorf("got %s, wanted gateway moved to dn", gw.Site)
+	}
+	if err := st.deliver(t, TOPIC_GW_BOOTUP, "hue", `{"gateway_id": "gw02", "message": {}}`); !errors.Is(err, ErrUnknownSite) {
+		t.Errorf("got %v, wanted %v", err, ErrUnknownSite)
 	}
 }
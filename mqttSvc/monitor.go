@@ -0,0 +1,123 @@
+package mqttSvc
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Broker connection events kept for status
+const CONNECTION_HISTORY_SIZE int = 20
+
+const (
+	CONNECTION_EVENT_CONNECTED string = "connected"
+	CONNECTION_EVENT_LOST      string = "lost"
+)
+
+type ConnectionEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Error string    `json:"error,omitempty"`
+}
+
+// ConnectionMonitor follows broker connection and gateway subscriptions of
+// the client, subscriptions are made again on every connect
+type ConnectionMonitor struct {
+	mu            sync.Mutex
+	connects      uint64
+	losses        uint64
+	history       []ConnectionEvent
+	expected      []string          // filters subscribed on every connect
+	subscriptions map[string]string // filter -> error, empty when subscribed
+}
+
+type ConnectionStatus struct {
+	Connects      uint64            `json:"connects"`
+	Losses        uint64            `json:"losses"`
+	History       []ConnectionEvent `json:"history"`
+	Subscribed    []string          `json:"subscribed"`
+	FailedTopics  map[string]string `json:"failed_topics,omitempty"`
+	MissingTopics []string          `json:"missing_topics,omitempty"` // expected but not subscribed yet
+	AllSubscribed bool              `json:"all_subscribed"`
+}
+
+func NewConnectionMonitor() *ConnectionMonitor {
+	return &ConnectionMonitor{
+		subscriptions: map[string]string{},
+	}
+}
+
+func (m *ConnectionMonitor) Connected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connects++
+	m.subscriptions = map[string]string{}
+	m.record(ConnectionEvent{Time: time.Now(), Event: CONNECTION_EVENT_CONNECTED})
+}
+
+func (m *ConnectionMonitor) Lost(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.losses++
+	event := ConnectionEvent{Time: time.Now(), Event: CONNECTION_EVENT_LOST}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	m.record(event)
+}
+
+// Expect sets filters client subscribes to on every connect, all of them
+// must be subscribed before AllSubscribed is reported
+func (m *ConnectionMonitor) Expect(filters ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expected = append([]string{}, filters...)
+	sort.Strings(m.expected)
+}
+
+// Subscription keeps result of subscribing filter
+func (m *ConnectionMonitor) Subscription(filter string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.subscriptions[filter] = err.Error()
+		return
+	}
+	m.subscriptions[filter] = ""
+}
+
+func (m *ConnectionMonitor) record(event ConnectionEvent) {
+	m.history = append(m.history, event)
+	if len(m.history) > CONNECTION_HISTORY_SIZE {
+		m.history = m.history[len(m.history)-CONNECTION_HISTORY_SIZE:]
+	}
+}
+
+func (m *ConnectionMonitor) Status() ConnectionStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := ConnectionStatus{
+		Connects:   m.connects,
+		Losses:     m.losses,
+		History:    append([]ConnectionEvent{}, m.history...),
+		Subscribed: []string{},
+	}
+	for filter, errMsg := range m.subscriptions {
+		if errMsg == "" {
+			status.Subscribed = append(status.Subscribed, filter)
+			continue
+		}
+		if status.FailedTopics == nil {
+			status.FailedTopics = map[string]string{}
+		}
+		status.FailedTopics[filter] = errMsg
+	}
+	sort.Strings(status.Subscribed)
+	for _, filter := range m.expected {
+		if _, ok := m.subscriptions[filter]; !ok {
+			status.MissingTopics = append(status.MissingTopics, filter)
+		}
+	}
+	status.AllSubscribed = len(m.expected) > 0 && status.MissingTopics == nil && status.FailedTopics == nil
+	return status
+}
@@ -0,0 +1,55 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConnectionMonitorSubscriptions(t *testing.T) {
+	m := NewConnectionMonitor()
+	if m.Status().AllSubscribed {
+		t.Errorf("got all subscribed before connect")
+	}
+
+	m.Expect("uams/+/gateway/log", "uams/+/gateway/bootup")
+	m.Connected()
+	m.Subscription("uams/+/gateway/log", nil)
+	if status := m.Status(); status.AllSubscribed || len(status.MissingTopics) != 1 {
+		t.Errorf("got %+v, wanted bootup subscription missing", status)
+	}
+	m.Subscription("uams/+/gateway/bootup", errors.New("not authorized"))
+	status := m.Status()
+	if status.AllSubscribed || status.FailedTopics["uams/+/gateway/bootup"] != "not authorized" {
+		t.Errorf("got %+v, wanted failed bootup subscription", status)
+	}
+
+	// Reconnect subscribes again from scratch
+	m.Lost(errors.New("EOF"))
+	m.Connected()
+	m.Subscription("uams/+/gateway/log", nil)
+	m.Subscription("uams/+/gateway/bootup", nil)
+	status = m.Status()
+	if !status.AllSubscribed || len(status.Subscribed) != 2 {
+		t.Errorf("got %+v, wanted all subscribed after reconnect", status)
+	}
+	if status.Connects != 2 || status.Losses != 1 || len(status.History) != 3 {
+		t.Errorf("got connects %d losses %d history %d, wanted 2 1 3",
+			status.Connects, status.Losses, len(status.History))
+	}
+	if status.History[1].Event != CONNECTION_EVENT_LOST || status.History[1].Error != "EOF" {
+		t.Errorf("got %+v, wanted lost event with error", status.History[1])
+	}
+}
+
+func TestConnectionMonitorHistoryBounded(t *testing.T) {
+	m := NewConnectionMonitor()
+	for i := 0; i < CONNECTION_HISTORY_SIZE+5; i++ {
+		m.Connected()
+	}
+	if n := len(m.Status().History); n != CONNECTION_HISTORY_SIZE {
+		t.Errorf("got history %d, wanted %d", n, CONNECTION_HISTORY_SIZE)
+	}
+}
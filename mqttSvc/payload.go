@@ -2,14 +2,63 @@ package mqttSvc
 
 import (
 	"encoding/json"
-	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/google/uuid"
 )
 
+// Envelope wraps every server to gateway message. MessageID lets gateway
+// drop duplicates of QoS 1 deliveries, Timestamp is unix time of creation.
+// CorrelationID and TraceParent are stamped by Publisher from context of the
+// request, gateways echo them in their response.
+type Envelope struct {
+	GatewayID       string      `json:"gateway_id"`
+	MessageID       string      `json:"message_id"`
+	Timestamp       int64       `json:"timestamp"`
+	ProtocolVersion int         `json:"protocol_version"`
+	CorrelationID   string      `json:"correlation_id,omitempty"`
+	TraceParent     string      `json:"traceparent,omitempty"`
+	Message         interface{} `json:"message"`
+}
+
+func NewEnvelope(gwId string, msg interface{}) *Envelope {
+	return &Envelope{
+		GatewayID:       gwId,
+		MessageID:       uuid.NewString(),
+		Timestamp:       time.Now().Unix(),
+		ProtocolVersion: DEFAULT_PROTOCOL_VERSION,
+		Message:         msg,
+	}
+}
+
+type UHFStatePayload struct {
+	Address string `json:"address"`
+	State   string `json:"state"`
+}
+
+type UHFAddressPayload struct {
+	Address string `json:"address"`
+}
+
+type GatewayStatePayload struct {
+	State string `json:"state"`
+}
+
+type GatewayCmdPayload struct {
+	Action string `json:"action"`
+}
+
+type SecretKeyPayload struct {
+	SecretKey string `json:"secret_key"`
+}
+
+// Message of commands carrying nothing but gateway ID, encoded as {}
+type EmptyPayload struct{}
+
 type UserIDPassword struct {
 	UserId     string `json:"user_id"`
 	RfidPass   string `json:"rfid_pw"`
@@ -46,29 +95,78 @@ type SyncPayload struct {
 	State string           `json:"state"`
 }
 
+// Changes of several UHFs of a gateway, sent by bulk operations
+type UHFBatchPayload struct {
+	Updates []UHFStatePayload   `json:"updates"`
+	Deletes []UHFAddressPayload `json:"deletes"`
+}
+
+type UpgradePayload struct {
+	CampaignID uint   `json:"campaign_id"`
+	Version    string `json:"version"`
+	Checksum   string `json:"checksum"`
+	Size       int64  `json:"size"`
+	Url        string `json:"url"`
+}
+
+type UHFConfigPayload struct {
+	Address string                 `json:"address"`
+	Config  models.UHFReaderConfig `json:"config"`
+}
+
 func ServerUpdateUHFPayload(uhf *models.UHF) string {
-	msg := fmt.Sprintf(`{"address":"%s","state":"%s"}`,
-		uhf.UHFAddress, uhf.ActiveState)
+	msg := UHFStatePayload{
+		Address: uhf.UHFAddress,
+		State:   uhfTargetState(uhf),
+	}
+	return PayloadWithGatewayId(uhf.GatewayID, msg)
+}
+
+func ServerUpdateUHFConfigPayload(uhf *models.UHF) string {
+	msg := UHFConfigPayload{Address: uhf.UHFAddress}
+	if uhf.DesiredConfig != nil {
+		msg.Config = *uhf.DesiredConfig
+		msg.Config.Normalize()
+	}
 	return PayloadWithGatewayId(uhf.GatewayID, msg)
 }
 
 func ServerDeleteUHFPayload(uhf *models.UHF) string {
-	msg := fmt.Sprintf(`{"address":"%s"}`, uhf.UHFAddress)
+	msg := UHFAddressPayload{Address: uhf.UHFAddress}
 	return PayloadWithGatewayId(uhf.GatewayID, msg)
 }
 
+// ServerBatchUHFPayload asks gateway gwId for target state of updated UHFs
+// and to remove deleted ones, in a single message
+func ServerBatchUHFPayload(gwId string, updated []models.UHF, deleted []models.UHF) string {
+	msg := UHFBatchPayload{Updates: []UHFStatePayload{}, Deletes: []UHFAddressPayload{}}
+	for i := range updated {
+		msg.Updates = append(msg.Updates, UHFStatePayload{Address: updated[i].UHFAddress, State: uhfTargetState(&updated[i])})
+	}
+	for _, uhf := range deleted {
+		msg.Deletes = append(msg.Deletes, UHFAddressPayload{Address: uhf.UHFAddress})
+	}
+	return PayloadWithGatewayId(gwId, msg)
+}
+
 func ServerUpdateGatewayPayload(gw *models.Gateway) string {
-	msg := fmt.Sprintf(`{"state":"%s"}`, gw.ConnectState)
+	msg := GatewayStatePayload{State: gw.DesiredState}
 	return PayloadWithGatewayId(gw.GatewayID, msg)
 }
 
 func ServerDeleteGatewayPayload(gwID string) string {
-	msg := `{}`
-	return PayloadWithGatewayId(gwID, msg)
+	return PayloadWithGatewayId(gwID, EmptyPayload{})
 }
 
-func PayloadWithGatewayId(gwId string, msg string) string {
-	return fmt.Sprintf(`{"gateway_id":"%s","message":%s}`, gwId, msg)
+// PayloadWithGatewayId wraps msg in a new envelope and encodes it
+func PayloadWithGatewayId(gwId string, msg interface{}) string {
+	payload, err := json.Marshal(NewEnvelope(gwId, msg))
+	if err != nil {
+		logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel,
+			"Encode payload of gateway %s failed, err %s", gwId, err.Error())
+		return ""
+	}
+	return string(payload)
 }
 
 func getDayMonthYearSlice(str string) []int {
@@ -85,12 +183,12 @@ func getDayMonthYearSlice(str string) []int {
 }
 
 func ServerUpdateSecretKeyPayload(gwId string, secretKey string) string {
-	msg := fmt.Sprintf(`{"secret_key":"%s"}`, secretKey)
+	msg := SecretKeyPayload{SecretKey: secretKey}
 	return PayloadWithGatewayId(gwId, msg)
 }
 
 func ServerUpdateGatewayCmd(gwId string, action string) string {
-	msg := fmt.Sprintf(`{"action":"%s"}`, action)
+	msg := GatewayCmdPayload{Action: action}
 	return PayloadWithGatewayId(gwId, msg)
 }
 
@@ -103,8 +201,7 @@ func ServerBootupUHFsPayload(gwId string, dls []models.UHF) string {
 		}
 		bootupDls = append(bootupDls, buDl)
 	}
-	bootupDlsJson, _ := json.Marshal(bootupDls)
-	return PayloadWithGatewayId(gwId, string(bootupDlsJson))
+	return PayloadWithGatewayId(gwId, bootupDls)
 }
 
 func ServerBootupRegisterPayload(
@@ -125,8 +222,7 @@ func ServerBootupRegisterPayload(
 			scheBoUpList = append(scheBoUpList, *sche)
 		}
 	}
-	bootupScheJson, _ := json.Marshal(scheBoUpList)
-	return PayloadWithGatewayId(gwId, string(bootupScheJson))
+	return PayloadWithGatewayId(gwId, scheBoUpList)
 }
 
 func isPastTime(t_compared int64) bool {
@@ -145,12 +241,30 @@ func ServerBootupSystemPayload(gwId string, uhfs []models.UHF) string {
 		new_uhf_important_info := UHFSyncPayload{}
 		new_uhf_important_info.UHFAddress = item.UHFAddress
 		new_uhf_important_info.ConnectState = item.ConnectState
-		new_uhf_important_info.State = item.ActiveState
+		new_uhf_important_info.State = uhfTargetState(&item)
 		new_uhf_important_info.Family = item.Family
 		new_uhf_important_info.Version = item.Version
 		uhf_important_info = append(uhf_important_info, new_uhf_important_info)
 	}
 	sync_payload := SyncPayload{UHFs: uhf_important_info, State: "active"}
-	sync_payload_converted, _ := json.Marshal(sync_payload)
-	return PayloadWithGatewayId(gwId, string(sync_payload_converted))
+	return PayloadWithGatewayId(gwId, sync_payload)
+}
+
+// UHF should be synced with its desired state, fallback to last known state when none was requested
+func uhfTargetState(uhf *models.UHF) string {
+	if uhf.DesiredState != "" {
+		return uhf.DesiredState
+	}
+	return uhf.ActiveState
+}
+
+func ServerUpgradeGatewayPayload(gwId string, campaignId uint, fw *models.Firmware, url string) string {
+	upgrade := UpgradePayload{
+		CampaignID: campaignId,
+		Version:    fw.Version,
+		Checksum:   fw.Checksum,
+		Size:       fw.Size,
+		Url:        url,
+	}
+	return PayloadWithGatewayId(gwId, upgrade)
 }
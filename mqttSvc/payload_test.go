@@ -0,0 +1,113 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
+)
+
+// decodeEnvelope decodes payload with its message into msg, a pointer
+func decodeEnvelope(t *testing.T, payload string, msg interface{}) *Envelope {
+	t.Helper()
+	env := &Envelope{Message: msg}
+	if err := json.Unmarshal([]byte(payload), env); err != nil {
+		t.Fatalf("payload %s is not valid JSON: %s", payload, err.Error())
+	}
+	if env.MessageID == "" || env.Timestamp == 0 || env.ProtocolVersion != DEFAULT_PROTOCOL_VERSION {
+		t.Errorf("payload %s: missing message_id, timestamp or protocol_version", payload)
+	}
+	return env
+}
+
+func TestServerPayloadsRoundTrip(t *testing.T) {
+	// quotes and braces must not break JSON or inject fields
+	gwId := `gw"1","message":{}`
+	uhf := &models.UHF{
+		GatewayID:   gwId,
+		UHFAddress:  `1"}`,
+		ActiveState: "1",
+		DeviceTwin:  models.DeviceTwin{DesiredState: "0"},
+		DesiredConfig: &models.UHFReaderConfig{
+			RFPower:  30,
+			Antennas: []int{2, 1},
+			Target:   "ab",
+			Filters:  []models.UHFReadFilter{{Bank: "epc", Offset: 32, Mask: "e2", Action: "include"}},
+		},
+	}
+	fw := &models.Firmware{Version: "1.2.0", Checksum: "abc", Size: 42}
+	tests := []struct {
+		topic   string
+		payload string
+		got     interface{}
+		want    interface{}
+	}{
+		{TOPIC_SV_UHF_U, ServerUpdateUHFPayload(uhf), &UHFStatePayload{}, &UHFStatePayload{Address: `1"}`, State: "0"}},
+		{TOPIC_SV_UHF_D, ServerDeleteUHFPayload(uhf), &UHFAddressPayload{}, &UHFAddressPayload{Address: `1"}`}},
+		{TOPIC_SV_UHF_CONFIG, ServerUpdateUHFConfigPayload(uhf), &UHFConfigPayload{}, &UHFConfigPayload{
+			Address: `1"}`,
+			Config: models.UHFReaderConfig{
+				RFPower:  30,
+				Antennas: []int{1, 2},
+				Target:   "AB",
+				Filters:  []models.UHFReadFilter{{Bank: "epc", Offset: 32, Mask: "E2", Action: "include"}},
+			},
+		}},
+		{TOPIC_SV_UHF_BATCH, ServerBatchUHFPayload(gwId, []models.UHF{*uhf}, []models.UHF{{UHFAddress: "2"}}), &UHFBatchPayload{}, &UHFBatchPayload{
+			Updates: []UHFStatePayload{{Address: `1"}`, State: "0"}},
+			Deletes: []UHFAddressPayload{{Address: "2"}},
+		}},
+		{TOPIC_SV_GATEWAY_U, ServerUpdateGatewayPayload(&models.Gateway{GatewayID: gwId, DeviceTwin: models.DeviceTwin{DesiredState: `{"a":"b"}`}}), &GatewayStatePayload{}, &GatewayStatePayload{State: `{"a":"b"}`}},
+		{TOPIC_SV_GATEWAY_D, ServerDeleteGatewayPayload(gwId), &EmptyPayload{}, &EmptyPayload{}},
+		{TOPIC_SV_GATEWAY_UPGRADE, ServerUpgradeGatewayPayload(gwId, 7, fw, "http://fw/1"), &UpgradePayload{}, &UpgradePayload{
+			CampaignID: 7, Version: "1.2.0", Checksum: "abc", Size: 42, Url: "http://fw/1",
+		}},
+		{TOPIC_SV_SYNC, ServerBootupSystemPayload(gwId, []models.UHF{*uhf}), &SyncPayload{}, &SyncPayload{
+			UHFs:  []UHFSyncPayload{{UHFAddress: `1"}`, State: "0"}},
+			State: "active",
+		}},
+		{TOPIC_SV_SYSTEM_U, ServerUpdateSecretKeyPayload(gwId, `k"ey`), &SecretKeyPayload{}, &SecretKeyPayload{SecretKey: `k"ey`}},
+		{TOPIC_SV_DOORLOCK_CMD, ServerUpdateGatewayCmd(gwId, "reboot"), &GatewayCmdPayload{}, &GatewayCmdPayload{Action: "reboot"}},
+		{TOPIC_SV_HP_BOOTUP, ServerBootupUHFsPayload(gwId, []models.UHF{*uhf}), &[]UHFBootUp{}, &[]UHFBootUp{{UHFAddress: `1"}`, ActiveState: "1"}}},
+	}
+	for _, tt := range tests {
+		env := decodeEnvelope(t, tt.payload, tt.got)
+		if env.GatewayID != gwId {
+			t.Errorf("%s: got gateway_id %q, wanted %q", tt.topic, env.GatewayID, gwId)
+		}
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got message %+v, wanted %+v", tt.topic, tt.got, tt.want)
+		}
+	}
+}
+
+func TestEnvelopeMessageIDUnique(t *testing.T) {
+	first := decodeEnvelope(t, ServerDeleteGatewayPayload("gw1"), &EmptyPayload{})
+	second := decodeEnvelope(t, ServerDeleteGatewayPayload("gw1"), &EmptyPayload{})
+	if first.MessageID == second.MessageID {
+		t.Errorf("got same message_id %s for two messages", first.MessageID)
+	}
+}
+
+func TestStampEnvelopeCarriesCorrelation(t *testing.T) {
+	payload := ServerUpdateUHFPayload(&models.UHF{GatewayID: "gw1", UHFAddress: "1", ActiveState: "active"})
+	if got := StampEnvelope(context.Background(), payload); got != payload {
+		t.Errorf("got %s, wanted payload unchanged without correlation ID", got)
+	}
+
+	ctx := tracing.WithCorrelationID(context.Background(), "req-1")
+	msg := UHFStatePayload{}
+	env := decodeEnvelope(t, StampEnvelope(ctx, payload), &msg)
+	if env.CorrelationID != "req-1" || env.GatewayID != "gw1" {
+		t.Errorf("got %+v, wanted correlation ID req-1 of gateway gw1", env)
+	}
+	if msg.Address != "1" || msg.State != "active" {
+		t.Errorf("got message %+v, wanted it kept", msg)
+	}
+}
@@ -0,0 +1,154 @@
+package mqttSvc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Publisher sends server commands to a gateway on the topic of its site
+type Publisher struct {
+	client   mqtt.Client
+	topics   *Topics
+	gateways models.GatewayService
+	uhfBatch bool // gateways take changes of several UHFs in one uhf/batch message
+}
+
+func NewPublisher(client mqtt.Client, topics *Topics, gateways models.GatewayService) *Publisher {
+	return &Publisher{
+		client:   client,
+		topics:   topics,
+		gateways: gateways,
+	}
+}
+
+func (p *Publisher) Topics() *Topics {
+	return p.topics
+}
+
+// SetUHFBatch enables uhf/batch messages, only for gateway firmware that
+// subscribes to TOPIC_SV_UHF_BATCH
+func (p *Publisher) SetUHFBatch(enabled bool) {
+	p.uhfBatch = enabled
+}
+
+func (p *Publisher) IsConnectionOpen() bool {
+	return p.client.IsConnectionOpen()
+}
+
+// Publish sends payload on TOPIC_SV_* topic of gateway gwId, site of the
+// gateway of any organization is looked up when topics are per site
+func (p *Publisher) Publish(ctx context.Context, topic string, gwId string, payload string) mqtt.Token {
+	site := ""
+	if p.topics.PerSite() {
+		var err error
+		site, _, err = p.gateways.FindGatewayTenant(models.WithSystemTenant(ctx), gwId)
+		if err != nil {
+			return &errorToken{err: err}
+		}
+	}
+	return p.PublishToSite(ctx, topic, site, gwId, payload)
+}
+
+// PublishToSite sends payload on TOPIC_SV_* topic of gateway gwId in site,
+// for callers that already know the site or whose gateway is deleted
+func (p *Publisher) PublishToSite(ctx context.Context, topic string, site string, gwId string, payload string) mqtt.Token {
+	ctx, span := tracing.Start(ctx, "mqtt.publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("mqtt.site", site), attribute.String("uams.gateway_id", gwId)))
+	defer span.End()
+
+	name, err := p.topics.Server(topic, site, gwId)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return &errorToken{err: err}
+	}
+	span.SetAttributes(attribute.String("mqtt.topic", name))
+	return p.client.Publish(name, 1, false, StampEnvelope(ctx, payload))
+}
+
+// PublishUHFChanges sends updated and deleted UHFs of gateway gwId in a single
+// uhf/batch message when enabled, else one uhf/update or uhf/delete message
+// per UHF like single changes. Errors are returned by UHF ID, a batch that
+// could not be sent fails every UHF in it.
+func (p *Publisher) PublishUHFChanges(ctx context.Context, gwId string, updated []models.UHF, deleted []models.UHF) map[uint]error {
+	failed := map[uint]error{}
+	if p.uhfBatch {
+		t := p.Publish(ctx, TOPIC_SV_UHF_BATCH, gwId, ServerBatchUHFPayload(gwId, updated, deleted))
+		if err := HandleMqttErr(t); err != nil {
+			for _, uhfs := range [][]models.UHF{updated, deleted} {
+				for _, uhf := range uhfs {
+					failed[uhf.ID] = err
+				}
+			}
+		}
+		return failed
+	}
+	for i := range updated {
+		t := p.Publish(ctx, TOPIC_SV_UHF_U, gwId, ServerUpdateUHFPayload(&updated[i]))
+		if err := HandleMqttErr(t); err != nil {
+			failed[updated[i].ID] = err
+		}
+	}
+	for i := range deleted {
+		t := p.Publish(ctx, TOPIC_SV_UHF_D, gwId, ServerDeleteUHFPayload(&deleted[i]))
+		if err := HandleMqttErr(t); err != nil {
+			failed[deleted[i].ID] = err
+		}
+	}
+	return failed
+}
+
+// StampEnvelope sets correlation ID and traceparent of ctx on an encoded
+// envelope, payload is returned as is when ctx has neither
+func StampEnvelope(ctx context.Context, payload string) string {
+	correlationId, traceParent := tracing.CorrelationID(ctx), tracing.TraceParent(ctx)
+	if correlationId == "" && traceParent == "" {
+		return payload
+	}
+	env := struct {
+		Envelope
+		Message json.RawMessage `json:"message"`
+	}{}
+	if err := json.Unmarshal([]byte(payload), &env); err != nil {
+		return payload
+	}
+	env.CorrelationID = correlationId
+	env.TraceParent = traceParent
+	stamped, err := json.Marshal(env)
+	if err != nil {
+		return payload
+	}
+	return string(stamped)
+}
+
+// errorToken is a completed token of a message that could not be published
+type errorToken struct {
+	err error
+}
+
+func (t *errorToken) Wait() bool {
+	return true
+}
+
+func (t *errorToken) WaitTimeout(time.Duration) bool {
+	return true
+}
+
+func (t *errorToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (t *errorToken) Error() error {
+	return t.err
+}
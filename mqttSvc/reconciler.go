@@ -0,0 +1,143 @@
+package mqttSvc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
+)
+
+// TwinReconciler periodically re-sends update commands to gateways and UHFs
+// whose reported state still differs from their desired state. Retries of a
+// device back off exponentially up to maxBackoff and stop after maxAttempts,
+// a new desired state or a report from the device starts them over.
+type TwinReconciler struct {
+	publisher   *Publisher
+	optSvc      *models.ServiceOptions
+	interval    time.Duration
+	maxAttempts int           // 0 retries forever
+	maxBackoff  time.Duration // 0 retries on every tick
+	done        chan struct{}
+	stopOnce    sync.Once
+	wg          sync.WaitGroup
+}
+
+func NewTwinReconciler(
+	publisher *Publisher,
+	optSvc *models.ServiceOptions,
+	interval time.Duration,
+	maxAttempts int,
+	maxBackoff time.Duration,
+) *TwinReconciler {
+	return &TwinReconciler{
+		publisher:   publisher,
+		optSvc:      optSvc,
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		maxBackoff:  maxBackoff,
+		done:        make(chan struct{}),
+	}
+}
+
+func (r *TwinReconciler) Start() {
+	if r.interval <= 0 {
+		logger.LogWithoutFields(logger.MQTT, logger.InfoLevel, "Twin reconciler disabled")
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				r.Reconcile(context.Background())
+			}
+		}
+	}()
+}
+
+func (r *TwinReconciler) Stop() {
+	r.stopOnce.Do(func() { close(r.done) })
+	r.wg.Wait()
+}
+
+// Reconcile publishes desired state once for every drifted device that is online
+// and due for a retry, devices of every organization are reconciled
+func (r *TwinReconciler) Reconcile(ctx context.Context) {
+	ctx = models.WithoutRevision(models.WithSystemTenant(tracing.EnsureCorrelationID(ctx, tracing.CorrelationID(ctx))))
+	ctx, span := tracing.Start(ctx, "twin.reconcile")
+	defer span.End()
+	now := time.Now()
+	gwList, err := r.optSvc.GatewaySvc.FindDriftedGateways(ctx)
+	if err == nil {
+		for i := range gwList {
+			gw := &gwList[i]
+			if gw.ConnectState == "disconnect" || !r.due(&gw.DeviceTwin, now) {
+				continue
+			}
+			t := r.publisher.PublishToSite(ctx, TOPIC_SV_GATEWAY_U, gw.Site, gw.GatewayID, ServerUpdateGatewayPayload(gw))
+			if HandleMqttErr(t) != nil {
+				continue
+			}
+			r.optSvc.GatewaySvc.MarkGatewaySyncAttempt(ctx, gw.GatewayID)
+			r.logGiveUp(&gw.DeviceTwin, "gateway ID "+gw.GatewayID)
+		}
+	}
+
+	uhfList, err := r.optSvc.UHFSvc.FindDriftedUHFs(ctx)
+	if err == nil {
+		for i := range uhfList {
+			uhf := &uhfList[i]
+			if uhf.ConnectState == "disconnect" || !r.due(&uhf.DeviceTwin, now) {
+				continue
+			}
+			t := r.publisher.Publish(ctx, TOPIC_SV_UHF_U, uhf.GatewayID, ServerUpdateUHFPayload(uhf))
+			if HandleMqttErr(t) != nil {
+				continue
+			}
+			r.optSvc.UHFSvc.MarkUHFSyncAttempt(ctx, uhf.UHFAddress, uhf.GatewayID)
+			r.logGiveUp(&uhf.DeviceTwin, fmt.Sprintf("UHF %s of gateway ID %s", uhf.UHFAddress, uhf.GatewayID))
+		}
+	}
+
+	if len(gwList)+len(uhfList) > 0 {
+		logger.LogfWithoutFields(logger.MQTT, logger.DebugLevel,
+			"Twin reconciler found %d gateways and %d UHFs out of sync", len(gwList), len(uhfList))
+	}
+}
+
+// due reports whether drifted twin is retried at now. The first retry waits
+// one interval and every next one twice as long, up to maxBackoff. Ticks and
+// attempts are not aligned, so a retry is due within half an interval.
+func (r *TwinReconciler) due(twin *models.DeviceTwin, now time.Time) bool {
+	if twin.SyncAttempts == 0 || twin.LastSyncAt == nil {
+		return true
+	}
+	if r.maxAttempts > 0 && twin.SyncAttempts >= r.maxAttempts {
+		return false
+	}
+	backoff := r.interval
+	for i := 1; i < twin.SyncAttempts && backoff < r.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if r.maxBackoff > 0 && backoff > r.maxBackoff {
+		backoff = r.maxBackoff
+	}
+	return !now.Add(r.interval / 2).Before(twin.LastSyncAt.Add(backoff))
+}
+
+// logGiveUp tells the attempt just sent to device was its last one
+func (r *TwinReconciler) logGiveUp(twin *models.DeviceTwin, device string) {
+	if r.maxAttempts > 0 && twin.SyncAttempts+1 == r.maxAttempts {
+		logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel,
+			"Twin reconciler gave up on %s after %d attempts, desired state %s", device, r.maxAttempts, twin.DesiredState)
+	}
+}
@@ -0,0 +1,110 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/fakes"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+func newTestReconciler(t *testing.T, interval time.Duration, maxAttempts int, maxBackoff time.Duration) (*TwinReconciler, *models.ServiceOptions, *fakes.Client) {
+	t.Helper()
+	opts := fakes.NewServiceOptions(fakes.NewStore())
+	topics, err := NewTopics(DEFAULT_TOPIC_PREFIX, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fakes.NewClient()
+	return NewTwinReconciler(NewPublisher(client, topics, opts.GatewaySvc), opts, interval, maxAttempts, maxBackoff), opts, client
+}
+
+func TestReconcileGivesUp(t *testing.T) {
+	// every tick is due, only the attempt cap stops retries
+	r, opts, client := newTestReconciler(t, time.Nanosecond, 3, 0)
+	ctx := models.WithTenant(context.Background(), 1)
+	for _, gwId := range []string{"gw01", "gw02"} {
+		opts.GatewaySvc.CreateGateway(ctx, &models.Gateway{GatewayID: gwId, ConnectState: "connect"})
+		opts.GatewaySvc.UpdateGatewayReportedState(ctx, gwId, "inactive")
+		opts.GatewaySvc.UpdateGatewayDesiredState(ctx, gwId, "active")
+	}
+	opts.GatewaySvc.UpdateGatewayConnectState(ctx, "gw02", "disconnect")
+
+	for i := 0; i < 5; i++ {
+		r.Reconcile(context.Background())
+	}
+	if published := client.Published(); len(published) != 3 {
+		t.Fatalf("got %d updates, wanted 3 to online gw01 then none", len(published))
+	}
+	gw, _ := opts.GatewaySvc.FindGatewayByGatewayID(ctx, "gw01")
+	if gw.SyncAttempts != 3 {
+		t.Errorf("got %d attempts, wanted 3", gw.SyncAttempts)
+	}
+
+	// a new desired state starts attempts over
+	opts.GatewaySvc.UpdateGatewayDesiredState(ctx, "gw01", "inactive")
+	opts.GatewaySvc.UpdateGatewayReportedState(ctx, "gw01", "active")
+	r.Reconcile(context.Background())
+	if published := client.Published(); len(published) != 4 {
+		t.Errorf("got %d updates, wanted gw01 retried again", len(published))
+	}
+}
+
+func TestReconcileGivesUpOnWrongReports(t *testing.T) {
+	r, opts, client := newTestReconciler(t, time.Nanosecond, 3, 0)
+	ctx := models.WithTenant(context.Background(), 1)
+	opts.GatewaySvc.CreateGateway(ctx, &models.Gateway{GatewayID: "gw01", ConnectState: "connect"})
+	opts.GatewaySvc.UpdateGatewayDesiredState(ctx, "gw01", "active")
+	opts.UHFSvc.CreateUHF(ctx, &models.UHF{GatewayID: "gw01", UHFAddress: "1", ConnectState: "connect"})
+	opts.UHFSvc.UpdateUHFDesiredState(ctx, "1", "gw01", "active")
+
+	// devices keep reporting another state, like uhf/scan does every scan
+	for i := 0; i < 5; i++ {
+		opts.GatewaySvc.UpdateGatewayReportedState(ctx, "gw01", "inactive")
+		opts.UHFSvc.UpdateUHFReportedState(ctx, "1", "gw01", "inactive")
+		r.Reconcile(context.Background())
+	}
+	if published := client.Published(); len(published) != 6 {
+		t.Fatalf("got %d updates, wanted 3 to gateway and 3 to UHF then none", len(published))
+	}
+	uhf, _ := opts.UHFSvc.FindUHFByAddress(ctx, "1", "gw01")
+	if uhf.SyncAttempts != 3 {
+		t.Errorf("got %d attempts, wanted 3", uhf.SyncAttempts)
+	}
+
+	// reporting the desired state starts attempts over
+	opts.UHFSvc.UpdateUHFReportedState(ctx, "1", "gw01", "active")
+	if uhf, _ := opts.UHFSvc.FindUHFByAddress(ctx, "1", "gw01"); uhf.SyncAttempts != 0 {
+		t.Errorf("got %d attempts, wanted 0 once in sync", uhf.SyncAttempts)
+	}
+}
+
+func TestReconcileBackoff(t *testing.T) {
+	r, _, _ := newTestReconciler(t, time.Minute, 0, 5*time.Minute)
+	last := time.Now()
+	cases := []struct {
+		attempts int
+		after    time.Duration
+		due      bool
+	}{
+		{0, 0, true},
+		{1, 50 * time.Second, true}, // within half a tick of one interval
+		{1, 20 * time.Second, false},
+		{2, time.Minute, false},
+		{2, 2 * time.Minute, true},
+		{3, 3 * time.Minute, false},
+		{3, 4 * time.Minute, true},
+		{8, 5 * time.Minute, true}, // capped at maxBackoff
+		{8, 4 * time.Minute, false},
+	}
+	for _, c := range cases {
+		twin := &models.DeviceTwin{DesiredState: "active", SyncAttempts: c.attempts, LastSyncAt: &last}
+		if due := r.due(twin, last.Add(c.after)); due != c.due {
+			t.Errorf("%d attempts after %s: got %v, wanted %v", c.attempts, c.after, due, c.due)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package mqttSvc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
+)
+
+// RolloutManager drives running firmware campaigns: it sends upgrade commands
+// batch by batch, fails targets that never report back and halts a campaign
+// once its failure rate passes the configured threshold.
+type RolloutManager struct {
+	publisher     *Publisher
+	optSvc        *models.ServiceOptions
+	interval      time.Duration
+	targetTimeout time.Duration
+	artifactUrl   string
+	signer        *models.ArtifactSigner
+	done          chan struct{}
+	stopOnce      sync.Once
+	wg            sync.WaitGroup
+}
+
+func NewRolloutManager(
+	publisher *Publisher,
+	optSvc *models.ServiceOptions,
+	interval time.Duration,
+	targetTimeout time.Duration,
+	artifactUrl string,
+	signer *models.ArtifactSigner,
+) *RolloutManager {
+	return &RolloutManager{
+		publisher:     publisher,
+		optSvc:        optSvc,
+		interval:      interval,
+		targetTimeout: targetTimeout,
+		artifactUrl:   artifactUrl,
+		signer:        signer,
+		done:          make(chan struct{}),
+	}
+}
+
+func (m *RolloutManager) Start() {
+	if m.interval <= 0 {
+		logger.LogWithoutFields(logger.MQTT, logger.InfoLevel, "Rollout manager disabled")
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-ticker.C:
+				m.Advance(context.Background())
+			}
+		}
+	}()
+}
+
+func (m *RolloutManager) Stop() {
+	m.stopOnce.Do(func() { close(m.done) })
+	m.wg.Wait()
+}
+
+// Advance moves every running campaign one step forward, campaigns target
+// gateways of every organization
+func (m *RolloutManager) Advance(ctx context.Context) {
+	ctx = models.WithSystemTenant(tracing.EnsureCorrelationID(ctx, tracing.CorrelationID(ctx)))
+	ctx, span := tracing.Start(ctx, "rollout.advance")
+	defer span.End()
+	campaigns, err := m.optSvc.RolloutSvc.FindRolloutsByStatus(ctx, models.ROLLOUT_RUNNING)
+	if err != nil {
+		return
+	}
+	for i := range campaigns {
+		m.advanceCampaign(ctx, &campaigns[i])
+	}
+}
+
+func (m *RolloutManager) advanceCampaign(ctx context.Context, c *models.RolloutCampaign) {
+	if m.targetTimeout > 0 {
+		m.optSvc.RolloutSvc.ExpireTargets(ctx, c.ID, time.Now().Add(-m.targetTimeout))
+	}
+	progress, err := m.optSvc.RolloutSvc.GetRolloutProgress(ctx, c.ID)
+	if err != nil {
+		return
+	}
+
+	minFinished := c.MinFinished
+	if minFinished <= 0 {
+		minFinished = 1
+	}
+	if c.FailureThreshold > 0 && progress.Finished() >= minFinished && progress.FailureRate() > c.FailureThreshold {
+		reason := fmt.Sprintf("failure rate %.2f passed threshold %.2f", progress.FailureRate(), c.FailureThreshold)
+		m.optSvc.RolloutSvc.UpdateRolloutStatus(ctx, c.ID, models.ROLLOUT_HALTED, reason)
+		logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel, "Rollout %d halted: %s", c.ID, reason)
+		return
+	}
+
+	if progress[models.TARGET_PENDING] == 0 && progress.InFlight() == 0 {
+		m.optSvc.RolloutSvc.UpdateRolloutStatus(ctx, c.ID, models.ROLLOUT_COMPLETED, "")
+		logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "Rollout %d completed, %d succeeded, %d failed",
+			c.ID, progress[models.TARGET_SUCCEEDED], progress[models.TARGET_FAILED])
+		return
+	}
+
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	slots := batchSize - progress.InFlight()
+	if slots <= 0 || c.Firmware == nil {
+		return
+	}
+	targets, err := m.optSvc.RolloutSvc.FindPendingTargets(ctx, c.ID, slots)
+	if err != nil {
+		return
+	}
+	// gateways download without API key, the URL is signed and expires
+	url := fmt.Sprintf("%s/v1/firmware/%d/artifact?%s", m.artifactUrl, c.FirmwareID,
+		m.signer.Sign(c.FirmwareID, time.Now()).Encode())
+	for _, target := range targets {
+		t := m.publisher.Publish(ctx, TOPIC_SV_GATEWAY_UPGRADE, target.GatewayID,
+			ServerUpgradeGatewayPayload(target.GatewayID, c.ID, c.Firmware, url))
+		if HandleMqttErr(t) != nil {
+			continue
+		}
+		m.optSvc.RolloutSvc.MarkTargetSent(ctx, target.ID)
+	}
+}
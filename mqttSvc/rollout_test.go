@@ -0,0 +1,204 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/fakes"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/tidwall/gjson"
+)
+
+type rolloutTest struct {
+	m      *RolloutManager
+	opts   *models.ServiceOptions
+	client *fakes.Client
+	r      *models.RolloutCampaign
+	signer *models.ArtifactSigner
+}
+
+// newRolloutTest starts campaign c of firmware 1.1.0 over gateways gwIds
+// running 1.0.0
+func newRolloutTest(t *testing.T, targetTimeout time.Duration, c models.RolloutCampaign, gwIds ...string) *rolloutTest {
+	t.Helper()
+	opts := fakes.NewServiceOptions(fakes.NewStore())
+	topics, err := NewTopics(DEFAULT_TOPIC_PREFIX, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fakes.NewClient()
+	ctx := models.WithTenant(context.Background(), 1)
+	for _, gwId := range gwIds {
+		if _, err := opts.GatewaySvc.CreateGateway(ctx, &models.Gateway{GatewayID: gwId, SoftwareVersion: "1.0.0"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fw, err := opts.FirmwareSvc.CreateFirmware(ctx, &models.Firmware{Version: "1.1.0"}, strings.NewReader("firmware"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Name = "q3"
+	c.FirmwareID = fw.ID
+	r, err := opts.RolloutSvc.CreateRollout(ctx, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.RolloutSvc.UpdateRolloutStatus(ctx, r.ID, models.ROLLOUT_RUNNING, "")
+	signer, err := models.NewArtifactSigner("test-secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &rolloutTest{
+		m:      NewRolloutManager(NewPublisher(client, topics, opts.GatewaySvc), opts, 0, targetTimeout, "http://dms", signer),
+		opts:   opts,
+		client: client,
+		r:      r,
+		signer: signer,
+	}
+}
+
+// campaign reloads the campaign with its targets by gateway ID
+func (rt *rolloutTest) campaign(t *testing.T) (*models.RolloutCampaign, map[string]models.RolloutTarget) {
+	t.Helper()
+	r, err := rt.opts.RolloutSvc.FindRolloutByID(context.Background(), fmt.Sprint(rt.r.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := map[string]models.RolloutTarget{}
+	for _, target := range r.Targets {
+		targets[target.GatewayID] = target
+	}
+	return r, targets
+}
+
+func (rt *rolloutTest) report(gwId string, status string) {
+	rt.opts.RolloutSvc.UpdateTargetStatus(context.Background(), rt.r.ID, gwId, status, "")
+}
+
+// upgraded lists gateways sent an upgrade command so far
+func (rt *rolloutTest) upgraded() []string {
+	gwIds := []string{}
+	for _, p := range rt.client.Published() {
+		if strings.HasSuffix(p.Topic, TOPIC_SV_GATEWAY_UPGRADE) {
+			gwIds = append(gwIds, gjson.Get(p.Payload, "gateway_id").String())
+		}
+	}
+	return gwIds
+}
+
+func TestRolloutNextBatch(t *testing.T) {
+	rt := newRolloutTest(t, 0, models.RolloutCampaign{BatchSize: 2}, "gw01", "gw02", "gw03")
+	rt.m.Advance(context.Background())
+	rt.m.Advance(context.Background())
+	if published := rt.client.Published(); len(published) != 2 {
+		t.Fatalf("got %d upgrades, wanted first batch of 2 only", len(published))
+	}
+	_, targets := rt.campaign(t)
+	if targets["gw01"].Status != models.TARGET_SENT || targets["gw02"].Status != models.TARGET_SENT ||
+		targets["gw03"].Status != models.TARGET_PENDING {
+		t.Errorf("got %+v, wanted gw01 and gw02 sent", targets)
+	}
+
+	// a finished target frees its slot
+	rt.report("gw01", models.TARGET_SUCCEEDED)
+	rt.report("gw02", models.TARGET_INSTALLING)
+	rt.m.Advance(context.Background())
+	r, targets := rt.campaign(t)
+	if len(rt.client.Published()) != 3 || targets["gw03"].Status != models.TARGET_SENT || r.Status != models.ROLLOUT_RUNNING {
+		t.Errorf("got %s %+v, wanted gw03 sent next", r.Status, targets)
+	}
+}
+
+func TestRolloutSignsArtifactUrl(t *testing.T) {
+	rt := newRolloutTest(t, 0, models.RolloutCampaign{BatchSize: 1}, "gw01")
+	rt.m.Advance(context.Background())
+	published := rt.client.Published()
+	if len(published) != 1 {
+		t.Fatalf("got %d upgrades, wanted 1", len(published))
+	}
+	u, err := url.Parse(gjson.Get(published[0].Payload, "message.url").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != fmt.Sprintf("/v1/firmware/%d/artifact", rt.r.FirmwareID) {
+		t.Errorf("got %s, wanted artifact of firmware %d", u.Path, rt.r.FirmwareID)
+	}
+	if err := rt.signer.Verify(rt.r.FirmwareID, u.Query(), time.Now()); err != nil {
+		t.Errorf("got %v, wanted signed URL", err)
+	}
+}
+
+func TestRolloutComplete(t *testing.T) {
+	rt := newRolloutTest(t, 0, models.RolloutCampaign{BatchSize: 2}, "gw01", "gw02")
+	rt.m.Advance(context.Background())
+	rt.report("gw01", models.TARGET_SUCCEEDED)
+	rt.m.Advance(context.Background())
+	if r, _ := rt.campaign(t); r.Status != models.ROLLOUT_RUNNING {
+		t.Fatalf("got %s, wanted running while gw02 upgrades", r.Status)
+	}
+
+	rt.report("gw02", models.TARGET_FAILED)
+	rt.m.Advance(context.Background())
+	r, _ := rt.campaign(t)
+	if r.Status != models.ROLLOUT_COMPLETED || r.FinishedAt == nil {
+		t.Errorf("got %s at %v, wanted completed", r.Status, r.FinishedAt)
+	}
+	if len(rt.client.Published()) != 2 {
+		t.Errorf("got %d upgrades, wanted 2", len(rt.client.Published()))
+	}
+}
+
+func TestRolloutHalt(t *testing.T) {
+	c := models.RolloutCampaign{BatchSize: 2, FailureThreshold: 0.4, MinFinished: 2}
+	rt := newRolloutTest(t, 0, c, "gw01", "gw02", "gw03", "gw04")
+	rt.m.Advance(context.Background())
+
+	// one failure is below min_finished
+	rt.report("gw01", models.TARGET_FAILED)
+	rt.m.Advance(context.Background())
+	if r, _ := rt.campaign(t); r.Status != models.ROLLOUT_RUNNING {
+		t.Fatalf("got %s, wanted running before min_finished", r.Status)
+	}
+
+	rt.report("gw02", models.TARGET_SUCCEEDED)
+	rt.report("gw03", models.TARGET_FAILED)
+	rt.m.Advance(context.Background())
+	r, targets := rt.campaign(t)
+	if r.Status != models.ROLLOUT_HALTED || !strings.Contains(r.HaltReason, "0.67") {
+		t.Errorf("got %s %q, wanted halted at failure rate 0.67", r.Status, r.HaltReason)
+	}
+	if targets["gw04"].Status != models.TARGET_PENDING {
+		t.Errorf("got %s, wanted gw04 never sent", targets["gw04"].Status)
+	}
+	published := len(rt.client.Published())
+	rt.m.Advance(context.Background())
+	if len(rt.client.Published()) != published {
+		t.Errorf("wanted halted campaign left alone")
+	}
+}
+
+func TestRolloutTimeout(t *testing.T) {
+	rt := newRolloutTest(t, time.Nanosecond, models.RolloutCampaign{BatchSize: 1}, "gw01", "gw02")
+	rt.m.Advance(context.Background())
+	time.Sleep(time.Millisecond)
+
+	// gw01 never reported back and gives its slot to gw02
+	rt.m.Advance(context.Background())
+	_, targets := rt.campaign(t)
+	if targets["gw01"].Status != models.TARGET_FAILED || targets["gw01"].Message != "timeout" {
+		t.Errorf("got %+v, wanted gw01 timed out", targets["gw01"])
+	}
+	if targets["gw02"].Status != models.TARGET_SENT {
+		t.Errorf("got %s, wanted gw02 sent", targets["gw02"].Status)
+	}
+	if gwIds := rt.upgraded(); len(gwIds) != 2 || gwIds[0] != "gw01" || gwIds[1] != "gw02" {
+		t.Errorf("got upgrades to %v, wanted gw01 then gw02", gwIds)
+	}
+}
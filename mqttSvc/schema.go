@@ -0,0 +1,216 @@
+package mqttSvc
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/tidwall/gjson"
+)
+
+// Gateways without protocol_version field speak version 1
+const DEFAULT_PROTOCOL_VERSION int = 1
+
+//go:embed schemas
+var embeddedSchemas embed.FS
+
+// Schema file of every gateway topic, looked up in schemas/v<version>/
+var topicSchemas = map[string]string{
+	TOPIC_GW_BOOTUP:            "bootup.json",
+	TOPIC_GW_SHUTDOWN:          "shutdown.json",
+	TOPIC_GW_LASTWILL:          "lastwill.json",
+	TOPIC_GW_GW_CONNECT_STATE:  "gateway_update.json",
+	TOPIC_GW_UHF_CONNECT_STATE: "uhf_update.json",
+	TOPIC_GW_UHF_SCAN:          "uhf_scan.json",
+	TOPIC_GW_TAG:               "uhf_tag.json",
+	TOPIC_GW_UHF_CONFIG:        "uhf_config.json",
+	TOPIC_GW_LOG:               "log.json",
+	TOPIC_GW_UPGRADE:           "upgrade.json",
+}
+
+var (
+	ErrSchemaViolation    = errors.New("payload violates schema")
+	ErrUnsupportedVersion = errors.New("protocol version unsupported")
+)
+
+// SchemaValidator checks gateway payloads against the JSON schema of their
+// topic for the protocol version they declare, so firmware speaking
+// different versions can share a server.
+type SchemaValidator struct {
+	schemas map[int]map[string]*jsonschema.Schema // version -> topic -> schema
+
+	mu         sync.Mutex
+	validated  map[int]uint64
+	violations map[string]uint64
+}
+
+type SchemaValidatorStats struct {
+	Versions   []int             `json:"versions"`
+	Validated  map[int]uint64    `json:"validated"`
+	Violations map[string]uint64 `json:"violations"`
+}
+
+func NewSchemaValidator() (*SchemaValidator, error) {
+	schemaFS, err := fs.Sub(embeddedSchemas, "schemas")
+	if err != nil {
+		return nil, err
+	}
+	return NewSchemaValidatorFS(schemaFS)
+}
+
+// NewSchemaValidatorFS compiles schemas from v<version> directories of fsys
+func NewSchemaValidatorFS(fsys fs.FS) (*SchemaValidator, error) {
+	dirs, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	schemas := map[int]map[string]*jsonschema.Schema{}
+	for _, dir := range dirs {
+		if !dir.IsDir() || !strings.HasPrefix(dir.Name(), "v") {
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(dir.Name(), "v"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema version directory %s", dir.Name())
+		}
+		compiler := jsonschema.NewCompiler()
+		files, err := fs.ReadDir(fsys, dir.Name())
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			b, err := fs.ReadFile(fsys, path.Join(dir.Name(), f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if err := compiler.AddResource(schemaURL(dir.Name(), f.Name()), bytes.NewReader(b)); err != nil {
+				return nil, err
+			}
+		}
+		schemas[version] = map[string]*jsonschema.Schema{}
+		for topic, file := range topicSchemas {
+			if _, err := fs.Stat(fsys, path.Join(dir.Name(), file)); err != nil {
+				return nil, fmt.Errorf("schema %s of topic %s missing in %s", file, topic, dir.Name())
+			}
+			schema, err := compiler.Compile(schemaURL(dir.Name(), file))
+			if err != nil {
+				return nil, err
+			}
+			schemas[version][topic] = schema
+		}
+	}
+	if _, ok := schemas[DEFAULT_PROTOCOL_VERSION]; !ok {
+		return nil, fmt.Errorf("schemas of protocol version %d missing", DEFAULT_PROTOCOL_VERSION)
+	}
+	return &SchemaValidator{
+		schemas:    schemas,
+		validated:  map[int]uint64{},
+		violations: map[string]uint64{},
+	}, nil
+}
+
+func schemaURL(dir string, file string) string {
+	return "file:///schemas/" + dir + "/" + file
+}
+
+// Validate returns protocol version of payload, or an error wrapping
+// ErrSchemaViolation or ErrUnsupportedVersion. Topics without schema pass.
+func (v *SchemaValidator) Validate(topic string, payload []byte) (int, error) {
+	version, err := v.validate(topic, payload)
+	v.mu.Lock()
+	if err != nil {
+		v.violations[topic]++
+	} else {
+		v.validated[version]++
+	}
+	v.mu.Unlock()
+	return version, err
+}
+
+func (v *SchemaValidator) validate(topic string, payload []byte) (int, error) {
+	version := DEFAULT_PROTOCOL_VERSION
+	if pv := gjson.GetBytes(payload, "protocol_version"); pv.Exists() {
+		if pv.Type != gjson.Number || pv.Num != float64(int(pv.Num)) {
+			return 0, fmt.Errorf("%w: protocol_version must be an integer", ErrSchemaViolation)
+		}
+		version = int(pv.Num)
+	}
+	versionSchemas, ok := v.schemas[version]
+	if !ok {
+		return version, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	schema, ok := versionSchemas[topic]
+	if !ok {
+		return version, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return version, fmt.Errorf("%w: not JSON, %s", ErrSchemaViolation, err.Error())
+	}
+	if err := schema.Validate(doc); err != nil {
+		return version, fmt.Errorf("%w v%d: %s", ErrSchemaViolation, version, schemaErrorDetail(err))
+	}
+	return version, nil
+}
+
+// schemaErrorDetail lists the innermost failures as "location: message"
+func schemaErrorDetail(err error) string {
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err.Error()
+	}
+	var leaves []string
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			loc := e.InstanceLocation
+			if loc == "" {
+				loc = "/"
+			}
+			leaves = append(leaves, loc+": "+e.Message)
+			return
+		}
+		for _, c := range e.Causes {
+			walk(c)
+		}
+	}
+	walk(ve)
+	sort.Strings(leaves)
+	return strings.Join(leaves, "; ")
+}
+
+func (v *SchemaValidator) Stats() SchemaValidatorStats {
+	versions := make([]int, 0, len(v.schemas))
+	for version := range v.schemas {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	validated := make(map[int]uint64, len(v.validated))
+	for version, cnt := range v.validated {
+		validated[version] = cnt
+	}
+	violations := make(map[string]uint64, len(v.violations))
+	for topic, cnt := range v.violations {
+		violations[topic] = cnt
+	}
+	return SchemaValidatorStats{
+		Versions:   versions,
+		Validated:  validated,
+		Violations: violations,
+	}
+}
@@ -0,0 +1,118 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSchemaValidatorValidate(t *testing.T) {
+	v, err := NewSchemaValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		err     error
+	}{
+		{"bootup", TOPIC_GW_BOOTUP, `{"gateway_id":"gw1","message":{"version":"1.2.0","state":"{}"}}`, nil},
+		{"shutdown", TOPIC_GW_SHUTDOWN, `{"gateway_id":"gw1"}`, nil},
+		{"lastwill", TOPIC_GW_LASTWILL, `{"gateway_id":"gw1","protocol_version":1}`, nil},
+		{"gateway update", TOPIC_GW_GW_CONNECT_STATE, `{"gateway_id":"gw1","message":{"connection_state":"1"}}`, nil},
+		{"uhf update", TOPIC_GW_UHF_CONNECT_STATE, `{"gateway_id":"gw1","message":{"address":"1","connection_state":"0","timestamp":"2022-01-01 10:00:00"}}`, nil},
+		{"uhf scan", TOPIC_GW_UHF_SCAN, `{"gateway_id":"gw1","message":{"uhfs":[{"address":"1","family":"R200"}]}}`, nil},
+		{"uhf tag", TOPIC_GW_TAG, `{"gateway_id":"gw1","message":{"address":"1","tags":[{"epc":"E2","mem":"U01ABCDE12345XYZ"}]}}`, nil},
+		{"uhf config", TOPIC_GW_UHF_CONFIG, `{"gateway_id":"gw1","message":{"address":"1","config":{"power":30}}}`, nil},
+		{"log", TOPIC_GW_LOG, `{"gateway_id":"gw1","message":{"log":"started","timestamp":"2022-01-01 10:00:00"}}`, nil},
+		{"upgrade", TOPIC_GW_UPGRADE, `{"gateway_id":"gw1","message":{"campaign_id":3,"status":"installing"}}`, nil},
+		{"topic without schema", "uams/gateway/unknown", `not json`, nil},
+		{"not json", TOPIC_GW_LOG, `{"gateway_id":`, ErrSchemaViolation},
+		{"missing gateway id", TOPIC_GW_SHUTDOWN, `{"message":{}}`, ErrSchemaViolation},
+		{"empty gateway id", TOPIC_GW_SHUTDOWN, `{"gateway_id":""}`, ErrSchemaViolation},
+		{"missing message", TOPIC_GW_TAG, `{"gateway_id":"gw1"}`, ErrSchemaViolation},
+		{"tag without mem", TOPIC_GW_TAG, `{"gateway_id":"gw1","message":{"address":"1","tags":[{"epc":"E2"}]}}`, ErrSchemaViolation},
+		{"bad upgrade status", TOPIC_GW_UPGRADE, `{"gateway_id":"gw1","message":{"campaign_id":3,"status":"paused"}}`, ErrSchemaViolation},
+		{"string campaign id", TOPIC_GW_UPGRADE, `{"gateway_id":"gw1","message":{"campaign_id":"3","status":"failed"}}`, ErrSchemaViolation},
+		{"fractional version", TOPIC_GW_SHUTDOWN, `{"gateway_id":"gw1","protocol_version":1.5}`, ErrSchemaViolation},
+		{"string version", TOPIC_GW_SHUTDOWN, `{"gateway_id":"gw1","protocol_version":"1"}`, ErrSchemaViolation},
+		{"unsupported version", TOPIC_GW_SHUTDOWN, `{"gateway_id":"gw1","protocol_version":9}`, ErrUnsupportedVersion},
+	}
+	for _, tt := range tests {
+		_, err := v.Validate(tt.topic, []byte(tt.payload))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got err %v, wanted %v", tt.name, err, tt.err)
+		}
+	}
+
+	stats := v.Stats()
+	if stats.Validated[1] != 11 || stats.Violations[TOPIC_GW_SHUTDOWN] != 5 || stats.Violations[TOPIC_GW_UPGRADE] != 2 {
+		t.Errorf("got %+v, wanted 11 validated v1, 5 shutdown and 2 upgrade violations", stats)
+	}
+}
+
+func TestSchemaValidatorErrorDetail(t *testing.T) {
+	v, _ := NewSchemaValidator()
+	_, err := v.Validate(TOPIC_GW_UHF_CONNECT_STATE, []byte(`{"gateway_id":"gw1","message":{"address":""}}`))
+	if err == nil {
+		t.Fatal("got nil error")
+	}
+	if !strings.Contains(err.Error(), "/message/address") || !strings.Contains(err.Error(), "connection_state") {
+		t.Errorf("got %q, wanted failing locations in error", err.Error())
+	}
+	if RejectReason(err) != "schema_violation" {
+		t.Errorf("got reason %s, wanted schema_violation", RejectReason(err))
+	}
+}
+
+func TestSchemaValidatorVersions(t *testing.T) {
+	fsys := fstest.MapFS{}
+	err := fs.WalkDir(embeddedSchemas, "schemas/v1", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, _ := embeddedSchemas.ReadFile(p)
+		fsys["v1/"+path.Base(p)] = &fstest.MapFile{Data: b}
+		// v2 firmware renames log message field to text
+		b2 := bytes.Replace(b, []byte(`"const": 1`), []byte(`"const": 2`), 1)
+		b2 = bytes.Replace(b2, []byte(`"log"`), []byte(`"text"`), -1)
+		fsys["v2/"+path.Base(p)] = &fstest.MapFile{Data: b2}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewSchemaValidatorFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version, err := v.Validate(TOPIC_GW_LOG, []byte(`{"gateway_id":"gw1","message":{"log":"a"}}`)); err != nil || version != 1 {
+		t.Errorf("v1 firmware: got version %d err %v", version, err)
+	}
+	if version, err := v.Validate(TOPIC_GW_LOG, []byte(`{"gateway_id":"gw1","protocol_version":2,"message":{"text":"a"}}`)); err != nil || version != 2 {
+		t.Errorf("v2 firmware: got version %d err %v", version, err)
+	}
+	if _, err := v.Validate(TOPIC_GW_LOG, []byte(`{"gateway_id":"gw1","protocol_version":2,"message":{"log":"a"}}`)); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("v1 message tagged v2: got %v, wanted %v", err, ErrSchemaViolation)
+	}
+	if stats := v.Stats(); len(stats.Versions) != 2 {
+		t.Errorf("got versions %v, wanted [1 2]", stats.Versions)
+	}
+
+	delete(fsys, "v2/log.json")
+	if _, err := NewSchemaValidatorFS(fsys); err == nil {
+		t.Errorf("missing topic schema: got nil error")
+	}
+	if _, err := NewSchemaValidatorFS(fstest.MapFS{"v2/log.json": fsys["v1/log.json"]}); err == nil {
+		t.Errorf("missing v1: got nil error")
+	}
+}
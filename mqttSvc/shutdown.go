@@ -0,0 +1,28 @@
+package mqttSvc
+
+import (
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+)
+
+// Disconnect stops gateway messages from arriving, lets ingestion handle the
+// queued ones while client can still publish replies, then disconnects
+func Disconnect(client mqtt.Client, ing *Ingestion, quiesce time.Duration) {
+	if client.IsConnectionOpen() {
+		filters := []string{}
+		for topic := range gatewayTopics {
+			filters = append(filters, ing.Topics.Subscriptions(topic)...)
+		}
+		t := client.Unsubscribe(filters...)
+		if !t.WaitTimeout(quiesce) {
+			logger.LogWithoutFields(logger.MQTT, logger.WarnLevel, "Unsubscribe timed out")
+		} else if err := HandleMqttErr(t); err == nil {
+			logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-INFO] Unsubscribed from %d topics", len(filters))
+		}
+	}
+	ing.Stop()
+	client.Disconnect(uint(quiesce.Milliseconds()))
+	logger.LogWithoutFields(logger.MQTT, logger.InfoLevel, "Disconnected")
+}
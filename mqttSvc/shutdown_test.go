@@ -0,0 +1,76 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type doneToken struct {
+	MockToken
+}
+
+func (t *doneToken) WaitTimeout(td time.Duration) bool { return true }
+func (t *doneToken) Error() error                      { return nil }
+
+// shutdownClient records calls Disconnect makes, other methods are not used
+type shutdownClient struct {
+	mqtt.Client
+	mu           sync.Mutex
+	calls        []string
+	unsubscribed []string
+}
+
+func (c *shutdownClient) record(call string) {
+	c.mu.Lock()
+	c.calls = append(c.calls, call)
+	c.mu.Unlock()
+}
+
+func (c *shutdownClient) IsConnectionOpen() bool { return true }
+
+func (c *shutdownClient) Unsubscribe(topics ...string) mqtt.Token {
+	c.record("unsubscribe")
+	c.unsubscribed = topics
+	return &doneToken{}
+}
+
+func (c *shutdownClient) Disconnect(quiesce uint) {
+	c.record("disconnect")
+}
+
+func TestDisconnectDrainsIngestionBeforeDisconnecting(t *testing.T) {
+	client := &shutdownClient{}
+	in := newTestIngestion(IngestionOptions{Workers: 1, QueueSize: 10, EnqueueTimeout: time.Second})
+	in.Start()
+	in.Enqueue(nil, gwMessage("gw01", 0), func(c mqtt.Client, msg *GatewayMessage) error {
+		time.Sleep(50 * time.Millisecond)
+		client.record("handled")
+		return nil
+	})
+
+	Disconnect(client, in, time.Second)
+
+	wanted := []string{"unsubscribe", "handled", "disconnect"}
+	if len(client.calls) != len(wanted) {
+		t.Fatalf("got calls %v, wanted %v", client.calls, wanted)
+	}
+	for i := range wanted {
+		if client.calls[i] != wanted[i] {
+			t.Fatalf("got calls %v, wanted %v", client.calls, wanted)
+		}
+	}
+	sort.Strings(client.unsubscribed)
+	if len(client.unsubscribed) != len(gatewayTopics) || client.unsubscribed[0] != TOPIC_GW_BOOTUP {
+		t.Errorf("got unsubscribed %v, wanted every gateway topic", client.unsubscribed)
+	}
+	if in.Enqueue(nil, gwMessage("gw01", 1), func(c mqtt.Client, msg *GatewayMessage) error { return nil }) {
+		t.Errorf("ingestion accepted message after disconnect")
+	}
+}
@@ -0,0 +1,237 @@
+package mqttSvc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Tag memory layout, in characters: type(1) group(2) id(10) random(3)
+const (
+	TAG_MEM_LEN    int = 16
+	TAG_GROUP_LEN  int = 2
+	TAG_ID_LEN     int = 10
+	TAG_RANDOM_LEN int = 3
+)
+
+// Encodings gateways send tag memory in
+const (
+	TAG_ENCODING_AUTO  string = "auto" // 16 chars is ASCII, 32 hex chars is hex
+	TAG_ENCODING_ASCII string = "ascii"
+	TAG_ENCODING_HEX   string = "hex"
+)
+
+// Kinds of access a tag type prefix is recorded as
+const (
+	TAG_KIND_USER    string = "user"
+	TAG_KIND_PACKAGE string = "package"
+)
+
+// Default type prefixes, more can be configured e.g. "U:user,P:package,V:user"
+const DEFAULT_TAG_TYPES string = "U:user,P:package"
+
+var (
+	ErrTagTooShort    = errors.New("tag memory too short")
+	ErrTagEncoding    = errors.New("tag memory encoding invalid")
+	ErrTagCharset     = errors.New("tag field has invalid characters")
+	ErrTagUnknownType = errors.New("tag type unknown")
+)
+
+// TagDecodeError tells which field of which memory failed to decode
+type TagDecodeError struct {
+	Err   error
+	Field string
+	Mem   string
+}
+
+func (e *TagDecodeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %q", e.Err.Error(), e.Mem)
+	}
+	return fmt.Sprintf("%s: %s of %q", e.Err.Error(), e.Field, e.Mem)
+}
+
+func (e *TagDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TagErrorReason maps decode error to short reason kept with the read
+func TagErrorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrTagTooShort):
+		return "too_short"
+	case errors.Is(err, ErrTagEncoding):
+		return "bad_encoding"
+	case errors.Is(err, ErrTagCharset):
+		return "bad_charset"
+	case errors.Is(err, ErrTagUnknownType):
+		return "unknown_type"
+	}
+	return "unknown"
+}
+
+type TagMemory struct {
+	Type   string
+	Kind   string
+	Group  string
+	ID     string
+	Random string
+}
+
+// TagDecoder validates tag memory and splits it into fields. It never panics
+// on malformed memory and counts failures by reason.
+type TagDecoder struct {
+	encoding string
+	kinds    map[byte]string
+
+	mu       sync.Mutex
+	decoded  uint64
+	failures map[string]uint64
+}
+
+type TagDecoderStats struct {
+	Encoding string            `json:"encoding"`
+	Types    map[string]string `json:"types"`
+	Decoded  uint64            `json:"decoded"`
+	Failures map[string]uint64 `json:"failures"`
+}
+
+// types is a comma separated list of prefix:kind, e.g. "U:user,P:package"
+func NewTagDecoder(encoding string, types string) (*TagDecoder, error) {
+	switch encoding {
+	case "":
+		encoding = TAG_ENCODING_AUTO
+	case TAG_ENCODING_AUTO, TAG_ENCODING_ASCII, TAG_ENCODING_HEX:
+	default:
+		return nil, fmt.Errorf("unsupported tag encoding %q", encoding)
+	}
+	if types == "" {
+		types = DEFAULT_TAG_TYPES
+	}
+	kinds := map[byte]string{}
+	for _, entry := range strings.Split(types, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 2 || len(parts[0]) != 1 || !isAlnum(parts[0][0]) {
+			return nil, fmt.Errorf("invalid tag type %q, want prefix:kind", entry)
+		}
+		switch parts[1] {
+		case TAG_KIND_USER, TAG_KIND_PACKAGE:
+		default:
+			return nil, fmt.Errorf("invalid tag kind %q, want %s or %s", parts[1], TAG_KIND_USER, TAG_KIND_PACKAGE)
+		}
+		kinds[parts[0][0]] = parts[1]
+	}
+	return &TagDecoder{
+		encoding: encoding,
+		kinds:    kinds,
+		failures: map[string]uint64{},
+	}, nil
+}
+
+func (d *TagDecoder) Decode(mem string) (TagMemory, error) {
+	tag, err := d.decode(mem)
+	d.mu.Lock()
+	if err != nil {
+		d.failures[TagErrorReason(err)]++
+	} else {
+		d.decoded++
+	}
+	d.mu.Unlock()
+	return tag, err
+}
+
+func (d *TagDecoder) decode(mem string) (TagMemory, error) {
+	raw, err := d.text(mem)
+	if err != nil {
+		return TagMemory{}, err
+	}
+	if len(raw) < TAG_MEM_LEN {
+		return TagMemory{}, &TagDecodeError{Err: ErrTagTooShort, Mem: mem}
+	}
+	// Memory may be longer than the layout, the rest must be padding
+	for i := TAG_MEM_LEN; i < len(raw); i++ {
+		if raw[i] != 0 && raw[i] != ' ' {
+			return TagMemory{}, &TagDecodeError{Err: ErrTagCharset, Field: "padding", Mem: mem}
+		}
+	}
+
+	kind, ok := d.kinds[raw[0]]
+	if !ok {
+		return TagMemory{}, &TagDecodeError{Err: ErrTagUnknownType, Field: "type", Mem: mem}
+	}
+	tag := TagMemory{
+		Type:   raw[0:1],
+		Kind:   kind,
+		Group:  raw[1 : 1+TAG_GROUP_LEN],
+		ID:     raw[1+TAG_GROUP_LEN : 1+TAG_GROUP_LEN+TAG_ID_LEN],
+		Random: raw[1+TAG_GROUP_LEN+TAG_ID_LEN : TAG_MEM_LEN],
+	}
+	for _, f := range []struct{ name, value string }{
+		{"group", tag.Group}, {"id", tag.ID}, {"random", tag.Random},
+	} {
+		for i := 0; i < len(f.value); i++ {
+			if !isAlnum(f.value[i]) {
+				return TagMemory{}, &TagDecodeError{Err: ErrTagCharset, Field: f.name, Mem: mem}
+			}
+		}
+	}
+	return tag, nil
+}
+
+// text turns memory into its ASCII form according to encoding
+func (d *TagDecoder) text(mem string) (string, error) {
+	encoding := d.encoding
+	if encoding == TAG_ENCODING_AUTO {
+		encoding = TAG_ENCODING_ASCII
+		if len(mem) >= 2*TAG_MEM_LEN && isHex(mem) {
+			encoding = TAG_ENCODING_HEX
+		}
+	}
+	if encoding == TAG_ENCODING_ASCII {
+		return mem, nil
+	}
+	b, err := hex.DecodeString(mem)
+	if err != nil {
+		return "", &TagDecodeError{Err: ErrTagEncoding, Mem: mem}
+	}
+	return string(b), nil
+}
+
+func (d *TagDecoder) Stats() TagDecoderStats {
+	types := make(map[string]string, len(d.kinds))
+	for prefix, kind := range d.kinds {
+		types[string(prefix)] = kind
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	failures := make(map[string]uint64, len(d.failures))
+	for reason, cnt := range d.failures {
+		failures[reason] = cnt
+	}
+	return TagDecoderStats{
+		Encoding: d.encoding,
+		Types:    types,
+		Decoded:  d.decoded,
+		Failures: failures,
+	}
+}
+
+func isAlnum(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+func isHex(s string) bool {
+	if len(s)%2 != 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !((c >= '0' && c <= '9') || (c >= 'A' && c <= 'F') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
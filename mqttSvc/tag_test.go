@@ -0,0 +1,72 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestTagDecoderDecode(t *testing.T) {
+	d, err := NewTagDecoder(TAG_ENCODING_AUTO, "U:user,P:package,V:user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		mem  string
+		want TagMemory
+		err  error
+	}{
+		{"user ascii", "U01ABCDE12345XYZ", TagMemory{"U", TAG_KIND_USER, "01", "ABCDE12345", "XYZ"}, nil},
+		{"package ascii", "P020000000042R01", TagMemory{"P", TAG_KIND_PACKAGE, "02", "0000000042", "R01"}, nil},
+		{"configured prefix", "V01ABCDE12345XYZ", TagMemory{"V", TAG_KIND_USER, "01", "ABCDE12345", "XYZ"}, nil},
+		{"hex", hex.EncodeToString([]byte("U01ABCDE12345XYZ")), TagMemory{"U", TAG_KIND_USER, "01", "ABCDE12345", "XYZ"}, nil},
+		{"padded", "U01ABCDE12345XYZ\x00\x00", TagMemory{"U", TAG_KIND_USER, "01", "ABCDE12345", "XYZ"}, nil},
+		{"empty", "", TagMemory{}, ErrTagTooShort},
+		{"short", "U01ABC", TagMemory{}, ErrTagTooShort},
+		{"unknown type", "X01ABCDE12345XYZ", TagMemory{}, ErrTagUnknownType},
+		{"bad group", "U0-ABCDE12345XYZ", TagMemory{}, ErrTagCharset},
+		{"bad id", "U01ABC E12345XYZ", TagMemory{}, ErrTagCharset},
+		{"bad random", "U01ABCDE12345X\x00Z", TagMemory{}, ErrTagCharset},
+		{"garbage after layout", "U01ABCDE12345XYZ!!", TagMemory{}, ErrTagCharset},
+	}
+	for _, tt := range tests {
+		got, err := d.Decode(tt.mem)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got err %v, wanted %v", tt.name, err, tt.err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, wanted %+v", tt.name, got, tt.want)
+		}
+	}
+
+	stats := d.Stats()
+	if stats.Decoded != 5 || stats.Failures["too_short"] != 2 || stats.Failures["bad_charset"] != 4 || stats.Failures["unknown_type"] != 1 {
+		t.Errorf("got %+v, wanted 5 decoded, 2 too_short, 4 bad_charset, 1 unknown_type", stats)
+	}
+}
+
+func TestTagDecoderHexEncoding(t *testing.T) {
+	d, _ := NewTagDecoder(TAG_ENCODING_HEX, "")
+	if _, err := d.Decode("U01ABCDE12345XYZ"); !errors.Is(err, ErrTagEncoding) {
+		t.Errorf("ascii memory in hex mode: got %v, wanted %v", err, ErrTagEncoding)
+	}
+	var decodeErr *TagDecodeError
+	if _, err := d.Decode("5530"); !errors.As(err, &decodeErr) || decodeErr.Mem != "5530" {
+		t.Errorf("got %v, wanted TagDecodeError of memory 5530", err)
+	}
+}
+
+func TestNewTagDecoderRejectsBadConfig(t *testing.T) {
+	if _, err := NewTagDecoder("base64", ""); err == nil {
+		t.Errorf("unsupported encoding: got nil error")
+	}
+	for _, types := range []string{"U", "UU:user", "U:visitor", "-:user"} {
+		if _, err := NewTagDecoder(TAG_ENCODING_AUTO, types); err == nil {
+			t.Errorf("types %q: got nil error", types)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package mqttSvc
 
+import (
+	"fmt"
+	"strings"
+)
+
 const (
 	TOPIC_GW_LOG_C           string = "uams/gateway/log/create"
 	TOPIC_GW_DOORLOCK_STATUS string = "uams/gateway/doorlock/status"
@@ -15,15 +20,21 @@ const (
 	TOPIC_GW_TAG               string = "uams/gateway/uhf/tag"
 	TOPIC_GW_LOG               string = "uams/gateway/log"
 	TOPIC_GW_GW_CONNECT_STATE  string = "uams/gateway/gateway/update"
+	TOPIC_GW_UPGRADE           string = "uams/gateway/upgrade"
+	TOPIC_GW_UHF_CONFIG        string = "uams/gateway/uhf/config"
 
 	TOPIC_SV_DOORLOCK_C   string = "uams/server/uhf/create"
 	TOPIC_SV_UHF_U        string = "uams/server/uhf/update"
 	TOPIC_SV_UHF_D        string = "uams/server/uhf/delete"
+	TOPIC_SV_UHF_BATCH    string = "uams/server/uhf/batch"
+	TOPIC_SV_UHF_CONFIG   string = "uams/server/uhf/config"
 	TOPIC_SV_DOORLOCK_CMD string = "server/doorlock/command"
 
 	TOPIC_SV_GATEWAY_U string = "uams/server/gateway/update"
 	TOPIC_SV_GATEWAY_D string = "uams/server/gateway/delete"
 
+	TOPIC_SV_GATEWAY_UPGRADE string = "uams/server/gateway/upgrade"
+
 	TOPIC_SV_SCHEDULER_C      string = "uams/server/register/create"
 	TOPIC_SV_SCHEDULER_U      string = "uams/server/register/update"
 	TOPIC_SV_SCHEDULER_D      string = "uams/server/register/delete"
@@ -40,3 +51,170 @@ const (
 	TOPIC_SV_SYSTEM_U string = "uams/server/system/update"
 	TOPIC_SV_SYNC     string = "uams/server/sync"
 )
+
+const (
+	DEFAULT_TOPIC_PREFIX string = "uams"
+	// Site name subscribing to gateways of every site
+	TOPIC_ALL_SITES string = "+"
+
+	topicBase       string = "uams/"
+	topicGatewayDir string = "gateway"
+	topicServerDir  string = "server"
+)
+
+// Topics maps the topics above to topics on broker. Without sites the legacy
+// layout {prefix}/gateway/uhf/tag is kept. With sites, topics carry site and
+// gateway ID: {prefix}/{site}/gateway/{gateway_id}/uhf/tag for gateway
+// messages and {prefix}/{site}/server/{gateway_id}/uhf/update for commands.
+type Topics struct {
+	prefix string
+	sites  []string
+}
+
+// Topics gateway publishes on, topics parsed to anything else are rejected
+var gatewayTopics = map[string]bool{
+	TOPIC_GW_BOOTUP:            true,
+	TOPIC_GW_UHF_CONNECT_STATE: true,
+	TOPIC_GW_UHF_SCAN:          true,
+	TOPIC_GW_SHUTDOWN:          true,
+	TOPIC_GW_LASTWILL:          true,
+	TOPIC_GW_TAG:               true,
+	TOPIC_GW_LOG:               true,
+	TOPIC_GW_GW_CONNECT_STATE:  true,
+	TOPIC_GW_UPGRADE:           true,
+	TOPIC_GW_UHF_CONFIG:        true,
+}
+
+// GatewayTopic is a gateway message topic parsed back to its topic constant
+type GatewayTopic struct {
+	Topic     string // one of TOPIC_GW_*
+	Site      string // empty without sites
+	GatewayID string // empty without sites
+}
+
+func NewTopics(prefix string, sites []string) (*Topics, error) {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		prefix = DEFAULT_TOPIC_PREFIX
+	}
+	if strings.ContainsAny(prefix, "+#") {
+		return nil, fmt.Errorf("topic prefix %q must not contain wildcards", prefix)
+	}
+	t := &Topics{prefix: prefix}
+	for _, site := range sites {
+		site = strings.TrimSpace(site)
+		if site == "" {
+			continue
+		}
+		if strings.ContainsAny(site, "/#") || (site != TOPIC_ALL_SITES && strings.Contains(site, "+")) {
+			return nil, fmt.Errorf("invalid site %q", site)
+		}
+		t.sites = append(t.sites, site)
+	}
+	return t, nil
+}
+
+func (t *Topics) Prefix() string {
+	return t.prefix
+}
+
+// PerSite tells whether topics carry site and gateway ID
+func (t *Topics) PerSite() bool {
+	return len(t.sites) > 0
+}
+
+func (t *Topics) Sites() []string {
+	return t.sites
+}
+
+func (t *Topics) servesSite(site string) bool {
+	for _, s := range t.sites {
+		if s == site || s == TOPIC_ALL_SITES {
+			return true
+		}
+	}
+	return false
+}
+
+// splitTopic returns direction and path of a topic constant, uams/server/uhf/update
+// gives server and uhf/update. ok is false for topics outside uams namespace.
+func splitTopic(topic string) (dir string, path string, ok bool) {
+	if !strings.HasPrefix(topic, topicBase) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(topic, topicBase), "/", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// Subscriptions returns topic filters to subscribe for a TOPIC_GW_* topic,
+// one per configured site
+func (t *Topics) Subscriptions(topic string) []string {
+	dir, path, ok := splitTopic(topic)
+	if !ok || dir != topicGatewayDir {
+		return []string{topic}
+	}
+	if !t.PerSite() {
+		return []string{t.prefix + "/" + dir + "/" + path}
+	}
+	filters := []string{}
+	for _, site := range t.sites {
+		filters = append(filters, t.prefix+"/"+site+"/"+dir+"/+/"+path)
+	}
+	return filters
+}
+
+// Parse maps topic a gateway message was received on back to its TOPIC_GW_* topic
+func (t *Topics) Parse(name string) (GatewayTopic, bool) {
+	if !strings.HasPrefix(name, t.prefix+"/") {
+		return GatewayTopic{}, false
+	}
+	rest := strings.TrimPrefix(name, t.prefix+"/")
+	if !t.PerSite() {
+		topic := topicBase + rest
+		return GatewayTopic{Topic: topic}, gatewayTopics[topic]
+	}
+	parts := strings.SplitN(rest, "/", 4)
+	if len(parts) != 4 || parts[1] != topicGatewayDir || parts[0] == "" || parts[2] == "" || !t.servesSite(parts[0]) {
+		return GatewayTopic{}, false
+	}
+	gwTopic := GatewayTopic{
+		Topic:     topicBase + topicGatewayDir + "/" + parts[3],
+		Site:      parts[0],
+		GatewayID: parts[2],
+	}
+	return gwTopic, gatewayTopics[gwTopic.Topic]
+}
+
+// Gateway returns topic gateway gwId of site publishes a TOPIC_GW_* topic on
+func (t *Topics) Gateway(topic string, site string, gwId string) (string, error) {
+	return t.device(topic, topicGatewayDir, site, gwId)
+}
+
+// Server returns topic a TOPIC_SV_* command for gateway gwId of site is
+// published on. Topics outside uams namespace are returned unchanged.
+func (t *Topics) Server(topic string, site string, gwId string) (string, error) {
+	return t.device(topic, topicServerDir, site, gwId)
+}
+
+func (t *Topics) device(topic string, wantDir string, site string, gwId string) (string, error) {
+	dir, path, ok := splitTopic(topic)
+	if !ok {
+		return topic, nil
+	}
+	if dir != wantDir {
+		return "", fmt.Errorf("topic %s is not a %s topic", topic, wantDir)
+	}
+	if !t.PerSite() {
+		return t.prefix + "/" + dir + "/" + path, nil
+	}
+	if site == "" || site == TOPIC_ALL_SITES || !t.servesSite(site) {
+		return "", fmt.Errorf("gateway %s has no site served by this server", gwId)
+	}
+	if gwId == "" || strings.ContainsAny(gwId, "/+#") {
+		return "", fmt.Errorf("gateway ID %q can not be used in topic", gwId)
+	}
+	return t.prefix + "/" + site + "/" + dir + "/" + gwId + "/" + path, nil
+}
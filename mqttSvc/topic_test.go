@@ -0,0 +1,125 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestTopicsWithoutSites(t *testing.T) {
+	topics, err := NewTopics("acme/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := topics.Subscriptions(TOPIC_GW_TAG); !reflect.DeepEqual(got, []string{"acme/gateway/uhf/tag"}) {
+		t.Errorf("got subscriptions %v", got)
+	}
+	if got, ok := topics.Parse("acme/gateway/uhf/tag"); !ok || got != (GatewayTopic{Topic: TOPIC_GW_TAG}) {
+		t.Errorf("got %+v %v, wanted %s", got, ok, TOPIC_GW_TAG)
+	}
+	if _, ok := topics.Parse("uams/gateway/uhf/tag"); ok {
+		t.Errorf("topic of another prefix parsed")
+	}
+	if got, err := topics.Server(TOPIC_SV_UHF_U, "", "gw1"); err != nil || got != "acme/server/uhf/update" {
+		t.Errorf("got %s %v, wanted acme/server/uhf/update", got, err)
+	}
+	if got, _ := topics.Server(TOPIC_SV_DOORLOCK_CMD, "", "gw1"); got != TOPIC_SV_DOORLOCK_CMD {
+		t.Errorf("topic outside namespace: got %s", got)
+	}
+	if _, err := topics.Server(TOPIC_GW_TAG, "", "gw1"); err == nil {
+		t.Errorf("gateway topic as server topic: got nil error")
+	}
+}
+
+func TestTopicsPerSite(t *testing.T) {
+	topics, err := NewTopics("", []string{"hcm", " hn ", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"uams/hcm/gateway/+/uhf/tag", "uams/hn/gateway/+/uhf/tag"}
+	if got := topics.Subscriptions(TOPIC_GW_TAG); !reflect.DeepEqual(got, want) {
+		t.Errorf("got subscriptions %v, wanted %v", got, want)
+	}
+
+	got, ok := topics.Parse("uams/hn/gateway/gw1/uhf/tag")
+	if !ok || got != (GatewayTopic{Topic: TOPIC_GW_TAG, Site: "hn", GatewayID: "gw1"}) {
+		t.Errorf("got %+v %v", got, ok)
+	}
+	for _, name := range []string{"uams/dn/gateway/gw1/uhf/tag", "uams/hn/gateway/uhf/tag", "uams/hn/server/gw1/uhf/update", "uams/gateway/uhf/tag"} {
+		if _, ok := topics.Parse(name); ok {
+			t.Errorf("%s parsed", name)
+		}
+	}
+
+	if name, err := topics.Server(TOPIC_SV_SYNC, "hcm", "gw1"); err != nil || name != "uams/hcm/server/gw1/sync" {
+		t.Errorf("got %s %v, wanted uams/hcm/server/gw1/sync", name, err)
+	}
+	if name, err := topics.Gateway(TOPIC_GW_BOOTUP, "hcm", "gw1"); err != nil || name != "uams/hcm/gateway/gw1/bootup" {
+		t.Errorf("got %s %v, wanted uams/hcm/gateway/gw1/bootup", name, err)
+	}
+	for _, site := range []string{"", "dn", TOPIC_ALL_SITES} {
+		if _, err := topics.Server(TOPIC_SV_SYNC, site, "gw1"); err == nil {
+			t.Errorf("site %q: got nil error", site)
+		}
+	}
+	if _, err := topics.Server(TOPIC_SV_SYNC, "hcm", "gw/1"); err == nil {
+		t.Errorf("gateway ID with slash: got nil error")
+	}
+}
+
+func TestTopicsAllSites(t *testing.T) {
+	topics, _ := NewTopics("uams", []string{TOPIC_ALL_SITES})
+	if got := topics.Subscriptions(TOPIC_GW_LOG); !reflect.DeepEqual(got, []string{"uams/+/gateway/+/log"}) {
+		t.Errorf("got subscriptions %v", got)
+	}
+	if got, ok := topics.Parse("uams/dn/gateway/gw1/log"); !ok || got.Site != "dn" {
+		t.Errorf("got %+v %v, wanted site dn", got, ok)
+	}
+	if _, err := topics.Server(TOPIC_SV_SYNC, "dn", "gw1"); err != nil {
+		t.Errorf("got %v, wanted nil error", err)
+	}
+}
+
+func TestNewTopicsRejectsBadConfig(t *testing.T) {
+	if _, err := NewTopics("uams/#", nil); err == nil {
+		t.Errorf("wildcard prefix: got nil error")
+	}
+	for _, site := range []string{"a/b", "a+", "#"} {
+		if _, err := NewTopics("uams", []string{site}); err == nil {
+			t.Errorf("site %q: got nil error", site)
+		}
+	}
+}
+
+func TestIngestionAttributesSite(t *testing.T) {
+	in := newTestIngestion(IngestionOptions{Workers: 1, QueueSize: 10})
+	in.Topics, _ = NewTopics("uams", []string{"hcm"})
+
+	var got *GatewayMessage
+	handler := func(c mqtt.Client, msg *GatewayMessage) error {
+		got = msg
+		return nil
+	}
+	msg := &storedMessage{topic: "uams/hcm/gateway/gw1/log", payload: []byte(`{"gateway_id":"gw1"}`)}
+	if err := in.handle(handler, nil, msg, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got.BaseTopic != TOPIC_GW_LOG || got.Site != "hcm" || got.GatewayID != "gw1" {
+		t.Errorf("got %+v, wanted log of gw1 in hcm", got)
+	}
+
+	spoofed := &storedMessage{topic: "uams/hcm/gateway/gw1/log", payload: []byte(`{"gateway_id":"gw2"}`)}
+	if err := in.handle(handler, nil, spoofed, time.Now()); !errors.Is(err, ErrInvalidTopic) {
+		t.Errorf("gateway_id differs from topic: got %v, wanted %v", err, ErrInvalidTopic)
+	}
+	legacy := &storedMessage{topic: TOPIC_GW_LOG, payload: []byte(`{"gateway_id":"gw1"}`)}
+	if err := in.handle(handler, nil, legacy, time.Now()); RejectReason(err) != "invalid_topic" {
+		t.Errorf("legacy topic: got %v, wanted invalid_topic", err)
+	}
+}
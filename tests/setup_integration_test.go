@@ -18,12 +18,17 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Admin key of .env.test, requests act as system tenant
+const testAdminApiKey = "test-admin-key"
+
 type TestRouter struct {
 	GinRouter *gin.Engine
 }
 
 var GlobalTestRouter = &TestRouter{}
 
+var cleanupApplication func()
+
 func TestMain(m *testing.M) {
 	setup()
 	code := m.Run()
@@ -38,23 +43,28 @@ func setup() {
 	_, filename, _, _ := runtime.Caller(0)
 	os.Chdir(path.Join(path.Dir(filename), ".."))
 	wd, _ := os.Getwd()
-	cc, _, err := initializers.InitApplication(fmt.Sprintf("%s/%s", wd, ".env.test"))
+	cc, cleanup, err := initializers.InitApplication(fmt.Sprintf("%s/%s", wd, ".env.test"))
 	if err != nil {
 		fmt.Printf("failed to create event: %s\n", err)
 		os.Exit(2)
 	}
 
+	cleanupApplication = cleanup
+
 	// setup router
 	router := handlers.SetupRouter(cc.HandlerOptions)
 	GlobalTestRouter.GinRouter = router
 }
 
 func shutdown() {
-
+	if cleanupApplication != nil {
+		cleanupApplication()
+	}
 }
 
 func DoRequest(r http.Handler, method, path string) *httptest.ResponseRecorder {
 	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Add("X-API-Key", testAdminApiKey)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -65,6 +75,7 @@ func DoRequestWithBody(r http.Handler, method, path string, body string) *httpte
 	req, _ := http.NewRequest(method, path, strings.NewReader(body))
 	w := httptest.NewRecorder()
 	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("X-API-Key", testAdminApiKey)
 	r.ServeHTTP(w, req)
 
 	return w
@@ -0,0 +1,176 @@
+// Package tracing carries correlation IDs and OpenTelemetry spans from HTTP
+// requests through models services into MQTT messages and back
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	CORRELATION_ID_HEADER string = "X-Correlation-ID"
+	// Incoming IDs longer than this are replaced by a new one
+	CORRELATION_ID_MAX_LENGTH int = 64
+
+	INSTRUMENTATION_NAME string = "github.com/ecoprohcm/DMS_BackendServer"
+
+	EXPORTER_NONE   string = "none"
+	EXPORTER_STDOUT string = "stdout"
+	EXPORTER_OTLP   string = "otlp"
+
+	// Span attribute of the correlation ID
+	CorrelationIDKey = attribute.Key("uams.correlation_id")
+)
+
+type correlationKey struct{}
+
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns correlation ID of ctx, empty when there is none
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+func NewCorrelationID() string {
+	return uuid.NewString()
+}
+
+// ValidCorrelationID reports whether an ID received from a client or gateway may be kept
+func ValidCorrelationID(id string) bool {
+	if id == "" || len(id) > CORRELATION_ID_MAX_LENGTH {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// EnsureCorrelationID keeps a valid id or generates a new one
+func EnsureCorrelationID(ctx context.Context, id string) context.Context {
+	if !ValidCorrelationID(id) {
+		id = NewCorrelationID()
+	}
+	return WithCorrelationID(ctx, id)
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(INSTRUMENTATION_NAME)
+}
+
+// Start starts a span tagged with correlation ID of ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	ctx, span := Tracer().Start(ctx, name, opts...)
+	if id := CorrelationID(ctx); id != "" {
+		span.SetAttributes(CorrelationIDKey.String(id))
+	}
+	return ctx, span
+}
+
+// TraceParent returns W3C traceparent of span in ctx, empty without a sampled span
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// WithTraceParent continues trace of a W3C traceparent received in a message
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+}
+
+// Fields returns correlation and trace IDs of ctx for log entries
+func Fields(ctx context.Context) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if ctx == nil {
+		return fields
+	}
+	if id := CorrelationID(ctx); id != "" {
+		fields["correlation_id"] = id
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+		fields["span_id"] = sc.SpanID().String()
+	}
+	return fields
+}
+
+type Options struct {
+	Exporter     string  // none, stdout or otlp
+	OtlpEndpoint string  // host:port of OTLP gRPC collector
+	SampleRatio  float64 // ratio of new traces sampled, traces of sampled parents always are
+	ServiceName  string
+	Version      string
+}
+
+// Provider owns the tracer provider installed by Init
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// Init installs W3C propagation and, unless exporter is none, a tracer
+// provider batching spans to stdout or an OTLP collector
+func Init(ctx context.Context, opts Options) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "", EXPORTER_NONE:
+		return &Provider{}, nil
+	case EXPORTER_STDOUT:
+		exporter, err = stdouttrace.New()
+	case EXPORTER_OTLP:
+		exporter, err = otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpoint(opts.OtlpEndpoint),
+			otlptracegrpc.WithInsecure())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %s, wanted %s, %s or %s",
+			opts.Exporter, EXPORTER_NONE, EXPORTER_STDOUT, EXPORTER_OTLP)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", opts.ServiceName),
+		attribute.String("service.version", opts.Version),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return &Provider{tp: tp}, nil
+}
+
+// Shutdown flushes spans still batched
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil || p.tp == nil {
+		return nil
+	}
+	return p.tp.Shutdown(ctx)
+}
@@ -0,0 +1,44 @@
+//go:build unit
+// +build unit
+
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestEnsureCorrelationID(t *testing.T) {
+	ctx := EnsureCorrelationID(context.Background(), "req-1")
+	if got := CorrelationID(ctx); got != "req-1" {
+		t.Errorf("got %s, wanted req-1 kept", got)
+	}
+	for _, id := range []string{"", "has space", strings.Repeat("a", CORRELATION_ID_MAX_LENGTH+1), "new\nline"} {
+		got := CorrelationID(EnsureCorrelationID(context.Background(), id))
+		if got == id || !ValidCorrelationID(got) {
+			t.Errorf("id %q: got %q, wanted a new valid ID", id, got)
+		}
+	}
+}
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	ctx, span := tp.Tracer("test").Start(context.Background(), "publish")
+	defer span.End()
+
+	traceParent := TraceParent(ctx)
+	if traceParent == "" {
+		t.Fatal("got no traceparent of sampled span")
+	}
+	fields := Fields(WithTraceParent(context.Background(), traceParent))
+	if fields["trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("got fields %v, wanted trace ID %s", fields, span.SpanContext().TraceID())
+	}
+	if TraceParent(context.Background()) != "" {
+		t.Errorf("got traceparent without a span")
+	}
+}
@@ -3,17 +3,21 @@ package utils
 
 import (
 	"errors"
-	"fmt"
 
 	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrRecordNotFound   = errors.New("can't find any record")
+	ErrNoRecordAffected = errors.New("no record affected")
+)
+
 // Error handler for ORM query
 func HandleQueryError(err error) error {
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		logger.LogWithoutFields(logger.SQLSERVER, logger.ErrorLevel, "Can't find any record", err.Error())
-		return fmt.Errorf("can't find any record")
+		return ErrRecordNotFound
 	}
 	logger.LogWithoutFields(logger.SQLSERVER, logger.ErrorLevel, err.Error())
 	return err
@@ -31,6 +35,6 @@ func ReturnBoolStateFromResult(result *gorm.DB) (bool, error) {
 		return true, nil
 	} else {
 		//logger.LogWithoutFields(logger.SQLSERVER, logger.ErrorLevel, "No record affected", err.Error())
-		return false, fmt.Errorf("no record affected")
+		return false, ErrNoRecordAffected
 	}
 }
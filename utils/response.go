@@ -1,14 +1,53 @@
 package utils
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
 
-// Http error response payload
+	"github.com/ecoprohcm/DMS_BackendServer/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+const PROBLEM_CONTENT_TYPE string = "application/problem+json"
+
+// Http error response payload, RFC 7807 problem details.
+// Code is stable and meant for clients, Detail is for humans and may change.
 type ErrorResponse struct {
-	StatusCode int
-	Msg        string
-	ErrorMsg   string
+	Type          string       `json:"type"`
+	Title         string       `json:"title"`
+	Status        int          `json:"status"`
+	Code          string       `json:"code"`
+	Detail        string       `json:"detail,omitempty"`
+	Instance      string       `json:"instance,omitempty"`
+	CorrelationID string       `json:"correlation_id,omitempty"`
+	Errors        []FieldError `json:"errors,omitempty"`
+}
+
+// Validation failure of a single request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func ResponseJson(c *gin.Context, statusCode int, data interface{}) {
 	c.IndentedJSON(statusCode, data)
 }
+
+// Write problem details as application/problem+json, title and instance
+// default to status text and request path
+func ResponseProblem(c *gin.Context, p *ErrorResponse) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	if p.CorrelationID == "" {
+		p.CorrelationID = tracing.CorrelationID(c.Request.Context())
+	}
+	c.Header("Content-Type", PROBLEM_CONTENT_TYPE)
+	c.IndentedJSON(p.Status, p)
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+var ErrUnsupportedTable = errors.New("file must be .csv or .xlsx")
+
+// Limits of an XLSX table. Row and cell references and compressed parts come
+// from the file, so a small upload must not make us allocate more than this.
+const (
+	MAX_TABLE_ROWS     int   = 10000
+	MAX_TABLE_COLUMNS  int   = 100
+	MAX_XLSX_PART_SIZE int64 = 16 << 20
+)
+
+// ReadTable returns rows of a CSV file, or of the first sheet of an XLSX
+// file, depending on extension of name. Cells are trimmed and trailing empty
+// rows are dropped.
+func ReadTable(r io.Reader, name string) ([][]string, error) {
+	var rows [][]string
+	var err error
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		rows, err = readCSV(r)
+	case ".xlsx":
+		rows, err = readXLSX(r)
+	default:
+		return nil, ErrUnsupportedTable
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		for j := range rows[i] {
+			rows[i][j] = strings.TrimSpace(rows[i][j])
+		}
+	}
+	for len(rows) > 0 && strings.Join(rows[len(rows)-1], "") == "" {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+func readCSV(r io.Reader) ([][]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read csv failed, err %s", err.Error())
+	}
+	// Excel saves UTF-8 CSV with a byte order mark
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+	}
+	return rows, nil
+}
+
+// Parts of SpreadsheetML readXLSX needs
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var sb strings.Builder
+	for _, r := range t.Runs {
+		sb.WriteString(r.T)
+	}
+	return sb.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Ref   int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSX(r io.Reader) ([][]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("read xlsx failed, err %s", err.Error())
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	workbook := xlsxWorkbook{}
+	if err := decodeXLSXPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("read xlsx failed, workbook has no sheet")
+	}
+	rels := xlsxRelationships{}
+	if err := decodeXLSXPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[0].RelID {
+			sheetPath = rel.Target
+		}
+	}
+	if strings.HasPrefix(sheetPath, "/") {
+		sheetPath = strings.TrimPrefix(sheetPath, "/")
+	} else {
+		sheetPath = path.Join("xl", sheetPath)
+	}
+
+	shared := xlsxSharedStrings{}
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXLSXPart(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+	sheet := xlsxSheet{}
+	if err := decodeXLSXPart(files, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		if row.Ref > MAX_TABLE_ROWS || len(rows) >= MAX_TABLE_ROWS {
+			return nil, fmt.Errorf("read xlsx failed, sheet has more than %d rows", MAX_TABLE_ROWS)
+		}
+		// Empty rows are left out of the sheet, keep row numbers as Excel shows them
+		for row.Ref > len(rows)+1 {
+			rows = append(rows, []string{})
+		}
+		cells := []string{}
+		for _, c := range row.Cells {
+			col := len(cells)
+			if c.Ref != "" {
+				col = xlsxColumn(c.Ref)
+			}
+			if col >= MAX_TABLE_COLUMNS {
+				return nil, fmt.Errorf("read xlsx failed, cell %s is beyond %d columns", c.Ref, MAX_TABLE_COLUMNS)
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			switch c.Type {
+			case "s":
+				var i int
+				if _, err := fmt.Sscan(c.Value, &i); err != nil || i < 0 || i >= len(shared.Items) {
+					return nil, fmt.Errorf("read xlsx failed, cell %s refers to unknown shared string", c.Ref)
+				}
+				cells[col] = shared.Items[i].String()
+			case "inlineStr":
+				cells[col] = c.Inline.String()
+			default:
+				cells[col] = c.Value
+			}
+		}
+		rows = append(rows, cells)
+	}
+	return rows, nil
+}
+
+func decodeXLSXPart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("read xlsx failed, %s is missing", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("read xlsx failed, err %s", err.Error())
+	}
+	defer rc.Close()
+	// size in zip header can lie, count what is actually inflated
+	lr := &io.LimitedReader{R: rc, N: MAX_XLSX_PART_SIZE + 1}
+	err = xml.NewDecoder(lr).Decode(v)
+	if lr.N <= 0 {
+		return fmt.Errorf("read xlsx failed, %s is larger than %d bytes", name, MAX_XLSX_PART_SIZE)
+	}
+	if err != nil {
+		return fmt.Errorf("read xlsx %s failed, err %s", name, err.Error())
+	}
+	return nil
+}
+
+// xlsxColumn returns zero based column of a cell reference like "AB12",
+// MAX_TABLE_COLUMNS for any column beyond
+func xlsxColumn(ref string) int {
+	col := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+		if col > MAX_TABLE_COLUMNS {
+			return MAX_TABLE_COLUMNS
+		}
+	}
+	return col - 1
+}
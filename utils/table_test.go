@@ -0,0 +1,89 @@
+//go:build unit
+// +build unit
+
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// xlsxFile builds a workbook whose first sheet has sheetData rows
+func xlsxFile(t *testing.T, rows string, shared ...string) []byte {
+	t.Helper()
+	sst := &strings.Builder{}
+	for _, s := range shared {
+		fmt.Fprintf(sst, `<si><t>%s</t></si>`, s)
+	}
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Import" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst>` + sst.String() + `</sst>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + rows + `</sheetData></worksheet>`,
+	}
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range parts {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadTableCSV(t *testing.T) {
+	rows, err := ReadTable(strings.NewReader("\ufefftype, name\narea,  Hall \n,\n"), "import.CSV")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0][0] != "type" || rows[1][1] != "Hall" {
+		t.Errorf("got %q, wanted header and Hall without empty row", rows)
+	}
+	if _, err := ReadTable(strings.NewReader(""), "import.txt"); err != ErrUnsupportedTable {
+		t.Errorf("got %v, wanted %v", err, ErrUnsupportedTable)
+	}
+}
+
+func TestReadTableXLSX(t *testing.T) {
+	content := xlsxFile(t, `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="inlineStr"><is><t>name</t></is></c></row>`+
+		`<row r="3"><c r="B3"><v>7</v></c></row>`, "type")
+	rows, err := ReadTable(bytes.NewReader(content), "import.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"type", "", "name"}, {}, {"", "7"}}
+	if fmt.Sprint(rows) != fmt.Sprint(want) {
+		t.Errorf("got %q, wanted %q", rows, want)
+	}
+}
+
+func TestReadTableXLSXLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"shared string", xlsxFile(t, `<row r="1"><c r="A1" t="s"><v>3</v></c></row>`), "unknown shared string"},
+		{"row ref", xlsxFile(t, `<row r="2000000000"><c r="A2000000000"><v>1</v></c></row>`), "rows"},
+		{"row count", xlsxFile(t, strings.Repeat(`<row><c><v>1</v></c></row>`, MAX_TABLE_ROWS+1)), "rows"},
+		{"column ref", xlsxFile(t, `<row r="1"><c r="XFD1"><v>1</v></c></row>`), "columns"},
+		{"long column ref", xlsxFile(t, `<row r="1"><c r="`+strings.Repeat("Z", 40)+`1"><v>1</v></c></row>`), "columns"},
+		// compresses to a few KB but inflates beyond the limit
+		{"zip bomb", xlsxFile(t, strings.Repeat(" ", int(MAX_XLSX_PART_SIZE))), "larger than"},
+	}
+	for _, tt := range tests {
+		_, err := ReadTable(bytes.NewReader(tt.content), "import.xlsx")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, wanted error about %s", tt.name, err, tt.want)
+		}
+	}
+}